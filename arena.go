@@ -0,0 +1,79 @@
+package hrtree
+
+// DefaultArenaSlab is the number of entries of the slabs of WithArena.
+const DefaultArenaSlab = 1 << 16
+
+// WithArena makes nodes take their entries from slabs of slab entries, or of
+// DefaultArenaSlab if slab isn't positive, instead of allocating them node by node. Trees
+// with millions of objects then hold a few large blocks rather than one per node, which
+// the garbage collector tracks and scans much faster. The entries of nodes emptied by
+// splits and merges are recycled by the next nodes, a slab is only freed once none of its
+// nodes is left, so trees shrinking a lot should be compacted to give memory back.
+func WithArena(slab int) Option {
+	return func(tree *HRtree) {
+		if slab <= 0 {
+			slab = DefaultArenaSlab
+		}
+		tree.arena = &entryArena{size: slab}
+	}
+}
+
+// entryArena hands out the entries of nodes from slabs. It is only used by mutations,
+// under the lock of the tree. A nil arena allocates each node on its own.
+type entryArena struct {
+	size int       // entries of a slab
+	node int       // entries of a node, the size of recycled lists
+	slab []entry   // part of the current slab not yet handed out
+	free [][]entry // released entries, cleared
+}
+
+// alloc returns an empty slice able to hold n entries.
+func (a *entryArena) alloc(n int) []entry {
+	if a == nil || n > a.size {
+		return make([]entry, 0, n)
+	}
+
+	if k := len(a.free) - 1; k >= 0 && cap(a.free[k]) >= n {
+		s := a.free[k]
+		a.free[k], a.free = nil, a.free[:k]
+		return s
+	}
+
+	if len(a.slab) < n {
+		a.slab = make([]entry, a.size)
+	}
+
+	s := a.slab[:0:n]
+	a.slab = a.slab[n:]
+	return s
+}
+
+// release recycles the entries of a list that is no longer used. They are cleared, so
+// that the slab doesn't keep removed objects alive. Lists smaller than nodes, such as
+// those of packed nodes, are left to the garbage collector.
+func (a *entryArena) release(l *entryList) {
+	if a == nil || cap(l.entries) < a.node {
+		return
+	}
+
+	s := l.entries[:cap(l.entries)]
+	clear(s)
+	a.free = append(a.free, s[:0])
+	l.entries = nil
+}
+
+// list returns an empty list of up to max entries.
+func (a *entryArena) list(max int) *entryList {
+	if a != nil {
+		a.node = max
+	}
+
+	return &entryList{entries: a.alloc(max), arena: a}
+}
+
+// allocNode returns an empty node whose entries come from the arena of the tree, if any.
+func (tree *HRtree) allocNode() *node {
+	n := newNode(tree.min, tree.max)
+	n.entries = tree.arena.list(tree.max)
+	return n
+}
@@ -0,0 +1,107 @@
+package hrtree
+
+import (
+	"math/rand"
+	"testing"
+)
+
+func TestArena(t *testing.T) {
+	for _, bulk := range []bool{false, true} {
+		r := rand.New(rand.NewSource(1))
+		rt, _ := NewTree(3, 8, 12, WithArena(64))
+		objs := make(map[Rectangle]bool)
+		var list []Rectangle
+
+		if bulk {
+			for i := 0; i < 500; i++ {
+				obj := rect(Point{uint64(3 * i), 5000}, Point{uint64(3*i + 1), 5001})
+				objs[obj] = true
+				list = append(list, obj)
+			}
+			rt.ReplaceAll(list)
+		}
+
+		for i := 0; i < 3000; i++ {
+			if len(list) == 0 || r.Intn(5) < 3 && i < 1500 {
+				x, y := uint64(3*i), uint64(r.Intn(1000))
+				obj := rect(Point{x, y}, Point{x + 1, y + uint64(r.Intn(10))})
+				rt.Insert(obj)
+				objs[obj] = true
+				list = append(list, obj)
+			} else {
+				j := r.Intn(len(list))
+				if ok, _ := rt.Delete(list[j]); !ok {
+					t.Fatalf("failed to delete %v", list[j])
+				}
+				delete(objs, list[j])
+				list[j] = list[len(list)-1]
+				list = list[:len(list)-1]
+			}
+
+			if i%100 == 0 {
+				checkTree(t, rt, objs)
+			}
+		}
+		checkTree(t, rt, objs)
+
+		// recycled entries don't keep removed objects alive.
+		for _, l := range rt.arena.free {
+			for _, e := range l[:cap(l)] {
+				if e.obj != nil || e.node != nil {
+					t.Fatalf("expected released entries to be cleared")
+				}
+			}
+		}
+	}
+}
+
+func TestArenaAlloc(t *testing.T) {
+	a := &entryArena{size: 10}
+	l1, l2 := a.list(4), a.list(4)
+	if &l1.entries[:1][0] == &l2.entries[:1][0] || cap(l1.entries) != 4 {
+		t.Fatalf("expected distinct lists of 4 entries")
+	}
+
+	// the slab can't hold a third list.
+	if a.list(4); len(a.slab) != 6 {
+		t.Errorf("expected a new slab, got %d entries left", len(a.slab))
+	}
+
+	l1.entries = append(l1.entries, entry{leaf: true})
+	s := l1.entries[:1]
+	a.release(l1)
+	if s[0].leaf {
+		t.Errorf("expected released entries to be cleared")
+	}
+
+	if l4 := a.list(4); &l4.entries[:1][0] != &s[0] {
+		t.Errorf("expected released entries to be recycled")
+	}
+
+	if l := (*entryArena)(nil).list(4); l.arena != nil || cap(l.entries) != 4 {
+		t.Errorf("expected a plain list without arena")
+	}
+}
+
+func TestArenaReplaceAll(t *testing.T) {
+	rt, _ := NewTree(3, 8, 12, WithArena(64))
+	var list []Rectangle
+	for i := 0; i < 2000; i++ {
+		list = append(list, rect(Point{uint64(3 * i), 5000}, Point{uint64(3*i + 1), 5001}))
+	}
+
+	// insertions keep allocating from the arena of the tree while the new one is built.
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 2000; i++ {
+			rt.Insert(rect(Point{uint64(i), 0}, Point{uint64(i + 1), 1}))
+		}
+	}()
+	rt.ReplaceAll(list)
+	<-done
+
+	if err := rt.CheckInvariants(); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
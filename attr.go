@@ -0,0 +1,15 @@
+package hrtree
+
+// Attributed is implemented by objects carrying a bitmask of application-defined
+// attributes (categories, flags...). Objects that don't implement it have no attributes.
+type Attributed interface {
+	Attributes() uint64
+}
+
+func attributesOf(obj Rectangle) uint64 {
+	if a, ok := obj.(Attributed); ok {
+		return a.Attributes()
+	}
+
+	return 0
+}
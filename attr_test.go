@@ -0,0 +1,70 @@
+package hrtree
+
+import (
+	"bytes"
+	"testing"
+)
+
+type attrRect struct {
+	rectangle
+	attrs uint64
+}
+
+func (r *attrRect) Attributes() uint64 {
+	return r.attrs
+}
+
+func attributed(lower, upper Point, attrs uint64) *attrRect {
+	return &attrRect{*rect(lower, upper), attrs}
+}
+
+func TestSearchIntersectMask(t *testing.T) {
+	rt, _ := NewTree(2, 4, 12)
+
+	for i := 0; i < 64; i++ {
+		rt.Insert(attributed(Point{uint64(i), 0}, Point{uint64(i + 1), 1}, 1<<uint(i%4)))
+	}
+	rt.Insert(rect(Point{5, 0}, Point{6, 1}))
+
+	bb := rect(Point{0, 0}, Point{100, 100})
+
+	if n := len(rt.SearchIntersect(bb, WithMask(1))); n != 16 {
+		t.Errorf("expected 16 results, got %d", n)
+	}
+
+	if n := len(rt.SearchIntersect(bb, WithMask(1|8))); n != 32 {
+		t.Errorf("expected 32 results, got %d", n)
+	}
+
+	if n := len(rt.SearchIntersect(bb, WithMask(16))); n != 0 {
+		t.Errorf("expected no results, got %d", n)
+	}
+
+	if n := len(rt.SearchIntersect(bb)); n != 65 {
+		t.Errorf("expected 65 results without a mask, got %d", n)
+	}
+
+	if rt.root.attrs != 15 {
+		t.Errorf("expected root attributes 15, got %d", rt.root.attrs)
+	}
+}
+
+func TestMaskWritePages(t *testing.T) {
+	rt, _ := NewTree(2, 4, 12)
+	for i := 0; i < 30; i++ {
+		rt.Insert(attributed(Point{uint64(i), 0}, Point{uint64(i + 1), 1}, uint64(i%2+1)))
+	}
+
+	var buf bytes.Buffer
+	rt.WritePages(&buf, MinPageSize)
+
+	loaded, err := ReadPages(bytes.NewReader(buf.Bytes()))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	bb := rect(Point{0, 0}, Point{100, 100})
+	if n := len(loaded.SearchIntersect(bb, WithMask(2))); n != 15 {
+		t.Errorf("expected 15 results, got %d", n)
+	}
+}
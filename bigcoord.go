@@ -0,0 +1,149 @@
+package hrtree
+
+import (
+	"errors"
+	"math/big"
+	"math/bits"
+)
+
+var ErrBigSpace = errors.New("The upper bounds of a space should not be less than its lower bounds.")
+
+// BigPoint is a point with arbitrary-precision integer coordinates.
+type BigPoint [Dim]*big.Int
+
+// BigSpace maps arbitrary-precision coordinates within its bounds to the unsigned
+// coordinates used by the tree, by offsetting them and dropping as many low bits as
+// needed to fit in 64 bits. Lower bounds are rounded down and upper bounds up, so the
+// tree finds every object a search window intersects, then BigRect compares exact
+// coordinates.
+type BigSpace struct {
+	min   BigPoint
+	shift uint
+	res   int
+}
+
+// NewBigSpace returns the space of points between min and max.
+func NewBigSpace(min, max BigPoint) (*BigSpace, error) {
+	s := &BigSpace{min: copyBigPoint(min)}
+
+	var extent [Dim]*big.Int
+	for i := range min {
+		extent[i] = new(big.Int).Sub(max[i], min[i])
+		if extent[i].Sign() < 0 {
+			return nil, ErrBigSpace
+		}
+
+		if n := extent[i].BitLen(); n > 64 && uint(n-64) > s.shift {
+			s.shift = uint(n - 64)
+		}
+	}
+
+	for i := range extent {
+		if n := bits.Len64(new(big.Int).Rsh(extent[i], s.shift).Uint64()); n > s.res {
+			s.res = n
+		}
+	}
+
+	if s.res == 0 {
+		s.res = 1
+	}
+
+	return s, nil
+}
+
+// Resolution returns the number of bits per dimension needed by trees storing objects of s.
+func (s *BigSpace) Resolution() int {
+	return s.res
+}
+
+// quantize maps coordinate v of dimension i, rounding up if up is set. Coordinates out of
+// the space are clamped to it.
+func (s *BigSpace) quantize(v *big.Int, i int, up bool) uint64 {
+	d := new(big.Int).Sub(v, s.min[i])
+	if d.Sign() < 0 {
+		return 0
+	}
+
+	if up && s.shift > 0 {
+		d.Add(d, new(big.Int).Sub(new(big.Int).Lsh(big.NewInt(1), s.shift), big.NewInt(1)))
+	}
+	d.Rsh(d, s.shift)
+
+	limit := uint64(1)<<uint(s.res) - 1
+	if !d.IsUint64() || d.Uint64() > limit {
+		return limit
+	}
+
+	return d.Uint64()
+}
+
+// Rect returns the rectangle of s with the given corners.
+func (s *BigSpace) Rect(min, max BigPoint) *BigRect {
+	r := &BigRect{Min: copyBigPoint(min), Max: copyBigPoint(max)}
+	for i := range min {
+		r.ll[i] = s.quantize(min[i], i, false)
+		r.ur[i] = s.quantize(max[i], i, true)
+	}
+
+	return r
+}
+
+// BigRect is a rectangle with arbitrary-precision integer coordinates, created with
+// BigSpace.Rect. It implements Rectangle through its quantized corners, which may be
+// shared by distinct rectangles: Intersects and Contains compare the exact ones, and
+// so does SearchIntersectBig. Delete can't tell such rectangles apart.
+type BigRect struct {
+	Min, Max BigPoint
+	ll, ur   Point
+}
+
+func (r *BigRect) LowerLeft() Point {
+	return r.ll
+}
+
+func (r *BigRect) UpperRight() Point {
+	return r.ur
+}
+
+// Intersects reports whether r and o share at least a point.
+func (r *BigRect) Intersects(o *BigRect) bool {
+	for i := range r.Min {
+		if r.Min[i].Cmp(o.Max[i]) > 0 || r.Max[i].Cmp(o.Min[i]) < 0 {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Contains reports whether o lies within r.
+func (r *BigRect) Contains(o *BigRect) bool {
+	for i := range r.Min {
+		if r.Min[i].Cmp(o.Min[i]) > 0 || r.Max[i].Cmp(o.Max[i]) < 0 {
+			return false
+		}
+	}
+
+	return true
+}
+
+// SearchIntersectBig returns the objects intersecting bb, comparing the exact coordinates
+// of BigRect objects and the quantized ones of others. The exact comparison filters the
+// objects during the search, before WithLimit and WithOffset count them.
+func (tree *HRtree) SearchIntersectBig(bb *BigRect, opts ...QueryOption) []Rectangle {
+	exact := WithFilter(func(obj Rectangle) bool {
+		r, ok := obj.(*BigRect)
+		return !ok || r.Intersects(bb)
+	})
+
+	return tree.SearchIntersect(bb, append(opts[:len(opts):len(opts)], exact)...)
+}
+
+func copyBigPoint(p BigPoint) BigPoint {
+	var c BigPoint
+	for i, v := range p {
+		c[i] = new(big.Int).Set(v)
+	}
+
+	return c
+}
@@ -0,0 +1,91 @@
+package hrtree
+
+import (
+	"errors"
+	"math/big"
+	"testing"
+)
+
+func bigInt(s string) *big.Int {
+	v, _ := new(big.Int).SetString(s, 10)
+	return v
+}
+
+// bigPoint returns the point of the given coordinates, zero in the dimensions left out.
+func bigPoint(coords ...*big.Int) BigPoint {
+	var p BigPoint
+	for i := range p {
+		p[i] = big.NewInt(0)
+	}
+	copy(p[:], coords)
+	return p
+}
+
+func TestBigSpace(t *testing.T) {
+	lo := bigPoint(bigInt("-100000000000000000000000"), big.NewInt(0))
+	hi := bigPoint(bigInt("100000000000000000000000"), big.NewInt(1000))
+
+	if _, err := NewBigSpace(hi, lo); !errors.Is(err, ErrBigSpace) {
+		t.Errorf("expected ErrBigSpace, got %v", err)
+	}
+
+	s, err := NewBigSpace(lo, hi)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if s.Resolution() != 64 {
+		t.Errorf("expected a resolution of 64 bits, got %d", s.Resolution())
+	}
+
+	small, _ := NewBigSpace(bigPoint(big.NewInt(0), big.NewInt(0)), bigPoint(big.NewInt(1000), big.NewInt(10)))
+	if small.Resolution() != 10 {
+		t.Errorf("expected a resolution of 10 bits, got %d", small.Resolution())
+	}
+
+	// the quantized corners enclose the exact ones.
+	r := s.Rect(bigPoint(bigInt("12345678901234567890123"), big.NewInt(3)), bigPoint(bigInt("12345678901234567890124"), big.NewInt(4)))
+	if r.LowerLeft()[0] >= r.UpperRight()[0] || r.LowerLeft()[1] != 3>>s.shift {
+		t.Errorf("unexpected quantized corners %v %v", r.LowerLeft(), r.UpperRight())
+	}
+}
+
+func TestSearchIntersectBig(t *testing.T) {
+	s, _ := NewBigSpace(
+		bigPoint(big.NewInt(0), big.NewInt(0)),
+		bigPoint(new(big.Int).Lsh(big.NewInt(1), 100), new(big.Int).Lsh(big.NewInt(1), 100)),
+	)
+	rt, _ := NewTree(2, 4, s.Resolution())
+
+	// adjacent rectangles sharing their quantized cells.
+	base := new(big.Int).Lsh(big.NewInt(1), 80)
+	at := func(x int64) *big.Int {
+		return new(big.Int).Add(base, big.NewInt(x))
+	}
+
+	var objs []*BigRect
+	for i := int64(0); i < 10; i++ {
+		obj := s.Rect(bigPoint(at(10*i), at(0)), bigPoint(at(10*i+5), at(5)))
+		objs = append(objs, obj)
+		rt.Insert(obj)
+	}
+
+	bb := s.Rect(bigPoint(at(16), at(1)), bigPoint(at(31), at(2)))
+	if n := len(rt.SearchIntersect(bb)); n != 10 {
+		t.Errorf("expected all objects to share quantized cells, got %d", n)
+	}
+
+	found := rt.SearchIntersectBig(bb)
+	if len(found) != 2 || found[0] != objs[2] && found[0] != objs[3] || found[1] != objs[2] && found[1] != objs[3] {
+		t.Errorf("expected objects 2 and 3, got %v", found)
+	}
+
+	// paging counts exact matches only.
+	if page := rt.SearchIntersectBig(bb, WithLimit(1), WithOffset(1)); len(page) != 1 || page[0] != found[1] {
+		t.Errorf("expected the second exact match, got %v", page)
+	}
+
+	if !bb.Intersects(objs[3]) || bb.Intersects(objs[1]) || bb.Contains(objs[2]) || !s.Rect(bigPoint(at(0), at(0)), bigPoint(at(100), at(5))).Contains(objs[9]) {
+		t.Errorf("unexpected exact predicates")
+	}
+}
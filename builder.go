@@ -0,0 +1,135 @@
+package hrtree
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// MaxResolution is the largest number of bits per dimension of the hilbert curve.
+const MaxResolution = 64
+
+var (
+	ErrMinTooSmall      = errors.New("Minimum number of node entries should be at least 2.")
+	ErrMaxTooSmall      = errors.New("Maximum number of node entries should be at least twice the minimum.")
+	ErrResolutionRange  = errors.New("Resolution should be between 1 and MaxResolution bits.")
+	ErrResolutionTooLow = errors.New("Resolution is too low for the coordinates of the objects.")
+)
+
+// BuildError lists all the problems found in the configuration of a TreeBuilder.
+type BuildError struct {
+	Errs []error
+}
+
+func (e *BuildError) Error() string {
+	msgs := make([]string, len(e.Errs))
+	for i, err := range e.Errs {
+		msgs[i] = err.Error()
+	}
+
+	return "TreeBuilder: " + strings.Join(msgs, "; ")
+}
+
+// Is reports whether any of the problems found matches target, see errors.Is.
+func (e *BuildError) Is(target error) bool {
+	for _, err := range e.Errs {
+		if errors.Is(err, target) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// TreeBuilder configures a tree step by step and creates it packed with the added
+// objects. Unlike NewTree, Build checks the configuration as a whole, reporting all
+// of its problems at once.
+type TreeBuilder struct {
+	min, max, bits int
+	opts           []Option
+	objs           []Rectangle
+}
+
+// NewTreeBuilder returns a builder of trees with the default number of entries per node.
+// Unless set with SetBits, the resolution is chosen as in NewTreeFrom.
+func NewTreeBuilder() *TreeBuilder {
+	return &TreeBuilder{min: DefaultMinNodeEntries, max: DefaultMaxNodeEntries}
+}
+
+// SetMin sets the minimum number of entries of a node.
+func (b *TreeBuilder) SetMin(min int) *TreeBuilder {
+	b.min = min
+	return b
+}
+
+// SetMax sets the maximum number of entries of a node.
+func (b *TreeBuilder) SetMax(max int) *TreeBuilder {
+	b.max = max
+	return b
+}
+
+// SetBits sets the number of bits per dimension of the hilbert curve.
+func (b *TreeBuilder) SetBits(bits int) *TreeBuilder {
+	b.bits = bits
+	return b
+}
+
+// SetOptions adds options enabling optional behaviour of the tree.
+func (b *TreeBuilder) SetOptions(opts ...Option) *TreeBuilder {
+	b.opts = append(b.opts, opts...)
+	return b
+}
+
+// AddAll adds objects to the tree to build.
+func (b *TreeBuilder) AddAll(objs ...Rectangle) *TreeBuilder {
+	b.objs = append(b.objs, objs...)
+	return b
+}
+
+// Validate returns a *BuildError listing the problems of the configuration, if any.
+func (b *TreeBuilder) Validate() error {
+	var errs []error
+	if b.min < 2 {
+		errs = append(errs, fmt.Errorf("min %d: %w", b.min, ErrMinTooSmall))
+	}
+
+	if b.max < 2*b.min {
+		errs = append(errs, fmt.Errorf("min %d, max %d: %w", b.min, b.max, ErrMaxTooSmall))
+	}
+
+	if b.bits != 0 {
+		if b.bits < 1 || b.bits > MaxResolution {
+			errs = append(errs, fmt.Errorf("resolution %d: %w", b.bits, ErrResolutionRange))
+		} else if res := resolutionOf(b.objs); res > b.bits {
+			errs = append(errs, fmt.Errorf("resolution %d, %d bits needed: %w", b.bits, res, ErrResolutionTooLow))
+		}
+	}
+
+	if len(errs) > 0 {
+		return &BuildError{errs}
+	}
+
+	return nil
+}
+
+// Build creates the tree, packed with the added objects (see Load).
+func (b *TreeBuilder) Build() (*HRtree, error) {
+	if err := b.Validate(); err != nil {
+		return nil, err
+	}
+
+	bits := b.bits
+	if bits == 0 {
+		bits = DefaultResolution
+		if n := resolutionOf(b.objs); n > bits {
+			bits = n
+		}
+	}
+
+	tree, err := NewTree(b.min, b.max, bits, b.opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	return tree, tree.loadAll(b.objs)
+}
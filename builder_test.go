@@ -0,0 +1,70 @@
+package hrtree
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestTreeBuilder(t *testing.T) {
+	rt, err := NewTreeBuilder().
+		SetMin(2).
+		SetMax(4).
+		SetBits(12).
+		SetOptions(WithLazyDelete()).
+		AddAll(rect(Point{1, 1}, Point{2, 2}), rect(Point{3, 3}, Point{4, 4})).
+		AddAll(rect(Point{5, 5}, Point{6, 6})).
+		Build()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if rt.min != 2 || rt.max != 4 || rt.bits != 12 || !rt.lazy {
+		t.Errorf("expected the configured tree, got %d-%d with %d bits", rt.min, rt.max, rt.bits)
+	}
+
+	if rt.Size() != 3 {
+		t.Errorf("expected 3 objects, got %d", rt.Size())
+	}
+
+	rt, err = NewTreeBuilder().AddAll(rect(Point{1, 1}, Point{1 << 33, 2})).Build()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if rt.bits != 34 {
+		t.Errorf("expected a resolution of 34 bits, got %d", rt.bits)
+	}
+}
+
+func TestTreeBuilderValidate(t *testing.T) {
+	b := NewTreeBuilder().SetMin(1).SetMax(1).SetBits(4).AddAll(rect(Point{1, 1}, Point{100, 100}))
+
+	_, err := b.Build()
+	be, ok := err.(*BuildError)
+	if !ok {
+		t.Fatalf("expected a *BuildError, got %v", err)
+	}
+
+	expected := []error{ErrMinTooSmall, ErrMaxTooSmall, ErrResolutionTooLow}
+	if len(be.Errs) != len(expected) {
+		t.Fatalf("expected %d problems, got %v", len(expected), be.Errs)
+	}
+
+	for i, err := range expected {
+		if !errors.Is(be.Errs[i], err) {
+			t.Errorf("expected %v, got %v", err, be.Errs[i])
+		}
+	}
+
+	if !errors.Is(err, ErrMinTooSmall) || errors.Is(err, ErrResolutionRange) {
+		t.Errorf("expected the error to match the problems found, got %v", err)
+	}
+
+	if err := NewTreeBuilder().SetBits(65).Validate(); !errors.Is(err, ErrResolutionRange) {
+		t.Errorf("expected %v, got %v", ErrResolutionRange, err)
+	}
+
+	if err := NewTreeBuilder().Validate(); err != nil {
+		t.Errorf("expected the defaults to be valid, got %v", err)
+	}
+}
@@ -0,0 +1,578 @@
+package hrtree
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"math/bits"
+	"sort"
+)
+
+var ErrNotSorted = errors.New("Objects should be sorted by hilbert value.")
+
+// DecodeFunc reads the next object of a stream from r. It returns io.EOF once the
+// stream is exhausted.
+type DecodeFunc func(r io.Reader) (Rectangle, error)
+
+// Load reads objects from r with decode until io.EOF, then rebuilds the tree packed with
+// them and the objects it already holds. Leaves are filled in hilbert order, then their
+// parents, up to the root.
+//
+// Objects are decoded straight into leaf entries which become the storage of the packed
+// leaves, so loading takes no more memory than the resulting tree, however long the
+// stream. Objects removed by lazy deletions are purged, Versioned objects are resolved as
+// if they had been inserted in stream order. If decode fails, the tree is left unchanged.
+func (tree *HRtree) Load(r io.Reader, decode DecodeFunc) (err error) {
+	if err := tree.lock(); err != nil {
+		return fmt.Errorf("Load: %w", err)
+	}
+	defer tree.unlock()
+	defer recoverCorrupted("Load", &err)
+
+	return tree.load(func() (Rectangle, error) {
+		return decode(r)
+	})
+}
+
+// NewTreeFrom creates a tree packed with objs (see Load) with the default number of
+// entries per node. Unless set with WithResolution, the resolution of the hilbert curve
+// is the smallest one covering all coordinates of objs, and at least DefaultResolution.
+func NewTreeFrom(objs []Rectangle, opts ...Option) (*HRtree, error) {
+	res := DefaultResolution
+	if n := resolutionOf(objs); n > res {
+		res = n
+	}
+
+	tree, err := NewTree(-1, -1, res, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	return tree, tree.loadAll(objs)
+}
+
+// NewTreeBulk creates a tree with the given node entries and resolution, packed with
+// objs (see Load). Objects are sorted by hilbert value and leaves filled bottom-up, which
+// is much faster than inserting them one by one and leaves every node nearly full.
+func NewTreeBulk(min, max, bits int, objs []Rectangle, opts ...Option) (*HRtree, error) {
+	tree, err := NewTree(min, max, bits, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	return tree, tree.loadAll(objs)
+}
+
+// NewTreeFromSorted is like NewTreeBulk for objs already sorted by hilbert value, such as
+// the objects of a tree read back in order. The sort is skipped and the tree built in a
+// single pass, which fails with ErrNotSorted on the first object out of order. Versioned
+// objects are taken as they come, objs should hold a single version of each ID.
+func NewTreeFromSorted(min, max, bits int, objs []Rectangle, opts ...Option) (*HRtree, error) {
+	tree, err := NewTree(min, max, bits, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	entries := make([]entry, len(objs))
+	for i, obj := range objs {
+		entries[i] = tree.newEntry(obj)
+		if i > 0 && entries[i-1].h.cmp(entries[i].h) > 0 {
+			return nil, fmt.Errorf("NewTreeFromSorted: object %d: %w", i, ErrNotSorted)
+		}
+
+		if v, ok := obj.(Versioned); ok {
+			if tree.versions == nil {
+				tree.versions = make(map[string]version)
+			}
+			tree.versions[v.ID()] = version{obj, v.Version()}
+		}
+	}
+
+	tree.pack(entries, 1)
+	tree.changed(len(objs))
+	tree.publish()
+
+	return tree, nil
+}
+
+// ReplaceAll replaces the contents of the tree with objs, packed as with Load. The new
+// tree is built without holding the lock of the tree, which keeps serving meanwhile, and
+// swapped in at once, so that searches see either the old or the new contents. Mutations
+// made during the build are discarded, and Versioned objects only compete with objs.
+// Errors are returned as with Insert.
+func (tree *HRtree) ReplaceAll(objs []Rectangle) (err error) {
+	// snapshots are rejected before anything is built.
+	if tree.frozen {
+		return fmt.Errorf("ReplaceAll: %w", ErrReadOnly)
+	}
+
+	// the fresh tree allocates its entries from an arena of its own, as the one of tree
+	// is only used with its lock held.
+	tree.mu.RLock()
+	fresh := tree.config()
+	tree.mu.RUnlock()
+
+	if err := fresh.replaceWith(objs); err != nil {
+		return err
+	}
+
+	if err := tree.lock(); err != nil {
+		return fmt.Errorf("ReplaceAll: %w", err)
+	}
+	defer tree.unlock()
+
+	tree.root = fresh.root
+	tree.arena = fresh.arena
+	tree.size = fresh.size
+	tree.versions = fresh.versions
+	tree.tombstones = 0
+	tree.deferred = 0
+	tree.changed(len(objs))
+
+	return nil
+}
+
+// replaceWith packs objs into the fresh tree built by ReplaceAll, which is not shared yet.
+func (tree *HRtree) replaceWith(objs []Rectangle) (err error) {
+	defer recoverCorrupted("ReplaceAll", &err)
+
+	return tree.loadAll(objs) // objs can't fail to decode
+}
+
+// InsertAll inserts objs as with Insert, in hilbert order, so that consecutive insertions
+// land in the same leaves rather than splitting nodes all over the tree. Versioned objects
+// are inserted first, in the order of objs. Errors are returned as with Insert; objects
+// rejected by WithDuplicatePolicy, including those equal to others of objs, are left out
+// and counted by an error wrapping ErrDuplicate once the others are inserted.
+func (tree *HRtree) InsertAll(objs []Rectangle) (err error) {
+	if err := tree.lock(); err != nil {
+		return fmt.Errorf("InsertAll: %w", err)
+	}
+	defer tree.unlock()
+	defer recoverCorrupted("InsertAll", &err)
+
+	entries := make([]entry, 0, len(objs))
+	for _, obj := range objs {
+		if _, ok := obj.(Versioned); ok {
+			tree.insertObject(obj)
+			continue
+		}
+		entries = append(entries, tree.newEntry(obj))
+	}
+	sortEntries(entries)
+
+	if tree.duplicates != DuplicateAllow {
+		rejected := 0
+		for _, e := range entries {
+			if tree.addObject(e.obj) != nil {
+				rejected++
+			}
+		}
+
+		if rejected > 0 {
+			return fmt.Errorf("InsertAll: %d objects: %w", rejected, ErrDuplicate)
+		}
+		return nil
+	}
+
+	for _, e := range entries {
+		tree.insert(e)
+	}
+	tree.size += len(entries)
+	tree.changed(len(entries))
+	return nil
+}
+
+// DeleteAll removes objs from the tree as with Delete and returns how many were removed.
+// Objects are removed from their leaves first, then the bounding-boxes of the affected
+// nodes are adjusted and their underflows handled, once for each node. Errors are
+// returned as with Insert.
+func (tree *HRtree) DeleteAll(objs []Rectangle) (removed int, err error) {
+	if err := tree.lock(); err != nil {
+		return 0, fmt.Errorf("DeleteAll: %w", err)
+	}
+	defer tree.unlock()
+	defer recoverCorrupted("DeleteAll", &err)
+
+	purged := 0
+	affected := make(map[*node]bool)
+	var touched []*node
+	for _, obj := range objs {
+		// lazy and versioned deletions only mark entries, leaving nodes in place.
+		if _, ok := obj.(Versioned); ok || tree.lazy {
+			if tree.deleteObject(obj) {
+				removed++
+			}
+			continue
+		}
+
+		// the entry removed is the one Delete would remove.
+		leaf, i := tree.lookup(obj)
+		if leaf == nil {
+			continue
+		}
+		leaf.entries.entries = append(leaf.entries.entries[:i], leaf.entries.entries[i+1:]...)
+		removed++
+		purged++
+
+		if !affected[leaf] {
+			affected[leaf] = true
+			touched = append(touched, leaf)
+		}
+	}
+
+	if purged > 0 {
+		tree.size -= purged
+		tree.changed(purged)
+		tree.settle(touched)
+	}
+
+	return removed, nil
+}
+
+// DeleteWhere removes the objects SearchIntersect(bb) would return that satisfy pred in a
+// single traversal, and returns how many were removed: windows are wrapped as set by
+// WithWrap, and objects refined as set by WithRefine. Underflows are handled as with
+// DeleteAll, errors are returned as with Insert.
+func (tree *HRtree) DeleteWhere(bb Rectangle, pred func(Rectangle) bool) (removed int, err error) {
+	if err := tree.lock(); err != nil {
+		return 0, fmt.Errorf("DeleteWhere: %w", err)
+	}
+	defer tree.unlock()
+	defer recoverCorrupted("DeleteWhere", &err)
+
+	return tree.deleteWhere(bb, pred), nil
+}
+
+// DeleteIntersecting removes the objects SearchIntersect(bb) would return in a single
+// traversal, and returns them. Underflows are handled as with DeleteAll, once for each
+// affected node rather than for each object, errors are returned as with Insert.
+func (tree *HRtree) DeleteIntersecting(bb Rectangle) (removed []Rectangle, err error) {
+	if err := tree.lock(); err != nil {
+		return nil, fmt.Errorf("DeleteIntersecting: %w", err)
+	}
+	defer tree.unlock()
+	defer recoverCorrupted("DeleteIntersecting", &err)
+
+	tree.deleteWhere(bb, func(obj Rectangle) bool {
+		removed = append(removed, obj)
+		return true
+	})
+
+	return removed, nil
+}
+
+// deleteWhere removes the objects found by a search of bb that satisfy pred, and returns
+// how many were removed. pred is called once for each object found.
+func (tree *HRtree) deleteWhere(bb Rectangle, pred func(Rectangle) bool) (removed int) {
+	windows := tree.windows(bb)
+	if windows == nil {
+		windows = []*rectangle{{bb.LowerLeft(), bb.UpperRight()}}
+	}
+
+	var touched []*node
+	var visit func(n *node)
+	visit = func(n *node) {
+		if !n.leaf {
+			for _, e := range n.getEntries() {
+				if intersectAny(e.getMBR(), windows) {
+					visit(e.node)
+				}
+			}
+			return
+		}
+
+		live := n.entries.entries[:0]
+		for i, e := range n.getEntries() {
+			if e.dead || !tree.foundIn(windows, e.obj) || !pred(e.obj) {
+				live = append(live, e)
+				continue
+			}
+			removed++
+
+			// versions are marked as removed and their deletion remembered, as with Delete.
+			v, versioned := e.obj.(Versioned)
+			if versioned {
+				tree.versions[v.ID()] = version{version: v.Version()}
+			}
+
+			if versioned || tree.lazy {
+				n.entries.entries[i].dead = true
+				n.touch()
+				live = append(live, n.entries.entries[i])
+				tree.tombstones++
+			}
+		}
+
+		if len(live) < n.entries.len() {
+			n.entries.entries = live
+			touched = append(touched, n)
+		}
+	}
+	visit(tree.root)
+
+	if removed > 0 {
+		tree.size -= removed
+		tree.changed(removed)
+		tree.settle(touched)
+	}
+
+	return removed
+}
+
+// settle adjusts the nodes above the given leaves, ordered left to right, after entries
+// were removed from them, then handles their underflows.
+func (tree *HRtree) settle(touched []*node) {
+	if len(touched) == 0 {
+		return
+	}
+	adjustAncestors(touched)
+
+	affected := make(map[*node]bool)
+	for _, leaf := range touched {
+		affected[leaf] = true
+	}
+
+	var leaves []*node
+	for _, leaf := range tree.root.leaves(nil) {
+		if !affected[leaf] {
+			continue
+		}
+
+		if tree.policy == UnderflowDefer && leaf.entries.len() > 0 {
+			if tree.isUnderflowing(leaf) {
+				tree.deferred++
+			}
+			continue
+		}
+		leaves = append(leaves, leaf)
+	}
+	tree.rebalance(leaves)
+}
+
+// Compact re-packs the tree from its leaves, so that nodes left sparse by deletions are
+// filled again. Objects removed by lazy deletions are purged on the way. Errors are
+// returned as with Insert.
+func (tree *HRtree) Compact() (err error) {
+	if err := tree.lock(); err != nil {
+		return fmt.Errorf("Compact: %w", err)
+	}
+	defer tree.unlock()
+	defer recoverCorrupted("Compact", &err)
+
+	// leaves already hold their entries in hilbert order.
+	tree.pack(tree.liveEntries(), 1)
+	tree.changed(0)
+
+	return nil
+}
+
+// Clear removes all objects from the tree, along with the versions it remembers, keeping
+// its options and hilbert curve so that it can be reused. Errors are returned as with
+// Insert.
+func (tree *HRtree) Clear() (err error) {
+	if err := tree.lock(); err != nil {
+		return fmt.Errorf("Clear: %w", err)
+	}
+	defer tree.unlock()
+	defer recoverCorrupted("Clear", &err)
+
+	tree.root = tree.allocNode()
+	tree.root.leaf = true
+	tree.size = 0
+	tree.versions = nil
+	tree.tombstones = 0
+	tree.deferred = 0
+	tree.changed(0)
+
+	return nil
+}
+
+// resolutionOf returns the number of bits needed by the largest coordinate of objs.
+func resolutionOf(objs []Rectangle) int {
+	res := 0
+	for _, obj := range objs {
+		for _, c := range obj.UpperRight() {
+			if n := bits.Len64(c); n > res {
+				res = n
+			}
+		}
+	}
+
+	return res
+}
+
+// loadAll packs objs with the objects of the tree, which is not locked.
+func (tree *HRtree) loadAll(objs []Rectangle) error {
+	i := 0
+	err := tree.load(func() (Rectangle, error) {
+		if i == len(objs) {
+			return nil, io.EOF
+		}
+		i++
+		return objs[i-1], nil
+	})
+	tree.publish()
+
+	return err
+}
+
+// load packs the objects returned by next until io.EOF with the objects of the tree.
+func (tree *HRtree) load(next func() (Rectangle, error)) error {
+	entries := tree.liveEntries()
+	versions := make(map[string]version)
+	p := tree.report(PhaseDecode, 0)
+	n := 0
+	for ; ; n++ {
+		obj, err := next()
+		if err == io.EOF {
+			break
+		}
+
+		if err != nil {
+			return fmt.Errorf("Load: record %d: %w", n, err)
+		}
+
+		if v, ok := obj.(Versioned); ok {
+			cur, ok := versions[v.ID()]
+			if !ok {
+				cur, ok = tree.versions[v.ID()]
+			}
+
+			if ok && cur.version >= v.Version() {
+				continue
+			}
+			versions[v.ID()] = version{obj, v.Version()}
+		}
+
+		entries = append(entries, tree.newEntry(obj))
+		p.step()
+	}
+	p.finish()
+
+	if len(versions) > 0 && tree.versions == nil {
+		tree.versions = make(map[string]version)
+	}
+
+	for id, v := range versions {
+		tree.versions[id] = v
+	}
+
+	// only the current version of each ID survives.
+	kept := entries[:0]
+	for _, e := range entries {
+		if v, ok := e.obj.(Versioned); ok && tree.versions[v.ID()].version != v.Version() {
+			continue
+		}
+		kept = append(kept, e)
+	}
+
+	sortEntries(kept)
+	tree.pack(kept, 1)
+	tree.changed(n)
+
+	return nil
+}
+
+// liveEntries returns the leaf entries of the tree that are not marked as removed.
+func (tree *HRtree) liveEntries() []entry {
+	entries := make([]entry, 0, tree.size)
+	for _, leaf := range tree.root.leaves(nil) {
+		for _, e := range leaf.getEntries() {
+			if !e.dead {
+				entries = append(entries, e)
+			}
+		}
+	}
+
+	return entries
+}
+
+// sortEntries sorts leaf entries by hilbert value.
+func sortEntries(entries []entry) {
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].h.cmp(entries[j].h) < 0
+	})
+}
+
+// pack replaces the contents of the tree with the given leaf entries, sorted by hilbert
+// value, spread evenly over as few nodes as possible on every level. The nodes of a level
+// are built by the given number of workers.
+func (tree *HRtree) pack(entries []entry, workers int) {
+	tree.size = len(entries)
+	tree.tombstones = 0
+	tree.deferred = 0
+
+	if len(entries) == 0 {
+		tree.root = tree.allocNode()
+		tree.root.leaf = true
+		return
+	}
+
+	total := 0
+	for n := len(entries); total == 0 || n > 1; {
+		n = (n + tree.max - 1) / tree.max
+		total += n
+	}
+	p := tree.report(PhasePack, total)
+
+	level := tree.packLevel(entries, true, workers, p)
+	for len(level) > 1 {
+		parents := make([]entry, len(level))
+		for i, n := range level {
+			parents[i] = entry{node: n}
+		}
+		level = tree.packLevel(parents, false, workers, p)
+	}
+	p.finish()
+
+	tree.root = level[0]
+}
+
+// packLevel builds the nodes holding the sorted entries of a level, which are linked to
+// their siblings once their parent is built. Nodes share the backing array of entries, they get their own once they grow. Workers
+// build the nodes of contiguous ranges of the level.
+func (tree *HRtree) packLevel(entries []entry, leaf bool, workers int, p *reporter) []*node {
+	nodes := make([]*node, (len(entries)+tree.max-1)/tree.max)
+	workers = min(workers, len(nodes))
+	parallel(workers, func(w int) {
+		for i := w * len(nodes) / workers; i < (w+1)*len(nodes)/workers; i++ {
+			// nodes aren't filled up to max, which would leave the last one with the
+			// remainder, below min if min is more than half of max.
+			lo, hi := i*len(entries)/len(nodes), (i+1)*len(entries)/len(nodes)
+			nodes[i] = tree.packNode(entries[lo:hi:hi], leaf)
+
+			// the reporter is not shared with other workers.
+			if workers == 1 {
+				p.step()
+			}
+		}
+	})
+
+	if workers > 1 {
+		p.advance(len(nodes))
+	}
+
+	return nodes
+}
+
+// packNode builds a node holding entries.
+func (tree *HRtree) packNode(entries []entry, leaf bool) *node {
+	n := &node{
+		min:     tree.min,
+		max:     tree.max,
+		leaf:    leaf,
+		entries: &entryList{entries: entries, arena: tree.arena},
+	}
+
+	if !leaf {
+		for _, e := range n.getEntries() {
+			e.node.parent = n
+		}
+		n.linkChildren()
+	}
+	n.adjustLHV()
+	n.adjustMBR()
+
+	return n
+}
@@ -0,0 +1,587 @@
+package hrtree
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"math/rand"
+	"strings"
+	"testing"
+)
+
+func decodeRect(r io.Reader) (Rectangle, error) {
+	var c [2 * Dim]uint64
+	if err := binary.Read(r, binary.LittleEndian, &c); err != nil {
+		return nil, err
+	}
+
+	return rect(Point{c[0], c[1]}, Point{c[2], c[3]}), nil
+}
+
+func encodeRects(n int) *bytes.Buffer {
+	var buf bytes.Buffer
+	for i := 0; i < n; i++ {
+		x, y := uint64(i%40), uint64(i/40)
+		binary.Write(&buf, binary.LittleEndian, [2 * Dim]uint64{x, y, x + 1, y + 1})
+	}
+
+	return &buf
+}
+
+func TestLoad(t *testing.T) {
+	rt, _ := NewTree(4, 9, 12)
+	rt.Insert(rect(Point{100, 100}, Point{101, 101}))
+
+	if err := rt.Load(encodeRects(1000), decodeRect); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if rt.Size() != 1001 {
+		t.Errorf("expected 1001 objects, got %d", rt.Size())
+	}
+
+	depths := make(map[int]bool)
+	leafDepths(rt.root, 0, depths)
+	if len(depths) != 1 {
+		t.Errorf("leaves at different depths after load")
+	}
+
+	if live, _ := countEntries(rt.root); live != 1001 {
+		t.Errorf("expected 1001 leaf entries, got %d", live)
+	}
+
+	for _, leaf := range rt.root.leaves(nil) {
+		if leaf != rt.root && leaf.entries.len() < rt.min {
+			t.Errorf("leaf underflowing with %d entries", leaf.entries.len())
+		}
+	}
+
+	q := rt.SearchIntersect(rect(Point{10, 10}, Point{12, 12}))
+	if len(q) != 16 {
+		t.Errorf("expected 16 results, got %d", len(q))
+	}
+
+	// packed nodes keep working with regular updates.
+	for i := 0; i < 200; i++ {
+		rt.Insert(rect(Point{uint64(i), 50}, Point{uint64(i), 50}))
+	}
+
+	if ok, _ := rt.Delete(q[0]); !ok {
+		t.Errorf("expected to delete a loaded object")
+	}
+
+	q = rt.SearchIntersect(rect(Point{0, 0}, Point{200, 200}))
+	if len(q) != 1200 {
+		t.Errorf("expected 1200 results, got %d", len(q))
+	}
+}
+
+func TestLoadVersioned(t *testing.T) {
+	rt, _ := NewTree(2, 4, 12)
+	rt.Insert(position("car", 2, 10, 10))
+
+	objs := []Rectangle{
+		position("car", 1, 1, 1),
+		position("bus", 1, 2, 2),
+		position("car", 3, 3, 3),
+		position("bus", 2, 4, 4),
+	}
+
+	err := rt.Load(nil, func(io.Reader) (Rectangle, error) {
+		if len(objs) == 0 {
+			return nil, io.EOF
+		}
+		obj := objs[0]
+		objs = objs[1:]
+		return obj, nil
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if rt.Size() != 2 {
+		t.Errorf("expected 2 objects, got %d", rt.Size())
+	}
+
+	if obj, _ := rt.Lookup("car"); obj.(Versioned).Version() != 3 {
+		t.Errorf("expected car version 3, got %v", obj)
+	}
+
+	if obj, _ := rt.Lookup("bus"); obj.(Versioned).Version() != 2 {
+		t.Errorf("expected bus version 2, got %v", obj)
+	}
+
+	if q := rt.SearchIntersect(rect(Point{0, 0}, Point{100, 100})); len(q) != 2 {
+		t.Errorf("expected 2 results, got %d", len(q))
+	}
+}
+
+func TestLoadError(t *testing.T) {
+	rt, _ := NewTree(2, 4, 12)
+	rt.Insert(rect(Point{1, 1}, Point{2, 2}))
+
+	buf := encodeRects(10)
+	buf.WriteByte(0) // truncated record
+
+	err := rt.Load(buf, decodeRect)
+	if !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Errorf("expected %v, got %v", io.ErrUnexpectedEOF, err)
+	}
+
+	if !strings.Contains(err.Error(), "record 10") {
+		t.Errorf("expected the failing record in %q", err)
+	}
+
+	if rt.Size() != 1 {
+		t.Errorf("expected the tree to be left unchanged, got %d objects", rt.Size())
+	}
+
+	fail := errors.New("decode failure")
+	err = rt.Load(nil, func(io.Reader) (Rectangle, error) { return nil, fail })
+	if !errors.Is(err, fail) {
+		t.Errorf("expected %v, got %v", fail, err)
+	}
+}
+
+func TestLoadEmpty(t *testing.T) {
+	rt, _ := NewTree(2, 4, 12)
+
+	if err := rt.Load(&bytes.Buffer{}, decodeRect); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if rt.Size() != 0 || !rt.root.leaf {
+		t.Errorf("expected an empty tree")
+	}
+
+	rt.Insert(rect(Point{1, 1}, Point{2, 2}))
+	if len(rt.SearchIntersect(rect(Point{0, 0}, Point{3, 3}))) != 1 {
+		t.Errorf("expected 1 result")
+	}
+}
+
+func TestNewTreeFrom(t *testing.T) {
+	objs := []Rectangle{
+		rect(Point{1, 1}, Point{2, 2}),
+		rect(Point{3, 3}, Point{4, 4}),
+		rect(Point{1 << 40, 5}, Point{1<<40 + 1, 6}),
+	}
+
+	rt, err := NewTreeFrom(objs)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if rt.bits != 41 {
+		t.Errorf("expected a resolution of 41 bits, got %d", rt.bits)
+	}
+
+	if rt.min != DefaultMinNodeEntries || rt.max != DefaultMaxNodeEntries {
+		t.Errorf("expected default node entries, got %d-%d", rt.min, rt.max)
+	}
+
+	if rt.Size() != 3 {
+		t.Errorf("expected 3 objects, got %d", rt.Size())
+	}
+
+	if q := rt.SearchIntersect(rect(Point{0, 0}, Point{3, 3})); len(q) != 2 {
+		t.Errorf("expected 2 results, got %d", len(q))
+	}
+
+	rt, err = NewTreeFrom(objs[:2], WithNodeEntries(2, 4), WithResolution(12))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if rt.bits != 12 || rt.min != 2 || rt.max != 4 {
+		t.Errorf("expected options to override defaults, got %d-%d with %d bits", rt.min, rt.max, rt.bits)
+	}
+
+	rt, err = NewTreeFrom(nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if rt.bits != DefaultResolution || rt.Size() != 0 {
+		t.Errorf("expected an empty tree with the default resolution")
+	}
+
+	if _, err := NewTreeFrom(objs, WithNodeEntries(5, 4)); !errors.Is(err, ErrMinGTMax) {
+		t.Errorf("expected %v, got %v", ErrMinGTMax, err)
+	}
+}
+
+func TestNewTreeBulk(t *testing.T) {
+	var objs []Rectangle
+	for i := 0; i < 1000; i++ {
+		x, y := uint64(i), uint64(i*7%64)
+		objs = append(objs, rect(Point{x, y}, Point{x + 1, y + 1}))
+	}
+
+	rt, err := NewTreeBulk(4, 10, 12, objs)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if s := rt.Stats(); s.Objects != 1000 || s.Leaves != 100 {
+		t.Errorf("expected 1000 objects in 100 full leaves, got %d in %d", s.Objects, s.Leaves)
+	}
+	checkLinks(t, rt.root)
+
+	incremental, _ := NewTree(4, 10, 12)
+	for _, obj := range objs {
+		incremental.Insert(obj)
+	}
+
+	window := rect(Point{100, 10}, Point{400, 30})
+	if a, b := len(rt.SearchIntersect(window)), len(incremental.SearchIntersect(window)); a != b {
+		t.Errorf("expected %d results, got %d", b, a)
+	}
+
+	// the remainder is spread rather than left to the last leaf.
+	rt, _ = NewTreeBulk(8, 10, 12, objs[:25])
+	for _, leaf := range rt.root.leaves(nil) {
+		if n := leaf.entries.len(); n < 8 {
+			t.Errorf("expected leaves of at least 8 entries, got %d", n)
+		}
+	}
+
+	if _, err := NewTreeBulk(5, 4, 12, objs); !errors.Is(err, ErrMinGTMax) {
+		t.Errorf("expected %v, got %v", ErrMinGTMax, err)
+	}
+}
+
+func TestNewTreeFromSorted(t *testing.T) {
+	var objs []Rectangle
+	for i := 0; i < 500; i++ {
+		x, y := uint64(i%50), uint64(i/50)
+		objs = append(objs, rect(Point{x, y}, Point{x + 1, y + 1}))
+	}
+
+	packed, _ := NewTreeBulk(4, 10, 12, objs)
+	var sorted []Rectangle
+	for _, e := range packed.liveEntries() {
+		sorted = append(sorted, e.obj)
+	}
+
+	rt, err := NewTreeFromSorted(4, 10, 12, sorted)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if s := rt.Stats(); s.Objects != 500 || s.Leaves != 50 {
+		t.Errorf("expected 500 objects in 50 full leaves, got %d in %d", s.Objects, s.Leaves)
+	}
+
+	window := rect(Point{10, 2}, Point{20, 5})
+	if a, b := len(rt.SearchIntersect(window)), len(packed.SearchIntersect(window)); a != b {
+		t.Errorf("expected %d results, got %d", b, a)
+	}
+
+	sorted[3], sorted[4] = sorted[4], sorted[3]
+	if _, err := NewTreeFromSorted(4, 10, 12, sorted); !errors.Is(err, ErrNotSorted) {
+		t.Errorf("expected %v, got %v", ErrNotSorted, err)
+	}
+}
+
+func TestInsertAll(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	rt, _ := NewTree(2, 6, 12)
+	objs := make(map[Rectangle]bool)
+
+	for batch := 0; batch < 5; batch++ {
+		var list []Rectangle
+		for i := 0; i < 400; i++ {
+			// lower and upper x coordinates are unique to each object.
+			x, y := uint64(3*(batch*400+i)), uint64(r.Intn(1000))
+			obj := rect(Point{x, y}, Point{x + 1, y + 1})
+			objs[obj] = true
+			list = append(list, obj)
+		}
+		r.Shuffle(len(list), func(i, j int) { list[i], list[j] = list[j], list[i] })
+
+		rt.InsertAll(list)
+		checkTree(t, rt, objs)
+	}
+}
+
+func TestDeleteAll(t *testing.T) {
+	for _, policy := range []UnderflowPolicy{UnderflowRedistribute, UnderflowMergeLeft, UnderflowDefer} {
+		r := rand.New(rand.NewSource(1))
+		rt, _ := NewTree(2, 6, 12, WithUnderflowPolicy(policy))
+		objs := make(map[Rectangle]bool)
+		var list []Rectangle
+
+		for i := 0; i < 2000; i++ {
+			// lower and upper x coordinates are unique to each object.
+			x, y := uint64(3*i), uint64(r.Intn(1000))
+			obj := rect(Point{x, y}, Point{x + 1, y + 1})
+			rt.Insert(obj)
+			objs[obj] = true
+			list = append(list, obj)
+		}
+		r.Shuffle(len(list), func(i, j int) { list[i], list[j] = list[j], list[i] })
+
+		for len(list) > 0 {
+			n := r.Intn(300) + 1
+			if n > len(list) {
+				n = len(list)
+			}
+
+			batch := append([]Rectangle{rect(Point{1, 1}, Point{2, 2})}, list[:n]...)
+			if removed, _ := rt.DeleteAll(batch); removed != n {
+				t.Errorf("expected %d objects removed, got %d", n, removed)
+			}
+
+			for _, obj := range list[:n] {
+				delete(objs, obj)
+			}
+			list = list[n:]
+			checkTree(t, rt, objs)
+		}
+	}
+
+	// of objects with the same bounds, the one Delete would remove is removed.
+	first, second := rect(Point{1, 1}, Point{2, 2}), rect(Point{1, 1}, Point{2, 2})
+	rt, _ := NewTree(2, 6, 12)
+	rt.Insert(first)
+	rt.Insert(second)
+	rt.DeleteAll([]Rectangle{rect(Point{1, 1}, Point{2, 2})})
+	if left := rt.SearchIntersect(first); len(left) != 1 || left[0] != second {
+		t.Errorf("expected the first object to be removed, got %v left", left)
+	}
+}
+
+func TestDeleteAllLazy(t *testing.T) {
+	rt, _ := NewTree(2, 6, 12, WithLazyDelete())
+	var objs []Rectangle
+	for i := 0; i < 100; i++ {
+		obj := rect(Point{uint64(i), 0}, Point{uint64(i), 1})
+		rt.Insert(obj)
+		objs = append(objs, obj)
+	}
+
+	if removed, _ := rt.DeleteAll(objs[:40]); removed != 40 {
+		t.Errorf("expected 40 objects removed, got %d", removed)
+	}
+
+	if s := rt.Stats(); s.Objects != 60 || s.Tombstones != 40 {
+		t.Errorf("expected 60 objects and 40 removed, got %d and %d", s.Objects, s.Tombstones)
+	}
+}
+
+func TestDeleteWhere(t *testing.T) {
+	for _, lazy := range []bool{false, true} {
+		var opts []Option
+		if lazy {
+			opts = append(opts, WithLazyDelete())
+		}
+		r := rand.New(rand.NewSource(1))
+		rt, _ := NewTree(2, 6, 12, opts...)
+		objs := make(map[Rectangle]bool)
+
+		for i := 0; i < 2000; i++ {
+			// lower and upper x coordinates are unique to each object.
+			x, y := uint64(3*i), uint64(r.Intn(1000))
+			obj := rect(Point{x, y}, Point{x + 1, y + 1})
+			rt.Insert(obj)
+			objs[obj] = true
+		}
+
+		window := rect(Point{1000, 200}, Point{5000, 700})
+		even := func(obj Rectangle) bool {
+			return obj.LowerLeft()[0]%2 == 0
+		}
+
+		want := 0
+		for obj := range objs {
+			if intersect(window, obj) && even(obj) {
+				delete(objs, obj)
+				want++
+			}
+		}
+
+		if removed, _ := rt.DeleteWhere(window, even); removed != want || want == 0 {
+			t.Errorf("expected %d objects removed, got %d", want, removed)
+		}
+		checkTree(t, rt, objs)
+
+		if removed, _ := rt.DeleteWhere(window, even); removed != 0 {
+			t.Errorf("expected no objects removed, got %d", removed)
+		}
+	}
+}
+
+func TestDeleteWhereWrapRefine(t *testing.T) {
+	// only objects whose lower x is even actually cross a window.
+	even := func(obj, window Rectangle) bool { return obj.LowerLeft()[0]%2 == 0 }
+	rt, _ := NewTree(2, 4, 8, WithWrap(0), WithRefine(even))
+	for x := uint64(0); x < 250; x += 5 {
+		rt.Insert(rect(Point{x, 10}, Point{x + 1, 11}))
+	}
+
+	window := NewWrapWindow(Point{240, 0}, Point{20, 20})
+	want := rt.SearchIntersect(window)
+	removed, err := rt.DeleteIntersecting(window)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(removed) != len(want) || len(want) != 4 {
+		t.Errorf("expected %v removed, got %v", want, removed)
+	}
+
+	if rt.Size() != 46 || len(rt.SearchIntersect(window)) != 0 {
+		t.Errorf("expected the objects found by the search to be removed, %d left", rt.Size())
+	}
+}
+
+func TestDeleteIntersecting(t *testing.T) {
+	for _, policy := range []UnderflowPolicy{UnderflowRedistribute, UnderflowMergeLeft} {
+		r := rand.New(rand.NewSource(2))
+		rt, _ := NewTree(2, 6, 12, WithUnderflowPolicy(policy))
+		objs := make(map[Rectangle]bool)
+
+		for i := 0; i < 2000; i++ {
+			x, y := uint64(r.Intn(1000)), uint64(r.Intn(1000))
+			obj := rect(Point{x, y}, Point{x + uint64(r.Intn(10)), y + uint64(r.Intn(10))})
+			rt.Insert(obj)
+			objs[obj] = true
+		}
+
+		window := rect(Point{100, 100}, Point{800, 600})
+		want := make(map[Rectangle]bool)
+		for obj := range objs {
+			if intersect(window, obj) {
+				delete(objs, obj)
+				want[obj] = true
+			}
+		}
+
+		removed, err := rt.DeleteIntersecting(window)
+		if err != nil || len(removed) != len(want) {
+			t.Fatalf("expected %d objects removed, got %d, %v", len(want), len(removed), err)
+		}
+
+		for _, obj := range removed {
+			if !want[obj] {
+				t.Errorf("unexpected object removed %v", obj)
+			}
+		}
+		checkTree(t, rt, objs)
+
+		if removed, _ := rt.DeleteIntersecting(window); len(removed) != 0 {
+			t.Errorf("expected no objects removed, got %d", len(removed))
+		}
+	}
+}
+
+func TestDeleteWhereVersioned(t *testing.T) {
+	rt, _ := NewTree(2, 4, 12)
+	for i := 0; i < 10; i++ {
+		rt.Insert(position(string(rune('a'+i)), 1, uint64(i), 0))
+	}
+
+	if removed, _ := rt.DeleteWhere(rect(Point{0, 0}, Point{3, 3}), func(Rectangle) bool { return true }); removed != 4 {
+		t.Errorf("expected 4 objects removed, got %d", removed)
+	}
+
+	if _, ok := rt.Lookup("a"); ok {
+		t.Errorf("expected a to be removed")
+	}
+
+	// versions are marked as removed, as with Delete.
+	if s := rt.Stats(); s.Objects != 6 || s.Tombstones != 4 {
+		t.Errorf("expected 6 objects and 4 tombstones, got %d and %d", s.Objects, s.Tombstones)
+	}
+
+	// the deletion is remembered at its version.
+	rt.Insert(position("a", 1, 0, 0))
+	if rt.Size() != 6 {
+		t.Errorf("expected 6 objects, got %d", rt.Size())
+	}
+}
+
+func TestCompact(t *testing.T) {
+	rt, _ := NewTree(4, 10, 12, WithLazyDelete())
+	var objs []Rectangle
+	for i := 0; i < 1000; i++ {
+		x, y := uint64(i), uint64(i*7%64)
+		obj := rect(Point{x, y}, Point{x + 1, y + 1})
+		objs = append(objs, obj)
+		rt.Insert(obj)
+	}
+
+	for i, obj := range objs {
+		if i%5 != 0 {
+			rt.Delete(obj)
+		}
+	}
+
+	rt.Compact()
+
+	if s := rt.Stats(); s.Objects != 200 || s.Tombstones != 0 || s.Leaves != 20 {
+		t.Errorf("expected 200 objects in 20 full leaves, got %d (%d removed) in %d", s.Objects, s.Tombstones, s.Leaves)
+	}
+
+	if got := rt.SearchIntersect(rect(Point{0, 0}, Point{1000, 64})); len(got) != 200 {
+		t.Errorf("expected 200 results, got %d", len(got))
+	}
+}
+
+func TestClear(t *testing.T) {
+	rt, _ := NewTree(2, 4, 12, WithLazyDelete())
+	for i := 0; i < 100; i++ {
+		rt.Insert(rect(Point{uint64(i), 0}, Point{uint64(i + 1), 1}))
+	}
+	rt.Insert(position("a", 2, 1, 1))
+	rt.Delete(rect(Point{0, 0}, Point{1, 1}))
+
+	rt.Clear()
+
+	if s := rt.Stats(); s.Objects != 0 || s.Tombstones != 0 || s.Nodes != 1 {
+		t.Errorf("expected an empty tree, got %+v", s)
+	}
+
+	if q := rt.SearchIntersect(rect(Point{0, 0}, Point{100, 100})); len(q) != 0 {
+		t.Errorf("expected no results, got %d", len(q))
+	}
+
+	// versions are forgotten.
+	rt.Insert(position("a", 1, 1, 1))
+	rt.Insert(rect(Point{5, 5}, Point{6, 6}))
+	if rt.Size() != 2 {
+		t.Errorf("expected 2 objects, got %d", rt.Size())
+	}
+}
+
+func TestReplaceAll(t *testing.T) {
+	rt, _ := NewTree(2, 4, 12, WithLazyDelete())
+
+	var old []Rectangle
+	for i := uint64(0); i < 100; i++ {
+		obj := rect(Point{3 * i, i}, Point{3*i + 1, i + 1})
+		rt.Insert(obj)
+		old = append(old, obj)
+	}
+	rt.Delete(old[0])
+
+	objs := make(map[Rectangle]bool)
+	var fresh []Rectangle
+	for i := uint64(0); i < 300; i++ {
+		obj := rect(Point{3 * i, 500 + i%20}, Point{3*i + 1, 501 + i%20})
+		objs[obj] = true
+		fresh = append(fresh, obj)
+	}
+
+	rt.ReplaceAll(fresh)
+
+	checkTree(t, rt, objs)
+	if s := rt.Stats(); s.Tombstones != 0 {
+		t.Errorf("expected no tombstones, got %d", s.Tombstones)
+	}
+
+	rt.ReplaceAll(nil)
+	checkTree(t, rt, map[Rectangle]bool{})
+}
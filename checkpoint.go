@@ -0,0 +1,147 @@
+package hrtree
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+// checkpointer periodically saves a paged snapshot of the tree.
+type checkpointer struct {
+	path     string
+	pageSize int
+	interval time.Duration
+
+	mu    sync.Mutex // serializes checkpoints
+	saved uint64     // value of tree.changes covered by the last checkpoint
+	err   error      // last background error
+	sums  []uint32   // checksum of each page of the last checkpoint
+	stats CheckpointStats
+	stop  chan struct{}
+	done  chan struct{}
+}
+
+// WithCheckpoint makes the tree save a full paged snapshot (see WritePages) to path
+// every interval while it has unsaved changes. The file is replaced atomically, so
+// after a crash the tree can be recovered from the last checkpoint with ReadPages.
+// Trees using checkpoints should be closed with Close. CheckpointStats reports how much
+// is written for the changes made.
+func WithCheckpoint(path string, pageSize int, interval time.Duration) Option {
+	return func(tree *HRtree) {
+		tree.cp = &checkpointer{
+			path:     path,
+			pageSize: pageSize,
+			interval: interval,
+		}
+	}
+}
+
+func (cp *checkpointer) start(tree *HRtree) {
+	cp.stop = make(chan struct{})
+	cp.done = make(chan struct{})
+
+	go func() {
+		defer close(cp.done)
+
+		ticker := time.NewTicker(cp.interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				if err := tree.Checkpoint(); err != nil {
+					cp.mu.Lock()
+					cp.err = err
+					cp.mu.Unlock()
+				}
+			case <-cp.stop:
+				return
+			}
+		}
+	}()
+}
+
+// Checkpoint saves a snapshot of the tree right away if it changed since the last one.
+// It is a no-op for trees created without WithCheckpoint.
+func (tree *HRtree) Checkpoint() error {
+	cp := tree.cp
+	if cp == nil {
+		return nil
+	}
+
+	cp.mu.Lock()
+	defer cp.mu.Unlock()
+
+	// only hold the tree while encoding, the file is written afterwards.
+	start := time.Now()
+	var buf bytes.Buffer
+	tree.mu.RLock()
+	changes, dataBytes := tree.changes, tree.dataBytes
+	if changes == cp.saved {
+		tree.mu.RUnlock()
+		return nil
+	}
+	err := tree.WritePages(&buf, cp.pageSize)
+	tree.mu.RUnlock()
+
+	if err != nil {
+		return fmt.Errorf("Checkpoint: %w", err)
+	}
+
+	if err := writeFileAtomic(cp.path, buf.Bytes()); err != nil {
+		return fmt.Errorf("Checkpoint: %w", err)
+	}
+
+	cp.saved = changes
+	cp.record(buf.Bytes(), dataBytes, time.Since(start))
+	return nil
+}
+
+// Close stops background checkpointing and saves any pending changes. It returns
+// the last error met by the background checkpoints, if any.
+func (tree *HRtree) Close() error {
+	cp := tree.cp
+	if cp == nil {
+		return nil
+	}
+
+	if cp.stop != nil {
+		close(cp.stop)
+		<-cp.done
+		cp.stop = nil
+	}
+
+	if err := tree.Checkpoint(); err != nil {
+		return err
+	}
+
+	cp.mu.Lock()
+	defer cp.mu.Unlock()
+	return cp.err
+}
+
+// writeFileAtomic writes data to a temporary file and renames it over path.
+func writeFileAtomic(path string, data []byte) error {
+	tmp := path + ".tmp"
+	f, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+
+	if _, err = f.Write(data); err == nil {
+		err = f.Sync()
+	}
+
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+
+	if err != nil {
+		os.Remove(tmp)
+		return err
+	}
+
+	return os.Rename(tmp, path)
+}
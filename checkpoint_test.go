@@ -0,0 +1,125 @@
+package hrtree
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func readCheckpoint(t *testing.T, path string) *HRtree {
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer f.Close()
+
+	rt, err := ReadPages(f)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	return rt
+}
+
+func TestCheckpoint(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "hrtree")
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "tree.pages")
+
+	rt, err := NewTree(2, MaxEntriesForPage(MinPageSize), 12, WithCheckpoint(path, MinPageSize, time.Millisecond))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	for i := 0; i < 50; i++ {
+		rt.Insert(rect(Point{uint64(i), uint64(i)}, Point{uint64(i + 1), uint64(i + 1)}))
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		if _, err := os.Stat(path); err == nil {
+			break
+		}
+
+		if time.Now().After(deadline) {
+			t.Fatalf("no checkpoint was written")
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	rt.Delete(rect(Point{3, 3}, Point{4, 4}))
+	if err := rt.Close(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if loaded := readCheckpoint(t, path); loaded.Size() != 49 {
+		t.Errorf("expected 49 objects in the last checkpoint, got %d", loaded.Size())
+	}
+}
+
+func TestCheckpointPageTooSmall(t *testing.T) {
+	if _, err := NewTree(2, 100, 12, WithCheckpoint("unused", MinPageSize, time.Second)); !errors.Is(err, ErrPageTooSmall) {
+		t.Errorf("expected ErrPageTooSmall, got %v", err)
+	}
+}
+
+func TestCheckpointStats(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "hrtree")
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "tree.pages")
+
+	// a long interval, checkpoints are only taken explicitly.
+	rt, err := NewTree(2, 8, 12, WithCheckpoint(path, PageSize4K, time.Hour))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer rt.Close()
+
+	for i := 0; i < 100; i++ {
+		rt.Insert(rect(Point{uint64(i), uint64(i)}, Point{uint64(i + 1), uint64(i + 1)}))
+	}
+
+	if err := rt.Checkpoint(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	s := rt.CheckpointStats()
+	if s.Checkpoints != 1 || s.LastDirty != s.LastPages || s.DirtyPages != uint64(s.LastPages) {
+		t.Errorf("expected every page of the first checkpoint to be dirty, got %+v", s)
+	}
+
+	if s.BytesWritten != uint64(s.LastPages*PageSize4K) || s.DataBytes != 100*dumpRecordSize {
+		t.Errorf("unexpected byte counts %+v", s)
+	}
+
+	if s.LastDuration <= 0 || s.TotalDuration != s.LastDuration {
+		t.Errorf("unexpected durations %+v", s)
+	}
+
+	// a single change to the last leaf only dirties the pages on its path.
+	rt.Insert(rect(Point{99, 99}, Point{100, 100}))
+	rt.Checkpoint()
+
+	s = rt.CheckpointStats()
+	if s.Checkpoints != 2 || s.LastDirty == 0 || s.LastDirty >= s.LastPages {
+		t.Errorf("expected a few dirty pages, got %+v", s)
+	}
+
+	if s.DataBytes != 101*dumpRecordSize || s.WriteAmplification() <= 1 {
+		t.Errorf("unexpected write amplification %v of %+v", s.WriteAmplification(), s)
+	}
+
+	// nothing changed, nothing is written.
+	rt.Checkpoint()
+	if rt.CheckpointStats().Checkpoints != 2 {
+		t.Errorf("expected no checkpoint without changes")
+	}
+
+	plain, _ := NewTree(2, 8, 12)
+	if plain.CheckpointStats() != (CheckpointStats{}) {
+		t.Errorf("expected no statistics without checkpoints")
+	}
+}
@@ -0,0 +1,39 @@
+// Command hrtree explores an index file interactively. It reads commands from the
+// standard input, such as searches and statistics, and times each of them:
+//
+//	hrtree tree.pages
+//	hrtree -dump objects.bin
+//
+// Index files are paged files written by WritePages or WithCheckpoint, or raw dumps
+// written by Export with -dump. Type help for the list of commands.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+)
+
+func main() {
+	dump := flag.Bool("dump", false, "read a raw dump rather than a paged file")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "usage: %s [-dump] [file]\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	r := newREPL(os.Stdout)
+	if flag.NArg() > 1 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	if flag.NArg() == 1 {
+		if err := r.load(flag.Arg(0), *dump); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+	}
+
+	r.run(os.Stdin)
+}
@@ -0,0 +1,215 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jtejido/hrtree"
+)
+
+var errNoTree = errors.New("no index loaded, see load")
+
+// repl runs the commands of a session against the loaded tree.
+type repl struct {
+	tree *hrtree.HRtree
+	out  io.Writer
+}
+
+// command runs with the arguments following its name.
+type command struct {
+	usage string
+	run   func(r *repl, args []string) error
+}
+
+var commands map[string]command
+
+func init() {
+	commands = map[string]command{
+		"load":      {"load [-dump] FILE      read a paged file, or a raw dump", (*repl).loadCmd},
+		"intersect": {"intersect X0 Y0 X1 Y1  list the objects intersecting a window", (*repl).intersect},
+		"knn":       {"knn X Y K              list the K objects nearest to a point", (*repl).knn},
+		"stats":     {"stats                  show the shape of the tree", (*repl).stats},
+		"histogram": {"histogram N            show the areas and aspect ratios of objects", (*repl).histogram},
+		"help":      {"help                   list the commands", (*repl).help},
+	}
+}
+
+func newREPL(out io.Writer) *repl {
+	return &repl{out: out}
+}
+
+// run executes the commands read from in until its end or quit.
+func (r *repl) run(in io.Reader) {
+	scanner := bufio.NewScanner(in)
+	for fmt.Fprint(r.out, "> "); scanner.Scan(); fmt.Fprint(r.out, "> ") {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 {
+			continue
+		}
+
+		if fields[0] == "quit" || fields[0] == "exit" {
+			return
+		}
+
+		cmd, ok := commands[fields[0]]
+		if !ok {
+			fmt.Fprintf(r.out, "unknown command %q, see help\n", fields[0])
+			continue
+		}
+
+		start := time.Now()
+		if err := cmd.run(r, fields[1:]); err != nil {
+			fmt.Fprintf(r.out, "error: %v\n", err)
+			continue
+		}
+		fmt.Fprintf(r.out, "(%v)\n", time.Since(start))
+	}
+	fmt.Fprintln(r.out)
+}
+
+// load reads the tree of path, a raw dump if dump is set.
+func (r *repl) load(path string, dump bool) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	var tree *hrtree.HRtree
+	if dump {
+		tree, err = hrtree.Import(f)
+	} else {
+		tree, err = hrtree.ReadPages(f)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	r.tree = tree
+	return nil
+}
+
+func (r *repl) loadCmd(args []string) error {
+	dump := len(args) == 2 && args[0] == "-dump"
+	if len(args) != 1 && !dump {
+		return errors.New("usage: " + commands["load"].usage)
+	}
+
+	if err := r.load(args[len(args)-1], dump); err != nil {
+		return err
+	}
+
+	fmt.Fprintf(r.out, "%d objects\n", r.tree.Size())
+	return nil
+}
+
+func (r *repl) intersect(args []string) error {
+	c, err := r.coordinates(args, 4, "intersect")
+	if err != nil {
+		return err
+	}
+
+	bb, err := hrtree.NewRect(hrtree.Point{c[0], c[1]}, hrtree.Point{c[2], c[3]})
+	if err != nil {
+		return err
+	}
+
+	objs := r.tree.SearchIntersect(bb)
+	for _, obj := range objs {
+		fmt.Fprintf(r.out, "%v %v\n", obj.LowerLeft(), obj.UpperRight())
+	}
+	fmt.Fprintf(r.out, "%d objects\n", len(objs))
+
+	return nil
+}
+
+func (r *repl) knn(args []string) error {
+	c, err := r.coordinates(args, 3, "knn")
+	if err != nil {
+		return err
+	}
+
+	n := uint64(0)
+	for obj, d := range r.tree.NearestWithin(hrtree.Point{c[0], c[1]}, math.Inf(1)) {
+		if n == c[2] {
+			break
+		}
+		fmt.Fprintf(r.out, "%v %v at %g\n", obj.LowerLeft(), obj.UpperRight(), d)
+		n++
+	}
+	fmt.Fprintf(r.out, "%d objects\n", n)
+
+	return nil
+}
+
+func (r *repl) stats(args []string) error {
+	if r.tree == nil {
+		return errNoTree
+	}
+
+	s := r.tree.Stats()
+	fmt.Fprintf(r.out, "objects    %d\ntombstones %d\nheight     %d\nnodes      %d\nleaves     %d\n",
+		s.Objects, s.Tombstones, s.Height, s.Nodes, s.Leaves)
+
+	return nil
+}
+
+func (r *repl) histogram(args []string) error {
+	c, err := r.coordinates(args, 1, "histogram")
+	if err != nil {
+		return err
+	}
+
+	h := r.tree.Histogram(int(c[0]))
+	for _, part := range []struct {
+		name    string
+		buckets []hrtree.Bucket
+	}{{"areas", h.Areas}, {"aspect ratios", h.Aspects}} {
+		fmt.Fprintln(r.out, part.name)
+		for _, b := range part.buckets {
+			fmt.Fprintf(r.out, "  %12.4g - %-12.4g %d\n", b.Min, b.Max, b.Count)
+		}
+	}
+
+	return nil
+}
+
+func (r *repl) help(args []string) error {
+	for _, name := range []string{"load", "intersect", "knn", "stats", "histogram", "help"} {
+		fmt.Fprintln(r.out, commands[name].usage)
+	}
+	fmt.Fprintln(r.out, "quit")
+
+	return nil
+}
+
+// coordinates parses the n unsigned integer arguments of the named command, which needs
+// a tree.
+func (r *repl) coordinates(args []string, n int, name string) ([]uint64, error) {
+	if r.tree == nil {
+		return nil, errNoTree
+	}
+
+	if len(args) != n {
+		return nil, errors.New("usage: " + commands[name].usage)
+	}
+
+	c := make([]uint64, n)
+	for i, arg := range args {
+		v, err := strconv.ParseUint(arg, 10, 64)
+		if err != nil {
+			return nil, err
+		}
+		c[i] = v
+	}
+
+	return c, nil
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/jtejido/hrtree"
+)
+
+func TestREPL(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "hrtree")
+	defer os.RemoveAll(dir)
+
+	tree := hrtree.MustNewTree(2, 4, 12)
+	for i := uint64(0); i < 20; i++ {
+		tree.Insert(hrtree.MustNewRect(hrtree.Point{10 * i, 10 * i}, hrtree.Point{10*i + 1, 10*i + 1}))
+	}
+
+	var pages, dump bytes.Buffer
+	tree.WritePages(&pages, hrtree.PageSize4K)
+	tree.Export(&dump)
+	ioutil.WriteFile(filepath.Join(dir, "tree.pages"), pages.Bytes(), 0644)
+	ioutil.WriteFile(filepath.Join(dir, "tree.bin"), dump.Bytes(), 0644)
+
+	var out bytes.Buffer
+	r := newREPL(&out)
+	r.run(strings.NewReader(strings.Join([]string{
+		"stats",
+		"load " + filepath.Join(dir, "tree.pages"),
+		"intersect 0 0 25 25",
+		"knn 100 100 2",
+		"stats",
+		"load -dump " + filepath.Join(dir, "tree.bin"),
+		"histogram 2",
+		"intersect 1 2",
+		"bogus",
+		"quit",
+		"stats",
+	}, "\n")))
+
+	// objects are printed with all their coordinates, 0 past the second.
+	obj := func(x uint64) string {
+		return fmt.Sprint(hrtree.Point{x, x}, " ", hrtree.Point{x + 1, x + 1})
+	}
+
+	for _, expected := range []string{
+		"error: no index loaded",
+		"20 objects\n",
+		obj(0) + "\n" + obj(10) + "\n" + obj(20) + "\n3 objects\n",
+		obj(100) + " at 0\n" + obj(90) + " at 12.7",
+		"2 objects\n",
+		"objects    20\n",
+		"areas\n",
+		"error: usage: intersect",
+		`unknown command "bogus"`,
+	} {
+		if !strings.Contains(out.String(), expected) {
+			t.Errorf("expected %q in the output:\n%s", expected, out.String())
+		}
+	}
+
+	if strings.Count(out.String(), "objects    20") != 1 {
+		t.Errorf("expected the session to end at quit:\n%s", out.String())
+	}
+}
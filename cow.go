@@ -0,0 +1,166 @@
+package hrtree
+
+import (
+	"errors"
+	"maps"
+)
+
+var ErrReadOnly = errors.New("Snapshots can't be modified.")
+
+// WithSnapshotReads makes searches traverse an immutable copy of the tree, published by
+// every mutation once it is complete, instead of the nodes being modified. Searches then
+// never wait for mutations nor hold any lock, at the cost of keeping a second copy of the
+// nodes: only the nodes modified by a mutation and their ancestors are copied again, the
+// others are shared with the previous copy.
+func WithSnapshotReads() Option {
+	return func(tree *HRtree) {
+		tree.cow = true
+	}
+}
+
+// published is a version of the tree installed for searches.
+type published struct {
+	root *node
+	size int
+}
+
+// readRoot returns the root searches start from.
+func (tree *HRtree) readRoot() *node {
+	if tree.cow {
+		return tree.view.Load().root
+	}
+
+	return tree.root
+}
+
+// lock takes the write lock of the tree for a mutation. It fails with ErrReadOnly,
+// without taking the lock, if the tree is a snapshot.
+func (tree *HRtree) lock() error {
+	if tree.frozen {
+		return ErrReadOnly
+	}
+
+	tree.mu.Lock()
+	return nil
+}
+
+// unlock publishes the tree with WithSnapshotReads, then releases the write lock.
+func (tree *HRtree) unlock() {
+	tree.publish()
+	tree.mu.Unlock()
+}
+
+// publish installs a copy of the tree for searches if set with WithSnapshotReads, and
+// writes its changes to the store of WithNodeStore.
+func (tree *HRtree) publish() {
+	if tree.cow {
+		tree.view.Store(&published{tree.root.publish(), tree.size})
+	}
+
+	if tree.store != nil {
+		tree.flush()
+	}
+}
+
+// publish returns the published copy of n, made again if n or a node under it changed
+// since the last one. The copy holds no sibling or parent links, which searches don't use.
+func (n *node) publish() *node {
+	if n.pub != nil {
+		return n.pub
+	}
+
+	entries := make([]entry, n.entries.len())
+	copy(entries, n.getEntries())
+	if !n.leaf {
+		for i := range entries {
+			entries[i].node = entries[i].node.publish()
+		}
+	}
+
+	n.pub = &node{
+		min:     n.min,
+		max:     n.max,
+		leaf:    n.leaf,
+		entries: &entryList{entries: entries},
+		lhv:     n.lhv,
+		bb:      n.bb,
+		layers:  n.layers,
+		attrs:   n.attrs,
+	}
+
+	return n.pub
+}
+
+// touch drops the published copies of n and its ancestors, which no longer match, and
+// marks their pages as outdated.
+func (n *node) touch() {
+	for ; n != nil && (n.pub != nil || n.stored); n = n.parent {
+		n.pub, n.stored = nil, false
+	}
+}
+
+// config returns an empty tree with the options of tree, sharing its hilbert curve and
+// keys, and with an allocator of entries of its own. The node store and checkpoints of
+// tree, which write to its files, are left out.
+func (tree *HRtree) config() *HRtree {
+	c := &HRtree{
+		min:        tree.min,
+		max:        tree.max,
+		bits:       tree.bits,
+		hf:         tree.hf,
+		lut:        tree.lut,
+		keyFunc:    tree.keyFunc,
+		curve:      tree.curve,
+		space:      tree.space,
+		weights:    tree.weights,
+		transform:  tree.transform,
+		wrap:       tree.wrap,
+		refine:     tree.refine,
+		equal:      tree.equal,
+		codec:      tree.codec,
+		underflow:  tree.underflow,
+		policy:     tree.policy,
+		duplicates: tree.duplicates,
+		lazy:       tree.lazy,
+		concurrent: tree.concurrent,
+		progress:   tree.progress,
+		labels:     tree.labels,
+		cow:        tree.cow,
+	}
+
+	if tree.arena != nil {
+		c.arena = &entryArena{size: tree.arena.size}
+	}
+	c.root = c.allocNode()
+	c.root.leaf = true
+	c.publish()
+
+	return c
+}
+
+// Snapshot returns a read-only tree holding the objects of the tree as they are, which
+// later mutations leave unchanged, so that queries can run against a consistent version
+// while the tree is being updated. Snapshots share their nodes with each other and with
+// the copies of WithSnapshotReads: only the nodes modified since the last snapshot are
+// copied. Mutations of a snapshot fail with ErrReadOnly.
+func (tree *HRtree) Snapshot() *HRtree {
+	if tree.frozen {
+		return tree
+	}
+
+	// the published copies are shared, nothing else changes: the tree isn't published again.
+	tree.mu.Lock()
+	defer tree.mu.Unlock()
+
+	snap := tree.config()
+	snap.root = tree.root.publish()
+	snap.size = tree.size
+	snap.tombstones = tree.tombstones
+	snap.versions = maps.Clone(tree.versions)
+	snap.frozen = true
+	if snap.cow {
+		snap.view.Store(&published{snap.root, snap.size})
+	}
+
+	return snap
+}
@@ -0,0 +1,272 @@
+package hrtree
+
+import (
+	"errors"
+	"math/rand"
+	"sync"
+	"testing"
+)
+
+// checkPublished compares the searches of the published view of rt with those of its nodes.
+func checkPublished(t *testing.T, rt *HRtree, windows ...Rectangle) {
+	t.Helper()
+
+	if rt.Size() != rt.size {
+		t.Fatalf("expected %d published objects, got %d", rt.size, rt.Size())
+	}
+
+	for _, bb := range windows {
+		published := rt.SearchIntersect(bb)
+		current := searchFrom(rt, rt.root, bb)
+		if len(published) != len(current) {
+			t.Fatalf("expected %d published results, got %d", len(current), len(published))
+		}
+
+		for i := range current {
+			if published[i] != current[i] {
+				t.Fatalf("expected %v, got %v", current[i], published[i])
+			}
+		}
+	}
+}
+
+// searchFrom returns the objects under n intersecting bb, n being the root of any version
+// of tree.
+func searchFrom(tree *HRtree, n *node, bb Rectangle) []Rectangle {
+	results := []Rectangle{}
+	tree.visitIntersect(n, bb, nil, &anyQuery, func(obj Rectangle) bool {
+		results = append(results, obj)
+		return true
+	})
+
+	return results
+}
+
+func TestSnapshotReads(t *testing.T) {
+	for _, lazy := range []bool{false, true} {
+		opts := []Option{WithSnapshotReads()}
+		if lazy {
+			opts = append(opts, WithLazyDelete())
+		}
+
+		r := rand.New(rand.NewSource(1))
+		rt, _ := NewTree(2, 6, 12, opts...)
+		var list []Rectangle
+		all := rect(Point{0, 0}, Point{1 << 20, 1 << 20})
+
+		for i := 0; i < 3000; i++ {
+			// lower and upper x coordinates are unique to each object.
+			x, y := uint64(3*i), uint64(r.Intn(1000))
+			obj := rect(Point{x, y}, Point{x + 1, y + 1})
+
+			switch op := r.Intn(10); {
+			case op < 6 || len(list) == 0:
+				rt.Insert(obj)
+				list = append(list, obj)
+			case op < 8:
+				j := r.Intn(len(list))
+				rt.Delete(list[j])
+				list = append(list[:j], list[j+1:]...)
+			case op < 9:
+				j := r.Intn(len(list))
+				moved := rect(Point{x, y}, Point{x + 1, y + 2})
+				rt.Update(list[j], moved)
+				list[j] = moved
+			default:
+				rt.Vacuum()
+			}
+
+			if i%50 == 0 {
+				wx, wy := uint64(r.Intn(9000)), uint64(r.Intn(1000))
+				checkPublished(t, rt, all, rect(Point{wx, wy}, Point{wx + 500, wy + 200}))
+			}
+		}
+		checkPublished(t, rt, all)
+
+		rt.Compact()
+		checkPublished(t, rt, all)
+
+		rt.Clear()
+		checkPublished(t, rt, all)
+	}
+}
+
+func TestSnapshotReadsSharing(t *testing.T) {
+	rt, _ := NewTree(2, 4, 12, WithSnapshotReads())
+	for i := 0; i < 500; i++ {
+		rt.Insert(rect(Point{uint64(i), 0}, Point{uint64(i + 1), 1}))
+	}
+
+	before := rt.readRoot()
+	rt.Insert(rect(Point{1000, 0}, Point{1001, 1}))
+	after := rt.readRoot()
+
+	if before == after {
+		t.Fatalf("expected a new version to be published")
+	}
+
+	// the first subtree is left untouched by an insertion at the far right.
+	if before.entries.get(0).node != after.entries.get(0).node {
+		t.Errorf("expected unmodified nodes to be shared between versions")
+	}
+
+	if n := len(searchFrom(rt, before, rect(Point{0, 0}, Point{2000, 2}))); n != 500 {
+		t.Errorf("expected the previous version to hold 500 objects, got %d", n)
+	}
+}
+
+func TestSnapshotReadsConcurrency(t *testing.T) {
+	rt, _ := NewTree(2, 6, 12, WithSnapshotReads())
+	bb := rect(Point{0, 0}, Point{4000, 4000})
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 2000; i++ {
+			x := uint64(2 * i)
+			obj := rect(Point{x, x % 100}, Point{x + 1, x%100 + 1})
+			rt.Insert(obj)
+			if i%3 == 0 {
+				rt.Delete(obj)
+			}
+		}
+	}()
+
+	for w := 0; w < 4; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 200; i++ {
+				rt.SearchIntersect(bb)
+				rt.NearestNeighbor(Point{uint64(i), 0})
+				for range rt.Intersecting(bb, WithLimit(5)) {
+				}
+				rt.SearchIntersectPooled(bb).Release()
+
+				// reads of the tree itself still hold the lock.
+				rt.Stats()
+				rt.Lookup("a")
+			}
+		}()
+	}
+	wg.Wait()
+
+	if n := len(rt.SearchIntersect(bb)); n != rt.Size() || n != 1333 {
+		t.Errorf("expected 1333 objects, got %d", n)
+	}
+}
+
+func TestSnapshot(t *testing.T) {
+	for _, cow := range []bool{false, true} {
+		var opts []Option
+		if cow {
+			opts = append(opts, WithSnapshotReads())
+		}
+		rt, _ := NewTree(2, 4, 12, opts...)
+		for i := 0; i < 300; i++ {
+			rt.Insert(rect(Point{uint64(i), 0}, Point{uint64(i + 1), 1}))
+		}
+		rt.Insert(position("a", 1, 5, 5))
+
+		bb := rect(Point{0, 0}, Point{1000, 1000})
+		snap := rt.Snapshot()
+		want := snap.SearchIntersect(bb)
+
+		for i := 0; i < 300; i += 2 {
+			rt.Delete(rect(Point{uint64(i), 0}, Point{uint64(i + 1), 1}))
+		}
+		rt.Insert(position("a", 2, 6, 6))
+		rt.Compact()
+
+		got := snap.SearchIntersect(bb)
+		if len(got) != len(want) || snap.Size() != 301 {
+			t.Errorf("expected the snapshot to hold 301 objects, got %d", len(got))
+		}
+
+		for i := range want {
+			if got[i] != want[i] {
+				t.Errorf("expected %v, got %v", want[i], got[i])
+			}
+		}
+
+		if obj, _ := snap.Lookup("a"); obj.(*vehicle).version != 1 {
+			t.Errorf("expected the first version in the snapshot")
+		}
+
+		if later := rt.Snapshot(); later.Size() != 151 || snap.Snapshot() != snap {
+			t.Errorf("expected snapshots to follow the tree")
+		}
+
+		obj := rect(Point{1, 1}, Point{2, 2})
+		_, verr := snap.Vacuum()
+		_, derr := snap.Delete(obj)
+		for i, err := range []error{snap.Insert(obj), derr, snap.Compact(), snap.Clear(), snap.ReplaceAll(nil), verr} {
+			if !errors.Is(err, ErrReadOnly) {
+				t.Errorf("mutation %d: expected %v, got %v", i, ErrReadOnly, err)
+			}
+		}
+
+		if snap.Size() != 301 {
+			t.Errorf("expected the snapshot to be left unchanged")
+		}
+
+		if snap.cow != cow || snap.concurrent || snap.duplicates != DuplicateAllow {
+			t.Errorf("expected the snapshot to keep the options of the tree")
+		}
+	}
+
+	rt, _ := NewTree(2, 4, 12, WithConcurrency(), WithDuplicatePolicy(DuplicateReject), WithArena(0), WithProgress(func(Progress) {}))
+	rt.Insert(rect(Point{1, 1}, Point{2, 2}))
+	if snap := rt.Snapshot(); !snap.concurrent || snap.duplicates != DuplicateReject || snap.arena == nil || snap.progress == nil || snap.Size() != 1 {
+		t.Errorf("expected the snapshot to keep the options of the tree")
+	}
+}
+
+func TestPinnedSnapshot(t *testing.T) {
+	for _, cow := range []bool{false, true} {
+		opts := []Option{WithConcurrency()}
+		if cow {
+			opts = append(opts, WithSnapshotReads())
+		}
+		rt, _ := NewTree(2, 4, 12, opts...)
+		want := make(map[Rectangle]bool)
+		for i := 0; i < 500; i++ {
+			obj := rect(Point{uint64(i), 0}, Point{uint64(i + 1), 1})
+			rt.Insert(obj)
+			want[obj] = true
+		}
+		bb := rect(Point{0, 0}, Point{4000, 10})
+
+		// each object found is replaced by another one, further along the window.
+		found := make(map[Rectangle]int)
+		i := 1000
+		for obj := range rt.Intersecting(bb, WithPinnedSnapshot()) {
+			found[obj]++
+			rt.Delete(obj)
+			rt.Insert(rect(Point{uint64(i), 5}, Point{uint64(i + 1), 6}))
+			i++
+		}
+
+		for obj, d := range rt.NearestWithin(Point{0, 0}, 1e9, WithPinnedSnapshot()) {
+			if d < 5 {
+				t.Errorf("expected the objects of the tree as the search started, got %v", obj)
+			}
+			rt.Delete(obj)
+		}
+
+		if len(found) != len(want) {
+			t.Errorf("expected %d objects, got %d", len(want), len(found))
+		}
+
+		for obj, n := range found {
+			if !want[obj] || n != 1 {
+				t.Errorf("expected %v to be found once, got it %d times", obj, n)
+			}
+		}
+
+		if err := rt.CheckInvariants(); err != nil || rt.Size() != 0 {
+			t.Errorf("expected an empty tree, got %d objects, %v", rt.Size(), err)
+		}
+	}
+}
@@ -0,0 +1,21 @@
+package hrtree
+
+// Curve maps points to their position along a space-filling curve, which orders the
+// objects of a tree: objects with close keys share nodes, so the curve decides how
+// tightly nodes cluster. Encode is given the Dim coordinates of the center of an object,
+// scaled by WithWeights, and must return the same key for the same coordinates. Trees
+// whose keys are wider than Dim*8 bytes can't be written to pages or tree files, their
+// writers fail with ErrKeyTooWide.
+type Curve interface {
+	Encode(coords ...uint64) Key
+}
+
+// WithCurve orders objects along c instead of the hilbert curve of the resolution of the
+// tree, such as a Z-order or Gray-code curve, whose clustering may suit a dataset better.
+// Searches are not affected. WithKeyFunc takes precedence over c, and trees read with
+// ReadPages keep the stored keys and should be given the same c.
+func WithCurve(c Curve) Option {
+	return func(tree *HRtree) {
+		tree.curve = c
+	}
+}
@@ -0,0 +1,94 @@
+package hrtree
+
+import (
+	"errors"
+	"io"
+	"math/big"
+	"testing"
+)
+
+// columns orders points by their first coordinate, then by the others.
+type columns struct{}
+
+func (columns) Encode(coords ...uint64) Key {
+	return Uint128Key(coords[0], coords[1])
+}
+
+func TestCurve(t *testing.T) {
+	rt, _ := NewTree(2, 4, 12, WithCurve(columns{}))
+	for i := uint64(0); i < 200; i++ {
+		x, y := (i*37)%200, i%7
+		rt.Insert(rect(Point{x, y}, Point{x, y}))
+	}
+
+	if err := rt.CheckInvariants(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	var last uint64
+	for _, leaf := range rt.root.leaves(nil) {
+		for _, e := range leaf.getEntries() {
+			p := e.obj.LowerLeft()
+			if e.h.cmp(hkey{hi: p[0], lo: p[1]}) != 0 {
+				t.Errorf("expected the key of %v along the curve, got %v", e.obj, e.h)
+			}
+
+			if p[0] < last {
+				t.Errorf("expected leaves ordered by x")
+			}
+			last = p[0]
+		}
+	}
+
+	if q := rt.SearchIntersect(rect(Point{10, 0}, Point{19, 10})); len(q) != 10 {
+		t.Errorf("expected 10 results, got %d", len(q))
+	}
+
+	rt.ReplaceAll(rt.SearchIntersect(rect(Point{0, 0}, Point{199, 10})))
+	if k := rt.root.leaves(nil)[0].entries.get(0).h; k.cmp(hkey{}) != 0 {
+		t.Errorf("expected the first key of the bulk load to be 0, got %v", k)
+	}
+}
+
+// wide maps every point to a key too wide for pages.
+type wide struct{}
+
+func (wide) Encode(coords ...uint64) Key {
+	return BigKey(new(big.Int).Lsh(new(big.Int).SetUint64(coords[0]+1), 300))
+}
+
+func TestCurveKeyTooWide(t *testing.T) {
+	rt, _ := NewTree(2, 4, 12, WithCurve(wide{}))
+	for i := uint64(0); i < 20; i++ {
+		rt.Insert(rect(Point{i, i}, Point{i, i}))
+	}
+
+	if err := rt.WritePages(io.Discard, MinPageSize); !errors.Is(err, ErrKeyTooWide) {
+		t.Errorf("expected %v, got %v", ErrKeyTooWide, err)
+	}
+
+	if _, err := rt.WriteTo(io.Discard); !errors.Is(err, ErrKeyTooWide) {
+		t.Errorf("expected %v, got %v", ErrKeyTooWide, err)
+	}
+
+	stored, _ := NewTree(2, 4, 12, WithCurve(wide{}), WithNodeStore(NewMemoryStore(MinPageSize)))
+	stored.Insert(rect(Point{1, 1}, Point{1, 1}))
+	if err := stored.Sync(); !errors.Is(err, ErrKeyTooWide) {
+		t.Errorf("expected %v, got %v", ErrKeyTooWide, err)
+	}
+}
+
+func TestKeyConstructors(t *testing.T) {
+	big1 := new(big.Int).Lsh(big.NewInt(1), 130)
+	keys := []Key{Uint64Key(1), Uint128Key(1, 0), BigKey(new(big.Int).Lsh(big.NewInt(1), 65)), BigKey(big1)}
+
+	for i := 1; i < len(keys); i++ {
+		if keys[i-1].Cmp(keys[i]) >= 0 {
+			t.Errorf("expected %v < %v", keys[i-1], keys[i])
+		}
+	}
+
+	if keys[1].String() != "18446744073709551616" || keys[3].Int().Cmp(big1) != 0 {
+		t.Errorf("expected 2^64 and 2^130, got %v and %v", keys[1], keys[3])
+	}
+}
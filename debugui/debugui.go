@@ -0,0 +1,187 @@
+// Package debugui serves an interactive page rendering the structure of a running
+// tree: the bounding-boxes of its nodes level by level, the details of a node on
+// click and live statistics, refreshed every second.
+//
+// The handler only exposes the structure of the tree (see MarshalStructureJSON),
+// never the stored objects. It can be mounted under any prefix:
+//
+//	http.Handle("/debug/hrtree/", http.StripPrefix("/debug/hrtree", debugui.Handler(tree)))
+package debugui
+
+import (
+	"net/http"
+
+	"github.com/jtejido/hrtree"
+)
+
+// Handler returns a handler serving the debugger page at "/" and the structure of
+// tree at "/structure.json".
+func Handler(tree *hrtree.HRtree) http.Handler {
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/" {
+			http.NotFound(w, r)
+			return
+		}
+
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Write([]byte(page))
+	})
+
+	mux.HandleFunc("/structure.json", func(w http.ResponseWriter, r *http.Request) {
+		data, err := tree.MarshalStructureJSON()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", "no-store")
+		w.Write(data)
+	})
+
+	return mux
+}
+
+const page = `<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>hrtree debugger</title>
+<style>
+body { font: 13px monospace; margin: 0; display: flex; height: 100vh; }
+#view { flex: 1; position: relative; }
+canvas { position: absolute; width: 100%; height: 100%; }
+#side { width: 320px; padding: 8px; overflow: auto; border-left: 1px solid #ccc; }
+pre { white-space: pre-wrap; }
+</style>
+</head>
+<body>
+<div id="view"><canvas id="canvas"></canvas></div>
+<div id="side">
+<label>level <select id="level"><option value="-1">all</option></select></label>
+<label><input type="checkbox" id="live" checked> live</label>
+<h4>stats</h4><pre id="stats"></pre>
+<h4>node</h4><pre id="node">click a node</pre>
+</div>
+<script>
+var doc = null, selected = null; // selected is the level and LHV of the inspected node
+var canvas = document.getElementById("canvas"), ctx = canvas.getContext("2d");
+var levelSelect = document.getElementById("level");
+var colors = ["#1f77b4", "#ff7f0e", "#2ca02c", "#d62728", "#9467bd", "#8c564b", "#e377c2"];
+
+function nodes(n, out) {
+	out.push(n);
+	(n.children || []).forEach(function (c) { nodes(c, out); });
+	return out;
+}
+
+function transform() {
+	var bb = doc.root.mbr, w = canvas.width, h = canvas.height, m = 10;
+	var dx = Math.max(bb.upperRight[0] - bb.lowerLeft[0], 1);
+	var dy = Math.max(bb.upperRight[1] - bb.lowerLeft[1], 1);
+	var s = Math.min((w - 2 * m) / dx, (h - 2 * m) / dy);
+	return {
+		x: function (v) { return m + (v - bb.lowerLeft[0]) * s; },
+		y: function (v) { return h - m - (v - bb.lowerLeft[1]) * s; },
+		inv: function (px, py) { return [bb.lowerLeft[0] + (px - m) / s, bb.lowerLeft[1] + (h - m - py) / s]; }
+	};
+}
+
+function visible(n) {
+	var level = +levelSelect.value;
+	return n.mbr && (level < 0 || n.level === level);
+}
+
+function draw() {
+	canvas.width = canvas.clientWidth;
+	canvas.height = canvas.clientHeight;
+	ctx.clearRect(0, 0, canvas.width, canvas.height);
+	if (!doc || !doc.root.mbr) {
+		return;
+	}
+
+	var t = transform();
+	nodes(doc.root, []).filter(visible).forEach(function (n) {
+		var x = t.x(n.mbr.lowerLeft[0]), y = t.y(n.mbr.upperRight[1]);
+		ctx.strokeStyle = colors[n.level % colors.length];
+		ctx.lineWidth = key(n) === selected ? 3 : 1;
+		ctx.strokeRect(x, y, Math.max(t.x(n.mbr.upperRight[0]) - x, 1), Math.max(t.y(n.mbr.lowerLeft[1]) - y, 1));
+	});
+}
+
+function stats() {
+	var levels = [];
+	nodes(doc.root, []).forEach(function (n) {
+		var l = levels[n.level] || (levels[n.level] = {nodes: 0, entries: 0});
+		l.nodes++;
+		l.entries += n.entries;
+	});
+
+	var s = "objects " + doc.size + "\nheight  " + doc.height + "\n";
+	for (var i = levels.length - 1; i >= 0; i--) {
+		s += "level " + i + ": " + levels[i].nodes + " nodes, " + (levels[i].entries / levels[i].nodes).toFixed(1) + " entries/node\n";
+	}
+	document.getElementById("stats").textContent = s;
+
+	if (levelSelect.options.length !== doc.height + 1) {
+		var value = levelSelect.value;
+		levelSelect.length = 1;
+		for (var j = doc.height - 1; j >= 0; j--) {
+			levelSelect.add(new Option(String(j), String(j)));
+		}
+		levelSelect.value = value < doc.height ? value : "-1";
+	}
+}
+
+function key(n) {
+	return n.level + ":" + n.lhv;
+}
+
+function inspect(n) {
+	selected = n && key(n);
+	document.getElementById("node").textContent = n ? JSON.stringify({
+		level: n.level, mbr: n.mbr, lhv: n.lhv, entries: n.entries, objects: n.objects
+	}, null, 1) : "click a node";
+	draw();
+}
+
+canvas.addEventListener("click", function (ev) {
+	if (!doc || !doc.root.mbr) {
+		return;
+	}
+
+	var p = transform().inv(ev.offsetX, ev.offsetY), best = null, area = Infinity;
+	nodes(doc.root, []).filter(visible).forEach(function (n) {
+		var ll = n.mbr.lowerLeft, ur = n.mbr.upperRight;
+		var a = (ur[0] - ll[0]) * (ur[1] - ll[1]);
+		if (p[0] >= ll[0] && p[0] <= ur[0] && p[1] >= ll[1] && p[1] <= ur[1] && a < area) {
+			best = n;
+			area = a;
+		}
+	});
+	inspect(best);
+});
+
+levelSelect.addEventListener("change", draw);
+window.addEventListener("resize", draw);
+
+function refresh() {
+	fetch("structure.json").then(function (r) { return r.json(); }).then(function (d) {
+		doc = d;
+		stats();
+		draw();
+	});
+}
+
+refresh();
+setInterval(function () {
+	if (document.getElementById("live").checked) {
+		refresh();
+	}
+}, 1000);
+</script>
+</body>
+</html>
+`
@@ -0,0 +1,58 @@
+package debugui
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/jtejido/hrtree"
+)
+
+func TestHandler(t *testing.T) {
+	tree := hrtree.MustNewTree(2, 4, 12)
+	for i := uint64(0); i < 20; i++ {
+		tree.Insert(hrtree.MustNewRect(hrtree.Point{i, i}, hrtree.Point{i + 1, i + 1}))
+	}
+
+	srv := httptest.NewServer(http.StripPrefix("/debug", Handler(tree)))
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL + "/debug/")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK || !strings.HasPrefix(resp.Header.Get("Content-Type"), "text/html") {
+		t.Errorf("expected the debugger page, got %s %s", resp.Status, resp.Header.Get("Content-Type"))
+	}
+
+	resp, err = http.Get(srv.URL + "/debug/structure.json")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer resp.Body.Close()
+
+	var doc struct {
+		Size int `json:"size"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&doc); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if doc.Size != 20 {
+		t.Errorf("expected 20 objects, got %d", doc.Size)
+	}
+
+	resp, err = http.Get(srv.URL + "/debug/missing")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusNotFound {
+		t.Errorf("expected %d, got %d", http.StatusNotFound, resp.StatusCode)
+	}
+}
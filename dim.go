@@ -0,0 +1,15 @@
+//go:build !hrtree3d
+
+package hrtree
+
+// The number of dimensions is fixed at build time rather than chosen per tree: Point is an
+// array of Dim coordinates, which objects return by value through Rectangle and entries
+// store inline, so that points are copied and compared without allocating. A dimension of
+// each tree would make points slices, allocated by every call of LowerLeft and UpperRight
+// and checked for their length by every comparison, or duplicate all types and methods
+// for 3 dimensions.
+const (
+	Dim = 2 // number of dimensions, 3 when built with the hrtree3d tag
+
+	pageMagic = "HRTP"
+)
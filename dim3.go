@@ -0,0 +1,13 @@
+//go:build hrtree3d
+
+package hrtree
+
+// Built with the hrtree3d tag, trees hold boxes in three dimensions: points have x, y and
+// z coordinates, objects are ordered along a 3-dimensional hilbert curve and searches
+// test boxes on the three axes. Paged files of 2 and 3-dimensional builds can't be read
+// by one another, reading fails with ErrDimensionMismatch.
+const (
+	Dim = 3
+
+	pageMagic = "HRT3"
+)
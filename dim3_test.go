@@ -0,0 +1,83 @@
+//go:build hrtree3d
+
+package hrtree
+
+import (
+	"bytes"
+	"math/rand"
+	"testing"
+)
+
+func box(x, y, z, size uint64) *rectangle {
+	return rect(Point{x, y, z}, Point{x + size, y + size, z + size})
+}
+
+func TestTree3D(t *testing.T) {
+	rt, _ := NewTree(2, 8, 12)
+	r := rand.New(rand.NewSource(1))
+
+	var objs []*rectangle
+	for i := 0; i < 500; i++ {
+		obj := box(uint64(i), uint64(r.Intn(64)), uint64(r.Intn(64)), 2)
+		objs = append(objs, obj)
+		rt.Insert(obj)
+	}
+
+	// boxes overlapping on x and y only are left out.
+	window := rect(Point{100, 10, 10}, Point{200, 40, 20})
+	want := 0
+	for _, obj := range objs {
+		if intersect(obj, window) {
+			want++
+		}
+	}
+
+	if got := len(rt.SearchIntersect(window)); got != want || want == 0 {
+		t.Errorf("expected %d objects, got %d", want, got)
+	}
+
+	for _, obj := range objs[:250] {
+		rt.Delete(obj)
+	}
+
+	if rt.Size() != 250 {
+		t.Errorf("expected 250 objects, got %d", rt.Size())
+	}
+
+	if got := rt.SearchIntersect(box(0, 0, 0, 1000)); len(got) != 250 {
+		t.Errorf("expected 250 objects, got %d", len(got))
+	}
+}
+
+func TestIntersect3D(t *testing.T) {
+	a := box(0, 0, 0, 10)
+
+	if !intersect(a, box(5, 5, 5, 10)) {
+		t.Errorf("expected overlapping boxes to intersect")
+	}
+
+	if intersect(a, box(5, 5, 11, 10)) {
+		t.Errorf("expected boxes apart on z not to intersect")
+	}
+}
+
+func TestWriteReadPages3D(t *testing.T) {
+	rt, _ := NewTree(4, MaxEntriesForPage(MinPageSize), 12)
+	for i := 0; i < 300; i++ {
+		rt.Insert(box(uint64(i%10), uint64(i/10%10), uint64(i/100), 1))
+	}
+
+	var buf bytes.Buffer
+	if err := rt.WritePages(&buf, MinPageSize); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	loaded, err := ReadPages(bytes.NewReader(buf.Bytes()))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if got := loaded.SearchIntersect(rect(Point{0, 0, 3}, Point{20, 20, 20})); len(got) != 100 {
+		t.Errorf("expected 100 objects, got %d", len(got))
+	}
+}
@@ -0,0 +1,80 @@
+package hrtree
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+)
+
+// WriteDOT writes the structure of the tree to w in the DOT language of Graphviz, leaving
+// the objects out. Each node is labelled with the number of its entries, its bounding-box
+// and the range of hilbert values under it, from the smallest one to its LHV, so that the
+// shape left by splits and underflows can be looked at while debugging, such as with:
+//
+//	dot -Tsvg tree.dot > tree.svg
+func (tree *HRtree) WriteDOT(w io.Writer) error {
+	if tree.rlockTree() {
+		defer tree.runlock()
+	}
+
+	bw := bufio.NewWriter(w)
+	fmt.Fprintln(bw, "digraph hrtree {")
+	fmt.Fprintln(bw, "\tnode [shape=box, fontname=monospace];")
+
+	// nodes are numbered breadth first, as they are queued.
+	queue := []*node{tree.root}
+	for id := 0; id < len(queue); id++ {
+		n := queue[id]
+		fmt.Fprintf(bw, "\tn%d [label=\"%s\"];\n", id, n.describe(`\n`))
+		if n.leaf {
+			continue
+		}
+
+		for _, e := range n.getEntries() {
+			fmt.Fprintf(bw, "\tn%d -> n%d;\n", id, len(queue))
+			queue = append(queue, e.node)
+		}
+	}
+	fmt.Fprintln(bw, "}")
+
+	if err := bw.Flush(); err != nil {
+		return fmt.Errorf("WriteDOT: %w", err)
+	}
+
+	return nil
+}
+
+// describe returns the kind of n, its number of entries, bounding-box and range of
+// hilbert values, separated by sep.
+func (n *node) describe(sep string) string {
+	kind := "node"
+	if n.leaf {
+		kind = "leaf"
+	}
+
+	lo, ok := n.lowest()
+	if !ok {
+		return fmt.Sprintf("%s, empty", kind)
+	}
+
+	return fmt.Sprintf("%s, %d entries%s%v%shilbert %v..%v", kind, n.entries.len(), sep, n.bb, sep, lo, n.lhv)
+}
+
+// lowest returns the smallest hilbert value under n, found in its leftmost leaf that
+// isn't empty, or false if there is none.
+func (n *node) lowest() (hkey, bool) {
+	if n.leaf {
+		if n.entries.len() == 0 {
+			return hkey{}, false
+		}
+		return n.entries.get(0).h, true
+	}
+
+	for _, e := range n.getEntries() {
+		if h, ok := e.node.lowest(); ok {
+			return h, true
+		}
+	}
+
+	return hkey{}, false
+}
@@ -0,0 +1,44 @@
+package hrtree
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestWriteDOT(t *testing.T) {
+	rt, _ := NewTree(2, 4, 12)
+
+	var buf bytes.Buffer
+	if err := rt.WriteDOT(&buf); err != nil || !strings.Contains(buf.String(), `n0 [label="leaf, empty"];`) {
+		t.Errorf("expected a single empty leaf, got %v and %q", err, buf.String())
+	}
+
+	for i := uint64(0); i < 100; i++ {
+		rt.Insert(rect(Point{3 * i, 10}, Point{3*i + 1, 11}))
+	}
+
+	buf.Reset()
+	if err := rt.WriteDOT(&buf); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	out := buf.String()
+	if !strings.HasPrefix(out, "digraph hrtree {\n") || !strings.HasSuffix(out, "}\n") {
+		t.Errorf("expected a digraph, got %q", out)
+	}
+
+	nodes, leaves := strings.Count(out, "[label="), strings.Count(out, `[label="leaf`)
+	if edges := strings.Count(out, " -> "); edges != nodes-1 || leaves != len(rt.root.leaves(nil)) {
+		t.Errorf("expected a tree of %d leaves, got %d nodes, %d leaves and %d edges", len(rt.root.leaves(nil)), nodes, leaves, edges)
+	}
+
+	// dimensions past the second are left at 0.
+	lo, _ := rt.root.lowest()
+	span := `[0, 298]x[10, 11]` + strings.Repeat(`x[0, 0]`, Dim-2)
+	root := `n0 [label="node, ` + fmt.Sprint(rt.root.entries.len()) + ` entries\n` + span + `\nhilbert ` + lo.String() + ".." + rt.root.lhv.String()
+	if !strings.Contains(out, root) {
+		t.Errorf("expected the root to span all objects, got %q", out)
+	}
+}
@@ -0,0 +1,121 @@
+package hrtree
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// dumpRecordSize is the size of a record of the raw dump format, see Export.
+const dumpRecordSize = 2 * Dim * 8
+
+// Export writes the objects of the tree in a raw dump format meant for exchanging data
+// with other programs and languages: a little-endian uint64 count of records, followed by
+// a record per object holding its lower left then upper right coordinates, each a
+// little-endian uint64. Objects are written in hilbert order, and read back as plain
+// rectangles by Import.
+func (tree *HRtree) Export(w io.Writer) error {
+	if tree.rlockTree() {
+		defer tree.runlock()
+	}
+
+	bw := bufio.NewWriter(w)
+
+	var buf [dumpRecordSize]byte
+	binary.LittleEndian.PutUint64(buf[:], uint64(tree.size))
+	if _, err := bw.Write(buf[:8]); err != nil {
+		return fmt.Errorf("Export: %w", err)
+	}
+
+	for _, leaf := range tree.root.leaves(nil) {
+		for _, e := range leaf.getEntries() {
+			if e.dead {
+				continue
+			}
+
+			for i := 0; i < Dim; i++ {
+				binary.LittleEndian.PutUint64(buf[8*i:], e.bb.lowerLeft[i])
+				binary.LittleEndian.PutUint64(buf[8*(Dim+i):], e.bb.upperRight[i])
+			}
+
+			if _, err := bw.Write(buf[:]); err != nil {
+				return fmt.Errorf("Export: %w", err)
+			}
+		}
+	}
+
+	if err := bw.Flush(); err != nil {
+		return fmt.Errorf("Export: %w", err)
+	}
+
+	return nil
+}
+
+// Import reads a raw dump written by Export, or by any other program following its
+// format, and returns a tree packed with its rectangles (see NewTreeFrom).
+func Import(r io.Reader, opts ...Option) (*HRtree, error) {
+	br := bufio.NewReader(r)
+
+	var buf [dumpRecordSize]byte
+	if _, err := io.ReadFull(br, buf[:8]); err != nil {
+		return nil, fmt.Errorf("Import: count: %w", err)
+	}
+	count := binary.LittleEndian.Uint64(buf[:])
+
+	// the count isn't trusted to size the slice up front, a corrupt one would exhaust memory.
+	objs := make([]Rectangle, 0, min(count, 1<<16))
+	for i := uint64(0); i < count; i++ {
+		if _, err := io.ReadFull(br, buf[:]); err != nil {
+			if err == io.EOF {
+				err = io.ErrUnexpectedEOF
+			}
+
+			return nil, fmt.Errorf("Import: record %d: %w", i, err)
+		}
+
+		r := &rectangle{}
+		for j := 0; j < Dim; j++ {
+			r.lowerLeft[j] = binary.LittleEndian.Uint64(buf[8*j:])
+			r.upperRight[j] = binary.LittleEndian.Uint64(buf[8*(Dim+j):])
+		}
+		objs = append(objs, r)
+	}
+
+	tree, err := NewTreeFrom(objs, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("Import: %w", err)
+	}
+
+	return tree, nil
+}
+
+// Dump writes the nodes of the tree to w as indented text, a line per node giving the
+// number of its entries, its bounding-box and the range of hilbert values under it, as
+// labelled by WriteDOT. Children follow their parent, indented one more level.
+func (tree *HRtree) Dump(w io.Writer) error {
+	if tree.rlockTree() {
+		defer tree.runlock()
+	}
+
+	bw := bufio.NewWriter(w)
+	var dump func(n *node, depth int)
+	dump = func(n *node, depth int) {
+		fmt.Fprintf(bw, "%s%s\n", strings.Repeat("  ", depth), n.describe(" "))
+		if n.leaf {
+			return
+		}
+
+		for _, e := range n.getEntries() {
+			dump(e.node, depth+1)
+		}
+	}
+	dump(tree.root, 0)
+
+	if err := bw.Flush(); err != nil {
+		return fmt.Errorf("Dump: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,104 @@
+package hrtree
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestExportImport(t *testing.T) {
+	rt, _ := NewTree(2, 4, 12, WithLazyDelete())
+	objs := make(map[Rectangle]bool)
+	for i := uint64(0); i < 200; i++ {
+		obj := rect(Point{3 * i, i % 17}, Point{3*i + 1, i%17 + 2})
+		rt.Insert(obj)
+		if i%10 != 0 {
+			objs[obj] = true
+		} else {
+			rt.Delete(obj)
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := rt.Export(&buf); err != nil {
+		t.Fatal(err)
+	}
+
+	if buf.Len() != 8+len(objs)*dumpRecordSize {
+		t.Errorf("expected %d bytes, got %d", 8+len(objs)*dumpRecordSize, buf.Len())
+	}
+
+	if n := binary.LittleEndian.Uint64(buf.Bytes()); n != uint64(len(objs)) {
+		t.Errorf("expected a count of %d, got %d", len(objs), n)
+	}
+
+	data := buf.Bytes()
+	imported, err := Import(bytes.NewReader(data), WithNodeEntries(2, 4))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if imported.Size() != len(objs) {
+		t.Errorf("expected %d objects, got %d", len(objs), imported.Size())
+	}
+
+	for _, obj := range imported.SearchIntersect(rect(Point{0, 0}, Point{1000, 1000})) {
+		found := false
+		for o := range objs {
+			if o.LowerLeft() == obj.LowerLeft() && o.UpperRight() == obj.UpperRight() {
+				found = true
+				break
+			}
+		}
+
+		if !found {
+			t.Errorf("unexpected object %v", obj)
+		}
+	}
+
+	if _, err := Import(bytes.NewReader(data[:len(data)-1])); !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Errorf("expected io.ErrUnexpectedEOF, got %v", err)
+	}
+
+	if _, err := Import(bytes.NewReader(nil)); !errors.Is(err, io.EOF) {
+		t.Errorf("expected io.EOF, got %v", err)
+	}
+}
+
+func TestDump(t *testing.T) {
+	rt, _ := NewTree(2, 4, 12)
+	for i := uint64(0); i < 30; i++ {
+		rt.Insert(rect(Point{3 * i, 10}, Point{3*i + 1, 11}))
+	}
+
+	var buf bytes.Buffer
+	if err := rt.Dump(&buf); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	lines := strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n")
+	if lines[0] != rt.root.describe(" ") || !strings.Contains(lines[0], "[0, 88]x[10, 11]") {
+		t.Errorf("expected the root first, got %q", lines[0])
+	}
+
+	leaves := rt.root.leaves(nil)
+	for _, line := range lines[1:] {
+		if !strings.HasPrefix(line, "  ") {
+			t.Errorf("expected children to be indented, got %q", line)
+		}
+
+		if strings.Contains(line, "leaf") {
+			if want := leaves[0].describe(" "); strings.TrimLeft(line, " ") != want {
+				t.Errorf("expected %q, got %q", want, line)
+			}
+			leaves = leaves[1:]
+		}
+	}
+
+	if len(leaves) != 0 {
+		t.Errorf("%d leaves missing", len(leaves))
+	}
+}
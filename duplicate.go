@@ -0,0 +1,56 @@
+package hrtree
+
+import (
+	"errors"
+)
+
+var ErrDuplicate = errors.New("Object equal to one already in the tree.")
+
+// DuplicatePolicy selects how insertions of objects equal to one already in the tree, as
+// compared by Delete (see WithEqualFunc), are handled.
+type DuplicatePolicy int
+
+const (
+	// DuplicateAllow inserts the object anyway, the tree then holding both. It is the
+	// default.
+	DuplicateAllow DuplicatePolicy = iota
+
+	// DuplicateReject leaves the tree unchanged, Insert returning an error wrapping
+	// ErrDuplicate.
+	DuplicateReject
+
+	// DuplicateReplace replaces the object of the tree with the inserted one, as with
+	// Upsert.
+	DuplicateReplace
+)
+
+// WithDuplicatePolicy sets how Insert, InsertAll and Ingester handle objects equal to one
+// already in the tree. Looking them up costs a search of the tree for each insertion,
+// unless they are allowed. Versioned objects supersede each other regardless, and bulk
+// loads, such as Load and ReplaceAll, don't look for duplicates.
+func WithDuplicatePolicy(p DuplicatePolicy) Option {
+	return func(tree *HRtree) {
+		tree.duplicates = p
+	}
+}
+
+// addObject inserts obj as set by WithDuplicatePolicy, the tree being locked by the
+// caller. It returns ErrDuplicate if obj is rejected.
+func (tree *HRtree) addObject(obj Rectangle) error {
+	if _, ok := obj.(Versioned); ok || tree.duplicates == DuplicateAllow {
+		tree.insertObject(obj)
+		return nil
+	}
+
+	if tree.duplicates == DuplicateReplace {
+		tree.upsert(obj)
+		return nil
+	}
+
+	if leaf, _ := tree.lookup(obj); leaf != nil {
+		return ErrDuplicate
+	}
+	tree.insertObject(obj)
+
+	return nil
+}
@@ -0,0 +1,46 @@
+package hrtree
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestDuplicatePolicy(t *testing.T) {
+	sameName := func(a, b Rectangle) bool {
+		return a.(*site).name == b.(*site).name
+	}
+
+	for _, policy := range []DuplicatePolicy{DuplicateAllow, DuplicateReject, DuplicateReplace} {
+		rt, _ := NewTree(2, 4, 12, WithDuplicatePolicy(policy), WithEqualFunc(sameName))
+		for i := uint64(0); i < 50; i++ {
+			rt.Insert(&site{string(rune('a'+i%26)) + string(rune('0'+i/26)), Point{10 * i, 10}})
+		}
+
+		again := &site{"c0", Point{20, 10}}
+		err := rt.Insert(again)
+		size := map[DuplicatePolicy]int{DuplicateAllow: 51, DuplicateReject: 50, DuplicateReplace: 50}[policy]
+		if (err != nil) != (policy == DuplicateReject) || rt.Size() != size {
+			t.Errorf("policy %v: unexpected error %v and size %d", policy, err, rt.Size())
+		}
+
+		if policy == DuplicateReject && !errors.Is(err, ErrDuplicate) {
+			t.Errorf("expected ErrDuplicate, got %v", err)
+		}
+
+		found := rt.SearchIntersect(rect(Point{20, 10}, Point{20, 10}))
+		if policy == DuplicateReplace && (len(found) != 1 || found[0] != again) {
+			t.Errorf("expected c0 to be replaced, got %v", found)
+		}
+
+		// duplicates among the inserted objects count as well.
+		err = rt.InsertAll([]Rectangle{&site{"z9", Point{5, 5}}, &site{"z9", Point{5, 5}}, &site{"d0", Point{30, 10}}})
+		size += map[DuplicatePolicy]int{DuplicateAllow: 3, DuplicateReject: 1, DuplicateReplace: 1}[policy]
+		if (err != nil) != (policy == DuplicateReject) || rt.Size() != size {
+			t.Errorf("policy %v: unexpected error %v and size %d, expected %d", policy, err, rt.Size(), size)
+		}
+
+		if err := rt.CheckInvariants(); err != nil {
+			t.Errorf("policy %v: unexpected error: %v", policy, err)
+		}
+	}
+}
@@ -0,0 +1,136 @@
+package hrtree
+
+import (
+	"errors"
+	"math"
+)
+
+var ErrGrid = errors.New("Grid bounds should be finite, with upper bounds greater than lower bounds, and its resolution between 1 and 64 bits.")
+
+// PointF is a point with float coordinates.
+type PointF [Dim]float64
+
+// Grid maps float coordinates within its bounds linearly to the cells of a hilbert curve of
+// the given resolution. Unlike Quantize, which preserves floats exactly but spreads them
+// over the whole 64-bit space, nearby points fall into nearby cells, so the curve keeps
+// the locality of the data at any resolution.
+type Grid struct {
+	min, scale PointF
+	bits       int
+	limit      uint64 // last cell of each dimension
+}
+
+// NewGrid returns the grid of the given resolution spanning min to max.
+func NewGrid(min, max PointF, bits int) (*Grid, error) {
+	if bits < 1 || bits > 64 {
+		return nil, ErrGrid
+	}
+
+	g := &Grid{min: min, bits: bits, limit: math.MaxUint64}
+	if bits < 64 {
+		g.limit = 1<<uint(bits) - 1
+	}
+
+	for i := range min {
+		extent := max[i] - min[i]
+		if !(extent > 0) || math.IsInf(extent, 0) {
+			return nil, ErrGrid
+		}
+		g.scale[i] = float64(g.limit) / extent
+	}
+
+	return g, nil
+}
+
+// Resolution returns the number of bits per dimension of trees storing objects of g.
+func (g *Grid) Resolution() int {
+	return g.bits
+}
+
+// cell maps coordinate v of dimension i to its cell, rounding up if up is set. Coordinates
+// out of the grid are clamped to it.
+func (g *Grid) cell(v float64, i int, up bool) uint64 {
+	c := (v - g.min[i]) * g.scale[i]
+	if up {
+		c = math.Ceil(c)
+	} else {
+		c = math.Floor(c)
+	}
+
+	switch {
+	case !(c > 0):
+		return 0
+	case c >= float64(g.limit):
+		return g.limit
+	}
+
+	return uint64(c)
+}
+
+// Cell returns the cell of p.
+func (g *Grid) Cell(p PointF) Point {
+	var c Point
+	for i, v := range p {
+		c[i] = g.cell(v, i, false)
+	}
+
+	return c
+}
+
+// Rect returns the rectangle of g with the given corners.
+func (g *Grid) Rect(min, max PointF) *RectF {
+	r := &RectF{Min: min, Max: max}
+	for i := range min {
+		r.ll[i] = g.cell(min[i], i, false)
+		r.ur[i] = g.cell(max[i], i, true)
+	}
+
+	return r
+}
+
+// RectF is a rectangle with float coordinates, created with Grid.Rect. It implements
+// Rectangle through the cells covering it, which may be shared by distinct rectangles:
+// Intersects and Contains compare the exact coordinates, and so do searches of trees
+// created with WithRefine(RefineF).
+type RectF struct {
+	Min, Max PointF
+	ll, ur   Point
+}
+
+func (r *RectF) LowerLeft() Point {
+	return r.ll
+}
+
+func (r *RectF) UpperRight() Point {
+	return r.ur
+}
+
+// Intersects reports whether r and o share at least a point.
+func (r *RectF) Intersects(o *RectF) bool {
+	for i := range r.Min {
+		if r.Min[i] > o.Max[i] || r.Max[i] < o.Min[i] {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Contains reports whether o lies within r.
+func (r *RectF) Contains(o *RectF) bool {
+	for i := range r.Min {
+		if r.Min[i] > o.Min[i] || r.Max[i] < o.Max[i] {
+			return false
+		}
+	}
+
+	return true
+}
+
+// RefineF is a refinement of searches (see WithRefine) comparing the exact coordinates of
+// objects and windows that are both of type *RectF.
+func RefineF(obj, window Rectangle) bool {
+	r, ok := obj.(*RectF)
+	w, wok := window.(*RectF)
+	return !ok || !wok || r.Intersects(w)
+}
@@ -0,0 +1,82 @@
+package hrtree
+
+import (
+	"errors"
+	"testing"
+)
+
+// grid returns the grid of the given bounds, spanning 0 to 1 in the dimensions left out.
+func grid(min, max PointF, bits int) (*Grid, error) {
+	for i := 2; i < Dim; i++ {
+		max[i] = 1
+	}
+	return NewGrid(min, max, bits)
+}
+
+func TestGrid(t *testing.T) {
+	for _, bounds := range [][2]PointF{
+		{{0, 0}, {0, 1}},
+		{{1, 0}, {0, 1}},
+		{{-1e308, 0}, {1e308, 1}},
+	} {
+		if _, err := grid(bounds[0], bounds[1], 16); !errors.Is(err, ErrGrid) {
+			t.Errorf("expected ErrGrid for %v, got %v", bounds, err)
+		}
+	}
+
+	if _, err := grid(PointF{0, 0}, PointF{1, 1}, 65); !errors.Is(err, ErrGrid) {
+		t.Errorf("expected ErrGrid for 65 bits, got %v", err)
+	}
+
+	g, err := grid(PointF{-180, -90}, PointF{180, 90}, 16)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if c := g.Cell(PointF{-180, -90}); c != (Point{0, 0}) {
+		t.Errorf("expected the first cell, got %v", c)
+	}
+
+	if c := g.Cell(PointF{180, 90}); c != (Point{1<<16 - 1, 1<<16 - 1}) {
+		t.Errorf("expected the last cell, got %v", c)
+	}
+
+	if c := g.Cell(PointF{-500, 500}); c != (Point{0, 1<<16 - 1}) {
+		t.Errorf("expected clamped cells, got %v", c)
+	}
+
+	// the cells of a rectangle enclose it.
+	r := g.Rect(PointF{0.001, 0.001}, PointF{0.002, 0.002})
+	if r.LowerLeft()[0] >= r.UpperRight()[0] || r.LowerLeft()[0] != g.Cell(PointF{0.001, 0})[0] {
+		t.Errorf("unexpected cells %v %v", r.LowerLeft(), r.UpperRight())
+	}
+}
+
+func TestGridTree(t *testing.T) {
+	g, _ := grid(PointF{-180, -90}, PointF{180, 90}, 12)
+	rt, _ := NewTree(2, 4, g.Resolution(), WithRefine(RefineF))
+
+	// rectangles much smaller than a cell.
+	var objs []*RectF
+	for i := 0; i < 100; i++ {
+		x := float64(i) * 0.001
+		obj := g.Rect(PointF{x, 0}, PointF{x + 0.0005, 0.0005})
+		rt.Insert(obj)
+		objs = append(objs, obj)
+	}
+
+	bb := g.Rect(PointF{0.0106, 0}, PointF{0.0125, 1})
+	found := rt.SearchIntersect(bb)
+	if len(found) != 2 || found[0] != objs[11] && found[0] != objs[12] {
+		t.Errorf("expected objects 11 and 12, got %v", found)
+	}
+
+	if !bb.Intersects(objs[11]) || bb.Intersects(objs[10]) || !bb.Contains(objs[11]) || bb.Contains(objs[13]) || !g.Rect(PointF{-1, -1}, PointF{1, 1}).Contains(objs[99]) {
+		t.Errorf("unexpected exact predicates")
+	}
+
+	// windows of other types are compared by cells.
+	if found := rt.SearchIntersect(rect(Point{0, 0}, Point{4095, 4095})); len(found) != 100 {
+		t.Errorf("expected 100 objects, got %d", len(found))
+	}
+}
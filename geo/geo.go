@@ -0,0 +1,372 @@
+// Package geo indexes objects located by WGS84 latitudes and longitudes in degrees, such
+// as points of interest, with distances in meters along the surface of the earth:
+//
+//	tree, err := geo.NewTree(20, 1000)
+//	...
+//	paris, err := geo.NewPoint(geo.LatLon{Lat: 48.8566, Lon: 2.3522}, "Paris")
+//	err = tree.Insert(paris)
+//	...
+//	near, err := tree.WithinRadius(geo.LatLon{Lat: 48.85, Lon: 2.35}, 5000)
+//
+// Degrees are mapped onto the grid of the hilbert curve of the tree, with a resolution of
+// about a centimeter, while searches and distances use the exact coordinates. Distances
+// are computed with the haversine formula, on a sphere of radius EarthRadius.
+//
+// Boxes whose western longitude is greater than their eastern one cross the antimeridian,
+// such as one from 170 to -170 degrees spanning 20 degrees around it. They are split into
+// two boxes on either side of it, both as objects and as search windows, and objects are
+// only returned once.
+//
+// In 3-dimensional builds, positions lie at 0 in the third dimension.
+package geo
+
+import (
+	"errors"
+	"fmt"
+	"iter"
+	"math"
+	"sync/atomic"
+
+	"github.com/jtejido/hrtree"
+)
+
+const (
+	EarthRadius = 6371008.8 // mean radius of the earth, in meters
+	Resolution  = 32        // bits per dimension of the trees
+)
+
+var ErrPosition = errors.New("Latitudes should be between -90 and 90 degrees, longitudes between -180 and 180, and boxes should have their northern edge above their southern one.")
+
+// world maps longitudes and latitudes onto the grid of the trees.
+var world = func() *hrtree.Grid {
+	max := hrtree.PointF{180, 90}
+	for i := 2; i < hrtree.Dim; i++ {
+		max[i] = 1
+	}
+
+	g, _ := hrtree.NewGrid(hrtree.PointF{-180, -90}, max, Resolution)
+	return g
+}()
+
+// LatLon is a position in degrees.
+type LatLon struct {
+	Lat, Lon float64
+}
+
+// valid reports whether p is a position on earth.
+func (p LatLon) valid() bool {
+	return p.Lat >= -90 && p.Lat <= 90 && p.Lon >= -180 && p.Lon <= 180
+}
+
+// Distance returns the great-circle distance between a and b in meters.
+func Distance(a, b LatLon) float64 {
+	lat1, lat2 := radians(a.Lat), radians(b.Lat)
+	dlat, dlon := lat2-lat1, radians(b.Lon-a.Lon)
+
+	h := sq(math.Sin(dlat/2)) + math.Cos(lat1)*math.Cos(lat2)*sq(math.Sin(dlon/2))
+	return 2 * EarthRadius * math.Asin(math.Sqrt(math.Min(h, 1)))
+}
+
+// Object is a point or a box of positions stored in a Tree, along with arbitrary Data.
+type Object struct {
+	sw, ne LatLon
+	boxes  []*box // stored in the tree, two if the object crosses the antimeridian
+	Data   any
+}
+
+// box is a part of an object not crossing the antimeridian, as stored in the underlying
+// tree.
+type box struct {
+	rect *hrtree.RectF // longitudes and latitudes, in this order
+	obj  *Object
+}
+
+func (b *box) LowerLeft() hrtree.Point {
+	return b.rect.LowerLeft()
+}
+
+func (b *box) UpperRight() hrtree.Point {
+	return b.rect.UpperRight()
+}
+
+// NewPoint returns the object located at p.
+func NewPoint(p LatLon, data any) (*Object, error) {
+	return NewBox(p, p, data)
+}
+
+// NewBox returns the object covering the positions from its south-west corner sw to its
+// north-east corner ne, crossing the antimeridian if sw.Lon is greater than ne.Lon.
+func NewBox(sw, ne LatLon, data any) (*Object, error) {
+	if !sw.valid() || !ne.valid() || sw.Lat > ne.Lat {
+		return nil, fmt.Errorf("box %v to %v: %w", sw, ne, ErrPosition)
+	}
+
+	o := &Object{sw: sw, ne: ne, Data: data}
+	if sw.Lon <= ne.Lon {
+		o.boxes = []*box{o.box(sw.Lon, ne.Lon)}
+	} else {
+		o.boxes = []*box{o.box(sw.Lon, 180), o.box(-180, ne.Lon)}
+	}
+
+	return o, nil
+}
+
+// box returns the part of o between the longitudes west and east.
+func (o *Object) box(west, east float64) *box {
+	return &box{world.Rect(hrtree.PointF{west, o.sw.Lat}, hrtree.PointF{east, o.ne.Lat}), o}
+}
+
+// Bounds returns the south-west and north-east corners of o, which are equal for points.
+func (o *Object) Bounds() (sw, ne LatLon) {
+	return o.sw, o.ne
+}
+
+// CrossesAntimeridian reports whether o spans the ±180 degrees longitude.
+func (o *Object) CrossesAntimeridian() bool {
+	return len(o.boxes) > 1
+}
+
+// Tree is a tree of objects located by latitudes and longitudes.
+type Tree struct {
+	tree     *hrtree.HRtree
+	crossing atomic.Int64 // objects stored in two boxes
+}
+
+// NewTree creates a tree with nodes of min to max entries, opts enable optional behaviour
+// of the underlying tree. Objects are looked up by identity rather than by bounds, so
+// that Delete only removes the given object.
+func NewTree(min, max int, opts ...hrtree.Option) (*Tree, error) {
+	opts = append([]hrtree.Option{
+		hrtree.WithRefine(refine),
+		hrtree.WithEqualFunc(func(a, b hrtree.Rectangle) bool { return a == b }),
+	}, opts...)
+
+	tree, err := hrtree.NewTree(min, max, Resolution, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Tree{tree: tree}, nil
+}
+
+// refine compares the exact coordinates of objects and search windows.
+func refine(obj, window hrtree.Rectangle) bool {
+	o, ok := obj.(*box)
+	w, wok := window.(*box)
+	return !ok || !wok || o.rect.Intersects(w.rect)
+}
+
+// Tree returns the underlying tree, such as to save it. It holds the boxes of objects,
+// see ObjectOf, and should not be modified directly.
+func (t *Tree) Tree() *hrtree.HRtree {
+	return t.tree
+}
+
+// Size returns the number of objects of the tree.
+func (t *Tree) Size() int {
+	return t.tree.Size() - int(t.crossing.Load())
+}
+
+// Insert adds o to the tree.
+func (t *Tree) Insert(o *Object) error {
+	for i, b := range o.boxes {
+		if err := t.tree.Insert(b); err != nil {
+			// parts are inserted whole or not at all.
+			for _, prev := range o.boxes[:i] {
+				t.tree.Delete(prev)
+			}
+			return err
+		}
+	}
+
+	if o.CrossesAntimeridian() {
+		t.crossing.Add(1)
+	}
+
+	return nil
+}
+
+// Delete removes o from the tree, and reports whether it was found.
+func (t *Tree) Delete(o *Object) (bool, error) {
+	found := false
+	for _, b := range o.boxes {
+		ok, err := t.tree.Delete(b)
+		if err != nil {
+			return found, err
+		}
+		found = found || ok
+	}
+
+	if found && o.CrossesAntimeridian() {
+		t.crossing.Add(-1)
+	}
+
+	return found, nil
+}
+
+// ObjectOf returns the object of a box of the underlying tree, see Tree, or nil if obj
+// isn't one.
+func ObjectOf(obj hrtree.Rectangle) *Object {
+	if b, ok := obj.(*box); ok {
+		return b.obj
+	}
+
+	return nil
+}
+
+// Search returns the objects intersecting the box from sw to ne, which crosses the
+// antimeridian if sw.Lon is greater than ne.Lon.
+func (t *Tree) Search(sw, ne LatLon) ([]*Object, error) {
+	window, err := NewBox(sw, ne, nil)
+	if err != nil {
+		return nil, fmt.Errorf("Search: %w", err)
+	}
+
+	var found []*Object
+	var seen map[*Object]bool // objects found in several parts
+	for _, w := range window.boxes {
+		t.tree.SearchIntersectFunc(w, func(obj hrtree.Rectangle) bool {
+			o := obj.(*box).obj
+			if len(window.boxes) > 1 || len(o.boxes) > 1 {
+				if seen[o] {
+					return true
+				}
+
+				if seen == nil {
+					seen = make(map[*Object]bool)
+				}
+				seen[o] = true
+			}
+
+			found = append(found, o)
+			return true
+		})
+	}
+
+	return found, nil
+}
+
+// Nearest returns the k objects nearest to p, nearest first.
+func (t *Tree) Nearest(p LatLon, k int) ([]*Object, error) {
+	if !p.valid() {
+		return nil, fmt.Errorf("Nearest: %v: %w", p, ErrPosition)
+	}
+
+	var found []*Object
+	for obj := range t.nearest(p, math.Inf(1)) {
+		if len(found) == k {
+			break
+		}
+		found = append(found, obj)
+	}
+
+	return found, nil
+}
+
+// WithinRadius returns the objects within the given number of meters of p, nearest first.
+func (t *Tree) WithinRadius(p LatLon, meters float64) ([]*Object, error) {
+	if !p.valid() {
+		return nil, fmt.Errorf("WithinRadius: %v: %w", p, ErrPosition)
+	}
+
+	var found []*Object
+	for obj := range t.nearest(p, meters) {
+		found = append(found, obj)
+	}
+
+	return found, nil
+}
+
+// nearest returns the objects within maxDist meters of p, nearest first. Objects crossing
+// the antimeridian are at the distance of their nearest part.
+func (t *Tree) nearest(p LatLon, maxDist float64) iter.Seq2[*Object, float64] {
+	dist := func(r hrtree.Rectangle) float64 {
+		if b, ok := r.(*box); ok {
+			return b.distance(p)
+		}
+
+		// the cells of nodes cover the positions of their objects.
+		ll, ur := r.LowerLeft(), r.UpperRight()
+		return boxDistance(p, position(ll), position(ur))
+	}
+
+	return func(yield func(*Object, float64) bool) {
+		var seen map[*Object]bool // objects crossing the antimeridian
+		for obj, d := range t.tree.NearestBy(dist, maxDist) {
+			o := obj.(*box).obj
+			if len(o.boxes) > 1 {
+				if seen[o] {
+					continue
+				}
+
+				if seen == nil {
+					seen = make(map[*Object]bool)
+				}
+				seen[o] = true
+			}
+
+			if !yield(o, d) {
+				return
+			}
+		}
+	}
+}
+
+// position returns the position of the corner of cell c nearest to the origin of the grid.
+func position(c hrtree.Point) LatLon {
+	const scale = 1<<Resolution - 1
+	lat := math.Min(float64(c[1])*180/scale-90, 90)
+	lon := math.Min(float64(c[0])*360/scale-180, 180)
+	return LatLon{lat, lon}
+}
+
+// distance returns the distance in meters from p to the nearest position of b.
+func (b *box) distance(p LatLon) float64 {
+	return boxDistance(p, LatLon{b.rect.Min[1], b.rect.Min[0]}, LatLon{b.rect.Max[1], b.rect.Max[0]})
+}
+
+// boxDistance returns the distance in meters from p to the nearest position of the box
+// from sw to ne, which doesn't cross the antimeridian.
+func boxDistance(p LatLon, sw, ne LatLon) float64 {
+	if p.Lon >= sw.Lon && p.Lon <= ne.Lon {
+		return Distance(p, LatLon{clamp(p.Lat, sw.Lat, ne.Lat), p.Lon})
+	}
+
+	// the nearest position lies on the western or eastern edge.
+	return math.Min(meridianDistance(p, sw.Lon, sw.Lat, ne.Lat), meridianDistance(p, ne.Lon, sw.Lat, ne.Lat))
+}
+
+// meridianDistance returns the distance in meters from p to the nearest position of the
+// meridian lon between the latitudes south and north.
+func meridianDistance(p LatLon, lon, south, north float64) float64 {
+	dlon := math.Abs(p.Lon - lon)
+	if dlon > 180 {
+		dlon = 360 - dlon
+	}
+
+	// within a quarter of a turn, the distance along the meridian is least at the latitude
+	// lat and grows away from it. Beyond, it is greatest in between the poles, so the
+	// nearest position is one of the ends.
+	if dlon >= 90 {
+		return math.Min(Distance(p, LatLon{south, lon}), Distance(p, LatLon{north, lon}))
+	}
+
+	lat := degrees(math.Atan(math.Tan(radians(p.Lat)) / math.Cos(radians(dlon))))
+	return Distance(p, LatLon{clamp(lat, south, north), lon})
+}
+
+func clamp(v, lo, hi float64) float64 {
+	return math.Max(lo, math.Min(v, hi))
+}
+
+func radians(deg float64) float64 {
+	return deg * math.Pi / 180
+}
+
+func degrees(rad float64) float64 {
+	return rad * 180 / math.Pi
+}
+
+func sq(v float64) float64 {
+	return v * v
+}
@@ -0,0 +1,250 @@
+package geo
+
+import (
+	"errors"
+	"math"
+	"math/rand"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/jtejido/hrtree"
+)
+
+var cities = map[string]LatLon{
+	"Paris":     {48.8566, 2.3522},
+	"London":    {51.5074, -0.1278},
+	"Berlin":    {52.52, 13.405},
+	"Madrid":    {40.4168, -3.7038},
+	"New York":  {40.7128, -74.006},
+	"Tokyo":     {35.6762, 139.6503},
+	"Sydney":    {-33.8688, 151.2093},
+	"Fiji":      {-17.7134, 178.065},
+	"Samoa":     {-13.759, -172.1046},
+	"Reykjavik": {64.1466, -21.9426},
+}
+
+func TestDistance(t *testing.T) {
+	for _, c := range []struct {
+		a, b   string
+		meters float64
+	}{
+		{"Paris", "London", 343.9e3},
+		{"New York", "Tokyo", 10.85e6},
+		{"Fiji", "Samoa", 1.14e6},
+	} {
+		if d := Distance(cities[c.a], cities[c.b]); math.Abs(d-c.meters) > c.meters/100 {
+			t.Errorf("expected about %v meters from %s to %s, got %v", c.meters, c.a, c.b, d)
+		}
+	}
+
+	if d := Distance(LatLon{90, 0}, LatLon{-90, 0}); math.Abs(d-math.Pi*EarthRadius) > 1e-6 {
+		t.Errorf("expected half of the circumference from pole to pole, got %v", d)
+	}
+}
+
+func TestTree(t *testing.T) {
+	tree, err := NewTree(2, 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for name, p := range cities {
+		o, err := NewPoint(p, name)
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", name, err)
+		}
+		tree.Insert(o)
+	}
+
+	found, err := tree.Search(LatLon{40, -5}, LatLon{53, 14})
+	if err != nil || len(found) != 4 {
+		t.Errorf("expected the 4 european capitals, got %v, %v", found, err)
+	}
+
+	near, _ := tree.Nearest(cities["Paris"], 3)
+	if names(near) != "Paris London Berlin" {
+		t.Errorf("expected Paris, London and Berlin, got %s", names(near))
+	}
+
+	// across the antimeridian.
+	near, _ = tree.WithinRadius(cities["Fiji"], 1.5e6)
+	if names(near) != "Fiji Samoa" {
+		t.Errorf("expected Fiji and Samoa, got %s", names(near))
+	}
+
+	if ok, err := tree.Delete(near[1]); !ok || err != nil || tree.Size() != len(cities)-1 {
+		t.Errorf("expected Samoa to be deleted, got %v, %v", ok, err)
+	}
+
+	// objects are deleted by identity.
+	if o, _ := NewPoint(cities["Paris"], "Paris"); o != nil {
+		if ok, _ := tree.Delete(o); ok {
+			t.Errorf("expected another object at the same place not to be deleted")
+		}
+	}
+
+	invalid := []func() error{
+		func() error { _, err := NewPoint(LatLon{91, 0}, nil); return err },
+		func() error { _, err := NewBox(LatLon{10, 10}, LatLon{5, 20}, nil); return err },
+		func() error { _, err := tree.Search(LatLon{0, -181}, LatLon{1, 1}); return err },
+		func() error { _, err := tree.Nearest(LatLon{0, 200}, 1); return err },
+		func() error { _, err := tree.WithinRadius(LatLon{-100, 0}, 1); return err },
+	}
+
+	for i, fn := range invalid {
+		if err := fn(); !errors.Is(err, ErrPosition) {
+			t.Errorf("%d: expected ErrPosition, got %v", i, err)
+		}
+	}
+}
+
+func TestNearest(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	tree, _ := NewTree(4, 16)
+
+	var objs []*Object
+	for i := 0; i < 2000; i++ {
+		sw := LatLon{r.Float64()*180 - 90, r.Float64()*360 - 180}
+		ne := sw
+		if i%2 == 0 {
+			ne = LatLon{math.Min(sw.Lat+r.Float64()*5, 90), sw.Lon + r.Float64()*5}
+			if ne.Lon > 180 {
+				ne.Lon -= 360
+			}
+		}
+
+		o, err := NewBox(sw, ne, i)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		tree.Insert(o)
+		objs = append(objs, o)
+	}
+
+	for _, p := range []LatLon{{0, 0}, {89.9, 10}, {-89, -170}, {10, 179.9}, {-45, -179.5}} {
+		dist := func(o *Object) float64 {
+			d := math.Inf(1)
+			for _, b := range o.boxes {
+				d = math.Min(d, b.distance(p))
+			}
+			return d
+		}
+
+		sort.Slice(objs, func(i, j int) bool { return dist(objs[i]) < dist(objs[j]) })
+
+		near, _ := tree.Nearest(p, 20)
+		for i, o := range near {
+			if dist(o) != dist(objs[i]) {
+				t.Errorf("%v: expected distance %v at %d, got %v", p, dist(objs[i]), i, dist(o))
+			}
+		}
+
+		within, _ := tree.WithinRadius(p, 1e6)
+		count := sort.Search(len(objs), func(i int) bool { return dist(objs[i]) > 1e6 })
+		if len(within) != count {
+			t.Errorf("%v: expected %d objects within 1000km, got %d", p, count, len(within))
+		}
+	}
+}
+
+func TestAntimeridian(t *testing.T) {
+	tree, _ := NewTree(2, 4)
+	for name, p := range cities {
+		o, _ := NewPoint(p, name)
+		tree.Insert(o)
+	}
+
+	pacific, err := NewBox(LatLon{-20, 175}, LatLon{-10, -170}, "Pacific")
+	if err != nil || !pacific.CrossesAntimeridian() {
+		t.Fatalf("expected a box crossing the antimeridian, got %v", err)
+	}
+	tree.Insert(pacific)
+
+	if tree.Tree().Size() != len(cities)+2 || tree.Size() != len(cities)+1 {
+		t.Errorf("expected the box to be stored in two parts, got %d objects in %d boxes", tree.Size(), tree.Tree().Size())
+	}
+
+	if sw, ne := pacific.Bounds(); sw.Lon != 175 || ne.Lon != -170 {
+		t.Errorf("expected the bounds of the box, got %v, %v", sw, ne)
+	}
+
+	for _, c := range []struct {
+		sw, ne LatLon
+		names  string
+	}{
+		{LatLon{-25, 170}, LatLon{-5, -160}, "Fiji Pacific Samoa"},
+		{LatLon{-25, 170}, LatLon{-5, 179}, "Fiji Pacific"},
+		{LatLon{-25, -175}, LatLon{-5, -160}, "Pacific Samoa"},
+		{LatLon{-90, 100}, LatLon{90, -100}, "Fiji Pacific Samoa Sydney Tokyo"},
+		{LatLon{-90, 0}, LatLon{90, -1}, "Berlin Fiji Madrid New York Pacific Paris Reykjavik Samoa Sydney Tokyo"},
+	} {
+		found, _ := tree.Search(c.sw, c.ne)
+		if sortedNames(found) != c.names {
+			t.Errorf("%v to %v: expected %s, got %s", c.sw, c.ne, c.names, names(found))
+		}
+	}
+
+	near, _ := tree.WithinRadius(LatLon{-15, 179}, 1.2e6)
+	if names(near) != "Pacific Fiji Samoa" {
+		t.Errorf("expected Pacific, Fiji and Samoa, got %s", names(near))
+	}
+
+	if near[0] != ObjectOf(tree.Tree().NearestNeighbor(world.Cell(hrtree.PointF{-170.5, -19}))) {
+		t.Errorf("expected the box to be found from its boxes")
+	}
+
+	if ok, _ := tree.Delete(pacific); !ok || tree.Tree().Size() != len(cities) || tree.Size() != len(cities) {
+		t.Errorf("expected both parts of the box to be deleted")
+	}
+
+	// objects on both sides are returned once.
+	band, _ := NewBox(LatLon{-1, -180}, LatLon{1, 180}, "Equator")
+	tree.Insert(band)
+	if found, _ := tree.Search(LatLon{-5, 170}, LatLon{5, -170}); names(found) != "Equator" {
+		t.Errorf("expected the equator once, got %s", names(found))
+	}
+}
+
+func TestBoxDistance(t *testing.T) {
+	r := rand.New(rand.NewSource(2))
+	for i := 0; i < 1000; i++ {
+		p := LatLon{r.Float64()*180 - 90, r.Float64()*360 - 180}
+		sw := LatLon{r.Float64()*170 - 90, r.Float64()*340 - 180}
+		ne := LatLon{sw.Lat + r.Float64()*10, sw.Lon + r.Float64()*20}
+
+		// no position sampled in the box is nearer than the distance to the box.
+		d := boxDistance(p, sw, ne)
+		for j := 0; j <= 20; j++ {
+			for k := 0; k <= 20; k++ {
+				q := LatLon{sw.Lat + (ne.Lat-sw.Lat)*float64(j)/20, sw.Lon + (ne.Lon-sw.Lon)*float64(k)/20}
+				if e := Distance(p, q); e < d-1e-6 {
+					t.Fatalf("%v to box %v, %v: %v nearer than %v at %v", p, sw, ne, e, d, q)
+				}
+			}
+		}
+	}
+}
+
+// sortedNames returns the names of objs in alphabetical order.
+func sortedNames(objs []*Object) string {
+	var s []string
+	for _, o := range objs {
+		s = append(s, o.Data.(string))
+	}
+	sort.Strings(s)
+
+	return strings.Join(s, " ")
+}
+
+func names(objs []*Object) string {
+	s := ""
+	for i, o := range objs {
+		if i > 0 {
+			s += " "
+		}
+		s += o.Data.(string)
+	}
+
+	return s
+}
@@ -0,0 +1,248 @@
+// Package geojson loads GeoJSON FeatureCollections into trees and writes the results of
+// searches back as GeoJSON.
+//
+// Features are stored with the bounding-box of their geometry, in longitudes and
+// latitudes mapped to the cells of a grid such as World:
+//
+//	grid := geojson.World(16)
+//	tree, err := geojson.Load(r, grid, 16, 64)
+//	...
+//	found := tree.SearchIntersect(grid.Rect(hrtree.PointF{2, 48}, hrtree.PointF{3, 49}))
+//	err = geojson.Encode(w, found)
+//
+// Altitudes are ignored: in 3-dimensional builds, features lie at 0 in the third dimension.
+package geojson
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+
+	"github.com/jtejido/hrtree"
+)
+
+var (
+	ErrNotFeatureCollection = errors.New("GeoJSON document should be a FeatureCollection.")
+	ErrGeometry             = errors.New("GeoJSON geometry should have at least one position.")
+	ErrNotFeature           = errors.New("Objects should be features or float rectangles to be encoded as GeoJSON.")
+)
+
+// Feature is a GeoJSON feature, stored in trees through the bounding-box of its geometry.
+// Its id, geometry and properties are kept as they were read.
+type Feature struct {
+	*hrtree.RectF
+	ID         json.RawMessage
+	Geometry   json.RawMessage
+	Properties json.RawMessage
+}
+
+// World returns the grid of longitudes from -180 to 180 and latitudes from -90 to 90
+// degrees, with the given resolution. In 3-dimensional builds, it spans 0 to 1 in the
+// third dimension.
+func World(bits int) *hrtree.Grid {
+	max := hrtree.PointF{180, 90}
+	for i := 2; i < hrtree.Dim; i++ {
+		max[i] = 1
+	}
+
+	g, err := hrtree.NewGrid(hrtree.PointF{-180, -90}, max, bits)
+	if err != nil {
+		panic(err)
+	}
+
+	return g
+}
+
+type featureCollection struct {
+	Type     string        `json:"type"`
+	Features []jsonFeature `json:"features"`
+}
+
+type jsonFeature struct {
+	Type       string          `json:"type"`
+	ID         json.RawMessage `json:"id,omitempty"`
+	Geometry   json.RawMessage `json:"geometry"`
+	Properties json.RawMessage `json:"properties"`
+}
+
+type geometry struct {
+	Type        string          `json:"type"`
+	Coordinates json.RawMessage `json:"coordinates"`
+	Geometries  []geometry      `json:"geometries"` // of a GeometryCollection
+}
+
+// Decode reads a FeatureCollection from r, and returns its features on grid g. Features
+// without geometry are skipped, as they can't be searched.
+func Decode(r io.Reader, g *hrtree.Grid) ([]*Feature, error) {
+	var fc featureCollection
+	if err := json.NewDecoder(r).Decode(&fc); err != nil {
+		return nil, fmt.Errorf("Decode: %w", err)
+	}
+
+	if fc.Type != "FeatureCollection" {
+		return nil, fmt.Errorf("Decode: type %q: %w", fc.Type, ErrNotFeatureCollection)
+	}
+
+	features := make([]*Feature, 0, len(fc.Features))
+	for i, f := range fc.Features {
+		if len(f.Geometry) == 0 || string(f.Geometry) == "null" {
+			continue
+		}
+
+		var geom geometry
+		if err := json.Unmarshal(f.Geometry, &geom); err != nil {
+			return nil, fmt.Errorf("Decode: feature %d: %w", i, err)
+		}
+
+		b := bounds{min: hrtree.PointF{math.Inf(1), math.Inf(1)}, max: hrtree.PointF{math.Inf(-1), math.Inf(-1)}}
+		if err := b.addGeometry(&geom); err != nil {
+			return nil, fmt.Errorf("Decode: feature %d: %w", i, err)
+		}
+
+		if b.min[0] > b.max[0] {
+			return nil, fmt.Errorf("Decode: feature %d: %w", i, ErrGeometry)
+		}
+
+		features = append(features, &Feature{
+			RectF:      g.Rect(b.min, b.max),
+			ID:         f.ID,
+			Geometry:   f.Geometry,
+			Properties: f.Properties,
+		})
+	}
+
+	return features, nil
+}
+
+// Load reads a FeatureCollection from r and bulk-loads its features into a new tree of
+// grid g, with min to max entries per node. See Decode.
+func Load(r io.Reader, g *hrtree.Grid, min, max int, opts ...hrtree.Option) (*hrtree.HRtree, error) {
+	features, err := Decode(r, g)
+	if err != nil {
+		return nil, err
+	}
+
+	objs := make([]hrtree.Rectangle, len(features))
+	for i, f := range features {
+		objs[i] = f
+	}
+
+	return hrtree.NewTreeBulk(min, max, g.Resolution(), objs, opts...)
+}
+
+// Encode writes objs, such as the results of a search, to w as a FeatureCollection.
+// Features are written as they were read, float rectangles as polygons.
+func Encode(w io.Writer, objs []hrtree.Rectangle) error {
+	fc := struct {
+		Type     string        `json:"type"`
+		Features []jsonFeature `json:"features"`
+	}{Type: "FeatureCollection", Features: make([]jsonFeature, 0, len(objs))}
+
+	for _, obj := range objs {
+		var f jsonFeature
+		switch o := obj.(type) {
+		case *Feature:
+			f = jsonFeature{ID: o.ID, Geometry: o.Geometry, Properties: o.Properties}
+		case *hrtree.RectF:
+			geom, err := json.Marshal(polygon(o))
+			if err != nil {
+				return fmt.Errorf("Encode: %w", err)
+			}
+			f = jsonFeature{Geometry: geom}
+		default:
+			return fmt.Errorf("Encode: %T: %w", obj, ErrNotFeature)
+		}
+
+		f.Type = "Feature"
+		if len(f.Properties) == 0 {
+			f.Properties = json.RawMessage("null")
+		}
+		if len(f.Geometry) == 0 {
+			f.Geometry = json.RawMessage("null")
+		}
+		fc.Features = append(fc.Features, f)
+	}
+
+	if err := json.NewEncoder(w).Encode(&fc); err != nil {
+		return fmt.Errorf("Encode: %w", err)
+	}
+
+	return nil
+}
+
+// polygon returns the GeoJSON polygon of r.
+func polygon(r *hrtree.RectF) any {
+	ring := [][2]float64{
+		{r.Min[0], r.Min[1]},
+		{r.Max[0], r.Min[1]},
+		{r.Max[0], r.Max[1]},
+		{r.Min[0], r.Max[1]},
+		{r.Min[0], r.Min[1]},
+	}
+
+	return struct {
+		Type        string         `json:"type"`
+		Coordinates [][][2]float64 `json:"coordinates"`
+	}{"Polygon", [][][2]float64{ring}}
+}
+
+// bounds is the bounding-box of the positions of a geometry.
+type bounds struct {
+	min, max hrtree.PointF
+}
+
+func (b *bounds) addGeometry(g *geometry) error {
+	if g.Type == "GeometryCollection" {
+		for i := range g.Geometries {
+			if err := b.addGeometry(&g.Geometries[i]); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	var coords any
+	if err := json.Unmarshal(g.Coordinates, &coords); err != nil {
+		return err
+	}
+
+	return b.add(coords)
+}
+
+// add extends b with the positions of coords, nested arrays of any depth whose innermost
+// arrays are positions.
+func (b *bounds) add(coords any) error {
+	a, ok := coords.([]any)
+	if !ok {
+		return ErrGeometry
+	}
+
+	if len(a) == 0 {
+		return nil
+	}
+
+	if _, ok := a[0].(float64); !ok {
+		for _, c := range a {
+			if err := b.add(c); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	if len(a) < 2 {
+		return ErrGeometry
+	}
+
+	for i := 0; i < 2; i++ {
+		v, ok := a[i].(float64)
+		if !ok {
+			return ErrGeometry
+		}
+		b.min[i], b.max[i] = math.Min(b.min[i], v), math.Max(b.max[i], v)
+	}
+
+	return nil
+}
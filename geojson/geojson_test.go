@@ -0,0 +1,122 @@
+package geojson
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/jtejido/hrtree"
+)
+
+const collection = `{
+	"type": "FeatureCollection",
+	"features": [
+		{"type": "Feature", "id": 1, "geometry": {"type": "Point", "coordinates": [2.35, 48.85]}, "properties": {"name": "Paris"}},
+		{"type": "Feature", "id": "thames", "geometry": {"type": "LineString", "coordinates": [[-1.9, 51.7], [0.5, 51.5]]}, "properties": {"name": "Thames"}},
+		{"type": "Feature", "geometry": {"type": "MultiPolygon", "coordinates": [[[[10, 10], [12, 10], [12, 13], [10, 10]]], [[[-5, -4], [-3, -4], [-5, -2], [-5, -4]]]]}, "properties": null},
+		{"type": "Feature", "geometry": {"type": "GeometryCollection", "geometries": [{"type": "Point", "coordinates": [100, 0, 12]}, {"type": "Point", "coordinates": [101, 1]}]}, "properties": {}},
+		{"type": "Feature", "geometry": null, "properties": {"name": "nowhere"}}
+	]
+}`
+
+func TestDecode(t *testing.T) {
+	features, err := Decode(strings.NewReader(collection), World(16))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(features) != 4 {
+		t.Fatalf("expected 4 features, got %d", len(features))
+	}
+
+	expected := []struct{ min, max hrtree.PointF }{
+		{hrtree.PointF{2.35, 48.85}, hrtree.PointF{2.35, 48.85}},
+		{hrtree.PointF{-1.9, 51.5}, hrtree.PointF{0.5, 51.7}},
+		{hrtree.PointF{-5, -4}, hrtree.PointF{12, 13}},
+		{hrtree.PointF{100, 0}, hrtree.PointF{101, 1}},
+	}
+
+	for i, f := range features {
+		if f.Min != expected[i].min || f.Max != expected[i].max {
+			t.Errorf("expected feature %d to span %v to %v, got %v to %v", i, expected[i].min, expected[i].max, f.Min, f.Max)
+		}
+	}
+
+	if string(features[1].ID) != `"thames"` {
+		t.Errorf("expected the id of the feature to be kept, got %s", features[1].ID)
+	}
+
+	for _, doc := range []string{
+		`{"type": "Feature", "geometry": null}`,
+		`{"type": "FeatureCollection", "features": [{"type": "Feature", "geometry": {"type": "Point", "coordinates": [1]}}]}`,
+		`{"type": "FeatureCollection", "features": [{"type": "Feature", "geometry": {"type": "LineString", "coordinates": []}}]}`,
+	} {
+		if _, err := Decode(strings.NewReader(doc), World(16)); !errors.Is(err, ErrNotFeatureCollection) && !errors.Is(err, ErrGeometry) {
+			t.Errorf("expected %s to be rejected, got %v", doc, err)
+		}
+	}
+}
+
+func TestLoadEncode(t *testing.T) {
+	grid := World(16)
+	tree, err := Load(strings.NewReader(collection), grid, 2, 4, hrtree.WithRefine(hrtree.RefineF))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if tree.Size() != 4 {
+		t.Fatalf("expected 4 features, got %d", tree.Size())
+	}
+
+	// western Europe.
+	found := tree.SearchIntersect(grid.Rect(hrtree.PointF{-5, 45}, hrtree.PointF{5, 55}))
+	if len(found) != 2 {
+		t.Fatalf("expected 2 features, got %d", len(found))
+	}
+
+	var buf bytes.Buffer
+	if err := Encode(&buf, append(found, grid.Rect(hrtree.PointF{0, 0}, hrtree.PointF{1, 2}))); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	var fc struct {
+		Type     string
+		Features []struct {
+			Type       string
+			Geometry   struct{ Type string }
+			Properties map[string]string
+		}
+	}
+	if err := json.Unmarshal(buf.Bytes(), &fc); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if fc.Type != "FeatureCollection" || len(fc.Features) != 3 {
+		t.Fatalf("expected a collection of 3 features, got %s", buf.Bytes())
+	}
+
+	names := map[string]bool{}
+	for _, f := range fc.Features[:2] {
+		names[f.Properties["name"]] = true
+	}
+
+	if !names["Paris"] || !names["Thames"] {
+		t.Errorf("expected Paris and the Thames, got %s", buf.Bytes())
+	}
+
+	if f := fc.Features[2]; f.Type != "Feature" || f.Geometry.Type != "Polygon" {
+		t.Errorf("expected a polygon feature, got %+v", f)
+	}
+
+	// roundtrip.
+	again, err := Decode(&buf, grid)
+	if err != nil || len(again) != 3 {
+		t.Errorf("expected 3 features, got %d and %v", len(again), err)
+	}
+
+	if err := Encode(&buf, []hrtree.Rectangle{hrtree.MustNewRect(hrtree.Point{0, 0}, hrtree.Point{1, 1})}); !errors.Is(err, ErrNotFeature) {
+		t.Errorf("expected %v, got %v", ErrNotFeature, err)
+	}
+}
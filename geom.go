@@ -1,10 +1,13 @@
 package hrtree
 
 import (
+	"errors"
 	"fmt"
 	"strings"
 )
 
+var ErrInvertedRect = errors.New("Lower left corner of a rectangle should not be above its upper right corner.")
+
 type Rectangle interface {
 	UpperRight() Point
 	LowerLeft() Point
@@ -28,6 +31,56 @@ func newRect(lowerLeft, upperRight Point) (r rectangle, err error) {
 	return
 }
 
+// NewRect returns a rectangle with the given corners. It returns an error wrapping
+// ErrInvertedRect if lowerLeft is above upperRight in some dimension, as such corners
+// would give the rectangle a meaningless center and bounding-box; see NewRectNormalized,
+// and NewWrapWindow for the search windows of WithWrap crossing the end of the space.
+func NewRect(lowerLeft, upperRight Point) (Rectangle, error) {
+	for i := range lowerLeft {
+		if lowerLeft[i] > upperRight[i] {
+			return nil, fmt.Errorf("NewRect: dimension %d: %d above %d: %w", i, lowerLeft[i], upperRight[i], ErrInvertedRect)
+		}
+	}
+
+	r, err := newRect(lowerLeft, upperRight)
+	if err != nil {
+		return nil, err
+	}
+
+	return &r, nil
+}
+
+// NewRectNormalized returns the rectangle spanned by the corners a and b, whose
+// coordinates are swapped in the dimensions where a is above b. It never fails, for
+// callers whose corners may come in any order.
+func NewRectNormalized(a, b Point) Rectangle {
+	for i := range a {
+		if a[i] > b[i] {
+			a[i], b[i] = b[i], a[i]
+		}
+	}
+
+	return &rectangle{a, b}
+}
+
+// MustNewRect is like NewRect but panics on error. It is meant for tests and examples.
+func MustNewRect(lowerLeft, upperRight Point) Rectangle {
+	r, err := NewRect(lowerLeft, upperRight)
+	if err != nil {
+		panic(err)
+	}
+
+	return r
+}
+
+func (r *rectangle) LowerLeft() Point {
+	return r.lowerLeft
+}
+
+func (r *rectangle) UpperRight() Point {
+	return r.upperRight
+}
+
 func (r *rectangle) String() string {
 	var s [Dim]string
 	for i, a := range r.lowerLeft {
@@ -68,10 +121,11 @@ func (r1 *rectangle) contains(r2 Rectangle) bool {
 	return true
 }
 
+// equal reports whether r1 and r2 have the same corners.
 func equal(r1, r2 Rectangle) (ok bool) {
 	for i, a1 := range r1.LowerLeft() {
 		b1, a2, b2 := r1.UpperRight()[i], r2.LowerLeft()[i], r2.UpperRight()[i]
-		if a1 != a2 && b2 != b1 {
+		if a1 != a2 || b1 != b2 {
 			return false
 		}
 	}
@@ -2,6 +2,7 @@
 package hrtree
 
 import (
+	"errors"
 	"testing"
 )
 
@@ -98,3 +99,40 @@ func TestToCenter(t *testing.T) {
 	}
 
 }
+
+func TestMustNewRect(t *testing.T) {
+	r := MustNewRect(Point{1, 2}, Point{3, 4})
+
+	if r.LowerLeft() != (Point{1, 2}) || r.UpperRight() != (Point{3, 4}) {
+		t.Errorf("expected [1, 3]x[2, 4], got %v", r)
+	}
+}
+
+func TestNewRectInverted(t *testing.T) {
+	if _, err := NewRect(Point{1, 4}, Point{3, 2}); !errors.Is(err, ErrInvertedRect) {
+		t.Errorf("expected ErrInvertedRect, got %v", err)
+	}
+
+	if _, err := NewRect(Point{1, 2}, Point{1, 2}); err != nil {
+		t.Errorf("unexpected error for a point: %v", err)
+	}
+
+	r := NewRectNormalized(Point{3, 2}, Point{1, 4})
+	if r.LowerLeft() != (Point{1, 2}) || r.UpperRight() != (Point{3, 4}) {
+		t.Errorf("expected [1, 3]x[2, 4], got %v", r)
+	}
+}
+
+func TestEqual(t *testing.T) {
+	r := rect(Point{1, 2}, Point{3, 4})
+	if !equal(r, rect(Point{1, 2}, Point{3, 4})) {
+		t.Errorf("expected %v to equal itself", r)
+	}
+
+	// a single matching corner isn't enough.
+	for _, other := range []Rectangle{rect(Point{1, 2}, Point{3, 5}), rect(Point{0, 2}, Point{3, 4}), rect(Point{1, 2}, Point{5, 6})} {
+		if equal(r, other) {
+			t.Errorf("expected %v not to equal %v", r, other)
+		}
+	}
+}
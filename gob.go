@@ -0,0 +1,253 @@
+package hrtree
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"math/big"
+
+	h "github.com/jtejido/hilbert"
+)
+
+var ErrInvalidGob = errors.New("Inconsistent gob encoding of a tree.")
+
+func init() {
+	gob.Register(&rectangle{})
+}
+
+// gobTree is the gob encoding of a tree.
+type gobTree struct {
+	Min, Max, Bits   int
+	Size, Tombstones int
+	Root             *gobNode
+	Versions         map[string]gobVersion
+}
+
+// gobNode is the gob encoding of a node, with its LHV and bounding-box.
+type gobNode struct {
+	Leaf                  bool
+	LHV                   *big.Int
+	LowerLeft, UpperRight Point
+	Entries               []gobEntry // objects of a leaf
+	Children              []*gobNode
+}
+
+type gobEntry struct {
+	Object  Rectangle
+	Hilbert *big.Int
+	Dead    bool
+}
+
+type gobVersion struct {
+	Object  Rectangle // nil once deleted
+	Version uint64
+}
+
+// GobEncode encodes the tree with its whole structure, so that it can be decoded without
+// being rebuilt. Objects are encoded as interface values, their types should be registered
+// with gob.Register, as is done for those of NewRect. Options are not encoded.
+func (tree *HRtree) GobEncode() ([]byte, error) {
+	if tree.rlockTree() {
+		defer tree.runlock()
+	}
+
+	t := gobTree{
+		Min:        tree.min,
+		Max:        tree.max,
+		Bits:       tree.bits,
+		Size:       tree.size,
+		Tombstones: tree.tombstones,
+		Root:       encodeGobNode(tree.root),
+	}
+
+	if len(tree.versions) > 0 {
+		t.Versions = make(map[string]gobVersion, len(tree.versions))
+		for id, v := range tree.versions {
+			t.Versions[id] = gobVersion{v.obj, v.version}
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(&t); err != nil {
+		return nil, fmt.Errorf("GobEncode: %w", err)
+	}
+
+	return buf.Bytes(), nil
+}
+
+func encodeGobNode(n *node) *gobNode {
+	g := &gobNode{Leaf: n.leaf, LHV: n.lhv.Int()}
+	if n.bb != nil {
+		g.LowerLeft, g.UpperRight = n.bb.lowerLeft, n.bb.upperRight
+	}
+
+	for _, e := range n.getEntries() {
+		if n.leaf {
+			g.Entries = append(g.Entries, gobEntry{e.obj, e.h.Int(), e.dead})
+		} else {
+			g.Children = append(g.Children, encodeGobNode(e.node))
+		}
+	}
+
+	return g
+}
+
+// GobDecode replaces the tree with the one encoded in data by GobEncode. The stored LHVs
+// and bounding-boxes are checked against the decoded objects, and the tree is left
+// unchanged if they don't match, with ErrInvalidGob.
+func (tree *HRtree) GobDecode(data []byte) error {
+	var t gobTree
+	if err := gob.NewDecoder(bytes.NewReader(data)).Decode(&t); err != nil {
+		return fmt.Errorf("GobDecode: %w", err)
+	}
+
+	if err := tree.restore(&t, ErrInvalidGob); err != nil {
+		return fmt.Errorf("GobDecode: %w", err)
+	}
+
+	return nil
+}
+
+// restore replaces the tree with the decoded t, failing with invalid if its structure is
+// inconsistent.
+func (tree *HRtree) restore(t *gobTree, invalid error) error {
+	if t.Root == nil || t.Min < 0 || t.Max < t.Min {
+		return invalid
+	}
+
+	hf, err := h.New(uint32(t.Bits), Dim)
+	if err != nil {
+		return fmt.Errorf("resolution %d: %w", t.Bits, err)
+	}
+
+	d := gobDecoder{min: t.Min, max: t.Max, depth: -1, invalid: invalid}
+	root, err := d.decode(t.Root, 0)
+	if err != nil {
+		return err
+	}
+
+	if d.live != t.Size || d.dead != t.Tombstones {
+		return fmt.Errorf("%d objects, %d removed: %w", d.live, d.dead, invalid)
+	}
+	linkLevels(root)
+
+	if err := tree.lock(); err != nil {
+		return err
+	}
+	defer tree.unlock()
+
+	tree.min, tree.max, tree.bits = t.Min, t.Max, t.Bits
+	tree.hf = hf
+	tree.lut = lutFor(hf, t.Bits)
+	tree.root = root
+	tree.size = t.Size
+	tree.tombstones = t.Tombstones
+	tree.deferred = 0
+	tree.versions = nil
+	for id, v := range t.Versions {
+		if tree.versions == nil {
+			tree.versions = make(map[string]version, len(t.Versions))
+		}
+		tree.versions[id] = version{v.Object, v.Version}
+	}
+	tree.changed(0)
+
+	return nil
+}
+
+// gobDecoder rebuilds nodes from their gob encoding.
+type gobDecoder struct {
+	min, max   int
+	depth      int // depth of the leaves, -1 until the first one
+	live, dead int
+	invalid    error // error of inconsistent encodings
+}
+
+func (d *gobDecoder) decode(g *gobNode, depth int) (*node, error) {
+	if g == nil || len(g.Entries)+len(g.Children) > d.max || (g.Leaf && len(g.Children) > 0) || (!g.Leaf && len(g.Entries) > 0) {
+		return nil, d.invalid
+	}
+
+	n := newNode(d.min, d.max)
+	n.leaf = g.Leaf
+
+	if n.leaf {
+		if d.depth >= 0 && d.depth != depth {
+			return nil, fmt.Errorf("leaves at depths %d and %d: %w", d.depth, depth, d.invalid)
+		}
+		d.depth = depth
+
+		for _, e := range g.Entries {
+			if e.Object == nil || e.Hilbert == nil || e.Hilbert.Sign() < 0 {
+				return nil, d.invalid
+			}
+
+			n.entries.entries = append(n.entries.entries, entry{
+				bb:    &rectangle{e.Object.LowerLeft(), e.Object.UpperRight()},
+				obj:   e.Object,
+				h:     keyOf(e.Hilbert),
+				leaf:  true,
+				layer: layerOf(e.Object),
+				attrs: attributesOf(e.Object),
+				dead:  e.Dead,
+			})
+
+			if e.Dead {
+				d.dead++
+			} else {
+				d.live++
+			}
+		}
+	}
+
+	for _, c := range g.Children {
+		cn, err := d.decode(c, depth+1)
+		if err != nil {
+			return nil, err
+		}
+
+		cn.parent = n
+		n.entries.entries = append(n.entries.entries, entry{node: cn})
+	}
+
+	n.adjustLHV()
+	n.adjustMBR()
+
+	lhv := g.LHV
+	if lhv == nil {
+		lhv = new(big.Int)
+	}
+
+	if n.lhv.cmp(keyOf(lhv)) != 0 || n.bb.lowerLeft != g.LowerLeft || n.bb.upperRight != g.UpperRight {
+		return nil, fmt.Errorf("node at depth %d: %w", depth, d.invalid)
+	}
+
+	return n, nil
+}
+
+// GobEncode encodes the bounds of r.
+func (r *rectangle) GobEncode() ([]byte, error) {
+	buf := make([]byte, dumpRecordSize)
+	for i := 0; i < Dim; i++ {
+		binary.LittleEndian.PutUint64(buf[8*i:], r.lowerLeft[i])
+		binary.LittleEndian.PutUint64(buf[8*(Dim+i):], r.upperRight[i])
+	}
+
+	return buf, nil
+}
+
+// GobDecode decodes the bounds of r.
+func (r *rectangle) GobDecode(data []byte) error {
+	if len(data) != dumpRecordSize {
+		return ErrInvalidGob
+	}
+
+	for i := 0; i < Dim; i++ {
+		r.lowerLeft[i] = binary.LittleEndian.Uint64(data[8*i:])
+		r.upperRight[i] = binary.LittleEndian.Uint64(data[8*(Dim+i):])
+	}
+
+	return nil
+}
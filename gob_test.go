@@ -0,0 +1,92 @@
+package hrtree
+
+import (
+	"bytes"
+	"encoding/gob"
+	"errors"
+	"math/big"
+	"testing"
+)
+
+// pin is a versioned point with exported fields, which gob can encode.
+type pin struct {
+	X, Y uint64
+	Name string
+	Rev  uint64
+}
+
+func (p *pin) LowerLeft() Point  { return Point{p.X, p.Y} }
+func (p *pin) UpperRight() Point { return Point{p.X, p.Y} }
+func (p *pin) ID() string        { return p.Name }
+func (p *pin) Version() uint64   { return p.Rev }
+
+func init() {
+	gob.Register(&pin{})
+}
+
+func TestGob(t *testing.T) {
+	rt, _ := NewTree(2, 4, 12, WithLazyDelete())
+	for i := 0; i < 300; i++ {
+		rt.Insert(rect(Point{uint64(i), uint64(i % 7)}, Point{uint64(i + 1), uint64(i%7 + 1)}))
+	}
+	rt.Insert(&pin{5, 5, "a", 1})
+	rt.Insert(&pin{6, 6, "a", 2})
+	rt.Delete(rect(Point{10, 3}, Point{11, 4}))
+
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(rt); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	var loaded HRtree
+	if err := gob.NewDecoder(&buf).Decode(&loaded); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if a, b := loaded.Stats(), rt.Stats(); a != b {
+		t.Errorf("expected %+v, got %+v", b, a)
+	}
+
+	bb := rect(Point{0, 0}, Point{1000, 1000})
+	want, got := rt.SearchIntersect(bb), loaded.SearchIntersect(bb)
+	if len(got) != len(want) {
+		t.Fatalf("expected %d results, got %d", len(want), len(got))
+	}
+
+	for i := range want {
+		if !equal(got[i], want[i]) {
+			t.Errorf("expected %v, got %v", want[i], got[i])
+		}
+	}
+
+	if obj, ok := loaded.Lookup("a"); !ok || obj.(*pin).Rev != 2 {
+		t.Errorf("expected the second version of a, got %v", obj)
+	}
+
+	// the decoded tree can be modified.
+	loaded.Insert(rect(Point{500, 500}, Point{501, 501}))
+	loaded.Vacuum()
+	if loaded.Size() != rt.Size()+1 {
+		t.Errorf("expected %d objects, got %d", rt.Size()+1, loaded.Size())
+	}
+}
+
+func TestGobInvalid(t *testing.T) {
+	rt, _ := NewTree(2, 4, 12)
+	for i := 0; i < 20; i++ {
+		rt.Insert(rect(Point{uint64(i), 0}, Point{uint64(i + 1), 1}))
+	}
+
+	data, _ := rt.GobEncode()
+	var g gobTree
+	gob.NewDecoder(bytes.NewReader(data)).Decode(&g)
+	g.Root.Children[0].LHV = big.NewInt(1)
+
+	var buf bytes.Buffer
+	gob.NewEncoder(&buf).Encode(&g)
+
+	var loaded HRtree
+	if err := loaded.GobDecode(buf.Bytes()); !errors.Is(err, ErrInvalidGob) {
+		t.Errorf("expected %v, got %v", ErrInvalidGob, err)
+	}
+}
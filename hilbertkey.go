@@ -0,0 +1,38 @@
+package hrtree
+
+// HilbertKeyed is implemented by objects keeping the ordering key a tree computes for
+// them, so that their center isn't encoded again when they are inserted again, such as
+// after a Delete, or merged. SetHilbertKey is called by mutations once the key is
+// computed; objects whose bounds change must be given the zero HilbertKey.
+type HilbertKeyed interface {
+	HilbertKey() HilbertKey
+	SetHilbertKey(HilbertKey)
+}
+
+// HilbertKey is the ordering key of an object, only used by the tree which computed it
+// and by trees ordering objects alike, such as those returned by Merge. The zero value
+// holds no key.
+type HilbertKey struct {
+	key   hkey
+	space *keySpace
+}
+
+// keySpace identifies the keys of trees ordering objects alike.
+type keySpace struct{ _ byte }
+
+// cachedKey returns the key of obj, reusing the one it keeps if it implements
+// HilbertKeyed.
+func (tree *HRtree) cachedKey(obj Rectangle) hkey {
+	o, ok := obj.(HilbertKeyed)
+	if !ok {
+		return tree.key(obj)
+	}
+
+	if k := o.HilbertKey(); k.space != nil && k.space == tree.space {
+		return k.key
+	}
+
+	h := tree.key(obj)
+	o.SetHilbertKey(HilbertKey{h, tree.space})
+	return h
+}
@@ -0,0 +1,62 @@
+package hrtree
+
+import (
+	"testing"
+)
+
+type keyedRect struct {
+	rectangle
+	key  HilbertKey
+	sets int
+}
+
+func (r *keyedRect) HilbertKey() HilbertKey {
+	return r.key
+}
+
+func (r *keyedRect) SetHilbertKey(k HilbertKey) {
+	r.key = k
+	r.sets++
+}
+
+func TestHilbertKeyed(t *testing.T) {
+	rt, _ := NewTree(2, 4, 12)
+	var objs []*keyedRect
+	for i := 0; i < 50; i++ {
+		obj := &keyedRect{rectangle: *rect(Point{uint64(3 * i), 7}, Point{uint64(3*i + 1), 8})}
+		rt.Insert(obj)
+		objs = append(objs, obj)
+	}
+
+	for _, obj := range objs {
+		if obj.sets != 1 || obj.key.key.cmp(rt.key(obj)) != 0 {
+			t.Fatalf("expected the key of %v to be kept", obj)
+		}
+	}
+
+	// inserting again reuses the key.
+	obj := objs[10]
+	rt.Delete(obj)
+	obj.key.key = hkey{lo: 1}
+	rt.Insert(obj)
+	if obj.sets != 1 {
+		t.Errorf("expected the key to be reused")
+	}
+
+	if leaf := rt.findLeaf(rt.root, obj); leaf == nil || leaf.getEntries()[0].obj != obj {
+		t.Errorf("expected the object to be ordered by its key")
+	}
+
+	// keys of other trees are computed again.
+	other, _ := NewTree(2, 4, 12)
+	other.Insert(objs[20])
+	if objs[20].sets != 2 || objs[20].key.space != other.space {
+		t.Errorf("expected the key to be computed by the other tree")
+	}
+
+	// merged trees order objects as the first one.
+	merged, _ := Merge(rt, other)
+	if objs[30].sets != 1 || objs[20].sets != 3 || merged.Size() != 50 {
+		t.Errorf("expected the keys of the first tree to be kept, got %d and %d sets", objs[30].sets, objs[20].sets)
+	}
+}
@@ -0,0 +1,81 @@
+package hrtree
+
+import (
+	"math"
+)
+
+// Histogram summarizes the extents of the objects of a tree, see HRtree.Histogram.
+type Histogram struct {
+	Areas   []Bucket // by number of cells covered, bounds being inclusive
+	Aspects []Bucket // by ratio of the longest side to the shortest one, in cells
+}
+
+// Bucket counts the values between Min and Max, Max being excluded but for the last bucket.
+type Bucket struct {
+	Min, Max float64
+	Count    int
+}
+
+// Histogram returns the distribution of the areas and aspect ratios of the objects of the
+// tree over the given number of buckets, spaced logarithmically from 1 to the largest
+// value. Large or elongated objects enlarge the bounding-boxes of their nodes, and so the
+// number of nodes visited by searches: they show up in the last buckets.
+func (tree *HRtree) Histogram(buckets int) Histogram {
+	if tree.rlockTree() {
+		defer tree.runlock()
+	}
+
+	if buckets < 1 {
+		buckets = 1
+	}
+
+	var areas, aspects []float64
+	for _, leaf := range tree.root.leaves(nil) {
+		for _, e := range leaf.getEntries() {
+			if e.dead {
+				continue
+			}
+
+			area, longest, shortest := 1.0, 0.0, math.Inf(1)
+			for i := 0; i < Dim; i++ {
+				side := float64(e.bb.upperRight[i]-e.bb.lowerLeft[i]) + 1
+				area *= side
+				longest = math.Max(longest, side)
+				shortest = math.Min(shortest, side)
+			}
+
+			areas = append(areas, area)
+			aspects = append(aspects, longest/shortest)
+		}
+	}
+
+	return Histogram{histogram(areas, buckets), histogram(aspects, buckets)}
+}
+
+// histogram spreads values, all at least 1, over buckets spaced logarithmically.
+func histogram(values []float64, n int) []Bucket {
+	max := 1.0
+	for _, v := range values {
+		max = math.Max(max, v)
+	}
+
+	buckets := make([]Bucket, n)
+	for i := range buckets {
+		buckets[i].Min = math.Pow(max, float64(i)/float64(n))
+		buckets[i].Max = math.Pow(max, float64(i+1)/float64(n))
+	}
+
+	for _, v := range values {
+		i := 0
+		if max > 1 {
+			i = int(math.Log(v) / math.Log(max) * float64(n))
+		}
+
+		if i >= n {
+			i = n - 1
+		}
+		buckets[i].Count++
+	}
+
+	return buckets
+}
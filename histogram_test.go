@@ -0,0 +1,57 @@
+package hrtree
+
+import (
+	"testing"
+)
+
+func TestHistogram(t *testing.T) {
+	// objects as wide in every dimension, which stay square in 3 dimensions.
+	cube := func(x, y, side uint64) *rectangle {
+		lower := Point{x, y}
+		upper := lower
+		for i := range upper {
+			upper[i] += side - 1
+		}
+		return rect(lower, upper)
+	}
+
+	rt, _ := NewTree(2, 4, 12, WithLazyDelete())
+	for i := uint64(0); i < 90; i++ {
+		rt.Insert(cube(2*i, 0, 1)) // a single cell
+	}
+	for i := uint64(0); i < 9; i++ {
+		rt.Insert(cube(10*i, 10, 10)) // 10x10 cells
+	}
+	outlier := rect(Point{0, 100}, Point{999, 100}) // 1000x1 cells
+	rt.Insert(outlier)
+	rt.Insert(rect(Point{0, 200}, Point{999, 299}))
+	rt.Delete(rect(Point{0, 200}, Point{999, 299})) // removed objects are left out
+
+	h := rt.Histogram(3)
+	if len(h.Areas) != 3 || len(h.Aspects) != 3 {
+		t.Fatalf("expected 3 buckets, got %d and %d", len(h.Areas), len(h.Aspects))
+	}
+
+	// areas 1, 100 and 1000 out of 1-10, 10-100 and 100-1000.
+	for i, count := range []int{90, 0, 10} {
+		if h.Areas[i].Count != count {
+			t.Errorf("expected %d areas in %v, got %d", count, h.Areas[i], h.Areas[i].Count)
+		}
+	}
+
+	if h.Areas[0].Min != 1 || h.Areas[2].Max < 999.99 || h.Areas[2].Max > 1000.01 {
+		t.Errorf("unexpected bounds %v", h.Areas)
+	}
+
+	// all but the outlier are square.
+	for i, count := range []int{99, 0, 1} {
+		if h.Aspects[i].Count != count {
+			t.Errorf("expected %d aspect ratios in %v, got %d", count, h.Aspects[i], h.Aspects[i].Count)
+		}
+	}
+
+	empty, _ := NewTree(2, 4, 12)
+	if h := empty.Histogram(0); len(h.Areas) != 1 || h.Areas[0].Count != 0 {
+		t.Errorf("expected a single empty bucket, got %v", h.Areas)
+	}
+}
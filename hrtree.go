@@ -4,20 +4,25 @@ import (
 	"errors"
 	"fmt"
 	h "github.com/jtejido/hilbert"
-	"math"
-	"math/big"
+	"runtime/pprof"
 	"sort"
+	"sync"
+	"sync/atomic"
 )
 
 const (
 	DefaultMaxNodeEntries = 1000
 	DefaultMinNodeEntries = 20
-	Dim                   = 2
 	SiblingsNumber        = 2  // minimum number of cooperating siblings used for moving entries before split is considered
 	DefaultResolution     = 32 // minimum resolution required for hilbert computation's resolution
 )
 
-var ErrMinGTMax = errors.New("Minimum number of nodes should be less than Maximum number of nodes and not vice versa.")
+var (
+	ErrMinGTMax           = errors.New("Minimum number of nodes should be less than Maximum number of nodes and not vice versa.")
+	ErrUnderflowThreshold = errors.New("Underflow threshold should not be greater than the minimum number of node entries.")
+	ErrDimensionMismatch  = errors.New("Tree has another number of dimensions than the build.")
+	ErrCorrupted          = errors.New("Tree structure is corrupted.")
+)
 
 // HRtree represents a Hilbert R-tree, a balanced search tree for storing and querying
 // spatial objects.  MinChildren/MaxChildren specify the minimum/maximum branching factors.
@@ -25,40 +30,156 @@ type HRtree struct {
 	min, max, bits int
 	root           *node
 	hf             *h.Hilbert
+	lut            *hilbertLUT // tables encoding small resolutions, or nil
+	keyFunc        func(Rectangle) uint64
+	curve          Curve     // ordering of objects, the hilbert curve if nil
+	space          *keySpace // keys computed by the tree, see HilbertKeyed
+	weights        []float64 // scale of each dimension before encoding, or nil
+	transform      *transform
+	wrap           []int // periodic dimensions
+	refine         func(obj, window Rectangle) bool
+	equal          func(a, b Rectangle) bool // lookup of objects, see WithEqualFunc
+	codec          *ObjectCodec
+	arena          *entryArena // allocator of node entries, or nil
+	underflow      int         // entries below which nodes are merged, min if 0
+	policy         UnderflowPolicy
+	duplicates     DuplicatePolicy
+	deferred       int // underflowing leaves left to Vacuum
 	size           int
+
+	mu         sync.RWMutex // held by mutations, and by searches with WithConcurrency
+	changes    uint64       // number of mutations so far
+	dataBytes  uint64       // bytes of the objects inserted or deleted so far
+	cp         *checkpointer
+	store      *nodeStore
+	lazy       bool // Delete only marks entries as removed
+	concurrent bool // searches hold the read lock
+	tombstones int  // number of entries marked as removed
+	versions   map[string]version
+	progress   func(Progress)
+	labels     []pprof.LabelSet // profiler labels of each operation
+	pools      pools
+
+	cow    bool                      // searches traverse the published view
+	frozen bool                      // snapshot, which can't be modified
+	view   atomic.Pointer[published] // version of the tree searched with WithSnapshotReads
 }
 
-// NewTree creates a new HRtree instance.
-func NewTree(min, max, bits int) (*HRtree, error) {
-	hf, err := h.New(uint32(bits), Dim)
+// NewTree creates a new HRtree instance, opts enable optional behaviour.
+func NewTree(min, max, bits int, opts ...Option) (*HRtree, error) {
+	rt := HRtree{min: min, max: max, bits: bits}
+	for _, opt := range opts {
+		opt(&rt)
+	}
+
+	hf, err := h.New(uint32(rt.bits), Dim)
 
 	if err != nil {
-		return nil, err
+		return nil, fmt.Errorf("NewTree: resolution %d: %w", rt.bits, err)
+	}
+
+	if rt.min < 0 {
+		rt.min = DefaultMinNodeEntries
+	}
+
+	if rt.max < 0 {
+		rt.max = DefaultMaxNodeEntries
 	}
 
-	if min < 0 {
-		min = DefaultMinNodeEntries
+	if rt.max < rt.min {
+		return nil, fmt.Errorf("NewTree: min %d, max %d: %w", rt.min, rt.max, ErrMinGTMax)
 	}
 
-	if max < 0 {
-		max = DefaultMaxNodeEntries
+	if rt.underflow < 0 || rt.underflow > rt.min {
+		return nil, fmt.Errorf("NewTree: underflow threshold %d, min %d: %w", rt.underflow, rt.min, ErrUnderflowThreshold)
 	}
 
-	if max < min {
-		return nil, ErrMinGTMax
+	if err := rt.checkWeights(); err != nil {
+		return nil, fmt.Errorf("NewTree: weights %v: %w", rt.weights, err)
 	}
 
-	rt := HRtree{min: min, max: max, bits: bits, hf: hf}
-	rt.root = newNode(min, max)
+	if err := rt.checkTransform(); err != nil {
+		return nil, fmt.Errorf("NewTree: transform: %w", err)
+	}
+
+	if err := rt.checkWrap(); err != nil {
+		return nil, fmt.Errorf("NewTree: wrapped dimensions %v: %w", rt.wrap, err)
+	}
+
+	if rt.store != nil {
+		pageSize := rt.store.PageSize()
+		if err := checkPageSize(pageSize); err != nil {
+			return nil, fmt.Errorf("NewTree: %w", err)
+		}
+
+		if rt.max > MaxEntriesForPage(pageSize) {
+			return nil, fmt.Errorf("NewTree: %d entries per node in %d-byte pages: %w", rt.max, pageSize, ErrPageTooSmall)
+		}
+	}
+
+	min, max = rt.min, rt.max
+	rt.hf = hf
+	rt.lut = lutFor(hf, rt.bits)
+	rt.space = new(keySpace)
+	rt.root = rt.allocNode()
 	rt.root.leaf = true
+	rt.publish()
+
+	if rt.cp != nil {
+		if err := checkPageSize(rt.cp.pageSize); err != nil {
+			return nil, fmt.Errorf("NewTree: %w", err)
+		}
+
+		if max > MaxEntriesForPage(rt.cp.pageSize) {
+			return nil, fmt.Errorf("NewTree: %d entries per node in %d-byte pages: %w", max, rt.cp.pageSize, ErrPageTooSmall)
+		}
+
+		rt.cp.start(&rt)
+	}
+
 	return &rt, nil
 }
 
+// corrupted is the value of the panics of node helpers finding the structure of the tree
+// broken, which mutations return as ErrCorrupted.
+type corrupted string
+
+// recoverCorrupted stores a corrupted panic of the operation op in err, as an error
+// wrapping ErrCorrupted. Other panics are not recovered.
+func recoverCorrupted(op string, err *error) {
+	if r := recover(); r != nil {
+		msg, ok := r.(corrupted)
+		if !ok {
+			panic(r)
+		}
+		*err = fmt.Errorf("%s: %s: %w", op, msg, ErrCorrupted)
+	}
+}
+
+// MustNewTree is like NewTree but panics on error. It is meant for tests and examples.
+func MustNewTree(min, max, bits int, opts ...Option) *HRtree {
+	tree, err := NewTree(min, max, bits, opts...)
+	if err != nil {
+		panic(err)
+	}
+
+	return tree
+}
+
 // Size returns the number of objects currently stored in tree.
 func (tree *HRtree) Size() int {
+	if tree.cow {
+		return tree.view.Load().size
+	}
+
+	if tree.rlock() {
+		defer tree.runlock()
+	}
+
 	return tree.size
 }
 
+// String returns a fixed name, the nodes of the tree are written by Dump.
 func (tree *HRtree) String() string {
 	return "(HRtree)"
 }
@@ -70,15 +191,20 @@ type node struct {
 	left, right *node
 	leaf        bool
 	entries     *entryList
-	lhv         *big.Int
+	lhv         hkey
 	bb          *rectangle // bounding-box of all children of this entry
+	layers      LayerMask  // layers of all objects under this node
+	attrs       uint64     // union of the attributes of all objects under this node
+	pub         *node      // copy published for searches, see WithSnapshotReads
+	page        uint64     // page of the node in the store of WithNodeStore, 0 until written
+	stored      bool       // whether the page is up to date
+	dirty       bool       // LHV and bounding-box to be recomputed, see clean
 }
 
 func newNode(min, max int) *node {
 	return &node{
 		min:     min,
 		max:     max,
-		lhv: 	 big.NewInt(0),
 		entries: newList(max),
 	}
 }
@@ -93,34 +219,117 @@ func (n *node) getEntries() []entry {
 
 // adjustLHV gets the largest Hilbert value among the node's entries
 func (n *node) adjustLHV() {
+	n.touch()
+	n.lhv = hkey{}
 	for _, en := range n.getEntries() {
 
-		if n.lhv.Cmp(en.getLHV()) < 0 {
+		if n.lhv.cmp(en.getLHV()) < 0 {
 			n.lhv = en.getLHV()
 		}
 	}
 }
 
-// adjustMBR adjusts the bounding box of the node
+// adjustMBR adjusts the bounding box of the node, along with the layers and attributes found under it
 func (n *node) adjustMBR() {
+	n.touch()
 	var bb rectangle
+	var layers LayerMask
+	var attrs uint64
 	for i, e := range n.getEntries() {
 		if i == 0 {
 			bb = *e.getMBR()
 		} else {
 			bb.enlarge(e.getMBR())
 		}
+
+		layers.union(e.getLayers())
+		attrs |= e.getAttributes()
 	}
 
 	n.bb = &bb
+	n.layers = layers
+	n.attrs = attrs
+}
+
+// extend brings the LHV and bounding-box of n up to date with e, an entry just added to
+// it, then those of its ancestors. As nothing was removed, they are enlarged rather than
+// recomputed, and ancestors are left alone from the first one that doesn't change.
+func (n *node) extend(e entry) {
+	n.touch()
+	for ; n != nil; e, n = (entry{node: n}), n.parent {
+		if n.bb == nil {
+			n.adjustLHV()
+			n.adjustMBR()
+			continue
+		}
+
+		layers := n.layers
+		layers.union(e.getLayers())
+		attrs := n.attrs | e.getAttributes()
+		higher, outside := n.lhv.cmp(e.getLHV()) < 0, !n.bb.contains(e.getMBR())
+		if !higher && !outside && layers == n.layers && attrs == n.attrs {
+			return
+		}
+
+		if higher {
+			n.lhv = e.getLHV()
+		}
+
+		// bounding-boxes may be shared with published copies, see touch.
+		if outside {
+			bb := *n.bb
+			bb.enlarge(e.getMBR())
+			n.bb = &bb
+		}
+		n.layers, n.attrs = layers, attrs
+	}
+}
+
+// refresh recomputes the LHV and bounding-box of n after its entries changed, then those
+// of its ancestors, up to the first one that doesn't change.
+func (n *node) refresh() {
+	for ; n != nil; n = n.parent {
+		lhv, bb, layers, attrs := n.lhv, n.bb, n.layers, n.attrs
+		n.adjustLHV()
+		n.adjustMBR()
+		if n.lhv.cmp(lhv) == 0 && bb != nil && *n.bb == *bb && n.layers == layers && n.attrs == attrs {
+			return
+		}
+	}
+}
+
+// clean recomputes the LHV and bounding-box of n if it is dirty, so that parents shared
+// by several nodes changed by a split or a merge are only recomputed once.
+func (n *node) clean() {
+	if n.dirty {
+		n.dirty = false
+		n.adjustLHV()
+		n.adjustMBR()
+	}
 }
 
 func (n *node) isOverflowing() bool {
 	return n.entries.len() == n.max
 }
 
+// isUnderflowing reports whether n holds fewer entries than the minimum.
 func (n *node) isUnderflowing() bool {
-	return n.entries.len() <= n.min
+	return n.entries.len() < n.min
+}
+
+// isUnderflowing reports whether n should be merged with its siblings, see
+// WithUnderflowThreshold.
+func (tree *HRtree) isUnderflowing(n *node) bool {
+	return n.entries.len() < tree.underflowAt()
+}
+
+// underflowAt returns the number of entries below which nodes underflow.
+func (tree *HRtree) underflowAt() int {
+	if tree.underflow > 0 {
+		return tree.underflow
+	}
+
+	return tree.min
 }
 
 func (n *node) getSiblings(siblingsNum int) []*node {
@@ -135,31 +344,9 @@ func (n *node) getSiblings(siblingsNum int) []*node {
 	return nodes
 }
 
-func (n *node) removeLeaf(obj Rectangle) bool {
-	if !n.leaf {
-		panic("Cannot remove entry from nonleaf node.")
-	}
-
-	ind := -1
-	for i, en := range n.entries.getEntries() {
-
-		if equal(en.obj, obj) {
-			ind = i
-		}
-	}
-
-	if ind < 0 {
-		return false
-	}
-
-	n.entries.entries = append(n.entries.entries[:ind], n.entries.entries[ind+1:]...)
-
-	return true
-}
-
 func (n *node) removeNonLeaf(node *node) bool {
 	if n.leaf {
-		panic("Cannot remove entry from leaf node.")
+		panic(corrupted("child removed from a leaf"))
 	}
 
 	ind := -1
@@ -182,11 +369,11 @@ func (n *node) removeNonLeaf(node *node) bool {
 func (n *node) insertLeaf(e entry) {
 
 	if !n.leaf {
-		panic("The current node is not a leaf node.")
+		panic(corrupted("object inserted into a non-leaf node"))
 	}
 
 	if n.isOverflowing() {
-		panic("The node is overflowing.")
+		panic(corrupted("entry inserted into a full node"))
 	}
 
 	n.entries.insert(e)
@@ -195,14 +382,14 @@ func (n *node) insertLeaf(e entry) {
 func (n *node) insertNonLeaf(e entry) {
 
 	if n.leaf {
-		panic("The current node is a leaf node.")
+		panic(corrupted("child inserted into a leaf"))
 	}
 
 	if n.isOverflowing() {
-		panic("The node is overflowing.")
+		panic(corrupted("entry inserted into a full node"))
 	}
 
-	it := n.entries.insert(e)
+	it := n.entries.insertNode(e)
 
 	e.node.parent = n
 
@@ -218,6 +405,11 @@ func (n *node) insertNonLeaf(e entry) {
 		prevSib = prev.node
 	}
 
+	// links are only kept within a parent: a node moved to the edge of another is
+	// unlinked from its former neighbours, which would otherwise still point to it.
+	if prevSib == nil && e.node.left != nil && e.node.left.right == e.node {
+		e.node.left.right = nil
+	}
 	e.node.left = prevSib
 
 	if prevSib != nil {
@@ -235,6 +427,9 @@ func (n *node) insertNonLeaf(e entry) {
 		nextSib = next.node
 	}
 
+	if nextSib == nil && e.node.right != nil && e.node.right.left == e.node {
+		e.node.right.left = nil
+	}
 	e.node.right = nextSib
 
 	if nextSib != nil {
@@ -243,11 +438,33 @@ func (n *node) insertNonLeaf(e entry) {
 	}
 }
 
+// linkChildren links the children of n to each other, left to right. As with
+// insertNonLeaf, links are kept within a parent: the first and last children have no
+// sibling on their outer side.
+func (n *node) linkChildren() {
+	var prev *node
+	for _, e := range n.getEntries() {
+		e.node.left = prev
+		if prev != nil {
+			prev.right = e.node
+		}
+		prev = e.node
+	}
+
+	if prev != nil {
+		prev.right = nil
+	}
+}
+
 // reset entries, bounding-box and largest hilbert value.
 func (n *node) reset() {
-	n.entries = newList(n.max)
+	arena := n.entries.arena
+	arena.release(n.entries)
+	n.entries = arena.list(n.max)
 	n.bb = nil
-	n.lhv = big.NewInt(0)
+	n.lhv = hkey{}
+	n.layers = LayerMask{}
+	n.attrs = 0
 }
 
 func (n *node) getMBR() *rectangle {
@@ -258,11 +475,14 @@ func (n *node) getMBR() *rectangle {
 // this is shared between non-leaf and leaf entries.
 // non-leaf has node, leaf has obj
 type entry struct {
-	bb   *rectangle // bounding-box of of this entry
-	node *node
-	obj  Rectangle
-	h    *big.Int // hilbert value
-	leaf bool
+	bb    *rectangle // bounding-box of of this entry
+	node  *node
+	obj   Rectangle
+	h     hkey // hilbert value
+	leaf  bool
+	layer uint8
+	attrs uint64
+	dead  bool // removed by a lazy deletion, waiting for Vacuum
 }
 
 func (e entry) String() string {
@@ -280,11 +500,28 @@ func (e entry) getMBR() *rectangle {
 	}
 }
 
-func (e entry) getLHV() *big.Int {
+func (e entry) getLayers() LayerMask {
+	if e.leaf {
+		return Layers(e.layer)
+	}
+
+	return e.node.layers
+}
+
+func (e entry) getAttributes() uint64 {
+	if e.leaf {
+		return e.attrs
+	}
+
+	return e.node.attrs
+}
+
+// getLHV returns the hilbert value of a leaf entry, or the LHV of the child node.
+func (e entry) getLHV() hkey {
 	if e.leaf {
 		return e.h
 	} else {
-		return big.NewInt(0)
+		return e.node.lhv
 	}
 }
 
@@ -292,6 +529,7 @@ func (e entry) getLHV() *big.Int {
 // this is used for abstracting utilities
 type entryList struct {
 	entries []entry
+	arena   *entryArena // arena of the entries, see WithArena
 }
 
 func newList(max int) *entryList {
@@ -308,7 +546,7 @@ func newListUncapped() *entryList {
 
 func (l *entryList) insert(el entry) int {
 
-	index := sort.Search(len(l.entries), func(i int) bool { return l.entries[i].getLHV().Cmp(el.getLHV()) ==1 })
+	index := sort.Search(len(l.entries), func(i int) bool { return l.entries[i].getLHV().cmp(el.getLHV()) == 1 })
 	l.entries = append(l.entries, entry{})
 	copy(l.entries[index+1:], l.entries[index:])
 	l.entries[index] = el
@@ -316,6 +554,31 @@ func (l *entryList) insert(el entry) int {
 
 }
 
+// insertNode inserts the entry of a child node. Among entries of the same LHV, it goes
+// next to its siblings already in the list, so that entries keep the order of the links.
+func (l *entryList) insertNode(el entry) int {
+	h := el.getLHV()
+	lo := sort.Search(len(l.entries), func(i int) bool { return l.entries[i].getLHV().cmp(h) >= 0 })
+	index := lo
+	for ; index < len(l.entries) && l.entries[index].getLHV().cmp(h) == 0; index++ {
+		if l.entries[index].node == el.node.right {
+			break
+		}
+	}
+
+	for i := lo; i < index; i++ {
+		if l.entries[i].node == el.node.left {
+			index = i + 1
+			break
+		}
+	}
+
+	l.entries = append(l.entries, entry{})
+	copy(l.entries[index+1:], l.entries[index:])
+	l.entries[index] = el
+	return index
+}
+
 func (l *entryList) first() entry {
 	return l.entries[0]
 }
@@ -338,60 +601,128 @@ func (l entryList) getEntries() []entry {
 
 // Insert inserts a spatial object into the tree. Through Center(), we compute the hilbert value
 // from the uncollapsed n-dimensional coordinates.
-func (tree *HRtree) Insert(obj Rectangle) {
+//
+// If obj is Versioned, it supersedes the current version of its ID, which is marked as
+// removed until the next Vacuum. Versions that are not newer than the current one are ignored.
+// It returns an error wrapping ErrCorrupted if the structure of the tree is found broken,
+// rather than panicking; the tree should then be rebuilt. Snapshots fail with ErrReadOnly.
+// Objects equal to one already in the tree are handled as set by WithDuplicatePolicy.
+func (tree *HRtree) Insert(obj Rectangle) (err error) {
+
+	if err := tree.lock(); err != nil {
+		return fmt.Errorf("Insert: %w", err)
+	}
+	defer tree.unlock()
+	defer recoverCorrupted("Insert", &err)
 
-	hv := tree.hf.Encode(getCenter(obj)...)
-	e := entry{&rectangle{obj.LowerLeft(), obj.UpperRight()}, nil, obj, hv, true}
-	tree.insert(e)
+	if err := tree.addObject(obj); err != nil {
+		return fmt.Errorf("Insert: %w", err)
+	}
+
+	return nil
+}
+
+// insertObject inserts obj, the tree being locked by the caller.
+func (tree *HRtree) insertObject(obj Rectangle) {
+	if v, ok := obj.(Versioned); ok && !tree.supersede(v) {
+		return
+	}
+
+	tree.insert(tree.newEntry(obj))
 	tree.size++
+	tree.changed(1)
+}
+
+// newEntry builds the leaf entry of obj.
+func (tree *HRtree) newEntry(obj Rectangle) entry {
+	return entry{
+		bb:    &rectangle{obj.LowerLeft(), obj.UpperRight()},
+		obj:   obj,
+		h:     tree.cachedKey(obj),
+		leaf:  true,
+		layer: layerOf(obj),
+		attrs: attributesOf(obj),
+	}
+}
+
+// key returns the ordering key of obj, see WithKeyFunc.
+func (tree *HRtree) key(obj Rectangle) hkey {
+	if tree.keyFunc != nil {
+		return hkey{lo: tree.keyFunc(obj)}
+	}
+
+	return tree.encode(tree.weigh(getCenter(obj)))
 }
 
 // insert adds the specified entry to the tree at the specified level.
 func (tree *HRtree) insert(e entry) {
-	siblings := make([]*node, 0)
 	leaf := tree.chooseNode(tree.root, e.h)
-	var split *node
+	if leaf == nil {
+		// only empty non-leaf nodes are left, start over from a single leaf.
+		tree.root = tree.allocNode()
+		tree.root.leaf = true
+		leaf = tree.root
+	}
 
 	if !leaf.isOverflowing() {
 		leaf.insertLeaf(e)
-		leaf.adjustLHV()
-		leaf.adjustMBR()
-		siblings = append(siblings, leaf)
-
-	} else {
-		// split leaf if overflows
-		split, siblings = handleOverflow(leaf, e, siblings)
+		leaf.extend(e)
+		return
 	}
 
+	// split leaf if overflows
+	split, siblings := handleOverflow(leaf, e, nil)
+
 	// TO-DO.. make the caller handle root adjustments
 	tree.root = tree.adjustTreeForInsert(tree.root, leaf, split, siblings)
 
 }
 
-// chooseNode finds the node to which e should be added.
-func (tree *HRtree) chooseNode(n *node, h *big.Int) *node {
-	if n.leaf {
-		return n
-	}
+// chooseNode finds the node to which e should be added. Subtrees without any leaf, left
+// by heavy deletions, are passed over; it returns nil if there is no leaf under n.
+func (tree *HRtree) chooseNode(n *node, h hkey) *node {
+	stack := tree.getStack()
+	defer tree.putStack(stack)
+
+	s := append(*stack, n)
+	for len(s) > 0 {
+		n := s[len(s)-1]
+		s = s[:len(s)-1]
+		if n.leaf {
+			*stack = s
+			return n
+		}
 
-	// choose the entry (R, ptr, LHV) with the minimum LHV value greater than h.
-	var last entry
-	for _, en := range n.getEntries() {
-		assert(!en.leaf)
-		if en.node.lhv.Cmp(h) >= 0 {
-			return tree.chooseNode(en.node, h)
+		// choose the entry (R, ptr, LHV) with the minimum LHV value greater than h.
+		//if h is larger than all the LHV already in the node,
+		//choose the last of the node entries
+		entries := n.getEntries()
+		chosen := len(entries) - 1
+		for i, en := range entries {
+			assert(!en.leaf)
+			if en.node.lhv.cmp(h) >= 0 {
+				chosen = i
+				break
+			}
+		}
+
+		// fall back on the closest entries, left ones first: entries are pushed so as to
+		// be popped from the chosen one down to the first, then up to the last.
+		for i := len(entries) - 1; i > chosen; i-- {
+			s = append(s, entries[i].node)
+		}
+
+		for i := 0; i <= chosen; i++ {
+			s = append(s, entries[i].node)
 		}
-		last = en
 	}
+	*stack = s
 
-	//if h is larger than all the LHV already in the node,
-	//choose the last of the node entries
-	return tree.chooseNode(last.node, h)
+	return nil
 }
 
 // TO-DO..unify with adjustTreeForRemove
 func (tree *HRtree) adjustTreeForInsert(root, n, nn *node, siblings []*node) (newRoot *node) {
-	var pp *node
 	var ok bool = true
 
 	newRoot = root
@@ -400,11 +731,12 @@ func (tree *HRtree) adjustTreeForInsert(root, n, nn *node, siblings []*node) (ne
 	s := siblings
 
 	for ok {
+		var pp *node
 		np := n.parent
 		if np == nil {
 			ok = false
 			if nn != nil {
-				newRoot = newNode(tree.min, tree.max)
+				newRoot = tree.allocNode()
 
 				newRoot.insertNonLeaf(entry{node: n})
 				newRoot.insertNonLeaf(entry{node: nn})
@@ -431,8 +763,11 @@ func (tree *HRtree) adjustTreeForInsert(root, n, nn *node, siblings []*node) (ne
 			}
 
 			for _, node := range s {
-				node.parent.adjustLHV()
-				node.parent.adjustMBR()
+				node.parent.dirty = true
+			}
+
+			for _, node := range s {
+				node.parent.clean()
 			}
 
 			n = np
@@ -451,8 +786,6 @@ func (tree *HRtree) adjustTreeForInsert(root, n, nn *node, siblings []*node) (ne
 func (tree *HRtree) adjustTreeForRemove(n, nn *node, siblings []*node) {
 	var keepRunning bool = true
 
-	newSiblings := make([]*node, 0)
-
 	s := siblings
 
 	for keepRunning {
@@ -483,23 +816,31 @@ func (tree *HRtree) adjustTreeForRemove(n, nn *node, siblings []*node) {
 			n.adjustLHV()
 			n.adjustMBR()
 		} else {
+			var newSiblings []*node
 
 			if nn != nil {
 				dnParent := nn.parent
 				dnParent.removeNonLeaf(nn)
 
-				if dnParent.isUnderflowing() {
-					dpParent, newSiblings = tree.handleUnderflow(dnParent, newSiblings)
+				if tree.isUnderflowing(dnParent) {
+					dpParent, newSiblings = tree.handleUnderflow(dnParent, nil)
 				} else {
+					dnParent.adjustLHV()
+					dnParent.adjustMBR()
 					newSiblings = append(newSiblings, dnParent)
 				}
 			}
 
-			newSiblings = append(newSiblings, np)
+			// siblings may belong to different parents, whose ancestors all need adjusting.
+			for _, node := range s {
+				node.parent.dirty = true
+			}
 
 			for _, node := range s {
-				node.parent.adjustLHV()
-				node.parent.adjustMBR()
+				if node.parent.dirty {
+					newSiblings = append(newSiblings, node.parent)
+				}
+				node.parent.clean()
 			}
 
 			n = np
@@ -544,6 +885,7 @@ func handleOverflow(n *node, e entry, nodes []*node) (*node, []*node) {
 
 	if entries.len() > len(nodes)*max {
 		nn = newNode(min, max)
+		nn.entries = n.entries.arena.list(max)
 		nn.leaf = e.leaf
 
 		prevSib := n.left
@@ -568,7 +910,25 @@ func handleOverflow(n *node, e entry, nodes []*node) (*node, []*node) {
 	return nn, nodes
 }
 
+// handleUnderflow moves the entries of the underflowing target to the cooperating
+// siblings chosen by the underflow policy, see WithUnderflowPolicy. It returns target if
+// it was dropped, and the nodes whose entries changed.
 func (tree *HRtree) handleUnderflow(target *node, nodes []*node) (*node, []*node) {
+	switch tree.policy {
+	case UnderflowBorrow:
+		if nodes := tree.lender(target); nodes != nil {
+			return tree.cooperate(target, nodes, false)
+		}
+	case UnderflowMergeLeft, UnderflowMergeRight:
+		if nodes := tree.mergeSiblings(target); nodes != nil {
+			entries := 0
+			for _, node := range nodes {
+				entries += node.entries.len()
+			}
+
+			return tree.cooperate(target, nodes, entries <= tree.max)
+		}
+	}
 
 	var nn *node
 
@@ -576,6 +936,11 @@ func (tree *HRtree) handleUnderflow(target *node, nodes []*node) (*node, []*node
 
 	nodes = target.getSiblings(SiblingsNumber + 1)
 
+	// the rightmost node has to cooperate with its left sibling instead.
+	if len(nodes) == 1 && target.left != nil {
+		nodes = []*node{target.left, target}
+	}
+
 	for _, node := range nodes {
 		for _, e := range node.getEntries() {
 			entries.insert(e)
@@ -584,21 +949,10 @@ func (tree *HRtree) handleUnderflow(target *node, nodes []*node) (*node, []*node
 		node.reset()
 	}
 
-	if entries.len() < len(nodes)*tree.min && target.parent != nil {
-		nn = nodes[0]
-		prevSib := nn.left
-		nextSib := nn.right
-
-		if prevSib != nil {
-			prevSib.right = nextSib
-		}
-
-		if nextSib != nil {
-			nextSib.left = prevSib
-		}
-
-		nodes = append(nodes[:0], nodes[0+1:]...)
-
+	// the target can only be dropped if some sibling is left to take its entries.
+	if entries.len() < len(nodes)*tree.min && len(nodes) > 1 && target.parent != nil {
+		nn = target
+		nodes = nn.unlink(nodes)
 	}
 
 	redistributeEntries(entries, nodes)
@@ -606,15 +960,35 @@ func (tree *HRtree) handleUnderflow(target *node, nodes []*node) (*node, []*node
 	return nn, nodes
 }
 
-func redistributeEntries(entries *entryList, siblings []*node) {
-	batchSize := int(math.Ceil(float64(entries.len()) / float64(len(siblings))))
+// unlink removes n from its siblings, and from nodes, which is returned.
+func (n *node) unlink(nodes []*node) []*node {
+	prevSib := n.left
+	nextSib := n.right
 
-	var currentBatch int
+	if prevSib != nil {
+		prevSib.right = nextSib
+	}
 
-	j := 0
-	for _, sibling := range siblings {
+	if nextSib != nil {
+		nextSib.left = prevSib
+	}
+
+	for i, node := range nodes {
+		if node == n {
+			return append(nodes[:i], nodes[i+1:]...)
+		}
+	}
 
-		for i := j; i < entries.len(); i++ {
+	return nodes
+}
+
+func redistributeEntries(entries *entryList, siblings []*node) {
+	// siblings get as many entries as each other, give or take one, so that none is left
+	// underflowing while others could spare some.
+	total, count := entries.len(), len(siblings)
+	for k, sibling := range siblings {
+		lo, hi := (k*total+count-1)/count, ((k+1)*total+count-1)/count
+		for i := lo; i < hi; i++ {
 			ee := entries.get(i)
 
 			if ee.leaf {
@@ -622,15 +996,6 @@ func redistributeEntries(entries *entryList, siblings []*node) {
 			} else {
 				sibling.insertNonLeaf(ee)
 			}
-
-			currentBatch++
-
-			if currentBatch == batchSize {
-				currentBatch = 0
-				j = i + 1
-
-				break
-			}
 		}
 
 		sibling.adjustLHV()
@@ -638,30 +1003,105 @@ func redistributeEntries(entries *entryList, siblings []*node) {
 	}
 }
 
-func (tree *HRtree) Delete(obj Rectangle) (ok bool) {
-	leaf := tree.findLeaf(tree.root, obj)
+// Delete removes obj, or any object with the same bounds, from the tree (see DeleteExact
+// and WithEqualFunc). Versioned objects are removed by ID and purged by the next Vacuum.
+// The deletion is remembered at the newest of the current version and obj's version, so
+// that only newer versions can be inserted again. Errors are returned as with Insert.
+func (tree *HRtree) Delete(obj Rectangle) (ok bool, err error) {
+
+	if err := tree.lock(); err != nil {
+		return false, fmt.Errorf("Delete: %w", err)
+	}
+	defer tree.unlock()
+	defer recoverCorrupted("Delete", &err)
+
+	return tree.deleteObject(obj), nil
+}
+
+// deleteObject removes obj, the tree being locked by the caller.
+func (tree *HRtree) deleteObject(obj Rectangle) (ok bool) {
+	if v, isVersioned := obj.(Versioned); isVersioned {
+		if ok = tree.deleteVersion(v); ok {
+			tree.changed(1)
+		}
+
+		return
+	}
+
+	leaf, i := tree.lookup(obj)
 	if leaf == nil {
 		return
 	}
+	tree.removeEntry(leaf, i)
 
-	var dl *node
+	return true
+}
 
-	siblings := make([]*node, 0)
+// lookup finds the live entry of an object equal to obj, see WithEqualFunc. It returns
+// the leaf and the entry's position in it, or a nil leaf.
+func (tree *HRtree) lookup(obj Rectangle) (*node, int) {
+	if leaf := tree.findLeaf(tree.root, obj); leaf != nil {
+		for i, en := range leaf.getEntries() {
+			if !en.dead && tree.equals(en.obj, obj) {
+				return leaf, i
+			}
+		}
+	}
 
-	if leaf.removeLeaf(obj) {
+	return nil, -1
+}
 
-		tree.size--
+// DeleteExact removes obj itself from the tree: unlike Delete, which removes any object
+// with the same bounds, only the entry holding obj is removed, objects being compared as
+// interface values. Versioned objects are removed by ID, as with Delete. Errors are
+// returned as with Insert.
+func (tree *HRtree) DeleteExact(obj Rectangle) (ok bool, err error) {
+	if err := tree.lock(); err != nil {
+		return false, fmt.Errorf("DeleteExact: %w", err)
+	}
+	defer tree.unlock()
+	defer recoverCorrupted("DeleteExact", &err)
 
-		if leaf.isUnderflowing() {
-			dl, siblings = tree.handleUnderflow(leaf, siblings)
-		}
+	if _, ok := obj.(Versioned); ok {
+		return tree.deleteObject(obj), nil
+	}
 
-		tree.adjustTreeForRemove(leaf, dl, siblings)
+	leaf, i := tree.findEntry(tree.root, obj, func(o Rectangle) bool {
+		return sameObject(o, obj)
+	})
+	if leaf == nil {
+		return false, nil
+	}
+	tree.removeEntry(leaf, i)
+
+	return true, nil
+}
+
+// removeEntry removes the i-th entry of leaf, or only marks it as removed with
+// WithLazyDelete.
+func (tree *HRtree) removeEntry(leaf *node, i int) {
+	tree.size--
+	tree.changed(1)
+
+	if tree.lazy {
+		leaf.entries.entries[i].dead = true
+		leaf.touch()
+		tree.tombstones++
+		return
+	}
 
-		ok = true
+	leaf.entries.entries = append(leaf.entries.entries[:i], leaf.entries.entries[i+1:]...)
+
+	if tree.isUnderflowing(leaf) && (tree.policy != UnderflowDefer || leaf.entries.len() == 0) {
+		dl, siblings := tree.handleUnderflow(leaf, nil)
+		tree.adjustTreeForRemove(leaf, dl, siblings)
+		return
 	}
 
-	return
+	if tree.isUnderflowing(leaf) {
+		tree.deferred++
+	}
+	leaf.refresh()
 }
 
 // findLeaf finds the leaf node containing obj.
@@ -679,7 +1119,7 @@ func (tree *HRtree) findLeaf(n *node, obj Rectangle) *node {
 			}
 			// check if the leaf actually contains the object
 			for _, leafEntry := range leaf.getEntries() {
-				if equal(leafEntry.obj, obj) {
+				if !leafEntry.dead && tree.equals(leafEntry.obj, obj) {
 					return leaf
 				}
 			}
@@ -690,23 +1130,23 @@ func (tree *HRtree) findLeaf(n *node, obj Rectangle) *node {
 }
 
 // Searching
-// SearchIntersect returns all objects that intersects the specified rectangle.
-func (tree *HRtree) SearchIntersect(bb Rectangle) []Rectangle {
-	results := []Rectangle{}
-	return tree.searchIntersect(tree.root, bb, results)
-}
+// SearchIntersect returns all objects that intersects the specified rectangle,
+// opts may further restrict the results.
+func (tree *HRtree) SearchIntersect(bb Rectangle, opts ...QueryOption) []Rectangle {
+	q := newQuery(opts)
+	if tree.label(q.ctx, opSearch) {
+		defer unlabel(q.ctx)
+	}
 
-func (tree *HRtree) searchIntersect(n *node, bb Rectangle, results []Rectangle) []Rectangle {
+	if tree.rlock() {
+		defer tree.runlock()
+	}
 
-	for _, e := range n.getEntries() {
+	results := []Rectangle{}
+	tree.searchIntersectFunc(bb, q, func(obj Rectangle) bool {
+		results = append(results, obj)
+		return true
+	})
 
-		if intersect(e.getMBR(), bb) {
-			if n.leaf {
-				results = append(results, e.obj)
-			} else {
-				results = tree.searchIntersect(e.node, bb, results)
-			}
-		}
-	}
 	return results
 }
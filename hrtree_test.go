@@ -1,21 +1,16 @@
 package hrtree
 
 import (
+	"errors"
 	"fmt"
 	h "github.com/jtejido/hilbert"
+	"math/rand"
+	"sync"
 	"testing"
 )
 
 var hf, _ = h.New(uint32(5), 2)
 
-func (r *rectangle) LowerLeft() Point {
-	return r.lowerLeft
-}
-
-func (r *rectangle) UpperRight() Point {
-	return r.upperRight
-}
-
 func rect(lower, upper Point) *rectangle {
 	r, err := newRect(lower, upper)
 
@@ -40,16 +35,16 @@ func TestChooseNode(t *testing.T) {
 	rt, _ := NewTree(DefaultMinNodeEntries, DefaultMaxNodeEntries, 5)
 
 	rect1 := rect(Point{2, 1}, Point{2, 1})
-	h1 := hf.Encode(getCenter(rect1)...)
+	h1 := keyOf(hf.Encode(getCenter(rect1)...))
 
 	rect2 := rect(Point{2, 2}, Point{2, 2})
-	h2 := hf.Encode(getCenter(rect2)...)
+	h2 := keyOf(hf.Encode(getCenter(rect2)...))
 
 	rect3 := rect(Point{2, 3}, Point{2, 3})
-	h3 := hf.Encode(getCenter(rect3)...)
+	h3 := keyOf(hf.Encode(getCenter(rect3)...))
 
 	rect4 := rect(Point{2, 4}, Point{2, 4})
-	h4 := hf.Encode(getCenter(rect4)...)
+	h4 := keyOf(hf.Encode(getCenter(rect4)...))
 
 	l1 := entry{bb: rect1, obj: rect1, h: h2, leaf: true}
 	l2 := entry{bb: rect2, obj: rect2, h: h3, leaf: true}
@@ -89,10 +84,19 @@ func TestChooseNode(t *testing.T) {
 	nonLeaf.insertNonLeaf(entry2)
 	nonLeaf.insertNonLeaf(entry1)
 
-	if childNode3 != rt.chooseNode(nonLeaf, h2) {
+	// the entry with the minimum LHV greater than or equal to h.
+	if childNode1 != rt.chooseNode(nonLeaf, h2) {
+		t.Errorf("incorrect chooseNode")
+	}
+
+	if childNode3 != rt.chooseNode(nonLeaf, h4) {
 		t.Errorf("incorrect chooseNode")
 	}
 
+	if nonLeaf.adjustLHV(); nonLeaf.lhv.cmp(h4) != 0 {
+		t.Errorf("expected the LHV of the children, got %v", nonLeaf.lhv)
+	}
+
 }
 
 func TestInsertNonLeafEntry(t *testing.T) {
@@ -118,7 +122,7 @@ func TestInsertNonLeafEntrySiblings(t *testing.T) {
 	childNode := newNode(2, 4)
 	childNode.leaf = true
 	rect := rect(Point{2, 2}, Point{2, 4})
-	h := hf.Encode(getCenter(rect)...)
+	h := keyOf(hf.Encode(getCenter(rect)...))
 	leafEntry := entry{bb: rect, obj: rect, h: h, leaf: true}
 	childNode.insertLeaf(leafEntry)
 
@@ -145,7 +149,7 @@ func TestInsertNonLeafEntrySiblings(t *testing.T) {
 
 func TestNodeOverflowing(t *testing.T) {
 	rect := rect(Point{2, 2}, Point{2, 4})
-	h := hf.Encode(getCenter(rect)...)
+	h := keyOf(hf.Encode(getCenter(rect)...))
 
 	leafEntry := entry{bb: rect, obj: rect, h: h, leaf: true}
 	leafEntry2 := entry{bb: rect, obj: rect, h: h, leaf: true}
@@ -167,7 +171,7 @@ func TestNodeOverflowing(t *testing.T) {
 
 func TestNodeUnderflowing(t *testing.T) {
 	rect := rect(Point{2, 2}, Point{2, 4})
-	h := hf.Encode(getCenter(rect)...)
+	h := keyOf(hf.Encode(getCenter(rect)...))
 
 	leafEntry := entry{bb: rect, obj: rect, h: h, leaf: true}
 	leafEntry2 := entry{bb: rect, obj: rect, h: h, leaf: true}
@@ -190,15 +194,15 @@ func TestNodeUnderflowing(t *testing.T) {
 
 func TestAdjustMBR(t *testing.T) {
 	rect1 := rect(Point{2, 0}, Point{2, 4})
-	h1 := hf.Encode(getCenter(rect1)...)
+	h1 := keyOf(hf.Encode(getCenter(rect1)...))
 	leafEntry1 := entry{bb: rect1, obj: rect1, h: h1, leaf: true}
 
 	rect2 := rect(Point{2, 1}, Point{2, 5})
-	h2 := hf.Encode(getCenter(rect2)...)
+	h2 := keyOf(hf.Encode(getCenter(rect2)...))
 	leafEntry2 := entry{bb: rect2, obj: rect2, h: h2, leaf: true}
 
 	rect3 := rect(Point{2, 5}, Point{2, 10})
-	h3 := hf.Encode(getCenter(rect3)...)
+	h3 := keyOf(hf.Encode(getCenter(rect3)...))
 	leafEntry3 := entry{bb: rect3, obj: rect3, h: h3, leaf: true}
 
 	n := newNode(2, 4)
@@ -253,12 +257,12 @@ func TestAdjustMBR(t *testing.T) {
 
 func TestAdjustMBR2(t *testing.T) {
 	rect1 := rect(Point{2, 2}, Point{2, 3})
-	h1 := hf.Encode(getCenter(rect1)...)
+	h1 := keyOf(hf.Encode(getCenter(rect1)...))
 
 	leafEntry1 := entry{bb: rect1, obj: rect1, h: h1, leaf: true}
 
 	rect2 := rect(Point{2, 8}, Point{2, 8})
-	h2 := hf.Encode(getCenter(rect2)...)
+	h2 := keyOf(hf.Encode(getCenter(rect2)...))
 
 	leafEntry2 := entry{bb: rect2, obj: rect2, h: h2, leaf: true}
 
@@ -289,11 +293,11 @@ func TestAdjustMBR2(t *testing.T) {
 
 func TestAdjustLHV(t *testing.T) {
 	rect1 := rect(Point{2, 0}, Point{2, 0})
-	h1 := hf.Encode(getCenter(rect1)...)
+	h1 := keyOf(hf.Encode(getCenter(rect1)...))
 	leafEntry1 := entry{bb: rect1, obj: rect1, h: h1, leaf: true}
 
 	rect2 := rect(Point{2, 0}, Point{2, 2})
-	h2 := hf.Encode(getCenter(rect2)...)
+	h2 := keyOf(hf.Encode(getCenter(rect2)...))
 	leafEntry2 := entry{bb: rect2, obj: rect2, h: h2, leaf: true}
 
 	n := newNode(2, 4)
@@ -303,11 +307,11 @@ func TestAdjustLHV(t *testing.T) {
 
 	n.adjustLHV()
 
-	if h1.Cmp(h2) >= 0 {
+	if h1.cmp(h2) >= 0 {
 		t.Errorf("incorrect hilbert value")
 	}
 
-	if h2.Cmp(n.lhv) != 0 {
+	if h2.cmp(n.lhv) != 0 {
 		t.Errorf("incorrect hilbert value")
 	}
 }
@@ -365,13 +369,13 @@ func TestHandleOverflow(t *testing.T) {
 
 	for i := 0; i < DefaultMaxNodeEntries; i++ {
 		rect := rect(Point{2, uint64(i)}, Point{2, uint64(i)})
-		h := hf2.Encode(getCenter(rect)...)
+		h := keyOf(hf2.Encode(getCenter(rect)...))
 		entry := entry{bb: rect, obj: rect, h: h, leaf: true}
 		node1.insertLeaf(entry)
 	}
 
 	rect2 := rect(Point{2, 0}, Point{2, 0})
-	h2 := hf2.Encode(getCenter(rect2)...)
+	h2 := keyOf(hf2.Encode(getCenter(rect2)...))
 	entry2 := entry{bb: rect2, obj: rect2, h: h2, leaf: true}
 
 	node2, _ := handleOverflow(node1, entry2, siblings)
@@ -499,6 +503,44 @@ func TestDelete(t *testing.T) {
 	}
 }
 
+func TestDeleteExact(t *testing.T) {
+	for _, lazy := range []bool{false, true} {
+		var opts []Option
+		if lazy {
+			opts = append(opts, WithLazyDelete())
+		}
+		rt, _ := NewTree(2, 4, 12, opts...)
+
+		for i := 0; i < 20; i++ {
+			rt.Insert(rect(Point{uint64(i), 0}, Point{uint64(i + 1), 1}))
+		}
+
+		first, second := rect(Point{5, 5}, Point{6, 6}), rect(Point{5, 5}, Point{6, 6})
+		rt.Insert(first)
+		rt.Insert(second)
+
+		if ok, _ := rt.DeleteExact(rect(Point{5, 5}, Point{6, 6})); ok {
+			t.Errorf("expected an equal object not to be removed")
+		}
+
+		if ok, _ := rt.DeleteExact(second); !ok {
+			t.Fatalf("expected %v to be removed", second)
+		}
+
+		if q := rt.SearchIntersect(first); len(q) != 1 || q[0] != first {
+			t.Errorf("expected the first object to remain, got %v", q)
+		}
+
+		if ok, _ := rt.DeleteExact(second); ok {
+			t.Errorf("expected %v to be removed once", second)
+		}
+
+		if rt.Size() != 21 {
+			t.Errorf("expected 21 objects, got %d", rt.Size())
+		}
+	}
+}
+
 func TestDeleteAtMax(t *testing.T) {
 	rt, _ := NewTree(DefaultMinNodeEntries, DefaultMaxNodeEntries, 12)
 
@@ -545,6 +587,31 @@ func TestDeleteAtMax2(t *testing.T) {
 	}
 }
 
+func leafDepths(n *node, depth int, depths map[int]bool) {
+	if n.leaf {
+		depths[depth] = true
+		return
+	}
+
+	for _, e := range n.getEntries() {
+		leafDepths(e.node, depth+1, depths)
+	}
+}
+
+func TestInsertBalanced(t *testing.T) {
+	rt, _ := NewTree(4, 9, 12)
+
+	for i := 0; i < 500; i++ {
+		rt.Insert(rect(Point{uint64(i % 40), uint64(i / 40)}, Point{uint64(i%40 + 1), uint64(i/40 + 1)}))
+
+		depths := make(map[int]bool)
+		leafDepths(rt.root, 0, depths)
+		if len(depths) != 1 {
+			t.Fatalf("leaves at different depths after %d inserts", i+1)
+		}
+	}
+}
+
 func TestRedistributeEntries(t *testing.T) {
 	entries := newListUncapped()
 	nodes := make([]*node, 0)
@@ -560,7 +627,7 @@ func TestRedistributeEntries(t *testing.T) {
 	for i := 0; i < DefaultMaxNodeEntries*2-1; i++ {
 		rect := rect(Point{2, 1}, Point{2, 1})
 
-		h := hf.Encode(getCenter(rect)...)
+		h := keyOf(hf.Encode(getCenter(rect)...))
 		leafEntry := entry{bb: rect, obj: rect, h: h, leaf: true}
 		entries.insert(leafEntry)
 	}
@@ -576,6 +643,62 @@ func TestRedistributeEntries(t *testing.T) {
 	}
 }
 
+func TestInsertNonLeafEqualLHV(t *testing.T) {
+	parent := newNode(2, 4)
+	var children []*node
+	for i := 0; i < 3; i++ {
+		n := newNode(2, 4)
+		n.leaf = true
+		n.lhv = hkey{lo: 5}
+		children = append(children, n)
+	}
+	x, y, z := children[0], children[1], children[2]
+
+	parent.insertNonLeaf(entry{node: x})
+	parent.insertNonLeaf(entry{node: y})
+
+	// z was linked between x and y, it goes back there rather than after all the nodes
+	// of the same LHV.
+	z.left, z.right = x, y
+	parent.insertNonLeaf(entry{node: z})
+
+	for i, want := range []*node{x, z, y} {
+		if parent.entries.get(i).node != want {
+			t.Errorf("expected child %d to be %c", i, "xzy"[i])
+		}
+	}
+
+	if x.right != z || z.right != y || y.left != z || z.left != x {
+		t.Errorf("expected the links to follow the entries")
+	}
+}
+
+func TestInsertNonLeafUnlinks(t *testing.T) {
+	var nodes []*node
+	for i := uint64(0); i < 4; i++ {
+		n := newNode(2, 4)
+		n.leaf = true
+		n.lhv = hkey{lo: i}
+		nodes = append(nodes, n)
+	}
+
+	// b, then c, are moved alone to other parents, away from their former neighbours.
+	a, b, c, d := nodes[0], nodes[1], nodes[2], nodes[3]
+	a.right, b.left = b, a
+	c.right, d.left = d, c
+
+	newNode(2, 4).insertNonLeaf(entry{node: b})
+	newNode(2, 4).insertNonLeaf(entry{node: c})
+
+	if a.right != nil || b.left != nil {
+		t.Errorf("expected a and b to be unlinked")
+	}
+
+	if c.right != nil || d.left != nil {
+		t.Errorf("expected c and d to be unlinked")
+	}
+}
+
 func TestSearchIntersectNoResult(t *testing.T) {
 	rt, _ := NewTree(3, 3, 12)
 	things := []Rectangle{
@@ -648,3 +771,416 @@ func BenchmarkInsert(b *testing.B) {
 		}
 	}
 }
+
+func TestMustNewTree(t *testing.T) {
+	rt := MustNewTree(2, 4, 12)
+	rt.Insert(MustNewRect(Point{1, 1}, Point{2, 2}))
+
+	if rt.Size() != 1 {
+		t.Errorf("expected 1 object, got %d", rt.Size())
+	}
+
+	defer func() {
+		if err, _ := recover().(error); !errors.Is(err, ErrMinGTMax) {
+			t.Errorf("expected a panic with %v, got %v", ErrMinGTMax, err)
+		}
+	}()
+
+	MustNewTree(4, 2, 12)
+}
+
+// checkTree verifies the structure of rt against the objects it should hold.
+func checkTree(t *testing.T, rt *HRtree, objs map[Rectangle]bool) {
+	t.Helper()
+
+	if rt.Size() != len(objs) {
+		t.Fatalf("expected %d objects, got %d", len(objs), rt.Size())
+	}
+
+	depths := make(map[int]bool)
+	leafDepths(rt.root, 0, depths)
+	if len(depths) > 1 {
+		t.Fatalf("leaves at different depths")
+	}
+
+	if err := rt.CheckInvariants(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	q := rt.SearchIntersect(rect(Point{0, 0}, Point{1 << 20, 1 << 20}))
+	if len(q) != len(objs) {
+		t.Fatalf("expected %d results, got %d", len(objs), len(q))
+	}
+
+	for _, obj := range q {
+		if !objs[obj] {
+			t.Fatalf("unexpected result %v", obj)
+		}
+	}
+
+	var check func(n *node)
+	check = func(n *node) {
+		var lhv hkey
+		for i, e := range n.getEntries() {
+			if i > 0 && lhv.cmp(e.getLHV()) > 0 {
+				t.Fatalf("entries not ordered by hilbert value")
+			}
+			lhv = e.getLHV()
+
+			if !n.leaf {
+				if e.node.parent != n {
+					t.Fatalf("incorrect parent")
+				}
+				check(e.node)
+			}
+		}
+
+		if n.entries.len() > 0 && n.lhv.cmp(lhv) != 0 {
+			t.Fatalf("expected LHV %v, got %v", lhv, n.lhv)
+		}
+	}
+	check(rt.root)
+}
+
+func TestRandomInsertDelete(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+
+	for _, size := range [][2]int{{2, 4}, {3, 8}, {4, 9}} {
+		rt, _ := NewTree(size[0], size[1], 12)
+		objs := make(map[Rectangle]bool)
+		var list []Rectangle
+
+		for i := 0; i < 3000; i++ {
+			if len(list) == 0 || r.Intn(5) < 3 {
+				// lower and upper x coordinates are unique to each object.
+				x, y := uint64(3*i), uint64(r.Intn(1000))
+				obj := rect(Point{x, y}, Point{x + 1 + uint64(r.Intn(2)), y + uint64(r.Intn(10))})
+				rt.Insert(obj)
+				objs[obj] = true
+				list = append(list, obj)
+			} else {
+				j := r.Intn(len(list))
+				if ok, _ := rt.Delete(list[j]); !ok {
+					t.Fatalf("failed to delete %v", list[j])
+				}
+				delete(objs, list[j])
+				list[j] = list[len(list)-1]
+				list = list[:len(list)-1]
+			}
+
+			if i%100 == 0 {
+				checkTree(t, rt, objs)
+			}
+		}
+		checkTree(t, rt, objs)
+	}
+}
+
+func TestDeleteAllReinsert(t *testing.T) {
+	rt, _ := NewTree(2, 4, 12)
+	objs := make(map[Rectangle]bool)
+
+	var list []Rectangle
+	for i := uint64(0); i < 500; i++ {
+		obj := rect(Point{3 * i, i % 50}, Point{3*i + 1, i%50 + 1})
+		rt.Insert(obj)
+		list = append(list, obj)
+	}
+
+	for _, obj := range list {
+		if ok, _ := rt.Delete(obj); !ok {
+			t.Fatalf("failed to delete %v", obj)
+		}
+	}
+	checkTree(t, rt, objs)
+
+	for i := uint64(0); i < 100; i++ {
+		obj := rect(Point{3 * i, 7}, Point{3*i + 1, 8})
+		rt.Insert(obj)
+		objs[obj] = true
+	}
+	checkTree(t, rt, objs)
+}
+
+func TestChooseNodeEmptySubtrees(t *testing.T) {
+	rt, _ := NewTree(2, 4, 12)
+
+	// a root left with empty non-leaf children.
+	rt.root.leaf = false
+	for i := 0; i < 2; i++ {
+		rt.root.insertNonLeaf(entry{node: newNode(2, 4)})
+	}
+
+	if rt.chooseNode(rt.root, hkey{lo: 5}) != nil {
+		t.Errorf("expected no leaf")
+	}
+
+	obj := rect(Point{1, 1}, Point{2, 2})
+	rt.Insert(obj)
+	checkTree(t, rt, map[Rectangle]bool{obj: true})
+
+	// an empty subtree next to a subtree holding a leaf.
+	leaf := newNode(2, 4)
+	leaf.leaf = true
+	parent := newNode(2, 4)
+	parent.insertNonLeaf(entry{node: leaf})
+	parent.lhv = hkey{lo: 10}
+
+	rt.root = newNode(2, 4)
+	rt.root.insertNonLeaf(entry{node: newNode(2, 4)})
+	rt.root.insertNonLeaf(entry{node: parent})
+
+	if rt.chooseNode(rt.root, hkey{lo: 0}) != leaf {
+		t.Errorf("expected the only leaf to be chosen")
+	}
+}
+
+func TestNodeAtMinimum(t *testing.T) {
+	rect := rect(Point{2, 2}, Point{2, 4})
+	n := newNode(2, 4)
+	n.leaf = true
+	n.insertLeaf(entry{bb: rect, obj: rect, h: keyOf(hf.Encode(getCenter(rect)...)), leaf: true})
+	if !n.isUnderflowing() {
+		t.Errorf("should be underflowing")
+	}
+
+	n.insertLeaf(entry{bb: rect, obj: rect, h: keyOf(hf.Encode(getCenter(rect)...)), leaf: true})
+	if n.isUnderflowing() {
+		t.Errorf("should not be underflowing at the minimum")
+	}
+}
+
+func TestUnderflowThreshold(t *testing.T) {
+	if _, err := NewTree(2, 6, 12, WithUnderflowThreshold(3)); !errors.Is(err, ErrUnderflowThreshold) {
+		t.Errorf("expected ErrUnderflowThreshold, got %v", err)
+	}
+
+	rt, err := NewTree(3, 6, 12, WithUnderflowThreshold(1))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	n := newNode(3, 6)
+	n.leaf = true
+	r := rect(Point{2, 2}, Point{2, 4})
+	n.insertLeaf(entry{bb: r, obj: r, h: keyOf(hf.Encode(getCenter(r)...)), leaf: true})
+	if rt.isUnderflowing(n) {
+		t.Errorf("should not be underflowing above the threshold")
+	}
+
+	objs := make(map[Rectangle]bool)
+	for i := 0; i < 300; i++ {
+		r := rect(Point{uint64(i), uint64(i * 7 % 300)}, Point{uint64(i + 1000), uint64(i*7%300 + 1)})
+		rt.Insert(r)
+		objs[r] = true
+	}
+
+	for r := range objs {
+		if len(objs)%2 == 0 {
+			rt.Delete(r)
+			delete(objs, r)
+		}
+	}
+	checkTree(t, rt, objs)
+}
+
+// disc is a circle stored by its bounding-box.
+type disc struct {
+	x, y, r uint64
+}
+
+func (d disc) LowerLeft() Point {
+	return Point{d.x - d.r, d.y - d.r}
+}
+
+func (d disc) UpperRight() Point {
+	return Point{d.x + d.r, d.y + d.r}
+}
+
+// crosses reports whether d intersects the window.
+func (d disc) crosses(window Rectangle) bool {
+	nearest := func(v, lo, hi uint64) float64 {
+		if v < lo {
+			return float64(lo - v)
+		} else if v > hi {
+			return float64(v - hi)
+		}
+		return 0
+	}
+
+	dx := nearest(d.x, window.LowerLeft()[0], window.UpperRight()[0])
+	dy := nearest(d.y, window.LowerLeft()[1], window.UpperRight()[1])
+	return dx*dx+dy*dy <= float64(d.r*d.r)
+}
+
+func TestRefine(t *testing.T) {
+	refine := func(obj, window Rectangle) bool {
+		return obj.(disc).crosses(window)
+	}
+
+	rt, _ := NewTree(2, 4, 12, WithRefine(refine))
+	for x := uint64(10); x < 1000; x += 20 {
+		rt.Insert(disc{x, 100, 10})
+	}
+
+	// the window touches the bounding-boxes of two discs, but only one of them.
+	bb := rect(Point{19, 105}, Point{22, 120})
+	found := rt.SearchIntersect(bb)
+	if len(found) != 1 || found[0] != (disc{30, 100, 10}) {
+		t.Errorf("expected a single disc, got %v", found)
+	}
+
+	r := rt.SearchIntersectPooled(bb)
+	if len(r.Objects) != 1 || r.Objects[0] != (disc{30, 100, 10}) {
+		t.Errorf("expected a single disc, got %v", r.Objects)
+	}
+	r.Release()
+
+	if n := len(rt.SearchIntersect(rect(Point{0, 0}, Point{1000, 200}))); n != 50 {
+		t.Errorf("expected 50 discs, got %d", n)
+	}
+}
+
+func TestEqualFunc(t *testing.T) {
+	sameName := func(a, b Rectangle) bool {
+		return a.(*site).name == b.(*site).name
+	}
+
+	rt, _ := NewTree(2, 4, 12, WithEqualFunc(sameName))
+	for i := uint64(0); i < 100; i++ {
+		// sites share their position in pairs.
+		rt.Insert(&site{string(rune('a'+i%26)) + string(rune('0'+i/26)), Point{10 * (i / 2), 10}})
+	}
+
+	// sites are only compared within the nodes containing the looked up one.
+	if ok, _ := rt.Delete(&site{"b0", Point{480, 10}}); ok {
+		t.Errorf("expected a site far from b0 not to be deleted")
+	}
+
+	if ok, _ := rt.Delete(&site{"x0", Point{0, 10}}); ok {
+		t.Errorf("expected a site named differently not to be deleted")
+	}
+
+	if ok, _ := rt.Delete(&site{"b0", Point{0, 10}}); !ok {
+		t.Fatalf("expected site b0 to be deleted")
+	}
+
+	found := rt.SearchIntersect(rect(Point{0, 10}, Point{0, 10}))
+	if len(found) != 1 || found[0].(*site).name != "a0" {
+		t.Errorf("expected only site a0 left, got %v", found)
+	}
+
+	if ok, _ := rt.Update(&site{"c0", Point{10, 10}}, &site{"c0", Point{15, 10}}); !ok || rt.Size() != 99 {
+		t.Errorf("expected site c0 to be moved")
+	}
+
+	if err := rt.CheckInvariants(); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestCorrupted(t *testing.T) {
+	rt, _ := NewTree(2, 4, 12)
+	for i := 0; i < 12; i++ {
+		rt.Insert(rect(Point{uint64(3 * i), 10}, Point{uint64(3*i + 1), 11}))
+	}
+
+	// a leaf linked to the root has the insertions splitting it mix up levels.
+	obj := rect(Point{0, 10}, Point{1, 11})
+	rt.chooseNode(rt.root, rt.key(obj)).right = rt.root
+	var err error
+	for i := 0; i < 5 && err == nil; i++ {
+		err = rt.Insert(obj)
+	}
+
+	if !errors.Is(err, ErrCorrupted) {
+		t.Errorf("expected ErrCorrupted, got %v", err)
+	}
+}
+
+func TestConcurrency(t *testing.T) {
+	rt, _ := NewTree(2, 6, 12, WithConcurrency())
+	bb := rect(Point{0, 0}, Point{4000, 4000})
+
+	var wg sync.WaitGroup
+	for w := 0; w < 4; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; i < 500; i++ {
+				x := uint64(4*i + w)
+				obj := rect(Point{x, x % 100}, Point{x + 1, x%100 + 1})
+				rt.Insert(obj)
+				if i%3 == 0 {
+					rt.Delete(obj)
+				}
+			}
+		}(w)
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 200; i++ {
+				rt.SearchIntersect(bb)
+				rt.SearchPoint(Point{uint64(i), uint64(i)})
+				rt.NearestNeighbor(Point{uint64(i), 0})
+				for range rt.Intersecting(bb, WithLimit(5)) {
+				}
+				rt.Size()
+			}
+		}()
+	}
+	wg.Wait()
+
+	if n := len(rt.SearchIntersect(bb)); n != rt.Size() || n != 4*333 {
+		t.Errorf("expected %d objects, got %d", 4*333, n)
+	}
+}
+
+func TestIncrementalAdjust(t *testing.T) {
+	r := rand.New(rand.NewSource(7))
+	rt, _ := NewTree(2, 5, 12)
+	var objs []Rectangle
+
+	// every node holds what adjustLHV and adjustMBR would compute from its entries.
+	var check func(n *node)
+	check = func(n *node) {
+		lhv, bb, layers, attrs := n.lhv, *n.bb, n.layers, n.attrs
+		n.adjustLHV()
+		n.adjustMBR()
+		if n.lhv.cmp(lhv) != 0 || *n.bb != bb || n.layers != layers || n.attrs != attrs {
+			t.Fatalf("expected %v, %v, %v and %d, got %v, %v, %v and %d", n.lhv, n.bb, n.layers, n.attrs, lhv, bb, layers, attrs)
+		}
+
+		if !n.leaf {
+			for _, e := range n.getEntries() {
+				check(e.node)
+			}
+		}
+	}
+
+	for i := 0; i < 2000; i++ {
+		switch op := r.Intn(10); {
+		case op < 6 || len(objs) == 0:
+			x, y := uint64(3*i), uint64(r.Intn(1000))
+			obj := layered(Point{x, y}, Point{x + 1, y + uint64(r.Intn(20))}, uint8(r.Intn(4)))
+			rt.Insert(obj)
+			objs = append(objs, obj)
+		case op < 9:
+			j := r.Intn(len(objs))
+			rt.Delete(objs[j])
+			objs[j] = objs[len(objs)-1]
+			objs = objs[:len(objs)-1]
+		default:
+			j := r.Intn(len(objs))
+			ll := objs[j].LowerLeft()
+			moved := rect(ll, Point{ll[0] + 1, ll[1] + uint64(r.Intn(5))})
+			rt.Update(objs[j], moved)
+			objs[j] = moved
+		}
+
+		if i%50 == 0 && rt.root.bb != nil {
+			check(rt.root)
+		}
+	}
+}
@@ -0,0 +1,123 @@
+package hrtree
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+)
+
+var (
+	ErrQueueFull      = errors.New("Ingestion queue is full.")
+	ErrIngesterClosed = errors.New("Ingester is closed.")
+)
+
+// Ingester feeds a tree from a bounded queue, applying backpressure to producers once
+// they outpace the tree's write throughput. Queued objects are inserted in the order
+// they were added, by batches sharing a single lock of the tree. Duplicates rejected by
+// WithDuplicatePolicy are dropped. Once an insertion fails, the objects still queued are
+// dropped and the error is returned by Close.
+type Ingester struct {
+	tree  *HRtree
+	queue chan Rectangle
+
+	mu     sync.RWMutex // held by producers while they queue
+	closed bool
+	done   chan struct{}
+	err    error // first failed insertion, read once done is closed
+}
+
+// NewIngester starts inserting objects into the tree from a queue holding up to size
+// objects. The ingester should be closed with Close.
+func (tree *HRtree) NewIngester(size int) *Ingester {
+	in := &Ingester{
+		tree:  tree,
+		queue: make(chan Rectangle, size),
+		done:  make(chan struct{}),
+	}
+
+	go in.run()
+
+	return in
+}
+
+func (in *Ingester) run() {
+	defer close(in.done)
+
+	for obj := range in.queue {
+		// the queue is still drained, so that producers don't wait forever.
+		if in.err == nil {
+			in.err = in.insert(obj)
+		}
+	}
+}
+
+// insert inserts obj, then whatever is already waiting in the queue while the tree is
+// held.
+func (in *Ingester) insert(obj Rectangle) (err error) {
+	if err := in.tree.lock(); err != nil {
+		return fmt.Errorf("Ingester: %w", err)
+	}
+	defer in.tree.unlock()
+	defer recoverCorrupted("Ingester", &err)
+
+	for n := len(in.queue); ; n-- {
+		if err := in.tree.addObject(obj); err != nil && !errors.Is(err, ErrDuplicate) {
+			return fmt.Errorf("Ingester: %w", err)
+		}
+
+		if n == 0 {
+			return nil
+		}
+		obj = <-in.queue
+	}
+}
+
+// Add queues obj for insertion, waiting for room in the queue if it is full.
+func (in *Ingester) Add(obj Rectangle) error {
+	in.mu.RLock()
+	defer in.mu.RUnlock()
+
+	if in.closed {
+		return ErrIngesterClosed
+	}
+
+	in.queue <- obj
+	return nil
+}
+
+// TryAdd queues obj for insertion, returning ErrQueueFull rather than waiting if the
+// queue is full.
+func (in *Ingester) TryAdd(obj Rectangle) error {
+	in.mu.RLock()
+	defer in.mu.RUnlock()
+
+	if in.closed {
+		return ErrIngesterClosed
+	}
+
+	select {
+	case in.queue <- obj:
+		return nil
+	default:
+		return ErrQueueFull
+	}
+}
+
+// Len returns the number of objects waiting in the queue.
+func (in *Ingester) Len() int {
+	return len(in.queue)
+}
+
+// Close stops accepting objects and returns once all queued objects are inserted, with the
+// error of the first insertion that failed, if any.
+func (in *Ingester) Close() error {
+	in.mu.Lock()
+	if !in.closed {
+		in.closed = true
+		close(in.queue)
+	}
+	in.mu.Unlock()
+
+	<-in.done
+	return in.err
+}
@@ -0,0 +1,102 @@
+package hrtree
+
+import (
+	"errors"
+	"sync"
+	"testing"
+)
+
+func TestIngester(t *testing.T) {
+	rt, _ := NewTree(4, 9, 12)
+	in := rt.NewIngester(16)
+
+	var wg sync.WaitGroup
+	for p := 0; p < 4; p++ {
+		wg.Add(1)
+		go func(p int) {
+			defer wg.Done()
+			for i := 0; i < 250; i++ {
+				if err := in.Add(rect(Point{uint64(p), uint64(i)}, Point{uint64(p), uint64(i)})); err != nil {
+					t.Errorf("unexpected error: %v", err)
+				}
+			}
+		}(p)
+	}
+	wg.Wait()
+
+	if err := in.Close(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if rt.Size() != 1000 {
+		t.Errorf("expected 1000 objects, got %d", rt.Size())
+	}
+
+	if q := rt.SearchIntersect(rect(Point{0, 0}, Point{3, 249})); len(q) != 1000 {
+		t.Errorf("expected 1000 results, got %d", len(q))
+	}
+
+	if err := in.Add(rect(Point{1, 1}, Point{1, 1})); err != ErrIngesterClosed {
+		t.Errorf("expected %v, got %v", ErrIngesterClosed, err)
+	}
+
+	if err := in.Close(); err != nil {
+		t.Errorf("unexpected error closing twice: %v", err)
+	}
+}
+
+func TestIngesterFull(t *testing.T) {
+	rt, _ := NewTree(4, 9, 12)
+	in := rt.NewIngester(2)
+
+	// hold the tree, so that the queue can only fill up.
+	rt.mu.Lock()
+	added := 0
+	for {
+		err := in.TryAdd(rect(Point{uint64(added), 0}, Point{uint64(added), 0}))
+		if err == ErrQueueFull {
+			break
+		}
+
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		added++
+	}
+
+	// one object may already have been taken from the queue.
+	if added < 2 || added > 3 {
+		t.Errorf("expected 2 or 3 queued objects, got %d", added)
+	}
+	rt.mu.Unlock()
+
+	in.Close()
+
+	if rt.Size() != added {
+		t.Errorf("expected %d objects, got %d", added, rt.Size())
+	}
+
+	if err := in.TryAdd(rect(Point{1, 1}, Point{1, 1})); err != ErrIngesterClosed {
+		t.Errorf("expected %v, got %v", ErrIngesterClosed, err)
+	}
+}
+
+func TestIngesterError(t *testing.T) {
+	rt, _ := NewTree(4, 9, 12)
+	rt.Insert(rect(Point{0, 0}, Point{1, 1}))
+	in := rt.Snapshot().NewIngester(4)
+
+	for i := 0; i < 10; i++ {
+		if err := in.Add(rect(Point{uint64(i), 0}, Point{uint64(i), 0})); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+
+	if err := in.Close(); !errors.Is(err, ErrReadOnly) {
+		t.Errorf("expected %v, got %v", ErrReadOnly, err)
+	}
+
+	if rt.Size() != 1 {
+		t.Errorf("expected 1 object, got %d", rt.Size())
+	}
+}
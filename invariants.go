@@ -0,0 +1,167 @@
+package hrtree
+
+import (
+	"errors"
+	"fmt"
+)
+
+var ErrInvariant = errors.New("Tree invariant violated.")
+
+// CheckInvariants verifies the structure of the tree, as meant for tests of code modifying
+// or embedding it: bounding-boxes containing those of their entries, entries ordered by
+// hilbert value with each node holding the largest of its own, leaves all at the same
+// depth, node fill within bounds, parent and sibling links, and the number of objects.
+// It returns nil if the tree is sound, or an error wrapping ErrInvariant describing the
+// first violation found. Snapshots hold no links, which are then not checked.
+//
+// Nodes other than the root hold at most max entries, and no fewer than those below which
+// they underflow (see WithUnderflowThreshold), except for the leaves left to Vacuum by
+// UnderflowDefer. Trees whose minimum is more than half of max+1 can't keep it, as a node
+// split on its own gets fewer entries. Siblings are linked within their parent only.
+func (tree *HRtree) CheckInvariants() error {
+	if tree.rlockTree() {
+		defer tree.runlock()
+	}
+
+	if err := tree.checkInvariants(); err != nil {
+		return fmt.Errorf("CheckInvariants: %w", err)
+	}
+
+	return nil
+}
+
+func (tree *HRtree) checkInvariants() error {
+	root := tree.root
+	if root.parent != nil || root.left != nil || root.right != nil {
+		return fmt.Errorf("root has a parent or siblings: %w", ErrInvariant)
+	}
+
+	links := !tree.frozen
+	live, dead, underflowing := 0, 0, 0
+	var last hkey // largest hilbert value of the level so far
+
+	for depth, level := 0, []*node{root}; len(level) > 0; depth++ {
+		var next []*node
+		for i, n := range level {
+			where := fmt.Sprintf("node %d at depth %d", i, depth)
+			if n.leaf != level[0].leaf {
+				return fmt.Errorf("%s: leaves at different depths: %w", where, ErrInvariant)
+			}
+
+			if err := tree.checkFill(n); err != nil {
+				return fmt.Errorf("%s: %w", where, err)
+			}
+
+			if n != root && n.leaf && tree.isUnderflowing(n) {
+				underflowing++
+			}
+
+			if err := n.checkEntries(links); err != nil {
+				return fmt.Errorf("%s: %w", where, err)
+			}
+
+			if links && !linked(level, i) {
+				return fmt.Errorf("%s: sibling links don't follow the level: %w", where, ErrInvariant)
+			}
+
+			for j, e := range n.getEntries() {
+				if (i > 0 || j > 0) && last.cmp(e.getLHV()) > 0 {
+					return fmt.Errorf("%s: entry %d out of hilbert order with the level: %w", where, j, ErrInvariant)
+				}
+				last = e.getLHV()
+
+				switch {
+				case !n.leaf:
+					next = append(next, e.node)
+				case e.dead:
+					dead++
+				default:
+					live++
+				}
+			}
+		}
+		level = next
+	}
+
+	if live != tree.size || dead != tree.tombstones {
+		return fmt.Errorf("%d objects and %d tombstones, %d and %d found: %w", tree.size, tree.tombstones, live, dead, ErrInvariant)
+	}
+
+	if underflowing > tree.deferred {
+		return fmt.Errorf("%d underflowing leaves, %d left to Vacuum: %w", underflowing, tree.deferred, ErrInvariant)
+	}
+
+	return nil
+}
+
+// linked reports whether the i-th node of a level is linked to its neighbours sharing its
+// parent, and to no other node.
+func linked(level []*node, i int) bool {
+	n := level[i]
+	var prev, next *node
+	if i > 0 && level[i-1].parent == n.parent {
+		prev = level[i-1]
+	}
+
+	if i+1 < len(level) && level[i+1].parent == n.parent {
+		next = level[i+1]
+	}
+
+	return n.left == prev && n.right == next
+}
+
+// checkFill checks that n holds no more entries than the maximum, nor fewer than the
+// number below which nodes underflow, unless it is the root or a leaf, whose underflows
+// may have been left to Vacuum by UnderflowDefer.
+func (tree *HRtree) checkFill(n *node) error {
+	count := n.entries.len()
+	if count > tree.max {
+		return fmt.Errorf("%d entries, at most %d: %w", count, tree.max, ErrInvariant)
+	}
+
+	if n != tree.root && !n.leaf && tree.isUnderflowing(n) {
+		return fmt.Errorf("%d entries, at least %d: %w", count, tree.underflowAt(), ErrInvariant)
+	}
+
+	return nil
+}
+
+// checkEntries checks that the entries of n are ordered by hilbert value, that its LHV is
+// the largest of them and that its bounding-box, layers and attributes cover theirs. The
+// parents of children are checked if links is set.
+func (n *node) checkEntries(links bool) error {
+	entries := n.getEntries()
+	if len(entries) > 0 && n.bb == nil {
+		return fmt.Errorf("no bounding-box: %w", ErrInvariant)
+	}
+
+	for j, e := range entries {
+		if e.leaf != n.leaf {
+			return fmt.Errorf("entry %d: leaf entry in a non-leaf node, or the reverse: %w", j, ErrInvariant)
+		}
+
+		if j > 0 && entries[j-1].getLHV().cmp(e.getLHV()) > 0 {
+			return fmt.Errorf("entry %d: out of hilbert order: %w", j, ErrInvariant)
+		}
+
+		if !n.bb.contains(e.getMBR()) {
+			return fmt.Errorf("entry %d: bounding-box %v outside of %v: %w", j, e.getMBR(), n.bb, ErrInvariant)
+		}
+
+		layers := n.layers
+		layers.union(e.getLayers())
+		if layers != n.layers || n.attrs|e.getAttributes() != n.attrs {
+			return fmt.Errorf("entry %d: layers or attributes missing from the node: %w", j, ErrInvariant)
+		}
+
+		if !n.leaf && links && e.node.parent != n {
+			return fmt.Errorf("entry %d: child linked to another parent: %w", j, ErrInvariant)
+		}
+	}
+
+	if len(entries) > 0 && n.lhv.cmp(entries[len(entries)-1].getLHV()) != 0 {
+		return fmt.Errorf("LHV %v, largest of the entries %v: %w", n.lhv, entries[len(entries)-1].getLHV(), ErrInvariant)
+	}
+
+	return nil
+}
@@ -0,0 +1,104 @@
+package hrtree
+
+import (
+	"errors"
+	"math/rand"
+	"testing"
+)
+
+func TestCheckInvariants(t *testing.T) {
+	for _, policy := range []UnderflowPolicy{UnderflowRedistribute, UnderflowBorrow, UnderflowMergeLeft, UnderflowDefer} {
+		r := rand.New(rand.NewSource(1))
+		rt, _ := NewTree(2, 6, 12, WithUnderflowPolicy(policy), WithLazyDelete())
+		var list []Rectangle
+
+		for i := 0; i < 3000; i++ {
+			if len(list) == 0 || r.Intn(3) > 0 {
+				x, y := uint64(r.Intn(1000)), uint64(r.Intn(1000))
+				obj := rect(Point{x, y}, Point{x + 1, y + uint64(r.Intn(10))})
+				rt.Insert(obj)
+				list = append(list, obj)
+			} else {
+				j := r.Intn(len(list))
+				rt.Delete(list[j])
+				list[j] = list[len(list)-1]
+				list = list[:len(list)-1]
+			}
+
+			if i%500 == 0 {
+				rt.Vacuum()
+			}
+
+			if err := rt.CheckInvariants(); err != nil {
+				t.Fatalf("policy %v, step %d: unexpected error: %v", policy, i, err)
+			}
+		}
+
+		rt.ReplaceAll(list)
+		if err := rt.CheckInvariants(); err != nil {
+			t.Fatalf("policy %v, bulk load: unexpected error: %v", policy, err)
+		}
+
+		if err := rt.Snapshot().CheckInvariants(); err != nil {
+			t.Fatalf("policy %v, snapshot: unexpected error: %v", policy, err)
+		}
+	}
+}
+
+func TestCheckInvariantsCorrupted(t *testing.T) {
+	corruptions := map[string]func(rt *HRtree){
+		"size": func(rt *HRtree) { rt.size++ },
+		"lhv": func(rt *HRtree) {
+			n := rt.root.entries.get(0).node
+			n.lhv = rt.root.lhv
+		},
+		"bounding-box": func(rt *HRtree) {
+			n := rt.root.entries.get(0).node
+			bb := *n.bb
+			bb.upperRight = bb.lowerLeft
+			n.bb = &bb
+		},
+		"parent": func(rt *HRtree) {
+			n := rt.root.entries.get(0).node
+			n.entries.get(0).node.parent = rt.root
+		},
+		"siblings": func(rt *HRtree) {
+			n := rt.root.entries.get(0).node
+			n.right = n
+		},
+		"cousins": func(rt *HRtree) {
+			l, r := rt.root.entries.get(0).node.entries.last().node, rt.root.entries.get(1).node.entries.get(0).node
+			l.right, r.left = r, l
+		},
+		"order": func(rt *HRtree) {
+			l := rt.root.leaves(nil)[0]
+			l.entries.entries[0], l.entries.entries[1] = l.entries.entries[1], l.entries.entries[0]
+		},
+		"overfull": func(rt *HRtree) {
+			l := rt.root.leaves(nil)[0]
+			for l.entries.len() <= rt.max {
+				l.entries.entries = append(l.entries.entries, l.entries.last())
+			}
+		},
+		"underfull": func(rt *HRtree) {
+			l := rt.root.leaves(nil)[0]
+			l.entries.entries = l.entries.entries[len(l.entries.entries)-1:]
+		},
+	}
+
+	for name, corrupt := range corruptions {
+		rt, _ := NewTree(2, 6, 12)
+		for i := 0; i < 500; i++ {
+			rt.Insert(rect(Point{uint64(3 * i), 10}, Point{uint64(3*i + 1), 11}))
+		}
+
+		if err := rt.CheckInvariants(); err != nil {
+			t.Fatalf("%s: unexpected error: %v", name, err)
+		}
+
+		corrupt(rt)
+		if err := rt.CheckInvariants(); !errors.Is(err, ErrInvariant) {
+			t.Errorf("%s: expected ErrInvariant, got %v", name, err)
+		}
+	}
+}
@@ -0,0 +1,71 @@
+package hrtree
+
+import (
+	"hash/crc32"
+	"time"
+)
+
+// CheckpointStats describes the writes of a tree saved with WithCheckpoint, to tune its
+// page size, the batching of its mutations and the checkpoint interval.
+type CheckpointStats struct {
+	Checkpoints   int           // checkpoints saved
+	BytesWritten  uint64        // bytes of all checkpoints
+	DataBytes     uint64        // bytes of the objects inserted or deleted until the last checkpoint, as dump records (see Export)
+	DirtyPages    uint64        // pages differing from the previous checkpoint, over all checkpoints
+	LastPages     int           // pages of the last checkpoint, metadata included
+	LastDirty     int           // pages of the last checkpoint differing from the previous one
+	LastDuration  time.Duration // time taken by the last checkpoint
+	TotalDuration time.Duration // time taken by all checkpoints
+}
+
+// WriteAmplification returns the number of bytes written per byte of objects changed.
+func (s CheckpointStats) WriteAmplification() float64 {
+	if s.DataBytes == 0 {
+		return 0
+	}
+
+	return float64(s.BytesWritten) / float64(s.DataBytes)
+}
+
+// CheckpointStats returns the statistics of the checkpoints saved so far. They are zero
+// for trees created without WithCheckpoint.
+func (tree *HRtree) CheckpointStats() CheckpointStats {
+	cp := tree.cp
+	if cp == nil {
+		return CheckpointStats{}
+	}
+
+	cp.mu.Lock()
+	defer cp.mu.Unlock()
+	return cp.stats
+}
+
+// changed records a mutation of the tree changing the given number of objects.
+func (tree *HRtree) changed(objects int) {
+	tree.changes++
+	tree.dataBytes += uint64(objects) * dumpRecordSize
+}
+
+// record accounts for a checkpoint of the given pages, covering dataBytes.
+func (cp *checkpointer) record(pages []byte, dataBytes uint64, took time.Duration) {
+	n := len(pages) / cp.pageSize
+	sums := make([]uint32, n)
+	dirty := 0
+	for i := range sums {
+		sums[i] = crc32.Checksum(pages[i*cp.pageSize:(i+1)*cp.pageSize], castagnoli)
+		if i >= len(cp.sums) || sums[i] != cp.sums[i] {
+			dirty++
+		}
+	}
+	cp.sums = sums
+
+	s := &cp.stats
+	s.Checkpoints++
+	s.BytesWritten += uint64(len(pages))
+	s.DataBytes = dataBytes
+	s.DirtyPages += uint64(dirty)
+	s.LastPages = n
+	s.LastDirty = dirty
+	s.LastDuration = took
+	s.TotalDuration += took
+}
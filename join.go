@@ -0,0 +1,116 @@
+package hrtree
+
+import (
+	"math"
+)
+
+// JoinWithin calls fn with every pair of objects of a and b whose bounding-boxes lie
+// within distance d of each other, d being 0 for intersecting ones. Both trees are
+// traversed together, so only pairs of subtrees close enough are visited.
+func JoinWithin(a, b *HRtree, d float64, fn func(x, y Rectangle)) {
+	defer rlockBoth(a, b)()
+
+	joinWithin(a.root, b.root, d, fn)
+}
+
+// joinWithin reports the pairs of objects under na and nb, whose bounding-boxes are
+// within d of each other.
+func joinWithin(na, nb *node, d float64, fn func(x, y Rectangle)) {
+	if na.entries.len() == 0 || nb.entries.len() == 0 {
+		return
+	}
+
+	if na.leaf && nb.leaf {
+		for _, ea := range na.getEntries() {
+			if ea.dead {
+				continue
+			}
+
+			for _, eb := range nb.getEntries() {
+				if !eb.dead && rectDistance(ea.getMBR(), eb.getMBR()) <= d {
+					fn(ea.obj, eb.obj)
+				}
+			}
+		}
+
+		return
+	}
+
+	// descend the non-leaf side, both at once when neither is a leaf.
+	switch {
+	case na.leaf:
+		for _, eb := range nb.getEntries() {
+			if rectDistance(na.getMBR(), eb.getMBR()) <= d {
+				joinWithin(na, eb.node, d, fn)
+			}
+		}
+	case nb.leaf:
+		for _, ea := range na.getEntries() {
+			if rectDistance(ea.getMBR(), nb.getMBR()) <= d {
+				joinWithin(ea.node, nb, d, fn)
+			}
+		}
+	default:
+		for _, ea := range na.getEntries() {
+			for _, eb := range nb.getEntries() {
+				if rectDistance(ea.getMBR(), eb.getMBR()) <= d {
+					joinWithin(ea.node, eb.node, d, fn)
+				}
+			}
+		}
+	}
+}
+
+// Collisions calls fn once with every pair of objects of the tree whose bounding-boxes
+// intersect, as the broad phase of collision detection. The tree is traversed once, pairs
+// of subtrees being joined only where their bounding-boxes intersect. The order of a and
+// b within a pair is unspecified.
+func (tree *HRtree) Collisions(fn func(a, b Rectangle)) {
+	if tree.rlockTree() {
+		defer tree.runlock()
+	}
+
+	collisions(tree.root, fn)
+}
+
+// collisions reports the pairs of intersecting objects under n.
+func collisions(n *node, fn func(a, b Rectangle)) {
+	entries := n.getEntries()
+	for i, ea := range entries {
+		if ea.dead {
+			continue
+		}
+
+		if !n.leaf {
+			collisions(ea.node, fn)
+		}
+
+		for _, eb := range entries[i+1:] {
+			if eb.dead || !intersect(ea.getMBR(), eb.getMBR()) {
+				continue
+			}
+
+			if n.leaf {
+				fn(ea.obj, eb.obj)
+			} else {
+				joinWithin(ea.node, eb.node, 0, fn)
+			}
+		}
+	}
+}
+
+// rectDistance returns the euclidean distance between the nearest points of r1 and r2.
+func rectDistance(r1, r2 *rectangle) float64 {
+	var sum float64
+	for i := 0; i < Dim; i++ {
+		var d uint64
+		if r1.upperRight[i] < r2.lowerLeft[i] {
+			d = r2.lowerLeft[i] - r1.upperRight[i]
+		} else if r2.upperRight[i] < r1.lowerLeft[i] {
+			d = r1.lowerLeft[i] - r2.upperRight[i]
+		}
+		sum += float64(d) * float64(d)
+	}
+
+	return math.Sqrt(sum)
+}
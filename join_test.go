@@ -0,0 +1,126 @@
+package hrtree
+
+import (
+	"math/rand"
+	"testing"
+)
+
+func TestJoinWithin(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	random := func(n int, opts ...Option) (*HRtree, []Rectangle) {
+		rt, _ := NewTree(2, 6, 12, opts...)
+		var objs []Rectangle
+		for i := 0; i < n; i++ {
+			x, y := uint64(r.Intn(1000)), uint64(r.Intn(1000))
+			obj := rect(Point{x, y}, Point{x + uint64(r.Intn(10)), y + uint64(r.Intn(10))})
+			rt.Insert(obj)
+			objs = append(objs, obj)
+		}
+		return rt, objs
+	}
+
+	// trees of different heights, one with removed objects.
+	a, as := random(300, WithLazyDelete())
+	b, bs := random(20)
+	for _, obj := range as[:50] {
+		a.Delete(obj)
+	}
+	as = as[50:]
+
+	type pair struct{ x, y Rectangle }
+	for _, d := range []float64{0, 10, 50} {
+		expected := make(map[pair]bool)
+		for _, x := range as {
+			for _, y := range bs {
+				if rectDistance(x.(*rectangle), y.(*rectangle)) <= d {
+					expected[pair{x, y}] = true
+				}
+			}
+		}
+
+		found := make(map[pair]bool)
+		JoinWithin(a, b, d, func(x, y Rectangle) {
+			if found[pair{x, y}] {
+				t.Errorf("pair %v %v reported twice", x, y)
+			}
+			found[pair{x, y}] = true
+		})
+
+		if len(found) != len(expected) {
+			t.Errorf("distance %v: expected %d pairs, got %d", d, len(expected), len(found))
+		}
+
+		for p := range found {
+			if !expected[p] {
+				t.Errorf("distance %v: unexpected pair %v", d, p)
+			}
+		}
+
+		// the join is symmetric.
+		n := 0
+		JoinWithin(b, a, d, func(x, y Rectangle) {
+			if !expected[pair{y, x}] {
+				t.Errorf("distance %v: unexpected pair %v %v", d, y, x)
+			}
+			n++
+		})
+
+		if n != len(expected) {
+			t.Errorf("distance %v: expected %d reversed pairs, got %d", d, len(expected), n)
+		}
+	}
+
+	empty, _ := NewTree(2, 6, 12)
+	JoinWithin(a, empty, 100, func(x, y Rectangle) {
+		t.Errorf("unexpected pair %v %v", x, y)
+	})
+}
+
+func TestCollisions(t *testing.T) {
+	r := rand.New(rand.NewSource(2))
+	rt, _ := NewTree(2, 6, 12, WithLazyDelete())
+	var objs []Rectangle
+	for i := 0; i < 400; i++ {
+		x, y := uint64(r.Intn(1000)), uint64(r.Intn(1000))
+		obj := rect(Point{x, y}, Point{x + uint64(r.Intn(30)), y + uint64(r.Intn(30))})
+		rt.Insert(obj)
+		objs = append(objs, obj)
+	}
+
+	// removed objects don't collide.
+	for _, obj := range objs[:50] {
+		rt.Delete(obj)
+	}
+	objs = objs[50:]
+
+	type pair struct{ x, y Rectangle }
+	expected := make(map[pair]bool)
+	for i, x := range objs {
+		for _, y := range objs[i+1:] {
+			if intersect(x.(*rectangle), y) {
+				expected[pair{x, y}] = true
+			}
+		}
+	}
+
+	found := make(map[pair]bool)
+	rt.Collisions(func(a, b Rectangle) {
+		if !expected[pair{a, b}] {
+			a, b = b, a
+		}
+
+		if !expected[pair{a, b}] || found[pair{a, b}] {
+			t.Errorf("unexpected or repeated pair %v %v", a, b)
+		}
+		found[pair{a, b}] = true
+	})
+
+	if len(found) != len(expected) {
+		t.Errorf("expected %d pairs, got %d", len(expected), len(found))
+	}
+
+	empty, _ := NewTree(2, 6, 12)
+	empty.Collisions(func(a, b Rectangle) {
+		t.Errorf("unexpected pair %v %v", a, b)
+	})
+}
@@ -0,0 +1,236 @@
+package hrtree
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+)
+
+var ErrInvalidJSON = errors.New("Inconsistent JSON encoding of a tree.")
+
+// ObjectCodec converts objects to and from the JSON values of MarshalJSON and
+// UnmarshalJSON.
+type ObjectCodec struct {
+	Marshal   func(Rectangle) ([]byte, error)
+	Unmarshal func([]byte) (Rectangle, error)
+}
+
+// WithObjectCodec makes MarshalJSON and UnmarshalJSON encode objects with c, rather than
+// only their bounding-boxes.
+func WithObjectCodec(c ObjectCodec) Option {
+	return func(tree *HRtree) {
+		tree.codec = &c
+	}
+}
+
+// jsonTree is the document produced by MarshalJSON.
+type jsonTree struct {
+	Min      int                    `json:"min"`
+	Max      int                    `json:"max"`
+	Bits     int                    `json:"bits"`
+	Size     int                    `json:"size"`
+	Removed  int                    `json:"removed"`
+	Root     *jsonTreeNode          `json:"root"`
+	Versions map[string]jsonVersion `json:"versions,omitempty"`
+}
+
+type jsonTreeNode struct {
+	Leaf     bool            `json:"leaf"`
+	LHV      string          `json:"lhv"` // decimal, as it may not fit in a JSON number
+	MBR      *jsonRect       `json:"mbr"` // null for an empty tree
+	Entries  []jsonEntry     `json:"entries,omitempty"`
+	Children []*jsonTreeNode `json:"children,omitempty"`
+}
+
+type jsonEntry struct {
+	MBR     jsonRect        `json:"mbr"`
+	Hilbert string          `json:"hilbert"`
+	Object  json.RawMessage `json:"object,omitempty"` // only with WithObjectCodec
+	Removed bool            `json:"removed,omitempty"`
+}
+
+type jsonVersion struct {
+	Version uint64          `json:"version"`
+	MBR     *jsonRect       `json:"mbr"` // null once deleted
+	Object  json.RawMessage `json:"object,omitempty"`
+}
+
+// MarshalJSON encodes the tree with its whole structure, so that it can be inspected or
+// compared by tools and decoded with UnmarshalJSON. Objects are encoded with the codec
+// of WithObjectCodec, without one only their bounding-boxes are. Options are not encoded.
+func (tree *HRtree) MarshalJSON() ([]byte, error) {
+	if tree.rlockTree() {
+		defer tree.runlock()
+	}
+
+	root, err := tree.encodeJSONNode(tree.root)
+	if err != nil {
+		return nil, fmt.Errorf("MarshalJSON: %w", err)
+	}
+
+	t := jsonTree{
+		Min:     tree.min,
+		Max:     tree.max,
+		Bits:    tree.bits,
+		Size:    tree.size,
+		Removed: tree.tombstones,
+		Root:    root,
+	}
+
+	if len(tree.versions) > 0 {
+		t.Versions = make(map[string]jsonVersion, len(tree.versions))
+		for id, v := range tree.versions {
+			jv := jsonVersion{Version: v.version}
+			if v.obj != nil {
+				jv.MBR = &jsonRect{v.obj.LowerLeft(), v.obj.UpperRight()}
+				if jv.Object, err = tree.encodeJSONObject(v.obj); err != nil {
+					return nil, fmt.Errorf("MarshalJSON: version %q: %w", id, err)
+				}
+			}
+			t.Versions[id] = jv
+		}
+	}
+
+	return json.Marshal(&t)
+}
+
+func (tree *HRtree) encodeJSONNode(n *node) (*jsonTreeNode, error) {
+	jn := &jsonTreeNode{Leaf: n.leaf, LHV: n.lhv.String()}
+	if n.bb != nil {
+		jn.MBR = &jsonRect{n.bb.lowerLeft, n.bb.upperRight}
+	}
+
+	for _, e := range n.getEntries() {
+		if !n.leaf {
+			c, err := tree.encodeJSONNode(e.node)
+			if err != nil {
+				return nil, err
+			}
+			jn.Children = append(jn.Children, c)
+			continue
+		}
+
+		obj, err := tree.encodeJSONObject(e.obj)
+		if err != nil {
+			return nil, err
+		}
+
+		jn.Entries = append(jn.Entries, jsonEntry{
+			MBR:     jsonRect{e.obj.LowerLeft(), e.obj.UpperRight()},
+			Hilbert: e.h.String(),
+			Object:  obj,
+			Removed: e.dead,
+		})
+	}
+
+	return jn, nil
+}
+
+func (tree *HRtree) encodeJSONObject(obj Rectangle) (json.RawMessage, error) {
+	if tree.codec == nil || tree.codec.Marshal == nil {
+		return nil, nil
+	}
+
+	return tree.codec.Marshal(obj)
+}
+
+// UnmarshalJSON replaces the tree with the one encoded in data by MarshalJSON. Objects
+// are decoded with the codec of WithObjectCodec, without one they are rectangles with
+// the encoded bounding-boxes. As with GobDecode, the structure is checked against the
+// objects, and the tree is left unchanged if they don't match, with ErrInvalidJSON.
+func (tree *HRtree) UnmarshalJSON(data []byte) error {
+	var t jsonTree
+	if err := json.Unmarshal(data, &t); err != nil {
+		return fmt.Errorf("UnmarshalJSON: %w", err)
+	}
+
+	g := gobTree{Min: t.Min, Max: t.Max, Bits: t.Bits, Size: t.Size, Tombstones: t.Removed}
+
+	var err error
+	if t.Root != nil {
+		if g.Root, err = tree.decodeJSONNode(t.Root); err != nil {
+			return fmt.Errorf("UnmarshalJSON: %w", err)
+		}
+	}
+
+	for id, v := range t.Versions {
+		gv := gobVersion{Version: v.Version}
+		if v.MBR != nil {
+			if gv.Object, err = tree.decodeJSONObject(*v.MBR, v.Object); err != nil {
+				return fmt.Errorf("UnmarshalJSON: version %q: %w", id, err)
+			}
+		}
+
+		if g.Versions == nil {
+			g.Versions = make(map[string]gobVersion, len(t.Versions))
+		}
+		g.Versions[id] = gv
+	}
+
+	if err := tree.restore(&g, ErrInvalidJSON); err != nil {
+		return fmt.Errorf("UnmarshalJSON: %w", err)
+	}
+
+	return nil
+}
+
+// decodeJSONNode converts jn to the gob encoding of the node, which is then checked and
+// rebuilt by restore.
+func (tree *HRtree) decodeJSONNode(jn *jsonTreeNode) (*gobNode, error) {
+	if jn == nil {
+		return nil, ErrInvalidJSON
+	}
+
+	g := &gobNode{Leaf: jn.Leaf, LHV: new(big.Int)}
+	if _, ok := g.LHV.SetString(jn.LHV, 10); !ok {
+		return nil, fmt.Errorf("LHV %q: %w", jn.LHV, ErrInvalidJSON)
+	}
+
+	if jn.MBR != nil {
+		g.LowerLeft, g.UpperRight = jn.MBR.LowerLeft, jn.MBR.UpperRight
+	}
+
+	for _, e := range jn.Entries {
+		hv, ok := new(big.Int).SetString(e.Hilbert, 10)
+		if !ok {
+			return nil, fmt.Errorf("hilbert value %q: %w", e.Hilbert, ErrInvalidJSON)
+		}
+
+		obj, err := tree.decodeJSONObject(e.MBR, e.Object)
+		if err != nil {
+			return nil, err
+		}
+
+		g.Entries = append(g.Entries, gobEntry{obj, hv, e.Removed})
+	}
+
+	for _, c := range jn.Children {
+		gc, err := tree.decodeJSONNode(c)
+		if err != nil {
+			return nil, err
+		}
+		g.Children = append(g.Children, gc)
+	}
+
+	return g, nil
+}
+
+// decodeJSONObject decodes an object with the codec of the tree, checking that it has the
+// encoded bounding-box, or returns a rectangle of the bounding-box without a codec.
+func (tree *HRtree) decodeJSONObject(bb jsonRect, data json.RawMessage) (Rectangle, error) {
+	if tree.codec == nil || tree.codec.Unmarshal == nil || data == nil {
+		return &rectangle{bb.LowerLeft, bb.UpperRight}, nil
+	}
+
+	obj, err := tree.codec.Unmarshal(data)
+	if err != nil {
+		return nil, err
+	}
+
+	if obj == nil || obj.LowerLeft() != bb.LowerLeft || obj.UpperRight() != bb.UpperRight {
+		return nil, fmt.Errorf("object %s: %w", data, ErrInvalidJSON)
+	}
+
+	return obj, nil
+}
@@ -0,0 +1,102 @@
+package hrtree
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+)
+
+// pinCodec encodes pins as JSON objects.
+var pinCodec = ObjectCodec{
+	Marshal: func(obj Rectangle) ([]byte, error) {
+		return json.Marshal(obj)
+	},
+	Unmarshal: func(data []byte) (Rectangle, error) {
+		var p pin
+		err := json.Unmarshal(data, &p)
+		return &p, err
+	},
+}
+
+func TestJSON(t *testing.T) {
+	rt, _ := NewTree(2, 4, 12, WithLazyDelete(), WithObjectCodec(pinCodec))
+	for i := 0; i < 200; i++ {
+		rt.Insert(&pin{uint64(3 * i), uint64(i % 7), "", 0})
+	}
+	rt.Insert(&pin{5, 5, "a", 1})
+	rt.Insert(&pin{6, 6, "a", 2})
+	rt.Delete(&pin{30, 3, "", 0})
+
+	data, err := json.Marshal(rt)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	loaded, _ := NewTree(2, 4, 12, WithObjectCodec(pinCodec))
+	if err := json.Unmarshal(data, loaded); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if a, b := loaded.Stats(), rt.Stats(); a != b {
+		t.Errorf("expected %+v, got %+v", b, a)
+	}
+
+	bb := rect(Point{0, 0}, Point{1000, 1000})
+	want, got := rt.SearchIntersect(bb), loaded.SearchIntersect(bb)
+	if len(got) != len(want) {
+		t.Fatalf("expected %d results, got %d", len(want), len(got))
+	}
+
+	for i := range want {
+		if *got[i].(*pin) != *want[i].(*pin) {
+			t.Errorf("expected %v, got %v", want[i], got[i])
+		}
+	}
+
+	if obj, ok := loaded.Lookup("a"); !ok || obj.(*pin).Rev != 2 {
+		t.Errorf("expected the second version of a, got %v", obj)
+	}
+
+	// the encoding is stable, so that trees can be diffed.
+	if again, _ := json.Marshal(loaded); string(again) != string(data) {
+		t.Errorf("expected the decoded tree to encode as %s, got %s", data, again)
+	}
+
+	// without a codec, objects are decoded as their bounding-boxes.
+	var plain HRtree
+	if err := json.Unmarshal(data, &plain); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if found := plain.SearchIntersect(bb); len(found) != len(want) || !equal(found[0], want[0]) {
+		t.Errorf("expected %d rectangles, got %v", len(want), found)
+	}
+
+	empty, _ := NewTree(2, 4, 12)
+	data, _ = json.Marshal(empty)
+	if err := json.Unmarshal(data, &plain); err != nil || plain.Size() != 0 {
+		t.Errorf("expected an empty tree, got %d objects and %v", plain.Size(), err)
+	}
+}
+
+func TestJSONInvalid(t *testing.T) {
+	rt, _ := NewTree(2, 4, 12)
+	for i := 0; i < 20; i++ {
+		rt.Insert(rect(Point{uint64(i), 0}, Point{uint64(i + 1), 1}))
+	}
+
+	data, _ := json.Marshal(rt)
+	var doc jsonTree
+	json.Unmarshal(data, &doc)
+	leaf := doc.Root
+	for !leaf.Leaf {
+		leaf = leaf.Children[0]
+	}
+	leaf.Entries[0].MBR.UpperRight[0] += 100
+	data, _ = json.Marshal(&doc)
+
+	var loaded HRtree
+	if err := json.Unmarshal(data, &loaded); !errors.Is(err, ErrInvalidJSON) {
+		t.Errorf("expected %v, got %v", ErrInvalidJSON, err)
+	}
+}
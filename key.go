@@ -0,0 +1,167 @@
+package hrtree
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"math/bits"
+	"strconv"
+)
+
+var ErrKeyTooWide = errors.New("Key is wider than the key field of pages and tree files.")
+
+// hkey is a hilbert value, or an LHV. Values of up to 128 bits, those of every resolution
+// in two dimensions, are held in two words and compared without allocating; wider ones
+// are held in a big.Int. The representation is canonical: big is only set for values
+// that don't fit in two words, so a key with big set is larger than any other.
+type hkey struct {
+	hi, lo uint64
+	big    *big.Int
+}
+
+// keyOf returns the key of v.
+func keyOf(v *big.Int) hkey {
+	if v.BitLen() > 128 {
+		return hkey{big: v}
+	}
+
+	var k hkey
+	words := v.Bits()
+	if bits.UintSize == 64 {
+		if len(words) > 0 {
+			k.lo = uint64(words[0])
+		}
+		if len(words) > 1 {
+			k.hi = uint64(words[1])
+		}
+		return k
+	}
+
+	var buf [16]byte
+	v.FillBytes(buf[:])
+	return keyFromBytes(buf[:])
+}
+
+// keyFromBytes returns the key of the big-endian integer of buf.
+func keyFromBytes(buf []byte) hkey {
+	for len(buf) > 16 && buf[0] == 0 {
+		buf = buf[1:]
+	}
+
+	if len(buf) > 16 {
+		return hkey{big: new(big.Int).SetBytes(buf)}
+	}
+
+	var k hkey
+	for i, b := range buf {
+		if shift := 8 * uint(len(buf)-1-i); shift >= 64 {
+			k.hi |= uint64(b) << (shift - 64)
+		} else {
+			k.lo |= uint64(b) << shift
+		}
+	}
+
+	return k
+}
+
+// cmp compares k with o, returning -1, 0 or 1.
+func (k hkey) cmp(o hkey) int {
+	switch {
+	case k.big != nil && o.big != nil:
+		return k.big.Cmp(o.big)
+	case k.big != nil:
+		return 1
+	case o.big != nil:
+		return -1
+	case k.hi != o.hi:
+		if k.hi < o.hi {
+			return -1
+		}
+		return 1
+	case k.lo != o.lo:
+		if k.lo < o.lo {
+			return -1
+		}
+		return 1
+	}
+
+	return 0
+}
+
+// Int returns k as a new big.Int.
+func (k hkey) Int() *big.Int {
+	if k.big != nil {
+		return new(big.Int).Set(k.big)
+	}
+
+	v := new(big.Int).SetUint64(k.hi)
+	v.Lsh(v, 64)
+	return v.Or(v, new(big.Int).SetUint64(k.lo))
+}
+
+// putKey stores k as a fixed-width big-endian integer in buf, which must be zeroed, or
+// returns ErrKeyTooWide if it doesn't fit. Keys of two words always fit.
+func putKey(buf []byte, k hkey) error {
+	if k.big != nil {
+		b := k.big.Bytes()
+		if len(b) > len(buf) {
+			return fmt.Errorf("%d-byte key in %d bytes: %w", len(b), len(buf), ErrKeyTooWide)
+		}
+		copy(buf[len(buf)-len(b):], b)
+		return nil
+	}
+
+	for i := len(buf) - 1; i >= 0 && len(buf)-1-i < 16; i-- {
+		if shift := 8 * uint(len(buf)-1-i); shift >= 64 {
+			buf[i] = byte(k.hi >> (shift - 64))
+		} else {
+			buf[i] = byte(k.lo >> shift)
+		}
+	}
+
+	return nil
+}
+
+func (k hkey) String() string {
+	if k.big == nil && k.hi == 0 {
+		return strconv.FormatUint(k.lo, 10)
+	}
+
+	return k.Int().String()
+}
+
+// Key is a position along a Curve, an unsigned integer of any width. Keys of up to 128
+// bits are held and compared without allocating, and keys of up to Dim*8 bytes can be
+// written to pages and tree files.
+type Key struct {
+	k hkey
+}
+
+// Uint64Key returns the key v.
+func Uint64Key(v uint64) Key {
+	return Key{hkey{lo: v}}
+}
+
+// Uint128Key returns the key hi<<64 | lo.
+func Uint128Key(hi, lo uint64) Key {
+	return Key{hkey{hi: hi, lo: lo}}
+}
+
+// BigKey returns the key v, which must not be negative.
+func BigKey(v *big.Int) Key {
+	return Key{keyOf(v)}
+}
+
+// Cmp compares k with o, returning -1, 0 or 1.
+func (k Key) Cmp(o Key) int {
+	return k.k.cmp(o.k)
+}
+
+// Int returns k as a new big.Int.
+func (k Key) Int() *big.Int {
+	return k.k.Int()
+}
+
+func (k Key) String() string {
+	return k.k.String()
+}
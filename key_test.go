@@ -0,0 +1,46 @@
+package hrtree
+
+import (
+	"math/big"
+	"math/rand"
+	"testing"
+)
+
+func TestKey(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	var values []*big.Int
+	for _, width := range []uint{0, 1, 63, 64, 65, 127, 128, 129, 200} {
+		for i := 0; i < 20; i++ {
+			v := new(big.Int).Rand(r, new(big.Int).Lsh(big.NewInt(1), width))
+			values = append(values, v, new(big.Int).Lsh(big.NewInt(1), width))
+		}
+	}
+
+	for _, a := range values {
+		ka := keyOf(a)
+		if ka.Int().Cmp(a) != 0 || ka.String() != a.String() {
+			t.Errorf("expected %v, got %v", a, ka)
+		}
+
+		buf := make([]byte, 32)
+		putKey(buf, ka)
+		if kb := keyFromBytes(buf); kb.cmp(ka) != 0 || (kb.big == nil) != (a.BitLen() <= 128) {
+			t.Errorf("expected %v back from its bytes, got %v", a, kb)
+		}
+
+		for _, b := range values {
+			if got, want := ka.cmp(keyOf(b)), a.Cmp(b); got != want {
+				t.Errorf("%v against %v: expected %d, got %d", a, b, want, got)
+			}
+		}
+	}
+}
+
+func BenchmarkKeyCmp(b *testing.B) {
+	x, y := keyOf(big.NewInt(1<<40)), keyOf(big.NewInt(1<<40+1))
+	for i := 0; i < b.N; i++ {
+		if x.cmp(y) >= 0 {
+			b.Fatal("unexpected order")
+		}
+	}
+}
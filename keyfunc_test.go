@@ -0,0 +1,70 @@
+package hrtree
+
+import (
+	"bytes"
+	"testing"
+)
+
+type event struct {
+	rectangle
+	time uint64
+}
+
+func byTime(obj Rectangle) uint64 {
+	if e, ok := obj.(*event); ok {
+		return e.time
+	}
+
+	return 0
+}
+
+func TestKeyFunc(t *testing.T) {
+	rt, _ := NewTree(2, 4, 12, WithKeyFunc(byTime))
+
+	for i := uint64(0); i < 100; i++ {
+		tm := (i * 37) % 100
+		rt.Insert(&event{*rect(Point{i, 100 - i}, Point{i, 100 - i}), tm})
+	}
+
+	var last uint64
+	var walk func(v NodeView)
+	walk = func(v NodeView) {
+		for _, c := range v.Children() {
+			walk(c)
+		}
+
+		for _, e := range v.Entries() {
+			if e.Hilbert.Uint64() != e.Object.(*event).time {
+				t.Errorf("expected the key of %v to be its time, got %v", e.Object, e.Hilbert)
+			}
+
+			if e.Hilbert.Uint64() < last {
+				t.Errorf("expected leaves ordered by time")
+			}
+			last = e.Hilbert.Uint64()
+		}
+	}
+	walk(rt.Root())
+
+	if q := rt.SearchIntersect(rect(Point{10, 0}, Point{19, 100})); len(q) != 10 {
+		t.Errorf("expected 10 results, got %d", len(q))
+	}
+
+	var buf bytes.Buffer
+	if err := rt.WritePages(&buf, MinPageSize); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	loaded, err := ReadPages(bytes.NewReader(buf.Bytes()), WithKeyFunc(byTime), WithNodeEntries(5, 10))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if loaded.min != 2 || loaded.max != 4 || loaded.keyFunc == nil {
+		t.Errorf("expected the stored configuration along with the key function")
+	}
+
+	if k := loaded.key(&event{*rect(Point{1, 1}, Point{1, 1}), 42}); k.cmp(hkey{lo: 42}) != 0 {
+		t.Errorf("expected key 42, got %v", k)
+	}
+}
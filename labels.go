@@ -0,0 +1,79 @@
+package hrtree
+
+import (
+	"context"
+	"runtime/pprof"
+)
+
+// operations tagged with profiler labels.
+const (
+	opInsert = iota
+	opDelete
+	opSearch
+	opCount
+)
+
+var opNames = [opCount]string{"insert", "delete", "search"}
+
+// WithProfileLabels makes operations add the runtime/pprof labels "hrtree.tree" (set to
+// name) and "hrtree.op" to the labels of the context they are given, so that CPU profiles
+// of programs embedding several trees attribute time to each of them. InsertContext and
+// DeleteContext take the context of mutations, WithProfileContext that of searches. The
+// goroutine gets the labels of the context back when the operation returns.
+//
+// Operations given no context leave the labels of the goroutine alone: runtime/pprof can't
+// tell which labels it had, to give them back.
+func WithProfileLabels(name string) Option {
+	return func(tree *HRtree) {
+		tree.labels = make([]pprof.LabelSet, opCount)
+		for op, opName := range opNames {
+			tree.labels[op] = pprof.Labels("hrtree.tree", name, "hrtree.op", opName)
+		}
+	}
+}
+
+// WithProfileContext makes the search run with the labels of ctx and those of the tree,
+// see WithProfileLabels. ctx should carry the labels the goroutine runs with, such as the
+// context pprof.Do passes to its function.
+func WithProfileContext(ctx context.Context) QueryOption {
+	return func(q *query) {
+		q.ctx = ctx
+	}
+}
+
+// InsertContext is like Insert, with the labels of ctx and those of the tree, see
+// WithProfileLabels.
+func (tree *HRtree) InsertContext(ctx context.Context, obj Rectangle) error {
+	if tree.label(ctx, opInsert) {
+		defer unlabel(ctx)
+	}
+
+	return tree.Insert(obj)
+}
+
+// DeleteContext is like Delete, with the labels of ctx and those of the tree, see
+// WithProfileLabels.
+func (tree *HRtree) DeleteContext(ctx context.Context, obj Rectangle) (bool, error) {
+	if tree.label(ctx, opDelete) {
+		defer unlabel(ctx)
+	}
+
+	return tree.Delete(obj)
+}
+
+// label tags the calling goroutine with the labels of ctx and those of op, it reports
+// whether the goroutine should be given the labels of ctx back with unlabel. Without ctx,
+// the goroutine is left alone.
+func (tree *HRtree) label(ctx context.Context, op int) bool {
+	if tree.labels == nil || ctx == nil {
+		return false
+	}
+
+	pprof.SetGoroutineLabels(pprof.WithLabels(ctx, tree.labels[op]))
+	return true
+}
+
+// unlabel gives the calling goroutine back the labels of ctx.
+func unlabel(ctx context.Context) {
+	pprof.SetGoroutineLabels(ctx)
+}
@@ -0,0 +1,63 @@
+package hrtree
+
+import (
+	"context"
+	"runtime/pprof"
+	"testing"
+)
+
+func TestProfileLabels(t *testing.T) {
+	rt, _ := NewTree(2, 4, 12, WithProfileLabels("roads"))
+
+	for op, name := range opNames {
+		ctx := pprof.WithLabels(context.Background(), rt.labels[op])
+		if v, _ := pprof.Label(ctx, "hrtree.op"); v != name {
+			t.Errorf("expected op label %s, got %s", name, v)
+		}
+
+		if v, _ := pprof.Label(ctx, "hrtree.tree"); v != "roads" {
+			t.Errorf("expected tree label roads, got %s", v)
+		}
+	}
+
+	for i := 0; i < 200; i++ {
+		rt.Insert(rect(Point{uint64(i), uint64(i)}, Point{uint64(i), uint64(i)}))
+	}
+	q := rt.SearchIntersect(rect(Point{0, 0}, Point{99, 99}))
+	rt.Delete(q[0])
+
+	if rt.Size() != 199 || len(q) != 100 {
+		t.Errorf("expected labeled operations to work as usual")
+	}
+
+	if rt, _ := NewTree(2, 4, 12); rt.label(context.Background(), opInsert) {
+		t.Errorf("expected no labels without WithProfileLabels")
+	}
+}
+
+func TestProfileLabelsContext(t *testing.T) {
+	rt, _ := NewTree(2, 4, 12, WithProfileLabels("roads"))
+
+	// each call adds the labels of the tree to those of its own ctx.
+	pprof.Do(context.Background(), pprof.Labels("request", "42"), func(ctx context.Context) {
+		rt.InsertContext(ctx, rect(Point{1, 1}, Point{2, 2}))
+		rt.InsertContext(ctx, rect(Point{3, 3}, Point{4, 4}))
+
+		found := rt.SearchIntersect(rect(Point{0, 0}, Point{2, 2}), WithProfileContext(ctx))
+		if ok, err := rt.DeleteContext(ctx, found[0]); len(found) != 1 || !ok || err != nil {
+			t.Errorf("expected labeled operations to work as usual")
+		}
+
+		if rt.NearestNeighbor(Point{0, 0}, WithProfileContext(ctx)) == nil {
+			t.Errorf("expected labeled searches to work as usual")
+		}
+	})
+
+	if rt.Size() != 1 {
+		t.Errorf("expected 1 object, got %d", rt.Size())
+	}
+
+	if rt.label(nil, opInsert) {
+		t.Errorf("expected no labels without a context")
+	}
+}
@@ -0,0 +1,55 @@
+package hrtree
+
+// Layered is implemented by objects that belong to a layer, such as roads or buildings.
+// Objects that don't implement it are stored on layer 0.
+type Layered interface {
+	Layer() uint8
+}
+
+// LayerMask is a set of layers.
+type LayerMask [4]uint64
+
+// Layers returns the set made of the given layers.
+func Layers(layers ...uint8) LayerMask {
+	var m LayerMask
+	for _, l := range layers {
+		m.Add(l)
+	}
+
+	return m
+}
+
+// Add adds layer l to the set.
+func (m *LayerMask) Add(l uint8) {
+	m[l/64] |= 1 << (l % 64)
+}
+
+// Has reports whether layer l is in the set.
+func (m LayerMask) Has(l uint8) bool {
+	return m[l/64]&(1<<(l%64)) != 0
+}
+
+// Intersects reports whether both sets share at least one layer.
+func (m LayerMask) Intersects(o LayerMask) bool {
+	for i := range m {
+		if m[i]&o[i] != 0 {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (m *LayerMask) union(o LayerMask) {
+	for i := range m {
+		m[i] |= o[i]
+	}
+}
+
+func layerOf(obj Rectangle) uint8 {
+	if l, ok := obj.(Layered); ok {
+		return l.Layer()
+	}
+
+	return 0
+}
@@ -0,0 +1,118 @@
+package hrtree
+
+import (
+	"bytes"
+	"testing"
+)
+
+type layeredRect struct {
+	rectangle
+	layer uint8
+}
+
+func (r *layeredRect) Layer() uint8 {
+	return r.layer
+}
+
+func layered(lower, upper Point, layer uint8) *layeredRect {
+	return &layeredRect{*rect(lower, upper), layer}
+}
+
+func TestLayerMask(t *testing.T) {
+	m := Layers(1, 70, 255)
+
+	if !m.Has(1) || !m.Has(70) || !m.Has(255) {
+		t.Errorf("missing layer in %v", m)
+	}
+
+	if m.Has(0) || m.Has(64) {
+		t.Errorf("unexpected layer in %v", m)
+	}
+
+	if !m.Intersects(Layers(70)) {
+		t.Errorf("expected masks to intersect")
+	}
+
+	if m.Intersects(Layers(2, 3)) {
+		t.Errorf("expected masks not to intersect")
+	}
+}
+
+func TestSearchIntersectLayers(t *testing.T) {
+	rt, _ := NewTree(2, 4, 12)
+
+	roads := 0
+	for i := 0; i < 100; i++ {
+		layer := uint8(i%3 + 1)
+		if layer == 1 {
+			roads++
+		}
+		rt.Insert(layered(Point{uint64(i), uint64(i)}, Point{uint64(i + 1), uint64(i + 1)}, layer))
+	}
+
+	bb := rect(Point{0, 0}, Point{200, 200})
+
+	if n := len(rt.SearchIntersect(bb)); n != 100 {
+		t.Errorf("expected 100 results without layers, got %d", n)
+	}
+
+	q := rt.SearchIntersect(bb, WithLayers(1))
+	if len(q) != roads {
+		t.Errorf("expected %d roads, got %d", roads, len(q))
+	}
+
+	for _, r := range q {
+		if r.(*layeredRect).layer != 1 {
+			t.Errorf("unexpected layer %d", r.(*layeredRect).layer)
+		}
+	}
+
+	if n := len(rt.SearchIntersect(bb, WithLayers(1, 2))); n != 67 {
+		t.Errorf("expected 67 roads and buildings, got %d", n)
+	}
+
+	if n := len(rt.SearchIntersect(bb, WithLayers(9))); n != 0 {
+		t.Errorf("expected no results on an empty layer, got %d", n)
+	}
+}
+
+func TestLayersAfterDelete(t *testing.T) {
+	rt, _ := NewTree(2, 4, 12)
+
+	var buildings []*layeredRect
+	for i := 0; i < 30; i++ {
+		r := layered(Point{uint64(i), 0}, Point{uint64(i + 1), 1}, uint8(i%2+1))
+		if r.layer == 2 {
+			buildings = append(buildings, r)
+		}
+		rt.Insert(r)
+	}
+
+	for _, r := range buildings {
+		rt.Delete(r)
+	}
+
+	if rt.root.layers != Layers(1) {
+		t.Errorf("expected only layer 1 under the root, got %v", rt.root.layers)
+	}
+}
+
+func TestLayersWritePages(t *testing.T) {
+	rt, _ := NewTree(2, 4, 12)
+	for i := 0; i < 30; i++ {
+		rt.Insert(layered(Point{uint64(i), 0}, Point{uint64(i + 1), 1}, uint8(i%2+1)))
+	}
+
+	var buf bytes.Buffer
+	rt.WritePages(&buf, MinPageSize)
+
+	loaded, err := ReadPages(bytes.NewReader(buf.Bytes()))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	bb := rect(Point{0, 0}, Point{100, 100})
+	if n := len(loaded.SearchIntersect(bb, WithLayers(2))); n != 15 {
+		t.Errorf("expected 15 objects on layer 2, got %d", n)
+	}
+}
@@ -0,0 +1,175 @@
+package hrtree
+
+import (
+	"math/big"
+	"sync"
+
+	h "github.com/jtejido/hilbert"
+)
+
+const (
+	lutMaxBits   = 16 // largest resolution encoded with lookup tables
+	lutChunkBits = 4  // bits of each coordinate consumed by a table lookup
+)
+
+// hilbertLUT encodes 2-dimensional hilbert values with lookup tables.
+//
+// The hilbert curve is a state machine: each level splits the current cell into four
+// quadrants, visited in an order given by the orientation of the curve in that cell,
+// which also gives the orientation in each quadrant. There are at most 8 orientations.
+// Rather than assuming the conventions of the curve, the tables are learnt from the
+// curve itself and checked against it, so both always give the same values.
+type hilbertLUT struct {
+	bits  int
+	start int // state of the whole space
+
+	// chunk[s][x<<lutChunkBits|y] holds the digits of lutChunkBits levels from state s
+	// in its low bits and the resulting state above them.
+	chunk [][1 << (2 * lutChunkBits)]uint16
+	step  [][4]uint8 // single level: digit in the low 2 bits, next state above
+}
+
+// luts caches the tables of each resolution, as learning them takes a while.
+var luts sync.Map // int -> *hilbertLUT
+
+// lutFor returns the tables of hf, a curve of the given resolution, or nil.
+func lutFor(hf *h.Hilbert, bits int) *hilbertLUT {
+	if lut, ok := luts.Load(bits); ok {
+		return lut.(*hilbertLUT)
+	}
+
+	lut := newHilbertLUT(hf, bits)
+	luts.Store(bits, lut)
+	return lut
+}
+
+// newHilbertLUT learns the tables of hf, a curve of the given resolution. It returns nil
+// if the resolution is too large, or if the tables don't reproduce the curve.
+func newHilbertLUT(hf *h.Hilbert, bits int) *hilbertLUT {
+	if Dim != 2 || bits < 1 || bits > lutMaxBits {
+		return nil
+	}
+
+	// digits returns the visiting order of the quadrants of the cell at the given
+	// level whose lower left corner is (x, y), in units of that level.
+	digits := func(x, y uint64, level int) (d [4]uint8) {
+		shift := uint(bits - level - 1)
+		for q := uint64(0); q < 4; q++ {
+			v := hf.Encode((x<<1|q>>1)<<shift, (y<<1|q&1)<<shift)
+			d[q] = uint8(new(big.Int).Rsh(v, 2*shift).Uint64() & 3)
+		}
+
+		return
+	}
+
+	type cell struct {
+		x, y  uint64
+		level int
+	}
+
+	lut := &hilbertLUT{bits: bits}
+	ids := make(map[[4]uint8]int)
+	var cells []cell // cell where each state was first found
+
+	state := func(c cell) int {
+		d := digits(c.x, c.y, c.level)
+		id, ok := ids[d]
+		if !ok {
+			id = len(cells)
+			ids[d] = id
+			cells = append(cells, c)
+			lut.step = append(lut.step, [4]uint8{})
+		}
+		return id
+	}
+
+	lut.start = state(cell{0, 0, 0})
+	for s := 0; s < len(cells); s++ {
+		c := cells[s]
+		d := digits(c.x, c.y, c.level)
+		for q := uint64(0); q < 4; q++ {
+			next := s // not needed on the last level
+			if c.level+1 < bits {
+				next = state(cell{c.x<<1 | q>>1, c.y<<1 | q&1, c.level + 1})
+			}
+			lut.step[s][q] = d[q] | uint8(next)<<2
+		}
+
+		if len(cells) > 8 {
+			return nil
+		}
+	}
+
+	lut.chunk = make([][1 << (2 * lutChunkBits)]uint16, len(cells))
+	for s := range lut.chunk {
+		for i := range lut.chunk[s] {
+			x, y := uint64(i>>lutChunkBits), uint64(i&(1<<lutChunkBits-1))
+			key, next := lut.walk(s, x, y, lutChunkBits)
+			lut.chunk[s][i] = uint16(key) | uint16(next)<<(2*lutChunkBits)
+		}
+	}
+
+	// check corners, a grid and a pseudo-random sample against the curve.
+	max := uint64(1)<<uint(bits) - 1
+	points := [][2]uint64{{0, 0}, {0, max}, {max, 0}, {max, max}}
+	for x := uint64(0); x <= max; x += max/16 + 1 {
+		for y := uint64(0); y <= max; y += max/16 + 1 {
+			points = append(points, [2]uint64{x, y})
+		}
+	}
+	for i, r := 0, uint64(1); i < 1024; i++ {
+		r = r*6364136223846793005 + 1442695040888963407
+		points = append(points, [2]uint64{r >> 32 & max, r >> 8 & max})
+	}
+
+	for _, p := range points {
+		if lut.encode(p[0], p[1]) != hf.Encode(p[0], p[1]).Uint64() {
+			return nil
+		}
+	}
+
+	return lut
+}
+
+// walk runs levels single steps from state s over the top bits of x and y.
+func (lut *hilbertLUT) walk(s int, x, y uint64, levels int) (key uint64, next int) {
+	for l := levels - 1; l >= 0; l-- {
+		t := lut.step[s][(x>>uint(l)&1)<<1|y>>uint(l)&1]
+		key = key<<2 | uint64(t&3)
+		s = int(t >> 2)
+	}
+
+	return key, s
+}
+
+// encode returns the hilbert value of (x, y), which must lie within the resolution.
+func (lut *hilbertLUT) encode(x, y uint64) uint64 {
+	// leading levels that don't fill a chunk are stepped through one by one.
+	head := lut.bits % lutChunkBits
+	shift := uint(lut.bits - head)
+	key, s := lut.walk(lut.start, x>>shift, y>>shift, head)
+
+	const mask = 1<<lutChunkBits - 1
+	for shift > 0 {
+		shift -= lutChunkBits
+		t := lut.chunk[s][(x>>shift&mask)<<lutChunkBits|y>>shift&mask]
+		key = key<<(2*lutChunkBits) | uint64(t&(1<<(2*lutChunkBits)-1))
+		s = int(t >> (2 * lutChunkBits))
+	}
+
+	return key
+}
+
+// encode returns the hilbert value of the given point, or its key along the curve set
+// with WithCurve.
+func (tree *HRtree) encode(p []uint64) hkey {
+	if tree.curve != nil {
+		return tree.curve.Encode(p...).k
+	}
+
+	if tree.lut != nil && p[0]>>uint(tree.bits) == 0 && p[1]>>uint(tree.bits) == 0 {
+		return hkey{lo: tree.lut.encode(p[0], p[1])}
+	}
+
+	return keyOf(tree.hf.Encode(p...))
+}
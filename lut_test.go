@@ -0,0 +1,70 @@
+//go:build !hrtree3d
+
+package hrtree
+
+import (
+	"testing"
+
+	h "github.com/jtejido/hilbert"
+)
+
+func TestHilbertLUT(t *testing.T) {
+	for bits := 1; bits <= lutMaxBits; bits++ {
+		hf, _ := h.New(uint32(bits), Dim)
+		lut := newHilbertLUT(hf, bits)
+		if lut == nil {
+			t.Fatalf("expected tables for %d bits", bits)
+		}
+
+		max := uint64(1)<<uint(bits) - 1
+		step := uint64(1)
+		if bits > 6 {
+			step = max/97 + 1
+		}
+
+		for x := uint64(0); x <= max; x += step {
+			for y := uint64(0); y <= max; y += step {
+				if v, w := lut.encode(x, y), hf.Encode(x, y).Uint64(); v != w {
+					t.Fatalf("%d bits: expected %d for (%d, %d), got %d", bits, w, x, y, v)
+				}
+			}
+		}
+	}
+
+	hf, _ := h.New(uint32(lutMaxBits+1), Dim)
+	if newHilbertLUT(hf, lutMaxBits+1) != nil {
+		t.Errorf("expected no tables above %d bits", lutMaxBits)
+	}
+}
+
+func TestEncodeOutOfRange(t *testing.T) {
+	rt, _ := NewTree(2, 4, 8)
+	if rt.lut == nil {
+		t.Fatalf("expected tables for 8 bits")
+	}
+
+	p := []uint64{300, 5}
+	if rt.encode(p).cmp(keyOf(rt.hf.Encode(p...))) != 0 {
+		t.Errorf("expected coordinates beyond the resolution to be encoded by the curve")
+	}
+}
+
+func BenchmarkEncodeLUT(b *testing.B) {
+	rt, _ := NewTree(2, 4, 16)
+	p := []uint64{12345, 54321}
+
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		rt.encode(p)
+	}
+}
+
+func BenchmarkEncodeCurve(b *testing.B) {
+	hf, _ := h.New(16, Dim)
+	p := []uint64{12345, 54321}
+
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		hf.Encode(p...)
+	}
+}
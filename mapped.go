@@ -0,0 +1,171 @@
+package hrtree
+
+import (
+	"encoding/binary"
+	"fmt"
+	"os"
+	"sync/atomic"
+)
+
+// MappedTree is a read-only tree searched in place in a file written by WritePages. The
+// file is memory-mapped rather than loaded: pages are only read, checked and decoded when
+// a search visits them, so that trees larger than memory can be searched, with the page
+// cache of the system holding the pages in use. As with ReadPages, objects are plain
+// rectangles carrying the stored bounds. Searches can run concurrently. Trees modified in
+// place in a mapped file are kept with MappedFile.
+type MappedTree struct {
+	data    []byte
+	meta    pageMeta
+	checked []atomic.Uint64 // bitset of the pages whose checksum was verified
+	unmap   func() error
+}
+
+// OpenMapped maps the paged file at path. The file must not be modified until the tree is
+// closed. On systems without mmap, the file is read into memory.
+func OpenMapped(path string) (*MappedTree, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("OpenMapped: %w", err)
+	}
+	defer f.Close()
+
+	m, err := readMeta(f)
+	if err != nil {
+		return nil, fmt.Errorf("OpenMapped: %w", err)
+	}
+
+	fi, err := f.Stat()
+	if err != nil {
+		return nil, fmt.Errorf("OpenMapped: %w", err)
+	}
+
+	if m.pages >= uint64(fi.Size())/uint64(m.pageSize) {
+		return nil, fmt.Errorf("OpenMapped: %d pages in %d bytes: %w", m.pages, fi.Size(), ErrInvalidPage)
+	}
+
+	data, unmap, err := mmapFile(f, int((m.pages+1)*uint64(m.pageSize)))
+	if err != nil {
+		return nil, fmt.Errorf("OpenMapped: %w", err)
+	}
+
+	return &MappedTree{
+		data:    data,
+		meta:    m,
+		checked: make([]atomic.Uint64, (m.pages+1+63)/64),
+		unmap:   unmap,
+	}, nil
+}
+
+// Close unmaps the file, the tree can't be searched afterwards.
+func (t *MappedTree) Close() error {
+	if t.unmap == nil {
+		return nil
+	}
+
+	err := t.unmap()
+	t.data, t.unmap = nil, nil
+	return err
+}
+
+// Size returns the number of objects of the tree.
+func (t *MappedTree) Size() int {
+	return t.meta.size
+}
+
+// page returns page id, verifying its checksum on the first access.
+func (t *MappedTree) page(id uint64) ([]byte, error) {
+	if id < rootPage || id > t.meta.pages {
+		return nil, &PageError{id, ErrInvalidPage}
+	}
+
+	size := uint64(t.meta.pageSize)
+	page := t.data[id*size : (id+1)*size]
+
+	word, bit := &t.checked[id/64], uint64(1)<<(id%64)
+	if word.Load()&bit == 0 {
+		if binary.LittleEndian.Uint32(page[pageCRCOffset:]) != pageChecksum(page) {
+			return nil, &PageError{id, ErrCorruptedPage}
+		}
+
+		if count := int(binary.LittleEndian.Uint16(page[2:])); count > t.meta.max {
+			return nil, &PageError{id, ErrInvalidPage}
+		}
+		word.Or(bit)
+	}
+
+	return page, nil
+}
+
+// SearchIntersect returns the objects intersecting bb, like HRtree.SearchIntersect. Only
+// the options restricting leaf entries apply, as pages don't record the layers and
+// attributes of subtrees. Errors are those of corrupted pages.
+func (t *MappedTree) SearchIntersect(bb Rectangle, opts ...QueryOption) ([]Rectangle, error) {
+	results := []Rectangle{}
+	window := rectangle{bb.LowerLeft(), bb.UpperRight()}
+	q := newQuery(opts)
+
+	if _, err := t.search(t.meta.root, 0, &window, q, &results); err != nil {
+		return nil, fmt.Errorf("SearchIntersect: %w", err)
+	}
+
+	return results, nil
+}
+
+// search appends the objects of page id intersecting bb to results, and reports whether
+// the limit of q is reached.
+func (t *MappedTree) search(id uint64, depth int, bb *rectangle, q *query, results *[]Rectangle) (bool, error) {
+	// a well-formed file is no deeper than its number of pages.
+	if depth > int(t.meta.pages) {
+		return false, &PageError{id, ErrInvalidPage}
+	}
+
+	page, err := t.page(id)
+	if err != nil {
+		return false, err
+	}
+
+	leaf := page[0]&pageFlagLeaf != 0
+	count := int(binary.LittleEndian.Uint16(page[2:]))
+
+	buf := page[pageHeaderSize:]
+	for i := 0; i < count; i, buf = i+1, buf[pageEntrySize:] {
+		var r rectangle
+		for j := 0; j < Dim; j++ {
+			r.lowerLeft[j] = binary.LittleEndian.Uint64(buf[j*8:])
+			r.upperRight[j] = binary.LittleEndian.Uint64(buf[(Dim+j)*8:])
+		}
+
+		if !intersect(&r, bb) {
+			continue
+		}
+
+		if !leaf {
+			full, err := t.search(binary.LittleEndian.Uint64(buf[pageRefOffset:]), depth+1, bb, q, results)
+			if full || err != nil {
+				return full, err
+			}
+			continue
+		}
+
+		obj := r
+		e := entry{
+			bb:    &r,
+			obj:   &obj,
+			leaf:  true,
+			layer: buf[pageRefOffset],
+			attrs: binary.LittleEndian.Uint64(buf[pageAttrOffset:]),
+			dead:  buf[pageRefOffset+1]&pageEntryDead != 0,
+		}
+
+		if !q.accepts(e) || !q.passes(e.obj) || !q.take() {
+			continue
+		}
+
+		*results = append(*results, e.obj)
+		if q.full() {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
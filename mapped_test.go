@@ -0,0 +1,113 @@
+package hrtree
+
+import (
+	"errors"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+)
+
+// writeMapped writes rt as a paged file in a temporary directory, and returns its path.
+func writeMapped(t *testing.T, rt *HRtree) string {
+	path := filepath.Join(t.TempDir(), "tree.pages")
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer f.Close()
+
+	if err := rt.WritePages(f, MinPageSize); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	return path
+}
+
+func TestMapped(t *testing.T) {
+	rt, _ := NewTree(4, MaxEntriesForPage(MinPageSize), 12, WithLazyDelete())
+	for i := 0; i < 3000; i++ {
+		rt.Insert(rect(Point{uint64(3 * (i % 300)), uint64(i / 10)}, Point{uint64(3*(i%300) + 2), uint64(i/10 + 5)}))
+	}
+	rt.Delete(rect(Point{30, 10}, Point{32, 15}))
+
+	mt, err := OpenMapped(writeMapped(t, rt))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer mt.Close()
+
+	if mt.Size() != rt.Size() {
+		t.Errorf("expected %d objects, got %d", rt.Size(), mt.Size())
+	}
+
+	var wg sync.WaitGroup
+	for w := 0; w < 4; w++ {
+		wg.Add(1)
+		go func(seed int64) {
+			defer wg.Done()
+			r := rand.New(rand.NewSource(seed))
+			for i := 0; i < 50; i++ {
+				x, y := uint64(r.Intn(900)), uint64(r.Intn(300))
+				bb := rect(Point{x, y}, Point{x + uint64(r.Intn(100)), y + uint64(r.Intn(100))})
+
+				found, err := mt.SearchIntersect(bb)
+				if err != nil {
+					t.Errorf("unexpected error: %v", err)
+					return
+				}
+
+				expected := rt.SearchIntersect(bb)
+				if len(found) != len(expected) {
+					t.Errorf("expected %d objects in %v, got %d", len(expected), bb, len(found))
+					continue
+				}
+
+				for j := range found {
+					if !equal(found[j], expected[j]) {
+						t.Errorf("expected %v at %d, got %v", expected[j], j, found[j])
+					}
+				}
+			}
+		}(int64(w))
+	}
+	wg.Wait()
+
+	bb := rect(Point{0, 0}, Point{900, 300})
+	if page, _ := mt.SearchIntersect(bb, WithOffset(10), WithLimit(5)); len(page) != 5 || !equal(page[0], rt.SearchIntersect(bb)[10]) {
+		t.Errorf("expected objects 10 to 14, got %v", page)
+	}
+
+	if err := mt.Close(); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestMappedCorrupted(t *testing.T) {
+	rt, _ := NewTree(2, 4, 12)
+	for i := 0; i < 20; i++ {
+		rt.Insert(rect(Point{uint64(i), uint64(i)}, Point{uint64(i + 1), uint64(i + 1)}))
+	}
+
+	path := writeMapped(t, rt)
+	data, _ := os.ReadFile(path)
+	data[2*MinPageSize+pageHeaderSize+3] ^= 0xff
+	os.WriteFile(path, data, 0o644)
+
+	mt, err := OpenMapped(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer mt.Close()
+
+	// pages are only checked when visited.
+	if _, err := mt.SearchIntersect(rect(Point{0, 0}, Point{30, 30})); !errors.Is(err, ErrCorruptedPage) {
+		t.Errorf("expected %v, got %v", ErrCorruptedPage, err)
+	}
+
+	os.WriteFile(path, data[:len(data)-MinPageSize], 0o644)
+	if _, err := OpenMapped(path); !errors.Is(err, ErrInvalidPage) {
+		t.Errorf("expected %v for a truncated file, got %v", ErrInvalidPage, err)
+	}
+}
@@ -0,0 +1,161 @@
+package hrtree
+
+import (
+	"fmt"
+	"os"
+)
+
+// MappedFile is a NodeStore keeping its pages in a memory-mapped file, grown as pages are
+// allocated. A tree created or opened with it through WithNodeStore and OpenTree writes
+// the pages of its changes in place in the mapping, the file being the index itself with
+// no save step: Sync flushes it to stable storage. Pages are written one at a time, so wrap
+// the file in a WAL (see OpenWAL) for the tree to survive crashes during mutations. The
+// file stays readable by OpenMapped and ReadPages.
+type MappedFile struct {
+	f        *os.File
+	pageSize int
+	pages    uint64 // pages written, the mapping may hold more
+	data     []byte
+	unmap    func() error
+	flush    func() error
+}
+
+// minMappedPages is the number of pages mapped at least.
+const minMappedPages = 16
+
+// OpenMappedFile opens or creates the page file at path and maps it. pageSize is that of
+// new files, existing ones keep theirs. The file must not be modified by others until it
+// is closed.
+func OpenMappedFile(path string, pageSize int) (*MappedFile, error) {
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("OpenMappedFile: %w", err)
+	}
+
+	mf, err := openMappedFile(f, pageSize)
+	if err != nil {
+		f.Close()
+		return nil, fmt.Errorf("OpenMappedFile: %w", err)
+	}
+
+	return mf, nil
+}
+
+func openMappedFile(f *os.File, pageSize int) (*MappedFile, error) {
+	fi, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+
+	// the file of a crashed process keeps the pages it was mapped with, past those written,
+	// so the metadata page tells how many there are.
+	var pages uint64
+	if fi.Size() > 0 {
+		m, err := readMeta(f)
+		if err != nil {
+			return nil, err
+		}
+		pageSize = m.pageSize
+
+		if pages = m.pages + 1; pages > uint64(fi.Size())/uint64(pageSize) {
+			return nil, fmt.Errorf("%d pages in %d bytes: %w", pages, fi.Size(), ErrInvalidPage)
+		}
+	}
+
+	if err := checkPageSize(pageSize); err != nil {
+		return nil, err
+	}
+
+	mf := &MappedFile{f: f, pageSize: pageSize, pages: pages}
+	if err := mf.remap(max(mf.pages, minMappedPages)); err != nil {
+		return nil, err
+	}
+
+	return mf, nil
+}
+
+func (mf *MappedFile) PageSize() int {
+	return mf.pageSize
+}
+
+func (mf *MappedFile) Pages() uint64 {
+	return mf.pages
+}
+
+func (mf *MappedFile) ReadPage(id uint64, page []byte) error {
+	if id >= mf.pages {
+		return ErrNoPage
+	}
+
+	copy(page, mf.data[id*uint64(mf.pageSize):])
+	return nil
+}
+
+// WritePage writes page in place, doubling the mapping if it doesn't hold page id yet.
+func (mf *MappedFile) WritePage(id uint64, page []byte) error {
+	if mapped := uint64(len(mf.data) / mf.pageSize); id >= mapped {
+		if err := mf.remap(max(2*mapped, id+1)); err != nil {
+			return &PageError{id, err}
+		}
+	}
+
+	copy(mf.data[id*uint64(mf.pageSize):(id+1)*uint64(mf.pageSize)], page)
+	mf.pages = max(mf.pages, id+1)
+
+	return nil
+}
+
+// Sync flushes the pages written to stable storage.
+func (mf *MappedFile) Sync() error {
+	return mf.flush()
+}
+
+// Close syncs the file, trims the pages mapped past the last one written, then closes it.
+func (mf *MappedFile) Close() error {
+	if mf.data == nil {
+		return nil
+	}
+
+	err := mf.Sync()
+	if uerr := mf.unmap(); err == nil {
+		err = uerr
+	}
+	mf.data = nil
+
+	if terr := mf.f.Truncate(int64(mf.pages) * int64(mf.pageSize)); err == nil {
+		err = terr
+	}
+
+	if cerr := mf.f.Close(); err == nil {
+		err = cerr
+	}
+
+	return err
+}
+
+// remap maps the first pages pages of the file, extending it if it's shorter.
+func (mf *MappedFile) remap(pages uint64) error {
+	if mf.data != nil {
+		if err := mf.unmap(); err != nil {
+			return err
+		}
+		mf.data = nil
+	}
+
+	size := int64(pages) * int64(mf.pageSize)
+	if fi, err := mf.f.Stat(); err != nil {
+		return err
+	} else if fi.Size() < size {
+		if err := mf.f.Truncate(size); err != nil {
+			return err
+		}
+	}
+
+	data, unmap, flush, err := mmapWritable(mf.f, int(size))
+	if err != nil {
+		return err
+	}
+	mf.data, mf.unmap, mf.flush = data, unmap, flush
+
+	return nil
+}
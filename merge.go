@@ -0,0 +1,155 @@
+package hrtree
+
+import (
+	"reflect"
+	"sort"
+)
+
+// Merge returns a new tree holding the union of two replicas, which may have been built
+// from overlapping sets of operations. The result only depends on the contents of a and b,
+// not on their order or structure, so replicas can be merged in any order.
+//
+// Versioned objects are resolved per ID, the newest version winning (a deletion wins over
+// an object of the same version, and equal versions are ordered by bounds). Other objects
+// found in both trees are only kept once. The new tree has the options of a, except for
+// WithNodeStore and WithCheckpoint, whose files belong to a: it is neither stored nor
+// checkpointed. It returns an error wrapping ErrCorrupted, rather than panicking, if the
+// structure of a tree is found broken.
+func Merge(a, b *HRtree) (_ *HRtree, err error) {
+	defer rlockBoth(a, b)()
+	defer recoverCorrupted("Merge", &err)
+
+	merged := a.config()
+
+	// resolve versions first, then only keep the winning version of each ID.
+	for _, t := range []*HRtree{a, b} {
+		for id, v := range t.versions {
+			if cur, ok := merged.versions[id]; !ok || newerVersion(v, cur) {
+				if merged.versions == nil {
+					merged.versions = make(map[string]version)
+				}
+				merged.versions[id] = v
+			}
+		}
+	}
+
+	var entries []entry
+	for _, t := range []*HRtree{a, b} {
+		for _, leaf := range t.root.leaves(nil) {
+			for _, e := range leaf.getEntries() {
+				if e.dead {
+					continue
+				}
+
+				if v, ok := e.obj.(Versioned); ok && !sameObject(merged.versions[v.ID()].obj, e.obj) {
+					continue
+				}
+
+				bb := *e.bb
+				e.bb = &bb
+				e.h = merged.cachedKey(e.obj)
+				entries = append(entries, e)
+			}
+		}
+	}
+
+	// insert in a deterministic order, dropping objects found in both trees.
+	sort.SliceStable(entries, func(i, j int) bool {
+		return compareEntries(entries[i], entries[j]) < 0
+	})
+
+	kept := entries[:0]
+	group := 0 // first kept entry sharing the hilbert value and bounds of e
+	for _, e := range entries {
+		if len(kept) > 0 && compareEntries(kept[group], e) != 0 {
+			group = len(kept)
+		}
+
+		duplicate := false
+		for _, k := range kept[group:] {
+			if sameObject(k.obj, e.obj) {
+				duplicate = true
+				break
+			}
+		}
+
+		if !duplicate {
+			kept = append(kept, e)
+		}
+	}
+
+	for _, e := range kept {
+		merged.insert(e)
+		merged.size++
+	}
+	merged.publish()
+
+	return merged, nil
+}
+
+// rlockBoth takes the read locks of a and b, which may be the same tree, and returns a
+// function releasing them. Locks are taken in the order of the addresses of the trees,
+// so that two calls on the same trees given in opposite orders can't deadlock behind a
+// waiting mutation.
+func rlockBoth(a, b *HRtree) (unlock func()) {
+	if reflect.ValueOf(a).Pointer() > reflect.ValueOf(b).Pointer() {
+		a, b = b, a
+	}
+
+	a.mu.RLock()
+	if b != a {
+		b.mu.RLock()
+	}
+
+	return func() {
+		if b != a {
+			b.mu.RUnlock()
+		}
+		a.mu.RUnlock()
+	}
+}
+
+// newerVersion reports whether v wins over cur.
+func newerVersion(v, cur version) bool {
+	if v.version != cur.version {
+		return v.version > cur.version
+	}
+
+	if v.obj == nil || cur.obj == nil {
+		return v.obj == nil && cur.obj != nil
+	}
+
+	return compareBounds(v.obj, cur.obj) < 0
+}
+
+// compareEntries orders leaf entries by hilbert value, then by bounds.
+func compareEntries(e1, e2 entry) int {
+	if c := e1.h.cmp(e2.h); c != 0 {
+		return c
+	}
+
+	return compareBounds(e1.obj, e2.obj)
+}
+
+// compareBounds orders rectangles lexicographically by their lower left then upper right corners.
+func compareBounds(r1, r2 Rectangle) int {
+	for _, p := range [][2]Point{{r1.LowerLeft(), r2.LowerLeft()}, {r1.UpperRight(), r2.UpperRight()}} {
+		for i := 0; i < Dim; i++ {
+			if p[0][i] < p[1][i] {
+				return -1
+			}
+
+			if p[0][i] > p[1][i] {
+				return 1
+			}
+		}
+	}
+
+	return 0
+}
+
+// sameObject reports whether both objects are equal values (or the same pointer).
+func sameObject(r1, r2 Rectangle) bool {
+	t := reflect.TypeOf(r1)
+	return t != nil && t == reflect.TypeOf(r2) && t.Comparable() && r1 == r2
+}
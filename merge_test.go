@@ -0,0 +1,150 @@
+package hrtree
+
+import (
+	"errors"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestMerge(t *testing.T) {
+	a, _ := NewTree(2, 4, 12)
+	b, _ := NewTree(2, 4, 12)
+
+	// shared operations
+	shared := rect(Point{1, 1}, Point{2, 2})
+	a.Insert(shared)
+	b.Insert(shared)
+	a.Insert(position("car", 1, 10, 10))
+	b.Insert(position("car", 1, 10, 10))
+	a.Insert(position("bus", 1, 20, 20))
+	b.Insert(position("bus", 1, 20, 20))
+
+	// diverging operations
+	a.Insert(rect(Point{3, 3}, Point{4, 4}))
+	b.Insert(rect(Point{5, 5}, Point{6, 6}))
+	a.Insert(position("car", 2, 11, 11))
+	b.Insert(position("car", 3, 12, 12))
+	a.Delete(position("bus", 1, 20, 20))
+
+	ab, err := Merge(a, b)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	ba, _ := Merge(b, a)
+
+	for _, m := range []*HRtree{ab, ba} {
+		if m.Size() != 4 {
+			t.Errorf("expected 4 objects, got %d", m.Size())
+		}
+
+		q := m.SearchIntersect(rect(Point{0, 0}, Point{100, 100}))
+		if len(q) != 4 {
+			t.Errorf("expected 4 results, got %d", len(q))
+		}
+
+		if index(q, shared) < 0 {
+			t.Errorf("shared object missing")
+		}
+
+		if cur, ok := m.Lookup("car"); !ok || cur.(*vehicle).version != 3 {
+			t.Errorf("expected the newest version of car")
+		}
+
+		if _, ok := m.Lookup("bus"); ok {
+			t.Errorf("expected bus to stay deleted")
+		}
+	}
+}
+
+func TestMergeTie(t *testing.T) {
+	a, _ := NewTree(2, 4, 12)
+	b, _ := NewTree(2, 4, 12)
+
+	a.Insert(position("car", 1, 10, 10))
+	b.Insert(position("car", 1, 12, 12))
+
+	ab, _ := Merge(a, b)
+	ba, _ := Merge(b, a)
+
+	x, _ := ab.Lookup("car")
+	y, _ := ba.Lookup("car")
+	if x != y {
+		t.Errorf("expected merges to agree on concurrent versions")
+	}
+}
+
+func TestMergeOptions(t *testing.T) {
+	steps := 0
+	a, _ := NewTree(3, 6, 12, WithDuplicatePolicy(DuplicateReject), WithUnderflowThreshold(2),
+		WithUnderflowPolicy(UnderflowDefer), WithLazyDelete(), WithProgress(func(Progress) { steps++ }),
+		WithCheckpoint(filepath.Join(t.TempDir(), "a.pages"), PageSize4K, time.Hour))
+	defer a.Close()
+	b, _ := NewTree(2, 4, 12)
+	a.Insert(rect(Point{1, 1}, Point{2, 2}))
+	b.Insert(rect(Point{3, 3}, Point{4, 4}))
+
+	m, err := Merge(a, b)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if m.min != 3 || m.max != 6 || m.underflow != 2 || m.policy != UnderflowDefer || !m.lazy {
+		t.Errorf("expected the options of a")
+	}
+
+	// the checkpoints of a belong to a, the merged tree mustn't write to its file.
+	if m.cp != nil {
+		t.Errorf("expected the merged tree not to be checkpointed")
+	}
+
+	if err := m.Insert(rect(Point{3, 3}, Point{4, 4})); !errors.Is(err, ErrDuplicate) {
+		t.Errorf("expected %v, got %v", ErrDuplicate, err)
+	}
+
+	m.Compact()
+	if steps == 0 {
+		t.Errorf("expected the progress of a to be reported")
+	}
+}
+
+func TestMergeLockOrder(t *testing.T) {
+	lo, _ := NewTree(2, 4, 12)
+	hi, _ := NewTree(2, 4, 12)
+	if reflect.ValueOf(lo).Pointer() > reflect.ValueOf(hi).Pointer() {
+		lo, hi = hi, lo
+	}
+
+	// a reader holds lo, which a mutation waits for: Merge should queue for lo before
+	// taking hi, rather than holding hi while it waits.
+	lo.mu.RLock()
+	go func() {
+		lo.mu.Lock()
+		lo.mu.Unlock()
+	}()
+	time.Sleep(10 * time.Millisecond)
+
+	merged := make(chan struct{})
+	go func() {
+		Merge(hi, lo)
+		close(merged)
+	}()
+	time.Sleep(10 * time.Millisecond)
+
+	inserted := make(chan struct{})
+	go func() {
+		hi.Insert(rect(Point{1, 1}, Point{2, 2}))
+		close(inserted)
+	}()
+
+	select {
+	case <-inserted:
+	case <-time.After(5 * time.Second):
+		t.Errorf("expected Merge not to hold hi while waiting for lo")
+	}
+	lo.mu.RUnlock()
+	<-merged
+	<-inserted
+}
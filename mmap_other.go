@@ -0,0 +1,40 @@
+//go:build !unix
+
+package hrtree
+
+import (
+	"io"
+	"os"
+)
+
+// mmapFile reads the first size bytes of f, as the system has no mmap.
+func mmapFile(f *os.File, size int) ([]byte, func() error, error) {
+	data := make([]byte, size)
+	if _, err := f.ReadAt(data, 0); err != nil && err != io.EOF {
+		return nil, nil, err
+	}
+
+	return data, func() error { return nil }, nil
+}
+
+// mmapWritable reads the first size bytes of f, as the system has no mmap. The changes
+// made to data are written back to f when it's unmapped or flushed.
+func mmapWritable(f *os.File, size int) (data []byte, unmap, flush func() error, err error) {
+	data, _, err = mmapFile(f, size)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	unmap = func() error {
+		_, err := f.WriteAt(data, 0)
+		return err
+	}
+	flush = func() error {
+		if err := unmap(); err != nil {
+			return err
+		}
+		return f.Sync()
+	}
+
+	return data, unmap, flush, nil
+}
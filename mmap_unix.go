@@ -0,0 +1,34 @@
+//go:build unix
+
+package hrtree
+
+import (
+	"os"
+	"syscall"
+
+	"golang.org/x/sys/unix"
+)
+
+// mmapFile maps the first size bytes of f read-only.
+func mmapFile(f *os.File, size int) ([]byte, func() error, error) {
+	data, err := syscall.Mmap(int(f.Fd()), 0, size, syscall.PROT_READ, syscall.MAP_SHARED)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return data, func() error { return syscall.Munmap(data) }, nil
+}
+
+// mmapWritable maps the first size bytes of f for reading and writing, the changes made to
+// data reaching the file. flush writes them to stable storage.
+func mmapWritable(f *os.File, size int) (data []byte, unmap, flush func() error, err error) {
+	data, err = syscall.Mmap(int(f.Fd()), 0, size, syscall.PROT_READ|syscall.PROT_WRITE, syscall.MAP_SHARED)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	unmap = func() error { return syscall.Munmap(data) }
+	flush = func() error { return unix.Msync(data, unix.MS_SYNC) }
+
+	return data, unmap, flush, nil
+}
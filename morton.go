@@ -0,0 +1,68 @@
+package hrtree
+
+import (
+	"math/big"
+)
+
+// Morton is the Z-order curve, which interleaves the bits of the coordinates, those of
+// the first one being the most significant. Encoding is branch-free and much cheaper than
+// along the hilbert curve, at the cost of looser nodes as the curve jumps between
+// quadrants, so it suits workloads favouring insert throughput over search performance:
+//
+//	tree, err := hrtree.NewTree(20, 1000, 32, hrtree.WithCurve(hrtree.Morton{}))
+//
+// Keys don't depend on the resolution of the tree, all 64 bits of each coordinate are
+// interleaved.
+type Morton struct{}
+
+// Encode returns the Z-order value of the given point.
+func (Morton) Encode(coords ...uint64) Key {
+	if Dim == 2 {
+		x, y := coords[0], coords[1]
+		return Uint128Key(spread2(x>>32)<<1|spread2(y>>32), spread2(x&(1<<32-1))<<1|spread2(y&(1<<32-1)))
+	}
+
+	// in three dimensions, coordinates are interleaved 21 bits at a time, up to 42 bits
+	// in two words.
+	c0 := interleave3(coords, 0)
+	c1 := interleave3(coords, 21)
+	if (coords[0]|coords[1]|coords[2])>>42 == 0 {
+		return Uint128Key(c1>>1, c1<<63|c0)
+	}
+
+	var top uint64 // bit 63 of each coordinate
+	for _, c := range coords {
+		top = top<<1 | c>>63
+	}
+
+	v := new(big.Int)
+	for _, c := range []uint64{top, interleave3(coords, 42), c1, c0} {
+		v.Lsh(v, 63).Or(v, new(big.Int).SetUint64(c))
+	}
+
+	return BigKey(v)
+}
+
+// spread2 spreads the low 32 bits of x to the even bits of the result.
+func spread2(x uint64) uint64 {
+	x = (x | x<<16) & 0x0000ffff0000ffff
+	x = (x | x<<8) & 0x00ff00ff00ff00ff
+	x = (x | x<<4) & 0x0f0f0f0f0f0f0f0f
+	x = (x | x<<2) & 0x3333333333333333
+	return (x | x<<1) & 0x5555555555555555
+}
+
+// spread3 spreads the low 21 bits of x to every third bit of the result.
+func spread3(x uint64) uint64 {
+	x &= 1<<21 - 1
+	x = (x | x<<32) & 0x001f00000000ffff
+	x = (x | x<<16) & 0x001f0000ff0000ff
+	x = (x | x<<8) & 0x100f00f00f00f00f
+	x = (x | x<<4) & 0x10c30c30c30c30c3
+	return (x | x<<2) & 0x1249249249249249
+}
+
+// interleave3 interleaves the 21 bits of 3 coordinates starting at bit shift.
+func interleave3(coords []uint64, shift uint) uint64 {
+	return spread3(coords[0]>>shift)<<2 | spread3(coords[1]>>shift)<<1 | spread3(coords[2]>>shift)
+}
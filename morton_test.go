@@ -0,0 +1,72 @@
+package hrtree
+
+import (
+	"math/big"
+	"math/rand"
+	"testing"
+)
+
+// zorder interleaves the bits of coords one by one.
+func zorder(coords []uint64) *big.Int {
+	v := new(big.Int)
+	for b := 63; b >= 0; b-- {
+		for _, c := range coords {
+			v.Lsh(v, 1).Or(v, big.NewInt(int64(c>>uint(b)&1)))
+		}
+	}
+
+	return v
+}
+
+func TestMorton(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	for i := 0; i < 2000; i++ {
+		p := make([]uint64, Dim)
+		for d := range p {
+			// coordinates of every width, with the widest ones often.
+			p[d] = r.Uint64() >> uint(r.Intn(70))
+		}
+
+		if i == 0 {
+			p[0] = 1<<64 - 1
+		}
+
+		if k, want := (Morton{}).Encode(p...), zorder(p); k.Int().Cmp(want) != 0 {
+			t.Errorf("%v: expected %v, got %v", p, want, k)
+		}
+	}
+
+	rt, _ := NewTree(2, 4, 12, WithCurve(Morton{}))
+	for i := uint64(0); i < 300; i++ {
+		x, y := (i*37)%300, (i*11)%50
+		rt.Insert(rect(Point{x, y}, Point{x + 1, y + 1}))
+	}
+
+	if err := rt.CheckInvariants(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	for _, leaf := range rt.root.leaves(nil) {
+		for _, e := range leaf.getEntries() {
+			if want := zorder(getCenter(e.obj)); e.h.Int().Cmp(want) != 0 {
+				t.Errorf("expected the key of %v to be %v, got %v", e.obj, want, e.h)
+			}
+		}
+	}
+
+	if q := rt.SearchIntersect(rect(Point{10, 0}, Point{19, 50})); len(q) != 11 {
+		t.Errorf("expected 11 results, got %d", len(q))
+	}
+}
+
+func BenchmarkEncodeMorton(b *testing.B) {
+	p := make([]uint64, Dim)
+	for d := range p {
+		p[d] = uint64(12345 * (d + 1))
+	}
+
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		Morton{}.Encode(p...)
+	}
+}
@@ -0,0 +1,214 @@
+package hrtree
+
+import (
+	"container/heap"
+	"iter"
+	"math"
+)
+
+// nearestItem is a node or an object queued by a nearest-first search, at its distance
+// from the searched point.
+type nearestItem struct {
+	node *node
+	obj  Rectangle
+	dist float64
+}
+
+// nearestQueue is a min-heap of nearestItem by distance.
+type nearestQueue []nearestItem
+
+func (q nearestQueue) Len() int           { return len(q) }
+func (q nearestQueue) Less(i, j int) bool { return q[i].dist < q[j].dist }
+func (q nearestQueue) Swap(i, j int)      { q[i], q[j] = q[j], q[i] }
+
+func (q *nearestQueue) Push(x any) {
+	*q = append(*q, x.(nearestItem))
+}
+
+func (q *nearestQueue) Pop() any {
+	old := *q
+	it := old[len(old)-1]
+	old[len(old)-1] = nearestItem{}
+	*q = old[:len(old)-1]
+	return it
+}
+
+// NearestWithin returns the objects whose bounding-box lies within maxDist of p, along with
+// the euclidean distance to it, nearest first, opts may further restrict the results.
+// Nodes are expanded lazily as the sequence is consumed, so ranking the first few results
+// of a large radius is cheap. Use WithPinnedSnapshot to modify the tree meanwhile.
+func (tree *HRtree) NearestWithin(p Point, maxDist float64, opts ...QueryOption) iter.Seq2[Rectangle, float64] {
+	return tree.nearest(func(e entry) float64 { return distance(p, e.getMBR()) }, maxDist, opts)
+}
+
+// NearestBy is like NearestWithin with the distance given by dist, such as a distance on
+// a sphere. dist is given the objects and the bounding-boxes of nodes, and must not
+// return more for a bounding-box than for any object within it.
+func (tree *HRtree) NearestBy(dist func(Rectangle) float64, maxDist float64, opts ...QueryOption) iter.Seq2[Rectangle, float64] {
+	return tree.nearest(func(e entry) float64 {
+		if e.leaf {
+			return dist(e.obj)
+		}
+		return dist(e.getMBR())
+	}, maxDist, opts)
+}
+
+// nearest returns the objects of the entries within maxDist by dist, nearest first.
+func (tree *HRtree) nearest(dist func(entry) float64, maxDist float64, opts []QueryOption) iter.Seq2[Rectangle, float64] {
+	return func(yield func(Rectangle, float64) bool) {
+		query := newQuery(opts)
+		tree := tree
+		if query.pinned {
+			tree = tree.Snapshot()
+		}
+
+		if tree.label(query.ctx, opSearch) {
+			defer unlabel(query.ctx)
+		}
+
+		if tree.rlock() {
+			defer tree.runlock()
+		}
+
+		q := &nearestQueue{{node: tree.readRoot()}}
+		for q.Len() > 0 {
+			it := heap.Pop(q).(nearestItem)
+			if it.node == nil {
+				if !query.take() {
+					continue
+				}
+
+				if !yield(it.obj, it.dist) || query.full() {
+					return
+				}
+				continue
+			}
+
+			for _, e := range it.node.getEntries() {
+				if !query.accepts(e) {
+					continue
+				}
+
+				d := dist(e)
+				if d > maxDist {
+					continue
+				}
+
+				if it.node.leaf {
+					if query.passes(e.obj) {
+						heap.Push(q, nearestItem{obj: e.obj, dist: d})
+					}
+				} else {
+					heap.Push(q, nearestItem{node: e.node, dist: d})
+				}
+			}
+		}
+	}
+}
+
+// distance returns the euclidean distance between p and the nearest point of r.
+func distance(p Point, r *rectangle) float64 {
+	var sum float64
+	for i, v := range p {
+		var d uint64
+		if v < r.lowerLeft[i] {
+			d = r.lowerLeft[i] - v
+		} else if v > r.upperRight[i] {
+			d = v - r.upperRight[i]
+		}
+		sum += float64(d) * float64(d)
+	}
+
+	return math.Sqrt(sum)
+}
+
+// NearestNeighbor returns the object whose bounding-box is nearest to p among those opts
+// accept, or nil if there is none. Nodes are visited nearest first, and the search stops
+// at the first node farther than the best object found so far.
+func (tree *HRtree) NearestNeighbor(p Point, opts ...QueryOption) Rectangle {
+	query := newQuery(opts)
+	if tree.label(query.ctx, opSearch) {
+		defer unlabel(query.ctx)
+	}
+
+	if tree.rlock() {
+		defer tree.runlock()
+	}
+
+	var best Rectangle
+	bestDist := math.Inf(1)
+
+	q := &nearestQueue{{node: tree.readRoot()}}
+	for q.Len() > 0 {
+		it := heap.Pop(q).(nearestItem)
+		if it.dist >= bestDist {
+			break
+		}
+
+		for _, e := range it.node.getEntries() {
+			if !query.accepts(e) {
+				continue
+			}
+
+			d := distance(p, e.getMBR())
+			if d >= bestDist {
+				continue
+			}
+
+			if !it.node.leaf {
+				heap.Push(q, nearestItem{node: e.node, dist: d})
+			} else if query.passes(e.obj) {
+				best, bestDist = e.obj, d
+			}
+		}
+	}
+
+	return best
+}
+
+// SearchIntersectSorted returns the objects intersecting bb like SearchIntersect, ordered
+// by the distance of their bounding-box from the point from, nearest first. Nodes are
+// visited nearest first, so that with WithLimit only the nodes holding the nearest objects
+// are visited. Windows are not wrapped, see WithWrap.
+func (tree *HRtree) SearchIntersectSorted(bb Rectangle, from Point, opts ...QueryOption) []Rectangle {
+	q := newQuery(opts)
+	if tree.label(q.ctx, opSearch) {
+		defer unlabel(q.ctx)
+	}
+
+	if tree.rlock() {
+		defer tree.runlock()
+	}
+
+	results := []Rectangle{}
+
+	pq := &nearestQueue{{node: tree.readRoot()}}
+	for pq.Len() > 0 {
+		it := heap.Pop(pq).(nearestItem)
+		if it.node == nil {
+			if !q.take() {
+				continue
+			}
+
+			if results = append(results, it.obj); q.full() {
+				break
+			}
+			continue
+		}
+
+		for _, e := range it.node.getEntries() {
+			if !intersect(e.getMBR(), bb) || !q.accepts(e) {
+				continue
+			}
+
+			d := distance(from, e.getMBR())
+			if !it.node.leaf {
+				heap.Push(pq, nearestItem{node: e.node, dist: d})
+			} else if (tree.refine == nil || tree.refine(e.obj, bb)) && q.passes(e.obj) {
+				heap.Push(pq, nearestItem{obj: e.obj, dist: d})
+			}
+		}
+	}
+
+	return results
+}
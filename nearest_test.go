@@ -0,0 +1,183 @@
+package hrtree
+
+import (
+	"math"
+	"math/rand"
+	"sort"
+	"testing"
+)
+
+func TestNearestWithin(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	rt, _ := NewTree(2, 6, 12, WithLazyDelete())
+
+	var objs []Rectangle
+	for i := 0; i < 500; i++ {
+		x, y := uint64(r.Intn(1000)), uint64(r.Intn(1000))
+		obj := rect(Point{x, y}, Point{x + uint64(r.Intn(20)), y + uint64(r.Intn(20))})
+		rt.Insert(obj)
+		objs = append(objs, obj)
+	}
+
+	// removed objects are skipped.
+	for _, obj := range objs[:100] {
+		rt.Delete(obj)
+	}
+	objs = objs[100:]
+
+	p := Point{500, 500}
+	var expected []float64
+	for _, obj := range objs {
+		if d := distance(p, obj.(*rectangle)); d <= 150 {
+			expected = append(expected, d)
+		}
+	}
+	sort.Float64s(expected)
+
+	var found []float64
+	for obj, d := range rt.NearestWithin(p, 150) {
+		if d != distance(p, obj.(*rectangle)) {
+			t.Errorf("wrong distance %v to %v", d, obj)
+		}
+		found = append(found, d)
+	}
+
+	if len(found) != len(expected) {
+		t.Fatalf("expected %d objects, got %d", len(expected), len(found))
+	}
+
+	for i, d := range found {
+		if d != expected[i] {
+			t.Errorf("expected distance %v at %d, got %v", expected[i], i, d)
+		}
+	}
+
+	n := 0
+	for range rt.NearestWithin(p, 150) {
+		if n++; n == 3 {
+			break
+		}
+	}
+
+	if n != 3 {
+		t.Errorf("expected to stop after 3 objects, got %d", n)
+	}
+
+	empty, _ := NewTree(2, 6, 12)
+	for obj := range empty.NearestWithin(p, 1000) {
+		t.Errorf("unexpected object %v", obj)
+	}
+}
+
+func TestNearestBy(t *testing.T) {
+	r := rand.New(rand.NewSource(3))
+	rt, _ := NewTree(2, 6, 12)
+
+	// chebyshev distance from (500, 500).
+	chebyshev := func(obj Rectangle) float64 {
+		var d uint64
+		for i := 0; i < 2; i++ {
+			if ll, ur := obj.LowerLeft()[i], obj.UpperRight()[i]; ll > 500 && ll-500 > d {
+				d = ll - 500
+			} else if ur < 500 && 500-ur > d {
+				d = 500 - ur
+			}
+		}
+		return float64(d)
+	}
+
+	var expected []float64
+	for i := 0; i < 500; i++ {
+		x, y := uint64(r.Intn(1000)), uint64(r.Intn(1000))
+		obj := rect(Point{x, y}, Point{x + uint64(r.Intn(20)), y + uint64(r.Intn(20))})
+		rt.Insert(obj)
+		if d := chebyshev(obj); d <= 200 {
+			expected = append(expected, d)
+		}
+	}
+	sort.Float64s(expected)
+
+	var found []float64
+	for obj, d := range rt.NearestBy(chebyshev, 200) {
+		if d != chebyshev(obj) {
+			t.Errorf("wrong distance %v to %v", d, obj)
+		}
+		found = append(found, d)
+	}
+
+	if len(found) != len(expected) {
+		t.Fatalf("expected %d objects, got %d", len(expected), len(found))
+	}
+
+	for i, d := range found {
+		if d != expected[i] {
+			t.Errorf("expected distance %v at %d, got %v", expected[i], i, d)
+		}
+	}
+}
+
+func TestNearestNeighbor(t *testing.T) {
+	r := rand.New(rand.NewSource(2))
+	rt, _ := NewTree(2, 6, 12, WithLazyDelete())
+
+	if obj := rt.NearestNeighbor(Point{1, 1}); obj != nil {
+		t.Errorf("expected no object in an empty tree, got %v", obj)
+	}
+
+	var objs []Rectangle
+	for i := 0; i < 500; i++ {
+		x, y := uint64(r.Intn(1000)), uint64(r.Intn(1000))
+		obj := rect(Point{x, y}, Point{x + uint64(r.Intn(20)), y + uint64(r.Intn(20))})
+		rt.Insert(obj)
+		objs = append(objs, obj)
+	}
+
+	for _, obj := range objs[:100] {
+		rt.Delete(obj)
+	}
+	objs = objs[100:]
+
+	for i := 0; i < 50; i++ {
+		p := Point{uint64(r.Intn(1100)), uint64(r.Intn(1100))}
+		nearest := math.Inf(1)
+		for _, obj := range objs {
+			nearest = math.Min(nearest, distance(p, obj.(*rectangle)))
+		}
+
+		obj := rt.NearestNeighbor(p)
+		if obj == nil || distance(p, obj.(*rectangle)) != nearest {
+			t.Errorf("expected an object at %v from %v, got %v", nearest, p, obj)
+		}
+	}
+}
+
+func TestSearchIntersectSorted(t *testing.T) {
+	r := rand.New(rand.NewSource(3))
+	rt, _ := NewTree(2, 6, 12)
+	for i := 0; i < 500; i++ {
+		x, y := uint64(r.Intn(1000)), uint64(r.Intn(1000))
+		rt.Insert(rect(Point{x, y}, Point{x + uint64(r.Intn(20)), y + uint64(r.Intn(20))}))
+	}
+
+	bb := rect(Point{200, 300}, Point{600, 700})
+	from := Point{0, 1000}
+	expected := rt.SearchIntersect(bb)
+	sort.SliceStable(expected, func(i, j int) bool {
+		return distance(from, expected[i].(*rectangle)) < distance(from, expected[j].(*rectangle))
+	})
+
+	found := rt.SearchIntersectSorted(bb, from)
+	if len(found) != len(expected) {
+		t.Fatalf("expected %d objects, got %d", len(expected), len(found))
+	}
+
+	for i, obj := range found {
+		if distance(from, obj.(*rectangle)) != distance(from, expected[i].(*rectangle)) {
+			t.Errorf("expected distance %v at %d, got %v", distance(from, expected[i].(*rectangle)), i, distance(from, obj.(*rectangle)))
+		}
+	}
+
+	if nearest := rt.SearchIntersectSorted(bb, from, WithLimit(3)); len(nearest) != 3 || nearest[2] != found[2] {
+		t.Errorf("expected the 3 nearest objects %v, got %v", found[:3], nearest)
+	}
+}
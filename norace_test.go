@@ -0,0 +1,5 @@
+//go:build !race
+
+package hrtree
+
+const raceEnabled = false
@@ -0,0 +1,108 @@
+package hrtree
+
+// Option configures optional behaviour of a tree, see NewTree.
+type Option func(*HRtree)
+
+// WithNodeEntries sets the minimum and maximum number of entries of a node, overriding
+// the arguments of NewTree. Negative values select the defaults.
+func WithNodeEntries(min, max int) Option {
+	return func(tree *HRtree) {
+		tree.min, tree.max = min, max
+	}
+}
+
+// WithResolution sets the number of bits per dimension of the hilbert curve, overriding
+// the argument of NewTree.
+func WithResolution(bits int) Option {
+	return func(tree *HRtree) {
+		tree.bits = bits
+	}
+}
+
+// WithKeyFunc orders objects by the key returned by fn, such as a precomputed cell id or
+// a timestamp, instead of the hilbert value of their center. Searches are not affected,
+// but objects with close keys share nodes, so keys should follow the locality of queries.
+// Trees read with ReadPages keep the stored keys and should be given the same fn.
+func WithKeyFunc(fn func(Rectangle) uint64) Option {
+	return func(tree *HRtree) {
+		tree.keyFunc = fn
+	}
+}
+
+// WithUnderflowThreshold makes nodes with fewer than n entries underflow, rather than
+// those with fewer than the minimum number of entries. Underflowing nodes are merged
+// with their siblings, a lower threshold trades space utilization for fewer merges in
+// trees with many deletions. n should be between 1 and the minimum.
+func WithUnderflowThreshold(n int) Option {
+	return func(tree *HRtree) {
+		tree.underflow = n
+	}
+}
+
+// WithRefine registers fn as the exact test of searches: objects whose bounding-box
+// intersects a search window are only returned if fn(obj, window) reports true, such as
+// when obj is a polygon actually crossing the window. Windows split by WithWrap are
+// passed one by one.
+func WithRefine(fn func(obj, window Rectangle) bool) Option {
+	return func(tree *HRtree) {
+		tree.refine = fn
+	}
+}
+
+// WithEqualFunc makes Delete, Update and Upsert look objects up with fn rather than by
+// comparing their bounds corner for corner, such as to match them by ID. Only objects in
+// the nodes whose bounding-boxes contain the looked up one are compared with it.
+func WithEqualFunc(fn func(a, b Rectangle) bool) Option {
+	return func(tree *HRtree) {
+		tree.equal = fn
+	}
+}
+
+// equals reports whether a and b are the same object for lookups, see WithEqualFunc.
+func (tree *HRtree) equals(a, b Rectangle) bool {
+	if tree.equal != nil {
+		return tree.equal(a, b)
+	}
+
+	return equal(a, b)
+}
+
+// WithConcurrency makes searches and other reads, such as Lookup and Stats, hold the read
+// lock of the tree, so that they can run in parallel with each other while mutations,
+// which always hold the write lock, wait for them. Without it, reads must not run
+// concurrently with mutations. Sequences such as
+// Intersecting hold the lock while they are consumed, the tree must not be modified from
+// within their loops.
+func WithConcurrency() Option {
+	return func(tree *HRtree) {
+		tree.concurrent = true
+	}
+}
+
+// rlock takes the read lock of the tree for a search if set with WithConcurrency, and
+// reports whether it did. Searches of WithSnapshotReads need no lock, as they read the
+// published copy of the tree.
+func (tree *HRtree) rlock() bool {
+	locked := tree.concurrent && !tree.cow
+	if locked {
+		tree.mu.RLock()
+	}
+
+	return locked
+}
+
+// rlockTree is like rlock for reads of the tree itself rather than of its published copy,
+// which hold the lock with WithSnapshotReads too.
+func (tree *HRtree) rlockTree() bool {
+	locked := tree.concurrent || tree.cow
+	if locked {
+		tree.mu.RLock()
+	}
+
+	return locked
+}
+
+// runlock releases the read lock taken by rlock or rlockTree.
+func (tree *HRtree) runlock() {
+	tree.mu.RUnlock()
+}
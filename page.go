@@ -0,0 +1,410 @@
+package hrtree
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+)
+
+// Page layout
+//
+// A paged file is a sequence of fixed-size pages. Page 0 holds the file
+// metadata, every other page holds exactly one node, so a single node can be
+// located (and rewritten in place) at offset id*pageSize without touching the
+// rest of the file. Pages are numbered breadth-first from the root, which is
+// always page 1.
+//
+// Node pages start with a small header (flags, entry count and a CRC32C of
+// the page) followed by fixed-width entries. Leaf entries store the bounds,
+// hilbert value, layer, flags and attributes of the object, non-leaf entries
+// store the bounding-box and LHV of the child along with the child's page
+// number.
+const (
+	PageSize4K = 4096
+	PageSize8K = 8192
+
+	MinPageSize = 512
+
+	pageVersion = 3
+
+	metaSize       = 52
+	pageHeaderSize = 8
+	pageCRCOffset  = 4
+	pageKeySize    = Dim * 8 // a hilbert value of Dim*64 bits
+	pageKeyOffset  = 2 * Dim * 8
+	pageRefOffset  = pageKeyOffset + pageKeySize // child page, or leaf layer and flags
+	pageAttrOffset = pageRefOffset + 8
+	pageEntrySize  = pageAttrOffset + 8
+	pageFlagLeaf   = 1
+	pageEntryDead  = 1
+	rootPage       = 1
+)
+
+var (
+	ErrPageSize       = errors.New("Page size should be a power of two and not smaller than MinPageSize.")
+	ErrPageTooSmall   = errors.New("Page size is too small for the maximum number of node entries.")
+	ErrInvalidPage    = errors.New("Invalid page file.")
+	ErrUnknownVersion = errors.New("Unsupported page file version.")
+	ErrCorruptedPage  = errors.New("Page checksum mismatch.")
+)
+
+var castagnoli = crc32.MakeTable(crc32.Castagnoli)
+
+// pageMagics maps the magic numbers of page files to the dimensions of the builds that
+// write them, so that files of the other build are told from invalid ones.
+var pageMagics = map[string]int{"HRTP": 2, "HRT3": 3}
+
+// PageError records an error found while reading a page.
+type PageError struct {
+	Page uint64
+	Err  error
+}
+
+func (e *PageError) Error() string {
+	return fmt.Sprintf("page %d: %v", e.Page, e.Err)
+}
+
+func (e *PageError) Unwrap() error {
+	return e.Err
+}
+
+// pageChecksum computes the CRC32C of a node page, leaving out the checksum itself.
+func pageChecksum(page []byte) uint32 {
+	crc := crc32.Update(0, castagnoli, page[:pageCRCOffset])
+	return crc32.Update(crc, castagnoli, page[pageCRCOffset+4:])
+}
+
+// MaxEntriesForPage returns the largest fan-out whose nodes fit in a single page
+// of the given size. It can be used as the max argument of NewTree.
+func MaxEntriesForPage(pageSize int) int {
+	return (pageSize - pageHeaderSize) / pageEntrySize
+}
+
+func checkPageSize(pageSize int) error {
+	if pageSize < MinPageSize || pageSize&(pageSize-1) != 0 {
+		return fmt.Errorf("page size %d: %w", pageSize, ErrPageSize)
+	}
+
+	return nil
+}
+
+// WritePages writes the tree to w as a sequence of pageSize-long pages, one node per page.
+// The tree's maximum number of entries must not exceed MaxEntriesForPage(pageSize).
+func (tree *HRtree) WritePages(w io.Writer, pageSize int) error {
+	if err := checkPageSize(pageSize); err != nil {
+		return fmt.Errorf("WritePages: %w", err)
+	}
+
+	if tree.max > MaxEntriesForPage(pageSize) {
+		return fmt.Errorf("WritePages: %d entries per node in %d-byte pages: %w", tree.max, pageSize, ErrPageTooSmall)
+	}
+
+	// number the pages breadth-first, children always come after their parent.
+	ids := map[*node]uint64{tree.root: rootPage}
+	queue := []*node{tree.root}
+	for i := 0; i < len(queue); i++ {
+		n := queue[i]
+		if n.leaf {
+			continue
+		}
+
+		for _, e := range n.getEntries() {
+			ids[e.node] = uint64(len(queue) + rootPage)
+			queue = append(queue, e.node)
+		}
+	}
+
+	p := tree.report(PhaseWrite, len(queue)+1)
+	page := make([]byte, pageSize)
+	tree.encodeMeta(page, rootPage, uint64(len(queue)))
+	if _, err := w.Write(page); err != nil {
+		return fmt.Errorf("WritePages: %w", &PageError{0, err})
+	}
+	p.step()
+
+	for _, n := range queue {
+		if err := encodeNode(n, func(c *node) uint64 { return ids[c] }, page); err != nil {
+			return fmt.Errorf("WritePages: %w", &PageError{ids[n], err})
+		}
+
+		if _, err := w.Write(page); err != nil {
+			return fmt.Errorf("WritePages: %w", &PageError{ids[n], err})
+		}
+		p.step()
+	}
+	p.finish()
+
+	return nil
+}
+
+func (tree *HRtree) encodeMeta(page []byte, root, pages uint64) {
+	clearPage(page)
+	copy(page, pageMagic)
+	binary.LittleEndian.PutUint32(page[4:], pageVersion)
+	binary.LittleEndian.PutUint32(page[8:], uint32(len(page)))
+	binary.LittleEndian.PutUint32(page[12:], uint32(tree.min))
+	binary.LittleEndian.PutUint32(page[16:], uint32(tree.max))
+	binary.LittleEndian.PutUint32(page[20:], uint32(tree.bits))
+	binary.LittleEndian.PutUint64(page[24:], uint64(tree.size))
+	binary.LittleEndian.PutUint64(page[32:], root)
+	binary.LittleEndian.PutUint64(page[40:], pages)
+	binary.LittleEndian.PutUint32(page[48:], crc32.Checksum(page[:48], castagnoli))
+}
+
+// encodeNode writes n into page, child nodes are referenced through their id. It fails
+// with ErrKeyTooWide if a key of a Curve doesn't fit in its entry.
+func encodeNode(n *node, id func(*node) uint64, page []byte) error {
+	clearPage(page)
+
+	if n.leaf {
+		page[0] = pageFlagLeaf
+	}
+	binary.LittleEndian.PutUint16(page[2:], uint16(n.entries.len()))
+
+	buf := page[pageHeaderSize:]
+	for _, e := range n.getEntries() {
+		bb := e.getMBR()
+		for i := 0; i < Dim; i++ {
+			binary.LittleEndian.PutUint64(buf[i*8:], bb.lowerLeft[i])
+			binary.LittleEndian.PutUint64(buf[(Dim+i)*8:], bb.upperRight[i])
+		}
+
+		key := buf[pageKeyOffset:pageRefOffset]
+		if e.leaf {
+			if err := putKey(key, e.h); err != nil {
+				return err
+			}
+			buf[pageRefOffset] = e.layer
+			if e.dead {
+				buf[pageRefOffset+1] = pageEntryDead
+			}
+			binary.LittleEndian.PutUint64(buf[pageAttrOffset:], e.attrs)
+		} else {
+			if err := putKey(key, e.node.lhv); err != nil {
+				return err
+			}
+			binary.LittleEndian.PutUint64(buf[pageRefOffset:], id(e.node))
+		}
+
+		buf = buf[pageEntrySize:]
+	}
+
+	binary.LittleEndian.PutUint32(page[pageCRCOffset:], pageChecksum(page))
+	return nil
+}
+
+func clearPage(page []byte) {
+	for i := range page {
+		page[i] = 0
+	}
+}
+
+// ReadPages loads a tree previously written by WritePages. Leaf entries are restored
+// as plain rectangles carrying the stored bounds, entries removed by lazy deletions
+// stay marked until the next Vacuum. opts enable optional behaviour, such as the
+// WithKeyFunc the tree was built with, the configuration stored in the file wins
+// over WithNodeEntries and WithResolution.
+func ReadPages(r io.ReaderAt, opts ...Option) (*HRtree, error) {
+	tree, err := readPages(r, opts, false)
+	if err != nil {
+		return nil, fmt.Errorf("ReadPages: %w", err)
+	}
+
+	return tree, nil
+}
+
+// pageMeta is the metadata of a paged file, stored in page 0.
+type pageMeta struct {
+	pageSize       int
+	min, max, bits int
+	size           int
+	root, pages    uint64
+}
+
+// readMeta reads and checks the metadata page of r.
+func readMeta(r io.ReaderAt) (pageMeta, error) {
+	var m pageMeta
+	meta := make([]byte, metaSize)
+	if _, err := r.ReadAt(meta, 0); err != nil {
+		return m, &PageError{0, err}
+	}
+
+	if magic := string(meta[:4]); magic != pageMagic {
+		if dims, ok := pageMagics[magic]; ok {
+			return m, fmt.Errorf("%d dimensions, %d built in: %w", dims, Dim, ErrDimensionMismatch)
+		}
+		return m, fmt.Errorf("magic %q: %w", meta[:4], ErrInvalidPage)
+	}
+
+	if binary.LittleEndian.Uint32(meta[48:]) != crc32.Checksum(meta[:48], castagnoli) {
+		return m, &PageError{0, ErrCorruptedPage}
+	}
+
+	if v := binary.LittleEndian.Uint32(meta[4:]); v != pageVersion {
+		return m, fmt.Errorf("version %d: %w", v, ErrUnknownVersion)
+	}
+
+	m.pageSize = int(binary.LittleEndian.Uint32(meta[8:]))
+	if err := checkPageSize(m.pageSize); err != nil {
+		return m, err
+	}
+
+	m.min = int(binary.LittleEndian.Uint32(meta[12:]))
+	m.max = int(binary.LittleEndian.Uint32(meta[16:]))
+	m.bits = int(binary.LittleEndian.Uint32(meta[20:]))
+	if m.max > MaxEntriesForPage(m.pageSize) {
+		return m, fmt.Errorf("%d entries per node in %d-byte pages: %w", m.max, m.pageSize, ErrInvalidPage)
+	}
+
+	m.size = int(binary.LittleEndian.Uint64(meta[24:]))
+	m.root = binary.LittleEndian.Uint64(meta[32:])
+	m.pages = binary.LittleEndian.Uint64(meta[40:])
+
+	return m, nil
+}
+
+// readPages reads the tree of r, which is its store if store is set.
+func readPages(r io.ReaderAt, opts []Option, store bool) (*HRtree, error) {
+	m, err := readMeta(r)
+	if err != nil {
+		return nil, err
+	}
+
+	opts = append(opts[:len(opts):len(opts)], WithNodeEntries(m.min, m.max), WithResolution(m.bits))
+	tree, err := NewTree(m.min, m.max, m.bits, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	d := pageDecoder{
+		r:     r,
+		tree:  tree,
+		page:  make([]byte, m.pageSize),
+		pages: m.pages,
+		store: store,
+	}
+
+	root, err := d.decode(m.root, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	root.adjustLHV()
+	root.adjustMBR()
+	root.stored = store
+
+	tree.root = root
+	tree.size = m.size
+	linkLevels(root)
+	tree.publish()
+
+	return tree, nil
+}
+
+// pageDecoder rebuilds nodes from the pages of a paged file.
+type pageDecoder struct {
+	r     io.ReaderAt
+	tree  *HRtree
+	page  []byte
+	pages uint64
+	seen  int
+	store bool // nodes are read from the store of the tree
+}
+
+func (d *pageDecoder) decode(id uint64, depth int) (*node, error) {
+	// a well-formed file references every page exactly once.
+	d.seen++
+	if id < rootPage || id > d.pages || uint64(d.seen) > d.pages || depth > int(d.pages) {
+		return nil, &PageError{id, ErrInvalidPage}
+	}
+
+	if _, err := d.r.ReadAt(d.page, int64(id)*int64(len(d.page))); err != nil {
+		return nil, &PageError{id, err}
+	}
+
+	if binary.LittleEndian.Uint32(d.page[pageCRCOffset:]) != pageChecksum(d.page) {
+		return nil, &PageError{id, ErrCorruptedPage}
+	}
+
+	n := d.tree.allocNode()
+	n.leaf = d.page[0]&pageFlagLeaf != 0
+	if d.store {
+		n.page = id
+	}
+
+	count := int(binary.LittleEndian.Uint16(d.page[2:]))
+	if count > d.tree.max {
+		return nil, &PageError{id, ErrInvalidPage}
+	}
+
+	// children are decoded after the whole page has been read, as they reuse the buffer.
+	type child struct {
+		id  uint64
+		lhv hkey
+	}
+	var children []child
+
+	buf := d.page[pageHeaderSize:]
+	for i := 0; i < count; i++ {
+		var bb rectangle
+		for j := 0; j < Dim; j++ {
+			bb.lowerLeft[j] = binary.LittleEndian.Uint64(buf[j*8:])
+			bb.upperRight[j] = binary.LittleEndian.Uint64(buf[(Dim+j)*8:])
+		}
+
+		key := keyFromBytes(buf[pageKeyOffset:pageRefOffset])
+		if n.leaf {
+			obj := bb
+			n.entries.entries = append(n.entries.entries, entry{
+				bb:    &bb,
+				obj:   &obj,
+				h:     key,
+				leaf:  true,
+				layer: buf[pageRefOffset],
+				attrs: binary.LittleEndian.Uint64(buf[pageAttrOffset:]),
+				dead:  buf[pageRefOffset+1]&pageEntryDead != 0,
+			})
+
+			if buf[pageRefOffset+1]&pageEntryDead != 0 {
+				d.tree.tombstones++
+			}
+		} else {
+			children = append(children, child{binary.LittleEndian.Uint64(buf[pageRefOffset:]), key})
+		}
+
+		buf = buf[pageEntrySize:]
+	}
+
+	for _, c := range children {
+		cn, err := d.decode(c.id, depth+1)
+		if err != nil {
+			return nil, err
+		}
+
+		cn.lhv = c.lhv
+		cn.parent = n
+		n.entries.entries = append(n.entries.entries, entry{node: cn})
+	}
+
+	if n.leaf {
+		n.adjustLHV()
+	}
+	n.adjustMBR()
+	n.stored = d.store
+
+	return n, nil
+}
+
+// linkLevels connects the left and right sibling pointers of the nodes below n.
+func linkLevels(n *node) {
+	if n.leaf {
+		return
+	}
+
+	n.linkChildren()
+	for _, e := range n.getEntries() {
+		linkLevels(e.node)
+	}
+}
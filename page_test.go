@@ -0,0 +1,129 @@
+package hrtree
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+func TestMaxEntriesForPage(t *testing.T) {
+	if n := MaxEntriesForPage(PageSize4K); n*pageEntrySize+pageHeaderSize > PageSize4K {
+		t.Errorf("%d entries do not fit in a 4K page", n)
+	}
+
+	if MaxEntriesForPage(PageSize8K) <= MaxEntriesForPage(PageSize4K) {
+		t.Errorf("larger pages should allow a larger fan-out")
+	}
+}
+
+func TestWriteReadPages(t *testing.T) {
+	rt, _ := NewTree(4, MaxEntriesForPage(MinPageSize), 12)
+
+	for i := 0; i < 500; i++ {
+		rt.Insert(rect(Point{uint64(i % 40), uint64(i / 40)}, Point{uint64(i%40 + 1), uint64(i/40 + 1)}))
+	}
+
+	var buf bytes.Buffer
+	if err := rt.WritePages(&buf, MinPageSize); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if buf.Len()%MinPageSize != 0 {
+		t.Errorf("file is not page aligned")
+	}
+
+	loaded, err := ReadPages(bytes.NewReader(buf.Bytes()))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if loaded.Size() != rt.Size() {
+		t.Errorf("expected size %d, got %d", rt.Size(), loaded.Size())
+	}
+	checkLinks(t, loaded.root)
+
+	bb := rect(Point{5, 2}, Point{20, 8})
+	if len(loaded.SearchIntersect(bb)) != len(rt.SearchIntersect(bb)) {
+		t.Errorf("loaded tree returned a different result")
+	}
+
+	r := rect(Point{10, 3}, Point{11, 4})
+	if ok, _ := loaded.Delete(r); !ok {
+		t.Errorf("loaded tree should allow deletion")
+	}
+
+	loaded.Insert(r)
+	if loaded.Size() != rt.Size() {
+		t.Errorf("loaded tree should allow insertion")
+	}
+}
+
+func TestWritePagesTooSmall(t *testing.T) {
+	rt, _ := NewTree(DefaultMinNodeEntries, DefaultMaxNodeEntries, 12)
+
+	if err := rt.WritePages(&bytes.Buffer{}, PageSize4K); !errors.Is(err, ErrPageTooSmall) {
+		t.Errorf("expected ErrPageTooSmall, got %v", err)
+	}
+
+	if err := rt.WritePages(&bytes.Buffer{}, 1000); !errors.Is(err, ErrPageSize) {
+		t.Errorf("expected ErrPageSize, got %v", err)
+	}
+}
+
+func TestReadPagesInvalid(t *testing.T) {
+	rt, _ := NewTree(2, 4, 12)
+	rt.Insert(rect(Point{1, 1}, Point{2, 2}))
+
+	var buf bytes.Buffer
+	rt.WritePages(&buf, MinPageSize)
+
+	data := buf.Bytes()
+	data[0] = 'X'
+
+	if _, err := ReadPages(bytes.NewReader(data)); !errors.Is(err, ErrInvalidPage) {
+		t.Errorf("expected ErrInvalidPage, got %v", err)
+	}
+}
+
+func TestReadPagesDimensionMismatch(t *testing.T) {
+	rt, _ := NewTree(2, 4, 12)
+	rt.Insert(rect(Point{1, 1}, Point{2, 2}))
+
+	var buf bytes.Buffer
+	rt.WritePages(&buf, MinPageSize)
+
+	// a file of the build of the other dimension.
+	data := buf.Bytes()
+	for magic, dims := range pageMagics {
+		if dims != Dim {
+			copy(data, magic)
+		}
+	}
+
+	if _, err := ReadPages(bytes.NewReader(data)); !errors.Is(err, ErrDimensionMismatch) {
+		t.Errorf("expected ErrDimensionMismatch, got %v", err)
+	}
+}
+
+func TestReadPagesCorrupted(t *testing.T) {
+	rt, _ := NewTree(2, 4, 12)
+	for i := 0; i < 20; i++ {
+		rt.Insert(rect(Point{uint64(i), uint64(i)}, Point{uint64(i + 1), uint64(i + 1)}))
+	}
+
+	var buf bytes.Buffer
+	rt.WritePages(&buf, MinPageSize)
+
+	data := buf.Bytes()
+	data[2*MinPageSize+pageHeaderSize+3] ^= 0xff
+
+	_, err := ReadPages(bytes.NewReader(data))
+	var perr *PageError
+	if !errors.As(err, &perr) {
+		t.Fatalf("expected a PageError, got %v", err)
+	}
+
+	if perr.Page != 2 || perr.Err != ErrCorruptedPage {
+		t.Errorf("expected corrupted page 2, got %v", perr)
+	}
+}
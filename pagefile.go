@@ -0,0 +1,212 @@
+package hrtree
+
+import (
+	"cmp"
+	"container/list"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+)
+
+// PageFile is a NodeStore keeping its pages in a file, through a buffer pool caching the
+// pages used last. Written pages stay in the pool until they are evicted or synced, so
+// that the nodes close to the root, written by most mutations, only reach the file once.
+type PageFile struct {
+	f        *os.File
+	pageSize int
+	pages    uint64
+	capacity int
+	frames   map[uint64]*list.Element
+	lru      list.List // frames, used last first
+	stats    PoolStats
+}
+
+// PoolStats counts the page accesses of a PageFile.
+type PoolStats struct {
+	Hits, Misses uint64 // reads served by the pool, and from the file
+	Writes       uint64 // pages written to the file
+	Evictions    uint64
+}
+
+type frame struct {
+	id    uint64
+	data  []byte
+	dirty bool
+}
+
+// OpenPageFile opens or creates the page file at path, with a buffer pool of up to
+// poolPages pages. pageSize is that of new files, existing ones keep theirs.
+func OpenPageFile(path string, pageSize, poolPages int) (*PageFile, error) {
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("OpenPageFile: %w", err)
+	}
+
+	fi, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, fmt.Errorf("OpenPageFile: %w", err)
+	}
+
+	if fi.Size() > 0 {
+		m, err := readMeta(f)
+		if err != nil {
+			f.Close()
+			return nil, fmt.Errorf("OpenPageFile: %w", err)
+		}
+		pageSize = m.pageSize
+	}
+
+	if err := checkPageSize(pageSize); err != nil {
+		f.Close()
+		return nil, fmt.Errorf("OpenPageFile: %w", err)
+	}
+
+	return &PageFile{
+		f:        f,
+		pageSize: pageSize,
+		pages:    uint64(fi.Size()) / uint64(pageSize),
+		capacity: max(poolPages, 1),
+		frames:   make(map[uint64]*list.Element),
+	}, nil
+}
+
+func (pf *PageFile) PageSize() int {
+	return pf.pageSize
+}
+
+func (pf *PageFile) Pages() uint64 {
+	return pf.pages
+}
+
+// Stats returns the accesses to the pages so far.
+func (pf *PageFile) Stats() PoolStats {
+	return pf.stats
+}
+
+func (pf *PageFile) ReadPage(id uint64, page []byte) error {
+	if el, ok := pf.frames[id]; ok {
+		pf.stats.Hits++
+		pf.lru.MoveToFront(el)
+		copy(page, el.Value.(*frame).data)
+		return nil
+	}
+
+	if id >= pf.pages {
+		return ErrNoPage
+	}
+
+	pf.stats.Misses++
+	fr, err := pf.frame(id)
+	if err != nil {
+		return err
+	}
+
+	if _, err := pf.f.ReadAt(fr.data, int64(id)*int64(pf.pageSize)); err != nil {
+		pf.drop(id)
+		if errors.Is(err, io.EOF) {
+			return ErrNoPage
+		}
+		return err
+	}
+
+	copy(page, fr.data)
+	return nil
+}
+
+func (pf *PageFile) WritePage(id uint64, page []byte) error {
+	var fr *frame
+	if el, ok := pf.frames[id]; ok {
+		pf.lru.MoveToFront(el)
+		fr = el.Value.(*frame)
+	} else {
+		var err error
+		if fr, err = pf.frame(id); err != nil {
+			return err
+		}
+	}
+
+	copy(fr.data, page)
+	fr.dirty = true
+	pf.pages = max(pf.pages, id+1)
+
+	return nil
+}
+
+// Sync writes the pages modified in the pool to the file, in order, then syncs it.
+func (pf *PageFile) Sync() error {
+	var dirty []*frame
+	for el := pf.lru.Front(); el != nil; el = el.Next() {
+		if fr := el.Value.(*frame); fr.dirty {
+			dirty = append(dirty, fr)
+		}
+	}
+
+	slices.SortFunc(dirty, func(a, b *frame) int {
+		return cmp.Compare(a.id, b.id)
+	})
+
+	for _, fr := range dirty {
+		if err := pf.writeBack(fr); err != nil {
+			return err
+		}
+	}
+
+	return pf.f.Sync()
+}
+
+// Close syncs the file, then closes it.
+func (pf *PageFile) Close() error {
+	err := pf.Sync()
+	if cerr := pf.f.Close(); err == nil {
+		err = cerr
+	}
+
+	return err
+}
+
+// frame returns a new frame for page id, evicting the page used last if the pool is full.
+func (pf *PageFile) frame(id uint64) (*frame, error) {
+	var fr *frame
+	if pf.lru.Len() >= pf.capacity {
+		el := pf.lru.Back()
+		fr = el.Value.(*frame)
+		if err := pf.writeBack(fr); err != nil {
+			return nil, err
+		}
+
+		pf.stats.Evictions++
+		pf.lru.Remove(el)
+		delete(pf.frames, fr.id)
+		fr.id = id
+	} else {
+		fr = &frame{id: id, data: make([]byte, pf.pageSize)}
+	}
+
+	pf.frames[id] = pf.lru.PushFront(fr)
+	return fr, nil
+}
+
+// drop removes the frame of page id from the pool, without writing it.
+func (pf *PageFile) drop(id uint64) {
+	if el, ok := pf.frames[id]; ok {
+		pf.lru.Remove(el)
+		delete(pf.frames, id)
+	}
+}
+
+func (pf *PageFile) writeBack(fr *frame) error {
+	if !fr.dirty {
+		return nil
+	}
+
+	if _, err := pf.f.WriteAt(fr.data, int64(fr.id)*int64(pf.pageSize)); err != nil {
+		return &PageError{fr.id, err}
+	}
+
+	pf.stats.Writes++
+	fr.dirty = false
+	return nil
+}
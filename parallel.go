@@ -0,0 +1,124 @@
+package hrtree
+
+import (
+	"runtime"
+	"sync"
+)
+
+// NewTreeParallel is like NewTreeBulk, with the work spread over the given number of
+// workers, or GOMAXPROCS if workers is not positive. Each worker computes the hilbert
+// values of a share of objs and sorts them, the sorted runs are merged in parallel, then
+// every level of the tree is split into contiguous hilbert ranges built by the workers and
+// stitched together. The resulting tree is the same as the one of NewTreeBulk.
+func NewTreeParallel(min, max, bits int, objs []Rectangle, workers int, opts ...Option) (*HRtree, error) {
+	tree, err := NewTree(min, max, bits, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	objs = tree.currentVersions(objs)
+	if workers <= 0 {
+		workers = runtime.GOMAXPROCS(0)
+	}
+
+	if workers > len(objs) {
+		workers = len(objs)
+	}
+
+	if workers == 0 {
+		workers = 1
+	}
+
+	p := tree.report(PhaseDecode, len(objs))
+	runs := make([][]entry, workers)
+	parallel(workers, func(w int) {
+		share := objs[w*len(objs)/workers : (w+1)*len(objs)/workers]
+		run := make([]entry, len(share))
+		for i, obj := range share {
+			run[i] = tree.newEntry(obj)
+		}
+		sortEntries(run)
+		runs[w] = run
+	})
+	p.advance(len(objs))
+	p.finish()
+
+	// runs are merged pairwise, earlier runs first on ties so that the order is stable.
+	for len(runs) > 1 {
+		merged := make([][]entry, (len(runs)+1)/2)
+		parallel(len(merged), func(i int) {
+			if 2*i+1 == len(runs) {
+				merged[i] = runs[2*i]
+				return
+			}
+			merged[i] = mergeEntries(runs[2*i], runs[2*i+1])
+		})
+		runs = merged
+	}
+
+	tree.pack(runs[0], workers)
+	tree.changed(len(objs))
+	tree.publish()
+
+	return tree, nil
+}
+
+// currentVersions registers the newest version of each ID among the Versioned objects of
+// objs, and returns objs without the older ones.
+func (tree *HRtree) currentVersions(objs []Rectangle) []Rectangle {
+	newest := make(map[string]version)
+	for _, obj := range objs {
+		if v, ok := obj.(Versioned); ok {
+			if cur, ok := newest[v.ID()]; !ok || cur.version < v.Version() {
+				newest[v.ID()] = version{obj, v.Version()}
+			}
+		}
+	}
+
+	if len(newest) == 0 {
+		return objs
+	}
+	tree.versions = newest
+
+	kept := make([]Rectangle, 0, len(objs))
+	for _, obj := range objs {
+		if v, ok := obj.(Versioned); ok && newest[v.ID()].version != v.Version() {
+			continue
+		}
+		kept = append(kept, obj)
+	}
+
+	return kept
+}
+
+// mergeEntries merges two runs of entries sorted by hilbert value, a first on ties.
+func mergeEntries(a, b []entry) []entry {
+	merged := make([]entry, 0, len(a)+len(b))
+	for len(a) > 0 && len(b) > 0 {
+		if b[0].h.cmp(a[0].h) < 0 {
+			merged, b = append(merged, b[0]), b[1:]
+		} else {
+			merged, a = append(merged, a[0]), a[1:]
+		}
+	}
+
+	return append(append(merged, a...), b...)
+}
+
+// parallel calls fn with 0 to workers-1 in separate goroutines and waits for them.
+func parallel(workers int, fn func(w int)) {
+	if workers == 1 {
+		fn(0)
+		return
+	}
+
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			fn(w)
+		}(w)
+	}
+	wg.Wait()
+}
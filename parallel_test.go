@@ -0,0 +1,63 @@
+package hrtree
+
+import (
+	"math/rand"
+	"testing"
+)
+
+func TestNewTreeParallel(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	var objs []Rectangle
+	for i := 0; i < 5000; i++ {
+		x, y := uint64(r.Intn(4000)), uint64(r.Intn(4000))
+		objs = append(objs, rect(Point{x, y}, Point{x + 1, y + 1}))
+	}
+
+	packed, _ := NewTreeBulk(4, 10, 12, objs)
+	for _, workers := range []int{0, 1, 3, 8} {
+		rt, err := NewTreeParallel(4, 10, 12, objs, workers)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		if a, b := rt.Stats(), packed.Stats(); a != b {
+			t.Errorf("expected %+v, got %+v", b, a)
+		}
+
+		// same objects in the same order, as with NewTreeBulk.
+		a, b := rt.liveEntries(), packed.liveEntries()
+		for i := range b {
+			if a[i].obj != b[i].obj {
+				t.Fatalf("expected %v at %d, got %v", b[i].obj, i, a[i].obj)
+			}
+		}
+
+		if rt.root.leaf || rt.root.entries.get(0).node.right != rt.root.entries.get(1).node {
+			t.Errorf("expected linked siblings")
+		}
+	}
+
+	rt, _ := NewTreeParallel(4, 10, 12, nil, 4)
+	if rt.Size() != 0 {
+		t.Errorf("expected an empty tree")
+	}
+}
+
+func TestNewTreeParallelVersioned(t *testing.T) {
+	objs := []Rectangle{
+		position("a", 1, 1, 1),
+		position("b", 1, 2, 2),
+		position("a", 3, 3, 3),
+		position("a", 2, 4, 4),
+		rect(Point{5, 5}, Point{6, 6}),
+	}
+
+	rt, _ := NewTreeParallel(2, 4, 12, objs, 2)
+	if rt.Size() != 3 {
+		t.Errorf("expected 3 objects, got %d", rt.Size())
+	}
+
+	if obj, _ := rt.Lookup("a"); obj.(*vehicle).version != 3 {
+		t.Errorf("expected the newest version of a")
+	}
+}
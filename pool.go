@@ -0,0 +1,81 @@
+package hrtree
+
+import (
+	"sync"
+)
+
+// Results holds the objects found by a pooled search. Its buffer is owned by the tree
+// and is handed out again after Release, so Objects must not be used afterwards.
+type Results struct {
+	Objects []Rectangle
+	pool    *sync.Pool
+}
+
+// Release returns the buffer of r to its tree.
+func (r *Results) Release() {
+	for i := range r.Objects {
+		r.Objects[i] = nil
+	}
+	r.Objects = r.Objects[:0]
+	r.pool.Put(r)
+}
+
+// anyQuery is the query of searches without options, it must not be modified.
+var anyQuery query
+
+// pools recycles the buffers of pooled searches.
+type pools struct {
+	results sync.Pool // *Results
+	stacks  sync.Pool // *[]*node
+}
+
+// SearchIntersectPooled is like SearchIntersect, but the results and the traversal stack
+// are taken from pools of the tree, so that servers running many searches avoid
+// allocating on each of them. The results should be released once used.
+func (tree *HRtree) SearchIntersectPooled(bb Rectangle, opts ...QueryOption) *Results {
+	// options can't be inlined, building a query from them allocates.
+	q := &anyQuery
+	if len(opts) > 0 {
+		q = newQuery(opts)
+	}
+
+	if tree.label(q.ctx, opSearch) {
+		defer unlabel(q.ctx)
+	}
+
+	if tree.rlock() {
+		defer tree.runlock()
+	}
+
+	r, _ := tree.pools.results.Get().(*Results)
+	if r == nil {
+		r = &Results{pool: &tree.pools.results}
+	}
+
+	// the traversal is the one of SearchIntersectFunc, on a pooled stack.
+	tree.searchIntersectFunc(bb, q, func(obj Rectangle) bool {
+		r.Objects = append(r.Objects, obj)
+		return true
+	})
+
+	return r
+}
+
+// getStack takes an empty traversal stack from the pool of the tree.
+func (tree *HRtree) getStack() *[]*node {
+	stack, _ := tree.pools.stacks.Get().(*[]*node)
+	if stack == nil {
+		stack = new([]*node)
+	}
+
+	return stack
+}
+
+// putStack returns stack to the pool of the tree, emptied.
+func (tree *HRtree) putStack(stack *[]*node) {
+	// the backing array may still reference nodes.
+	s := (*stack)[:cap(*stack)]
+	clear(s)
+	*stack = s[:0]
+	tree.pools.stacks.Put(stack)
+}
@@ -0,0 +1,54 @@
+package hrtree
+
+import (
+	"testing"
+)
+
+func TestSearchIntersectPooled(t *testing.T) {
+	rt, _ := NewTree(2, 4, 12)
+	for i := 0; i < 500; i++ {
+		rt.Insert(layered(Point{uint64(i % 25), uint64(i / 25)}, Point{uint64(i%25 + 1), uint64(i/25 + 1)}, uint8(i%3)))
+	}
+
+	queries := []Rectangle{
+		rect(Point{0, 0}, Point{30, 30}),
+		rect(Point{3, 4}, Point{7, 9}),
+		rect(Point{100, 100}, Point{200, 200}),
+	}
+
+	for round := 0; round < 3; round++ {
+		for _, bb := range queries {
+			expected := rt.SearchIntersect(bb, WithLayers(1))
+			r := rt.SearchIntersectPooled(bb, WithLayers(1))
+
+			if len(r.Objects) != len(expected) {
+				t.Fatalf("expected %d results, got %d", len(expected), len(r.Objects))
+			}
+
+			for i, obj := range expected {
+				if r.Objects[i] != obj {
+					t.Errorf("expected %v at %d, got %v", obj, i, r.Objects[i])
+				}
+			}
+			r.Release()
+
+			if len(r.Objects) != 0 {
+				t.Errorf("expected released results to be empty")
+			}
+		}
+	}
+}
+
+func BenchmarkSearchIntersectPooled(b *testing.B) {
+	rt, _ := NewTree(25, 50, 12)
+	for i := 0; i < 10000; i++ {
+		rt.Insert(rect(Point{uint64(i % 100), uint64(i / 100)}, Point{uint64(i%100 + 1), uint64(i/100 + 1)}))
+	}
+	bb := rect(Point{10, 10}, Point{40, 40})
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		rt.SearchIntersectPooled(bb).Release()
+	}
+}
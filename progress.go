@@ -0,0 +1,81 @@
+package hrtree
+
+// Phase names a step of a long-running operation, see WithProgress.
+type Phase string
+
+const (
+	PhaseDecode    Phase = "decode"    // Load reading its stream
+	PhasePack      Phase = "pack"      // building packed nodes
+	PhaseWrite     Phase = "write"     // WritePages and checkpoints writing pages
+	PhasePurge     Phase = "purge"     // Vacuum purging removed entries from leaves
+	PhaseRebalance Phase = "rebalance" // Vacuum handling underflowing leaves
+)
+
+// progressInterval is the number of processed items between two reports of a phase.
+const progressInterval = 1024
+
+// Progress reports how far a phase has gone. Total is 0 while it is unknown.
+type Progress struct {
+	Phase Phase
+	Done  int
+	Total int
+}
+
+// WithProgress makes bulk loads, page writes and vacuums report their progress to fn,
+// every few hundred processed items and once at the end of each phase. fn is called
+// while the operation holds the tree, so it must not use it.
+func WithProgress(fn func(Progress)) Option {
+	return func(tree *HRtree) {
+		tree.progress = fn
+	}
+}
+
+// reporter tracks the progress of a phase. A nil reporter ignores all calls.
+type reporter struct {
+	fn    func(Progress)
+	phase Phase
+	done  int
+	total int
+}
+
+func (tree *HRtree) report(phase Phase, total int) *reporter {
+	if tree.progress == nil {
+		return nil
+	}
+
+	return &reporter{fn: tree.progress, phase: phase, total: total}
+}
+
+// step records one more processed item.
+func (r *reporter) step() {
+	if r == nil {
+		return
+	}
+
+	r.done++
+	if r.done%progressInterval == 0 {
+		r.fn(Progress{r.phase, r.done, r.total})
+	}
+}
+
+// advance records n more processed items at once.
+func (r *reporter) advance(n int) {
+	if r == nil {
+		return
+	}
+
+	before := r.done / progressInterval
+	r.done += n
+	if r.done/progressInterval > before {
+		r.fn(Progress{r.phase, r.done, r.total})
+	}
+}
+
+// finish reports the end of the phase, whose total is known by then.
+func (r *reporter) finish() {
+	if r == nil {
+		return
+	}
+
+	r.fn(Progress{r.phase, r.done, r.done})
+}
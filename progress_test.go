@@ -0,0 +1,83 @@
+package hrtree
+
+import (
+	"io/ioutil"
+	"testing"
+)
+
+func TestProgress(t *testing.T) {
+	var reports []Progress
+	rt, _ := NewTree(4, 9, 12, WithProgress(func(p Progress) {
+		reports = append(reports, p)
+	}), WithLazyDelete())
+
+	if err := rt.Load(encodeRects(3000), decodeRect); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	for _, obj := range rt.SearchIntersect(rect(Point{0, 0}, Point{20, 20})) {
+		rt.Delete(obj)
+	}
+	rt.Vacuum()
+
+	if err := rt.WritePages(ioutil.Discard, PageSize4K); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	last := make(map[Phase]Progress)
+	counts := make(map[Phase]int)
+	for _, p := range reports {
+		if prev, ok := last[p.Phase]; ok && p.Done < prev.Done {
+			t.Errorf("%s progress went backwards: %d after %d", p.Phase, p.Done, prev.Done)
+		}
+		last[p.Phase] = p
+		counts[p.Phase]++
+	}
+
+	if counts[PhaseDecode] != 3000/progressInterval+1 {
+		t.Errorf("expected %d decode reports, got %d", 3000/progressInterval+1, counts[PhaseDecode])
+	}
+
+	if p := last[PhaseDecode]; p.Done != 3000 || p.Total != 3000 {
+		t.Errorf("expected 3000/3000 decoded objects, got %d/%d", p.Done, p.Total)
+	}
+
+	for _, phase := range []Phase{PhasePack, PhasePurge, PhaseRebalance, PhaseWrite} {
+		p, ok := last[phase]
+		if !ok {
+			t.Errorf("no %s progress reported", phase)
+			continue
+		}
+
+		if p.Done == 0 || p.Done != p.Total {
+			t.Errorf("expected %s to end with done equal to total, got %d/%d", phase, p.Done, p.Total)
+		}
+	}
+}
+
+func TestProgressPackTotal(t *testing.T) {
+	var reports []Progress
+	rt, _ := NewTree(2, 4, 12, WithProgress(func(p Progress) {
+		reports = append(reports, p)
+	}))
+
+	if err := rt.Load(encodeRects(5000), decodeRect); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// intermediate reports carry the number of nodes to build on every level.
+	want := 1250 + 313 + 79 + 20 + 5 + 2 + 1
+	seen := false
+	for _, p := range reports {
+		if p.Phase == PhasePack && p.Done < want {
+			seen = true
+			if p.Total != want {
+				t.Errorf("expected %d nodes to pack, got %d", want, p.Total)
+			}
+		}
+	}
+
+	if !seen {
+		t.Errorf("no intermediate pack progress reported")
+	}
+}
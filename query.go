@@ -0,0 +1,131 @@
+package hrtree
+
+import (
+	"context"
+)
+
+// QueryOption restricts the objects returned by a search.
+type QueryOption func(*query)
+
+// query holds the restrictions of a single search.
+type query struct {
+	layers    LayerMask
+	hasLayers bool
+	mask      uint64
+
+	limit, offset int // paging of the results, see WithLimit and WithOffset
+	seen          int // matching objects so far, when paged
+
+	filter func(Rectangle) bool
+	pinned bool            // the search runs on a snapshot, see WithPinnedSnapshot
+	ctx    context.Context // the profiler labels to add those of the tree to
+}
+
+func newQuery(opts []QueryOption) *query {
+	q := &query{}
+	for _, opt := range opts {
+		opt(q)
+	}
+
+	return q
+}
+
+// WithLayers restricts a search to objects on any of the given layers. Subtrees
+// holding none of these layers are not visited.
+func WithLayers(layers ...uint8) QueryOption {
+	return func(q *query) {
+		q.layers = Layers(layers...)
+		q.hasLayers = true
+	}
+}
+
+// WithMask restricts a search to objects having any of the attributes in m (see
+// Attributed). Subtrees holding none of these attributes are not visited.
+func WithMask(m uint64) QueryOption {
+	return func(q *query) {
+		q.mask = m
+	}
+}
+
+// WithLimit returns at most n objects, 0 meaning no limit. The search stops once it
+// found them.
+func WithLimit(n int) QueryOption {
+	return func(q *query) {
+		q.limit = n
+	}
+}
+
+// WithOffset skips the first n objects found. Along with WithLimit, it pages the results
+// of a search: as long as the tree doesn't change, pages follow the order of the search.
+func WithOffset(n int) QueryOption {
+	return func(q *query) {
+		q.offset = n
+	}
+}
+
+// WithFilter restricts a search to the objects for which fn reports true, such as those of
+// a category. fn is called during the traversal, on objects otherwise matching the search.
+// Several filters all apply.
+func WithFilter(fn func(Rectangle) bool) QueryOption {
+	return func(q *query) {
+		if prev := q.filter; prev != nil {
+			q.filter = func(obj Rectangle) bool {
+				return prev(obj) && fn(obj)
+			}
+		} else {
+			q.filter = fn
+		}
+	}
+}
+
+// WithPinnedSnapshot runs a streaming search, that is SearchIntersectFunc, Intersecting,
+// NearestWithin or NearestBy, on a snapshot of the tree taken as it starts (see Snapshot).
+// No lock is held while the results are consumed, and the tree may be modified meanwhile,
+// even by the consumer: the search goes on with the objects of the tree when it started,
+// none being skipped or returned twice. Other searches ignore it.
+func WithPinnedSnapshot() QueryOption {
+	return func(q *query) {
+		q.pinned = true
+	}
+}
+
+// paged reports whether q restricts the number of objects returned.
+func (q *query) paged() bool {
+	return q.limit > 0 || q.offset > 0
+}
+
+// take counts a matching object, and reports whether it is to be returned rather than
+// skipped by the offset.
+func (q *query) take() bool {
+	if !q.paged() {
+		return true
+	}
+
+	q.seen++
+	return q.seen > q.offset
+}
+
+// full reports whether the limit of objects is reached.
+func (q *query) full() bool {
+	return q.limit > 0 && q.seen >= q.offset+q.limit
+}
+
+// accepts reports whether e, or the subtree under it, may hold matching objects. Filters
+// are left to passes, as they only see the objects matching the search.
+func (q *query) accepts(e entry) bool {
+	if e.dead {
+		return false
+	}
+
+	if q.hasLayers && !q.layers.Intersects(e.getLayers()) {
+		return false
+	}
+
+	return q.mask == 0 || q.mask&e.getAttributes() != 0
+}
+
+// passes reports whether obj, an object matching the search, passes the filters of
+// WithFilter.
+func (q *query) passes(obj Rectangle) bool {
+	return q.filter == nil || q.filter(obj)
+}
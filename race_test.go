@@ -0,0 +1,7 @@
+//go:build race
+
+package hrtree
+
+// raceEnabled reports whether tests run with the race detector, which makes pools drop
+// some of their buffers.
+const raceEnabled = true
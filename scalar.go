@@ -0,0 +1,103 @@
+package hrtree
+
+import (
+	"math"
+)
+
+// Scalar is the set of coordinate types supported by PointOf and RectOf.
+type Scalar interface {
+	uint32 | uint64 | int64 | float64
+}
+
+// PointOf is a point with coordinates of type T.
+type PointOf[T Scalar] [Dim]T
+
+// Quantize maps p to the unsigned coordinates used by the tree, see Quantize.
+func (p PointOf[T]) Quantize() Point {
+	var q Point
+	for i, v := range p {
+		q[i] = Quantize(v)
+	}
+
+	return q
+}
+
+// RectOf is a rectangle with coordinates of type T. It implements Rectangle through
+// its quantized corners, so it can be stored in and used to query any tree whose
+// resolution is at least ResolutionOf[T]().
+type RectOf[T Scalar] struct {
+	Min, Max PointOf[T]
+}
+
+// NewRectOf returns the rectangle with the given corners.
+func NewRectOf[T Scalar](min, max PointOf[T]) RectOf[T] {
+	return RectOf[T]{min, max}
+}
+
+func (r RectOf[T]) LowerLeft() Point {
+	return r.Min.Quantize()
+}
+
+func (r RectOf[T]) UpperRight() Point {
+	return r.Max.Quantize()
+}
+
+// ResolutionOf returns the number of bits per dimension needed to store coordinates of
+// type T without loss.
+func ResolutionOf[T Scalar]() int {
+	var v T
+	if _, ok := any(v).(uint32); ok {
+		return 32
+	}
+
+	return 64
+}
+
+// Quantize maps v to an unsigned coordinate, preserving order: signed integers are
+// offset by 2^63, floats are mapped through their IEEE 754 representation (-0 and +0
+// being the same point). The mapping is lossless, so comparisons between quantized
+// coordinates are exact. NaN is not supported.
+func Quantize[T Scalar](v T) uint64 {
+	switch x := any(v).(type) {
+	case uint32:
+		return uint64(x)
+	case uint64:
+		return x
+	case int64:
+		return uint64(x) ^ 1<<63
+	case float64:
+		if x == 0 {
+			x = 0 // -0
+		}
+
+		b := math.Float64bits(x)
+		if b&(1<<63) != 0 {
+			return ^b
+		}
+
+		return b | 1<<63
+	}
+
+	panic("unreachable")
+}
+
+// Dequantize is the inverse of Quantize.
+func Dequantize[T Scalar](q uint64) T {
+	var v T
+	switch p := any(&v).(type) {
+	case *uint32:
+		*p = uint32(q)
+	case *uint64:
+		*p = q
+	case *int64:
+		*p = int64(q ^ 1<<63)
+	case *float64:
+		if q&(1<<63) != 0 {
+			*p = math.Float64frombits(q &^ (1 << 63))
+		} else {
+			*p = math.Float64frombits(^q)
+		}
+	}
+
+	return v
+}
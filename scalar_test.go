@@ -0,0 +1,75 @@
+package hrtree
+
+import (
+	"math"
+	"testing"
+)
+
+func TestQuantizeOrder(t *testing.T) {
+	floats := []float64{math.Inf(-1), -math.MaxFloat64, -1e10, -1.5, -math.SmallestNonzeroFloat64, 0, math.SmallestNonzeroFloat64, 0.25, 1, 1e300, math.Inf(1)}
+	for i := 1; i < len(floats); i++ {
+		if Quantize(floats[i-1]) >= Quantize(floats[i]) {
+			t.Errorf("expected %v to quantize below %v", floats[i-1], floats[i])
+		}
+	}
+
+	ints := []int64{math.MinInt64, -1 << 40, -1, 0, 1, 1 << 40, math.MaxInt64}
+	for i := 1; i < len(ints); i++ {
+		if Quantize(ints[i-1]) >= Quantize(ints[i]) {
+			t.Errorf("expected %v to quantize below %v", ints[i-1], ints[i])
+		}
+	}
+
+	if Quantize(math.Copysign(0, -1)) != Quantize(0.0) {
+		t.Errorf("expected -0 and +0 to be the same point")
+	}
+
+	for _, f := range floats {
+		if g := Dequantize[float64](Quantize(f)); g != f {
+			t.Errorf("expected %v back, got %v", f, g)
+		}
+	}
+
+	for _, i := range ints {
+		if j := Dequantize[int64](Quantize(i)); j != i {
+			t.Errorf("expected %v back, got %v", i, j)
+		}
+	}
+
+	if v := Dequantize[uint32](Quantize(uint32(math.MaxUint32))); v != math.MaxUint32 {
+		t.Errorf("expected %v back, got %v", uint32(math.MaxUint32), v)
+	}
+}
+
+func TestRectOf(t *testing.T) {
+	rt, _ := NewTree(2, 4, ResolutionOf[float64]())
+
+	for i := -10; i < 10; i++ {
+		x := float64(i) * 0.5
+		rt.Insert(NewRectOf(PointOf[float64]{x, -x}, PointOf[float64]{x + 0.25, -x + 0.25}))
+	}
+
+	q := rt.SearchIntersect(NewRectOf(PointOf[float64]{-1.1, 0}, PointOf[float64]{0, 1.1}))
+	if len(q) != 3 {
+		t.Fatalf("expected 3 results, got %d", len(q))
+	}
+
+	for _, obj := range q {
+		r := obj.(RectOf[float64])
+		if r.Min[0] < -1.1 || r.Min[0] > 0 {
+			t.Errorf("unexpected result %v", r)
+		}
+	}
+
+	it, _ := NewTree(2, 4, ResolutionOf[int64]())
+	it.Insert(NewRectOf(PointOf[int64]{-5, -5}, PointOf[int64]{-3, -3}))
+	it.Insert(NewRectOf(PointOf[int64]{3, 3}, PointOf[int64]{5, 5}))
+
+	if q := it.SearchIntersect(NewRectOf(PointOf[int64]{-4, -4}, PointOf[int64]{0, 0})); len(q) != 1 {
+		t.Errorf("expected 1 result, got %d", len(q))
+	}
+
+	if ResolutionOf[uint32]() != 32 || ResolutionOf[uint64]() != 64 {
+		t.Errorf("unexpected resolutions")
+	}
+}
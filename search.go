@@ -0,0 +1,252 @@
+package hrtree
+
+import (
+	"iter"
+)
+
+// SearchContained returns the objects whose bounding-box lies within bb, unlike
+// SearchIntersect which also returns those crossing its bounds. A window wrapped as set by
+// WithWrap is split, and objects are returned if they lie within one of its parts and
+// WithRefine accepts them with it.
+func (tree *HRtree) SearchContained(bb Rectangle, opts ...QueryOption) []Rectangle {
+	q := newQuery(opts)
+	if tree.label(q.ctx, opSearch) {
+		defer unlabel(q.ctx)
+	}
+
+	if tree.rlock() {
+		defer tree.runlock()
+	}
+
+	windows := tree.windows(bb)
+	if windows == nil {
+		windows = []*rectangle{{bb.LowerLeft(), bb.UpperRight()}}
+	}
+
+	// objects don't wrap, so that none lies within two parts of a window.
+	results := []Rectangle{}
+	for i := 0; i < len(windows) && !q.full(); i++ {
+		w := windows[i]
+		visit := func(r *rectangle) bool { return intersect(r, w) }
+		match := func(e entry) bool {
+			return w.contains(e.getMBR()) && (tree.refine == nil || tree.refine(e.obj, w))
+		}
+		results = tree.searchWith(tree.readRoot(), visit, match, q, results)
+	}
+
+	return results
+}
+
+// searchWith appends to results the objects under n accepted by q whose entry matches,
+// only visiting the subtrees whose bounding-box passes visit.
+func (tree *HRtree) searchWith(n *node, visit func(*rectangle) bool, match func(entry) bool, q *query, results []Rectangle) []Rectangle {
+	for _, e := range n.getEntries() {
+		if !q.accepts(e) {
+			continue
+		}
+
+		if n.leaf {
+			if match(e) && q.passes(e.obj) && q.take() {
+				results = append(results, e.obj)
+			}
+		} else if visit(e.getMBR()) {
+			results = tree.searchWith(e.node, visit, match, q, results)
+		}
+
+		if q.full() {
+			break
+		}
+	}
+
+	return results
+}
+
+// SearchCovering returns the objects whose bounding-box contains bb, only visiting the
+// subtrees whose bounding-box contains it too. A window wrapped as set by WithWrap is only
+// covered by objects containing all of its parts, which WithRefine should accept with
+// each part.
+func (tree *HRtree) SearchCovering(bb Rectangle, opts ...QueryOption) []Rectangle {
+	q := newQuery(opts)
+	if tree.label(q.ctx, opSearch) {
+		defer unlabel(q.ctx)
+	}
+
+	if tree.rlock() {
+		defer tree.runlock()
+	}
+
+	windows := tree.windows(bb)
+	if windows == nil {
+		windows = []*rectangle{{bb.LowerLeft(), bb.UpperRight()}}
+	}
+
+	covers := func(r *rectangle) bool {
+		for _, w := range windows {
+			if !r.contains(w) {
+				return false
+			}
+		}
+
+		return true
+	}
+	match := func(e entry) bool {
+		if !covers(e.getMBR()) {
+			return false
+		}
+
+		for _, w := range windows {
+			if tree.refine != nil && !tree.refine(e.obj, w) {
+				return false
+			}
+		}
+
+		return true
+	}
+
+	return tree.searchWith(tree.readRoot(), covers, match, q, []Rectangle{})
+}
+
+// SearchPoint returns the objects whose bounding-box contains p, bounds included. It is
+// the fast path of hit-testing, comparing p to the bounding-boxes directly. Objects are
+// then refined as set by WithRefine, with a window holding only p; points never wrap, so
+// WithWrap has no effect.
+func (tree *HRtree) SearchPoint(p Point, opts ...QueryOption) []Rectangle {
+	q := newQuery(opts)
+	if tree.label(q.ctx, opSearch) {
+		defer unlabel(q.ctx)
+	}
+
+	if tree.rlock() {
+		defer tree.runlock()
+	}
+
+	return tree.searchPoint(tree.readRoot(), &rectangle{p, p}, q, []Rectangle{})
+}
+
+// searchPoint appends to results the objects under n containing the point window w.
+func (tree *HRtree) searchPoint(n *node, w *rectangle, q *query, results []Rectangle) []Rectangle {
+	for _, e := range n.getEntries() {
+		if !containsPoint(e.getMBR(), &w.lowerLeft) || !q.accepts(e) {
+			continue
+		}
+
+		if !n.leaf {
+			results = tree.searchPoint(e.node, w, q, results)
+		} else if (tree.refine == nil || tree.refine(e.obj, w)) && q.passes(e.obj) && q.take() {
+			results = append(results, e.obj)
+		}
+
+		if q.full() {
+			break
+		}
+	}
+
+	return results
+}
+
+// containsPoint reports whether p lies within r, bounds included.
+func containsPoint(r *rectangle, p *Point) bool {
+	for i, v := range p {
+		if v < r.lowerLeft[i] || v > r.upperRight[i] {
+			return false
+		}
+	}
+
+	return true
+}
+
+// SearchIntersectFunc calls fn with the objects SearchIntersect would return, in the same
+// order, without collecting them. The search stops as soon as fn returns false.
+func (tree *HRtree) SearchIntersectFunc(bb Rectangle, fn func(Rectangle) bool, opts ...QueryOption) {
+	q := newQuery(opts)
+	if q.pinned {
+		tree = tree.Snapshot()
+	}
+
+	if tree.label(q.ctx, opSearch) {
+		defer unlabel(q.ctx)
+	}
+
+	if tree.rlock() {
+		defer tree.runlock()
+	}
+
+	tree.searchIntersectFunc(bb, q, fn)
+}
+
+// searchIntersectFunc calls fn with the objects found by a search of bb, splitting it
+// into wrapped windows and paging the objects as set by q.
+func (tree *HRtree) searchIntersectFunc(bb Rectangle, q *query, fn func(Rectangle) bool) {
+	root := tree.readRoot()
+	windows := tree.windows(bb)
+	if windows == nil {
+		tree.visitIntersect(root, bb, nil, q, fn)
+		return
+	}
+
+	for i, w := range windows {
+		if !tree.visitIntersect(root, w, windows[:i], q, fn) {
+			return
+		}
+	}
+}
+
+// visitIntersect calls fn with the objects under n found by a search of bb, skipping those
+// found by one of the earlier windows, and paged as set by q. It returns false once fn or
+// the limit of q stopped the search. The traversal is depth-first on a pooled stack,
+// children being pushed right to left so that objects come in the order of the leaves.
+func (tree *HRtree) visitIntersect(n *node, bb Rectangle, earlier []*rectangle, q *query, fn func(Rectangle) bool) bool {
+	stack := tree.getStack()
+	defer tree.putStack(stack)
+
+	s := append(*stack, n)
+	defer func() { *stack = s }()
+
+	for len(s) > 0 {
+		n := s[len(s)-1]
+		s = s[:len(s)-1]
+
+		entries := n.getEntries()
+		if n.leaf {
+			for _, e := range entries {
+				if !intersect(e.getMBR(), bb) || !q.accepts(e) || (tree.refine != nil && !tree.refine(e.obj, bb)) {
+					continue
+				}
+
+				if q.passes(e.obj) && !tree.foundIn(earlier, e.obj) && q.take() && (!fn(e.obj) || q.full()) {
+					return false
+				}
+			}
+			continue
+		}
+
+		for i := len(entries) - 1; i >= 0; i-- {
+			if e := entries[i]; intersect(e.getMBR(), bb) && q.accepts(e) {
+				s = append(s, e.node)
+			}
+		}
+	}
+
+	return true
+}
+
+// Intersecting returns the objects SearchIntersect would return as a sequence, so that
+// callers can range over them and stop early. The tree is traversed as the sequence is
+// consumed, use iter.Pull for a resumable traversal, and WithPinnedSnapshot to modify the
+// tree meanwhile.
+func (tree *HRtree) Intersecting(bb Rectangle, opts ...QueryOption) iter.Seq[Rectangle] {
+	return func(yield func(Rectangle) bool) {
+		tree.SearchIntersectFunc(bb, yield, opts...)
+	}
+}
+
+// AnyIntersect reports whether any object intersects bb, stopping at the first one found.
+func (tree *HRtree) AnyIntersect(bb Rectangle, opts ...QueryOption) bool {
+	found := false
+	tree.SearchIntersectFunc(bb, func(Rectangle) bool {
+		found = true
+		return false
+	}, opts...)
+
+	return found
+}
@@ -0,0 +1,468 @@
+package hrtree
+
+import (
+	"iter"
+	"math/rand"
+	"testing"
+)
+
+// randomTree returns a tree of n random objects, along with them.
+func randomTree(r *rand.Rand, n int) (*HRtree, []*rectangle) {
+	rt, _ := NewTree(2, 6, 12)
+	var objs []*rectangle
+	for i := 0; i < n; i++ {
+		x, y := uint64(r.Intn(1000)), uint64(r.Intn(1000))
+		obj := rect(Point{x, y}, Point{x + uint64(r.Intn(50)), y + uint64(r.Intn(50))})
+		rt.Insert(obj)
+		objs = append(objs, obj)
+	}
+
+	return rt, objs
+}
+
+// checkSearch compares the results of a search with the objects matching it.
+func checkSearch(t *testing.T, name string, found []Rectangle, objs []*rectangle, match func(*rectangle) bool) {
+	expected := 0
+	for _, obj := range objs {
+		if match(obj) {
+			expected++
+		}
+	}
+
+	if len(found) != expected {
+		t.Errorf("%s: expected %d objects, got %d", name, expected, len(found))
+	}
+
+	for _, obj := range found {
+		if !match(obj.(*rectangle)) {
+			t.Errorf("%s: unexpected object %v", name, obj)
+		}
+	}
+}
+
+func TestSearchContained(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	rt, objs := randomTree(r, 500)
+
+	for i := 0; i < 20; i++ {
+		x, y := uint64(r.Intn(1000)), uint64(r.Intn(1000))
+		bb := rect(Point{x, y}, Point{x + uint64(r.Intn(300)), y + uint64(r.Intn(300))})
+		checkSearch(t, "SearchContained", rt.SearchContained(bb), objs, func(obj *rectangle) bool {
+			return bb.contains(obj)
+		})
+	}
+
+	// objects on the bounds of the window are contained.
+	obj := objs[0]
+	if found := rt.SearchContained(obj); index(found, obj) < 0 {
+		t.Errorf("expected %v to contain itself", obj)
+	}
+}
+
+func TestSearchContainedWrapRefine(t *testing.T) {
+	even := func(obj, window Rectangle) bool { return obj.LowerLeft()[1]%2 == 0 }
+	rt, _ := NewTree(2, 4, 8, WithWrap(0), WithRefine(even))
+	west := rect(Point{2, 10}, Point{4, 12})
+	east := rect(Point{250, 10}, Point{253, 12})
+	odd := rect(Point{3, 11}, Point{4, 12})
+	wide := rect(Point{0, 10}, Point{255, 12}) // crosses the end of the space
+	for _, obj := range []Rectangle{west, east, odd, wide} {
+		rt.Insert(obj)
+	}
+
+	found := rt.SearchContained(NewWrapWindow(Point{240, 0}, Point{10, 20}))
+	if len(found) != 2 || index(found, west) < 0 || index(found, east) < 0 {
+		t.Errorf("expected %v and %v, got %v", west, east, found)
+	}
+
+	if found := rt.SearchContained(NewWrapWindow(Point{240, 0}, Point{10, 20}), WithLimit(1)); len(found) != 1 {
+		t.Errorf("expected a single object, got %v", found)
+	}
+}
+
+func TestSearchCovering(t *testing.T) {
+	r := rand.New(rand.NewSource(2))
+	rt, objs := randomTree(r, 500)
+
+	for i := 0; i < 50; i++ {
+		x, y := uint64(r.Intn(1000)), uint64(r.Intn(1000))
+		bb := rect(Point{x, y}, Point{x + uint64(r.Intn(10)), y + uint64(r.Intn(10))})
+		checkSearch(t, "SearchCovering", rt.SearchCovering(bb), objs, func(obj *rectangle) bool {
+			return obj.contains(bb)
+		})
+	}
+
+	// a point is covered by every object around it.
+	p := rect(Point{500, 500}, Point{500, 500})
+	checkSearch(t, "SearchCovering", rt.SearchCovering(p), objs, func(obj *rectangle) bool {
+		return obj.contains(p)
+	})
+}
+
+func TestSearchCoveringWrapRefine(t *testing.T) {
+	even := func(obj, window Rectangle) bool { return obj.LowerLeft()[1]%2 == 0 }
+	rt, _ := NewTree(2, 4, 8, WithWrap(0), WithRefine(even))
+	east := rect(Point{240, 10}, Point{255, 12})
+	wide := rect(Point{0, 10}, Point{255, 12})
+	odd := rect(Point{0, 9}, Point{255, 12})
+	for _, obj := range []Rectangle{east, wide, odd} {
+		rt.Insert(obj)
+	}
+
+	// only objects spanning the whole dimension cover both parts of the window.
+	if found := rt.SearchCovering(NewWrapWindow(Point{250, 11}, Point{5, 11})); len(found) != 1 || found[0] != wide {
+		t.Errorf("expected %v, got %v", wide, found)
+	}
+
+	if found := rt.SearchCovering(rect(Point{250, 11}, Point{251, 11})); len(found) != 2 || index(found, odd) >= 0 {
+		t.Errorf("expected %v and %v, got %v", east, wide, found)
+	}
+}
+
+func TestSearchPoint(t *testing.T) {
+	r := rand.New(rand.NewSource(3))
+	rt, objs := randomTree(r, 500)
+
+	for i := 0; i < 50; i++ {
+		p := Point{uint64(r.Intn(1050)), uint64(r.Intn(1050))}
+		checkSearch(t, "SearchPoint", rt.SearchPoint(p), objs, func(obj *rectangle) bool {
+			return obj.contains(rect(p, p))
+		})
+	}
+
+	// corners are part of objects.
+	obj := objs[0]
+	for _, p := range []Point{obj.lowerLeft, obj.upperRight} {
+		if index(rt.SearchPoint(p), obj) < 0 {
+			t.Errorf("expected %v to contain its corner %v", obj, p)
+		}
+	}
+}
+
+func TestSearchPointRefine(t *testing.T) {
+	// objects are triangles below the diagonal of their bounding-box.
+	below := func(obj, window Rectangle) bool {
+		ll, ur, p := obj.LowerLeft(), obj.UpperRight(), window.LowerLeft()
+		return (p[0]-ll[0])*(ur[1]-ll[1]) >= (p[1]-ll[1])*(ur[0]-ll[0])
+	}
+	rt, _ := NewTree(2, 4, 12, WithRefine(below))
+	obj := rect(Point{10, 10}, Point{20, 20})
+	rt.Insert(obj)
+
+	if found := rt.SearchPoint(Point{18, 12}); len(found) != 1 {
+		t.Errorf("expected %v, got %v", obj, found)
+	}
+
+	if found := rt.SearchPoint(Point{12, 18}); len(found) != 0 {
+		t.Errorf("expected no objects, got %v", found)
+	}
+}
+
+func TestSearchIntersectFunc(t *testing.T) {
+	r := rand.New(rand.NewSource(4))
+	rt, _ := randomTree(r, 500)
+
+	bb := rect(Point{200, 200}, Point{700, 700})
+	expected := rt.SearchIntersect(bb)
+
+	var found []Rectangle
+	rt.SearchIntersectFunc(bb, func(obj Rectangle) bool {
+		found = append(found, obj)
+		return true
+	})
+
+	if len(found) != len(expected) {
+		t.Fatalf("expected %d objects, got %d", len(expected), len(found))
+	}
+
+	for i, obj := range found {
+		if obj != expected[i] {
+			t.Errorf("expected %v at %d, got %v", expected[i], i, obj)
+		}
+	}
+
+	n := 0
+	rt.SearchIntersectFunc(bb, func(obj Rectangle) bool {
+		n++
+		return n < 5
+	})
+
+	if n != 5 {
+		t.Errorf("expected the search to stop after 5 objects, got %d", n)
+	}
+
+	// wrapped windows are searched in turn.
+	wrapped, _ := NewTree(2, 6, 12, WithWrap(0))
+	west, east := rect(Point{0, 0}, Point{4095, 1}), rect(Point{4000, 0}, Point{4001, 1})
+	wrapped.Insert(west)
+	wrapped.Insert(east)
+
+	found = found[:0]
+	wrapped.SearchIntersectFunc(rect(Point{3000, 0}, Point{10, 5}), func(obj Rectangle) bool {
+		found = append(found, obj)
+		return true
+	})
+
+	if len(found) != 2 {
+		t.Errorf("expected 2 objects, got %v", found)
+	}
+}
+
+func TestIntersecting(t *testing.T) {
+	r := rand.New(rand.NewSource(5))
+	rt, _ := randomTree(r, 500)
+
+	bb := rect(Point{100, 100}, Point{600, 900})
+	expected := rt.SearchIntersect(bb, WithMask(0))
+
+	i := 0
+	for obj := range rt.Intersecting(bb, WithMask(0)) {
+		if obj != expected[i] {
+			t.Errorf("expected %v at %d, got %v", expected[i], i, obj)
+		}
+		i++
+	}
+
+	if i != len(expected) {
+		t.Errorf("expected %d objects, got %d", len(expected), i)
+	}
+
+	// a pulled sequence resumes where it stopped.
+	next, stop := iter.Pull(rt.Intersecting(bb))
+	defer stop()
+	for i := 0; i < 3; i++ {
+		if obj, ok := next(); !ok || obj != expected[i] {
+			t.Errorf("expected %v at %d, got %v", expected[i], i, obj)
+		}
+	}
+}
+
+func TestLimitOffset(t *testing.T) {
+	r := rand.New(rand.NewSource(6))
+	rt, _ := randomTree(r, 500)
+
+	bb := rect(Point{0, 0}, Point{800, 800})
+	all := rt.SearchIntersect(bb)
+	if len(all) < 100 {
+		t.Fatalf("expected many objects, got %d", len(all))
+	}
+
+	// pages of 30 objects follow the order of the search.
+	var paged []Rectangle
+	for offset := 0; ; offset += 30 {
+		page := rt.SearchIntersect(bb, WithLimit(30), WithOffset(offset))
+		if len(page) > 30 {
+			t.Fatalf("expected at most 30 objects, got %d", len(page))
+		}
+
+		pooled := rt.SearchIntersectPooled(bb, WithLimit(30), WithOffset(offset))
+		if len(pooled.Objects) != len(page) {
+			t.Errorf("expected %d pooled objects, got %d", len(page), len(pooled.Objects))
+		}
+		pooled.Release()
+
+		if len(page) == 0 {
+			break
+		}
+		paged = append(paged, page...)
+	}
+
+	if len(paged) != len(all) {
+		t.Fatalf("expected %d objects, got %d", len(all), len(paged))
+	}
+
+	for i, obj := range paged {
+		if obj != all[i] {
+			t.Errorf("expected %v at %d, got %v", all[i], i, obj)
+		}
+	}
+
+	n := 0
+	for range rt.Intersecting(bb, WithOffset(len(all)-5), WithLimit(10)) {
+		n++
+	}
+
+	if n != 5 {
+		t.Errorf("expected the last 5 objects, got %d", n)
+	}
+
+	contained := rt.SearchContained(bb)
+	if page := rt.SearchContained(bb, WithOffset(10), WithLimit(3)); len(page) != 3 || page[0] != contained[10] {
+		t.Errorf("expected objects 10 to 12 of %v, got %v", contained, page)
+	}
+
+	p := all[0].LowerLeft()
+	if page := rt.SearchPoint(p, WithLimit(1)); len(page) != 1 || page[0] != rt.SearchPoint(p)[0] {
+		t.Errorf("expected the first object of %v, got %v", rt.SearchPoint(p), page)
+	}
+
+	// objects found by several wrapped windows are counted once.
+	wrapped, _ := NewTree(2, 6, 12, WithWrap(0))
+	wrapped.Insert(rect(Point{0, 0}, Point{4095, 1}))
+	wrapped.Insert(rect(Point{4000, 0}, Point{4001, 1}))
+	if page := wrapped.SearchIntersect(rect(Point{3000, 0}, Point{10, 5}), WithOffset(1)); len(page) != 1 {
+		t.Errorf("expected a single object, got %v", page)
+	}
+}
+
+func TestAnyIntersect(t *testing.T) {
+	rt, _ := NewTree(2, 6, 12, WithLazyDelete())
+	if rt.AnyIntersect(rect(Point{0, 0}, Point{4095, 4095})) {
+		t.Errorf("expected no object in an empty tree")
+	}
+
+	for i := uint64(0); i < 100; i++ {
+		rt.Insert(rect(Point{10 * i, 10 * i}, Point{10*i + 5, 10*i + 5}))
+	}
+
+	if !rt.AnyIntersect(rect(Point{14, 14}, Point{20, 20})) {
+		t.Errorf("expected a hit")
+	}
+
+	if rt.AnyIntersect(rect(Point{16, 16}, Point{19, 19})) {
+		t.Errorf("expected no hit between objects")
+	}
+
+	rt.Delete(rect(Point{10, 10}, Point{15, 15}))
+	rt.Delete(rect(Point{20, 20}, Point{25, 25}))
+	if rt.AnyIntersect(rect(Point{14, 14}, Point{20, 20})) {
+		t.Errorf("expected removed objects to be skipped")
+	}
+
+	if rt.AnyIntersect(rect(Point{0, 0}, Point{100, 100}), WithMask(1)) {
+		t.Errorf("expected options to apply")
+	}
+}
+
+func TestFilter(t *testing.T) {
+	r := rand.New(rand.NewSource(7))
+	rt, objs := randomTree(r, 500)
+
+	// objects with an even lower x coordinate.
+	even := func(obj Rectangle) bool {
+		return obj.LowerLeft()[0]%2 == 0
+	}
+
+	bb := rect(Point{100, 100}, Point{900, 900})
+	checkSearch(t, "SearchIntersect", rt.SearchIntersect(bb, WithFilter(even)), objs, func(obj *rectangle) bool {
+		return even(obj) && intersect(obj, bb)
+	})
+
+	checkSearch(t, "SearchContained", rt.SearchContained(bb, WithFilter(even)), objs, func(obj *rectangle) bool {
+		return even(obj) && bb.contains(obj)
+	})
+
+	pooled := rt.SearchIntersectPooled(bb, WithFilter(even))
+	checkSearch(t, "SearchIntersectPooled", pooled.Objects, objs, func(obj *rectangle) bool {
+		return even(obj) && intersect(obj, bb)
+	})
+	pooled.Release()
+
+	// filtered objects don't count towards the limit.
+	if page := rt.SearchIntersect(bb, WithFilter(even), WithLimit(10)); len(page) != 10 {
+		t.Errorf("expected 10 objects, got %d", len(page))
+	}
+
+	p := Point{500, 500}
+	if obj := rt.NearestNeighbor(p, WithFilter(even)); obj == nil || !even(obj) {
+		t.Errorf("expected an even object, got %v", obj)
+	}
+
+	n := 0
+	for obj := range rt.NearestWithin(p, 300, WithFilter(even), WithLimit(5)) {
+		if !even(obj) {
+			t.Errorf("unexpected object %v", obj)
+		}
+		n++
+	}
+
+	if n != 5 {
+		t.Errorf("expected 5 objects, got %d", n)
+	}
+}
+
+func TestFilterMatchesFirst(t *testing.T) {
+	r := rand.New(rand.NewSource(7))
+	rt, _ := randomTree(r, 500)
+	bb := rect(Point{100, 100}, Point{900, 900})
+	p := Point{500, 500}
+
+	// the filter only sees the objects matching the search.
+	covers := func(obj Rectangle) bool {
+		return containsPoint(&rectangle{obj.LowerLeft(), obj.UpperRight()}, &p)
+	}
+
+	var seen []Rectangle
+	record := WithFilter(func(obj Rectangle) bool {
+		seen = append(seen, obj)
+		return true
+	})
+
+	checks := []struct {
+		name    string
+		search  func()
+		matches func(Rectangle) bool
+	}{
+		{"SearchIntersect", func() { rt.SearchIntersect(bb, record) }, func(obj Rectangle) bool { return intersect(bb, obj) }},
+		{"SearchContained", func() { rt.SearchContained(bb, record) }, func(obj Rectangle) bool { return bb.contains(obj) }},
+		{"SearchCovering", func() { rt.SearchCovering(rect(p, p), record) }, covers},
+		{"SearchPoint", func() { rt.SearchPoint(p, record) }, covers},
+		{"SearchIntersectSorted", func() { rt.SearchIntersectSorted(bb, p, record) }, func(obj Rectangle) bool { return intersect(bb, obj) }},
+	}
+
+	for _, c := range checks {
+		seen = nil
+		c.search()
+		for _, obj := range seen {
+			if !c.matches(obj) {
+				t.Errorf("%s: expected the filter to see matching objects only, got %v", c.name, obj)
+				break
+			}
+		}
+	}
+}
+
+func TestSearchTraversal(t *testing.T) {
+	r := rand.New(rand.NewSource(5))
+	rt, _ := randomTree(r, 2000)
+	bb := rect(Point{100, 300}, Point{600, 500})
+
+	// objects come in the order of the leaves, as a recursive traversal finds them.
+	var expected []Rectangle
+	var walk func(n *node)
+	walk = func(n *node) {
+		for _, e := range n.getEntries() {
+			if !intersect(e.getMBR(), bb) {
+				continue
+			}
+
+			if n.leaf {
+				expected = append(expected, e.obj)
+			} else {
+				walk(e.node)
+			}
+		}
+	}
+	walk(rt.root)
+
+	found := rt.SearchIntersect(bb)
+	if len(found) != len(expected) {
+		t.Fatalf("expected %d objects, got %d", len(expected), len(found))
+	}
+
+	for i := range found {
+		if found[i] != expected[i] {
+			t.Fatalf("expected %v at %d, got %v", expected[i], i, found[i])
+		}
+	}
+
+	// traversal stacks are reused, only the query is allocated.
+	n := 0
+	count := func(Rectangle) bool {
+		n++
+		return true
+	}
+	if allocs := testing.AllocsPerRun(100, func() { rt.SearchIntersectFunc(bb, count) }); allocs > 1 && !raceEnabled {
+		t.Errorf("expected searches not to allocate, got %v allocations", allocs)
+	}
+}
@@ -0,0 +1,66 @@
+package hrtree
+
+import (
+	"math/bits"
+)
+
+// Offset maps signed coordinates to the unsigned ones used by the tree by subtracting an
+// origin, the lowest value of each dimension. Unlike RectOf[int64], which offsets values
+// by 2^63 and needs a 64-bit resolution, spaces much smaller than the whole int64 range
+// keep a small resolution.
+type Offset PointOf[int64]
+
+// Point maps p, clamping the coordinates below the origin to it.
+func (o Offset) Point(p PointOf[int64]) Point {
+	var q Point
+	for i, v := range p {
+		if v > o[i] {
+			q[i] = uint64(v) - uint64(o[i])
+		}
+	}
+
+	return q
+}
+
+// Signed is the inverse of Point.
+func (o Offset) Signed(q Point) PointOf[int64] {
+	var p PointOf[int64]
+	for i, v := range q {
+		p[i] = int64(v + uint64(o[i]))
+	}
+
+	return p
+}
+
+// Resolution returns the number of bits per dimension of trees storing points of o up to
+// max.
+func (o Offset) Resolution(max PointOf[int64]) int {
+	res := 1
+	for _, v := range o.Point(max) {
+		if n := bits.Len64(v); n > res {
+			res = n
+		}
+	}
+
+	return res
+}
+
+// Rect returns the rectangle of o with the given corners.
+func (o Offset) Rect(min, max PointOf[int64]) *SignedRect {
+	return &SignedRect{min, max, o.Point(min), o.Point(max)}
+}
+
+// SignedRect is a rectangle with signed coordinates, created with Offset.Rect. It
+// implements Rectangle through its offset corners.
+type SignedRect struct {
+	Min, Max PointOf[int64]
+	ll, ur   Point
+}
+
+func (r *SignedRect) LowerLeft() Point {
+	return r.ll
+}
+
+func (r *SignedRect) UpperRight() Point {
+	return r.ur
+}
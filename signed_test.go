@@ -0,0 +1,40 @@
+package hrtree
+
+import (
+	"testing"
+)
+
+func TestOffset(t *testing.T) {
+	o := Offset{-1000, -500}
+
+	if res := o.Resolution(PointOf[int64]{1000, 500}); res != 11 {
+		t.Errorf("expected a resolution of 11 bits, got %d", res)
+	}
+
+	for _, p := range []PointOf[int64]{{-1000, -500}, {0, 0}, {999, -1}} {
+		if q := o.Signed(o.Point(p)); q != p {
+			t.Errorf("expected %v, got %v", p, q)
+		}
+	}
+
+	if q := o.Point(PointOf[int64]{-2000, 0}); q != (Point{0, 500}) {
+		t.Errorf("expected coordinates clamped to the origin, got %v", q)
+	}
+
+	// a window across the axes of screen space.
+	rt, _ := NewTree(2, 4, o.Resolution(PointOf[int64]{1000, 500}))
+	for x := int64(-900); x < 900; x += 100 {
+		rt.Insert(o.Rect(PointOf[int64]{x, -x / 2}, PointOf[int64]{x + 10, -x/2 + 10}))
+	}
+
+	found := rt.SearchIntersect(o.Rect(PointOf[int64]{-150, -100}, PointOf[int64]{150, 100}))
+	if len(found) != 3 {
+		t.Fatalf("expected 3 objects, got %d", len(found))
+	}
+
+	for _, obj := range found {
+		if x := obj.(*SignedRect).Min[0]; x < -150 || x > 150 {
+			t.Errorf("unexpected object at %d", x)
+		}
+	}
+}
@@ -0,0 +1,49 @@
+package hrtree
+
+// Stats describes the contents and shape of a tree.
+type Stats struct {
+	Objects    int // live objects, as returned by Size
+	Tombstones int // objects removed by lazy deletions, kept until Vacuum
+	Height     int // number of levels, 1 for a single leaf
+	Nodes      int
+	Leaves     int
+}
+
+// Dead returns the fraction of the entries in leaves that are tombstones, a hint of when
+// to run Vacuum.
+func (s Stats) Dead() float64 {
+	if s.Tombstones == 0 {
+		return 0
+	}
+
+	return float64(s.Tombstones) / float64(s.Objects+s.Tombstones)
+}
+
+// Stats returns statistics of the tree.
+func (tree *HRtree) Stats() Stats {
+	if tree.rlockTree() {
+		defer tree.runlock()
+	}
+
+	s := Stats{Objects: tree.size, Tombstones: tree.tombstones}
+	s.count(tree.root, 1)
+
+	return s
+}
+
+// count adds the nodes under n, at the given depth, to s.
+func (s *Stats) count(n *node, depth int) {
+	s.Nodes++
+	if depth > s.Height {
+		s.Height = depth
+	}
+
+	if n.leaf {
+		s.Leaves++
+		return
+	}
+
+	for _, e := range n.getEntries() {
+		s.count(e.node, depth+1)
+	}
+}
@@ -0,0 +1,53 @@
+package hrtree
+
+import (
+	"testing"
+)
+
+func TestStats(t *testing.T) {
+	rt, _ := NewTree(2, 4, 12, WithLazyDelete())
+	if s := rt.Stats(); s != (Stats{Height: 1, Nodes: 1, Leaves: 1}) {
+		t.Errorf("unexpected stats of an empty tree %+v", s)
+	}
+
+	var things []Rectangle
+	for i := 0; i < 100; i++ {
+		r := rect(Point{uint64(i), uint64(i)}, Point{uint64(i + 1), uint64(i + 1)})
+		things = append(things, r)
+		rt.Insert(r)
+	}
+
+	for _, r := range things[:25] {
+		rt.Delete(r)
+	}
+
+	s := rt.Stats()
+	if s.Objects != 75 || s.Tombstones != 25 || s.Dead() != 0.25 {
+		t.Errorf("unexpected counts %+v", s)
+	}
+
+	if s.Height < 3 || s.Leaves < 25 || s.Nodes <= s.Leaves {
+		t.Errorf("unexpected shape %+v", s)
+	}
+
+	// every search path skips the removed objects.
+	bb := rect(Point{0, 0}, Point{200, 200})
+	if n := len(rt.SearchIntersect(bb)); n != 75 {
+		t.Errorf("expected 75 objects, got %d", n)
+	}
+
+	if n := len(rt.SearchIntersect(bb, WithMask(0))); n != 75 {
+		t.Errorf("expected 75 objects, got %d", n)
+	}
+
+	r := rt.SearchIntersectPooled(bb)
+	if n := len(r.Objects); n != 75 {
+		t.Errorf("expected 75 objects, got %d", n)
+	}
+	r.Release()
+
+	rt.Vacuum()
+	if s := rt.Stats(); s.Objects != 75 || s.Tombstones != 0 || s.Dead() != 0 {
+		t.Errorf("unexpected counts after Vacuum %+v", s)
+	}
+}
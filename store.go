@@ -0,0 +1,309 @@
+package hrtree
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"slices"
+)
+
+var ErrNoPage = errors.New("Page was never written.")
+
+// NodeStore holds the nodes of a tree as the fixed-size pages of WritePages, page 0 being
+// the metadata. Stores need not be safe for concurrent use, trees serialize their calls.
+type NodeStore interface {
+	// PageSize returns the size of the pages of the store.
+	PageSize() int
+	// Pages returns the number of pages of the store, those past the last one written
+	// being unused.
+	Pages() uint64
+	// ReadPage reads page id into page, failing with ErrNoPage if it was never written.
+	ReadPage(id uint64, page []byte) error
+	// WritePage writes page as page id.
+	WritePage(id uint64, page []byte) error
+	// Sync writes the pages to stable storage.
+	Sync() error
+}
+
+// WithNodeStore makes the tree write its nodes to s, page by page: every mutation writes
+// the nodes it changed, and releases the pages of the nodes it removed for later ones.
+// NewTree overwrites any tree of s, use OpenTree to load it instead. The pages only
+// record the bounds, layers and attributes of objects, such as those of WritePages, which
+// are read back as plain rectangles. Write errors are reported by Sync.
+func WithNodeStore(s NodeStore) Option {
+	return func(tree *HRtree) {
+		tree.store = &nodeStore{NodeStore: s, page: make([]byte, s.PageSize()), next: rootPage}
+	}
+}
+
+// OpenTree loads the tree of s, written by a tree created with WithNodeStore(s), and
+// keeps writing its changes to s. opts are those of ReadPages.
+func OpenTree(s NodeStore, opts ...Option) (*HRtree, error) {
+	tree, err := readPages(storeReader{s}, opts, true)
+	if err != nil {
+		return nil, fmt.Errorf("OpenTree: %w", err)
+	}
+
+	// the store is only set once the tree is read, so that it isn't written meanwhile.
+	WithNodeStore(s)(tree)
+	st := tree.store
+	st.root = tree.root.page
+	st.next = max(s.Pages(), rootPage)
+
+	// pages left unreachable by a crash are free.
+	used := make([]bool, s.Pages())
+	for q := []*node{tree.root}; len(q) > 0; q = q[1:] {
+		used[q[0].page] = true
+		if !q[0].leaf {
+			for _, e := range q[0].getEntries() {
+				q = append(q, e.node)
+			}
+		}
+	}
+
+	for id := uint64(rootPage); id < uint64(len(used)); id++ {
+		if !used[id] {
+			st.free = append(st.free, id)
+		}
+	}
+
+	return tree, nil
+}
+
+// Sync writes the changes of the tree to stable storage through the store of
+// WithNodeStore, returning the first error met while writing them, if any. It is a no-op
+// for trees created without WithNodeStore.
+func (tree *HRtree) Sync() error {
+	st := tree.store
+	if st == nil {
+		return nil
+	}
+
+	tree.mu.Lock()
+	defer tree.mu.Unlock()
+
+	if st.err != nil {
+		return fmt.Errorf("Sync: %w", st.err)
+	}
+
+	if err := st.Sync(); err != nil {
+		return fmt.Errorf("Sync: %w", err)
+	}
+
+	return nil
+}
+
+// nodeStore tracks the pages of a tree in its NodeStore.
+type nodeStore struct {
+	NodeStore
+	page []byte   // buffer of the page being written
+	root uint64   // page of the root written last
+	free []uint64 // released pages
+	next uint64   // first page never used
+	err  error    // first write error
+}
+
+// allocate returns a page for a new node.
+func (st *nodeStore) allocate() uint64 {
+	if n := len(st.free); n > 0 {
+		id := st.free[n-1]
+		st.free = st.free[:n-1]
+		return id
+	}
+
+	st.next++
+
+	return st.next - 1
+}
+
+// flush writes the nodes changed since the last flush, then the metadata. Pages of the
+// nodes dropped from the tree are released, they can only be referenced by the previous
+// pages of changed nodes.
+func (tree *HRtree) flush() {
+	st := tree.store
+	if tree.root.stored || st.err != nil {
+		return
+	}
+
+	var dirty []*node
+	for q := []*node{tree.root}; len(q) > 0; q = q[1:] {
+		n := q[0]
+		dirty = append(dirty, n)
+		if n.leaf {
+			continue
+		}
+
+		for _, e := range n.getEntries() {
+			if !e.node.stored {
+				q = append(q, e.node)
+			}
+		}
+	}
+
+	// pages referenced before, and those still referenced.
+	var old []uint64
+	kept := map[uint64]bool{tree.root.page: true}
+	if st.root != 0 && st.root != tree.root.page {
+		old = append(old, st.root)
+	}
+
+	for _, n := range dirty {
+		if n.page != 0 && !n.leaf {
+			children, err := st.children(n.page)
+			if err != nil {
+				st.err = err
+				return
+			}
+			old = append(old, children...)
+		}
+
+		if !n.leaf {
+			for _, e := range n.getEntries() {
+				kept[e.node.page] = true
+			}
+		}
+	}
+
+	// released pages take their unreferenced children with them.
+	for len(old) > 0 {
+		id := old[len(old)-1]
+		old = old[:len(old)-1]
+		if kept[id] || id == 0 {
+			continue
+		}
+		kept[id] = true
+
+		children, err := st.children(id)
+		if err != nil {
+			st.err = err
+			return
+		}
+		old = append(old, children...)
+		st.free = append(st.free, id)
+	}
+	slices.Sort(st.free)
+	slices.Reverse(st.free)
+
+	// children are allocated before their parents reference them.
+	for _, n := range dirty {
+		if n.page == 0 {
+			n.page = st.allocate()
+		}
+	}
+
+	for _, n := range dirty {
+		if err := encodeNode(n, func(c *node) uint64 { return c.page }, st.page); err != nil {
+			st.err = &PageError{n.page, err}
+			return
+		}
+
+		if err := st.WritePage(n.page, st.page); err != nil {
+			st.err = &PageError{n.page, err}
+			return
+		}
+	}
+
+	last := st.Pages()
+	if st.next > last {
+		last = st.next
+	}
+	tree.encodeMeta(st.page, tree.root.page, last-1)
+	if err := st.WritePage(0, st.page); err != nil {
+		st.err = &PageError{0, err}
+		return
+	}
+
+	for _, n := range dirty {
+		n.stored = true
+	}
+	st.root = tree.root.page
+}
+
+// children returns the child pages recorded in page id, none for leaves.
+func (st *nodeStore) children(id uint64) ([]uint64, error) {
+	if err := st.ReadPage(id, st.page); err != nil {
+		return nil, &PageError{id, err}
+	}
+
+	if st.page[0]&pageFlagLeaf != 0 {
+		return nil, nil
+	}
+
+	count := int(binary.LittleEndian.Uint16(st.page[2:]))
+	children := make([]uint64, 0, count)
+	buf := st.page[pageHeaderSize:]
+	for i := 0; i < count && len(buf) >= pageEntrySize; i, buf = i+1, buf[pageEntrySize:] {
+		children = append(children, binary.LittleEndian.Uint64(buf[pageRefOffset:]))
+	}
+
+	return children, nil
+}
+
+// MemoryStore is a NodeStore keeping its pages in memory.
+type MemoryStore struct {
+	pageSize int
+	pages    [][]byte
+}
+
+// NewMemoryStore returns an empty store of pageSize-long pages.
+func NewMemoryStore(pageSize int) *MemoryStore {
+	return &MemoryStore{pageSize: pageSize}
+}
+
+func (s *MemoryStore) PageSize() int {
+	return s.pageSize
+}
+
+func (s *MemoryStore) Pages() uint64 {
+	return uint64(len(s.pages))
+}
+
+func (s *MemoryStore) ReadPage(id uint64, page []byte) error {
+	if id >= uint64(len(s.pages)) || s.pages[id] == nil {
+		return ErrNoPage
+	}
+
+	copy(page, s.pages[id])
+	return nil
+}
+
+func (s *MemoryStore) WritePage(id uint64, page []byte) error {
+	for uint64(len(s.pages)) <= id {
+		s.pages = append(s.pages, nil)
+	}
+
+	if s.pages[id] == nil {
+		s.pages[id] = make([]byte, s.pageSize)
+	}
+	copy(s.pages[id], page)
+
+	return nil
+}
+
+func (s *MemoryStore) Sync() error {
+	return nil
+}
+
+// storeReader reads the pages of a store as a paged file.
+type storeReader struct {
+	s NodeStore
+}
+
+func (r storeReader) ReadAt(p []byte, off int64) (int, error) {
+	size := int64(r.s.PageSize())
+	id := off / size
+	if off%size+int64(len(p)) > size {
+		return 0, ErrInvalidPage
+	}
+
+	if int64(len(p)) == size {
+		return len(p), r.s.ReadPage(uint64(id), p)
+	}
+
+	page := make([]byte, size)
+	if err := r.s.ReadPage(uint64(id), page); err != nil {
+		return 0, err
+	}
+
+	return copy(p, page[off%size:]), nil
+}
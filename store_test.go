@@ -0,0 +1,212 @@
+package hrtree
+
+import (
+	"errors"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// checkStored compares the tree read back from s with rt.
+func checkStored(t *testing.T, s NodeStore, rt *HRtree) *HRtree {
+	t.Helper()
+	loaded, err := OpenTree(s)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if a, b := loaded.Stats(), rt.Stats(); a != b {
+		t.Errorf("expected %+v, got %+v", b, a)
+	}
+
+	bb := rect(Point{0, 0}, Point{4095, 4095})
+	want, got := rt.SearchIntersect(bb), loaded.SearchIntersect(bb)
+	if len(got) != len(want) {
+		t.Fatalf("expected %d objects, got %d", len(want), len(got))
+	}
+
+	for i := range want {
+		if got[i].LowerLeft() != want[i].LowerLeft() || got[i].UpperRight() != want[i].UpperRight() {
+			t.Fatalf("expected %v at %d, got %v", want[i], i, got[i])
+		}
+	}
+
+	return loaded
+}
+
+func TestNodeStore(t *testing.T) {
+	s := NewMemoryStore(MinPageSize)
+	rt, err := NewTree(2, 4, 12, WithNodeStore(s))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	r := rand.New(rand.NewSource(1))
+	var objs []*rectangle
+	for round := 0; round < 5; round++ {
+		for i := 0; i < 300; i++ {
+			x, y := uint64(3*(round*300+i)%4000), uint64(r.Intn(4000))
+			obj := rect(Point{x, y}, Point{x + 2, y + uint64(r.Intn(50))})
+			rt.Insert(obj)
+			objs = append(objs, obj)
+		}
+
+		for i := 0; i < 200; i++ {
+			j := r.Intn(len(objs))
+			rt.Delete(objs[j])
+			objs = append(objs[:j], objs[j+1:]...)
+		}
+
+		checkStored(t, s, rt)
+	}
+
+	// pages of removed nodes are reused.
+	nodes := rt.Stats().Nodes
+	if pages := int(s.Pages()) - 1; pages > nodes+nodes/4 {
+		t.Errorf("expected about %d pages, got %d", nodes, pages)
+	}
+
+	rt.Clear()
+	rt.InsertAll([]Rectangle{rect(Point{1, 1}, Point{2, 2})})
+	checkStored(t, s, rt)
+
+	if err := rt.Sync(); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	if _, err := NewTree(2, 100, 12, WithNodeStore(NewMemoryStore(MinPageSize))); !errors.Is(err, ErrPageTooSmall) {
+		t.Errorf("expected %v, got %v", ErrPageTooSmall, err)
+	}
+}
+
+func TestPageFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "tree.pages")
+	pf, err := OpenPageFile(path, MinPageSize, 8)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	rt, _ := NewTree(2, 5, 12, WithNodeStore(pf))
+	for i := 0; i < 2000; i++ {
+		rt.Insert(rect(Point{uint64(3 * (i % 1000)), uint64(i / 2)}, Point{uint64(3*(i%1000) + 1), uint64(i/2 + 3)}))
+	}
+
+	// the tree is read back through the pool, evicting the pages written.
+	checkStored(t, pf, rt)
+	if s := pf.Stats(); s.Evictions == 0 || s.Writes == 0 || s.Misses == 0 {
+		t.Errorf("expected the pool to overflow, got %+v", s)
+	}
+
+	if err := rt.Sync(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if err := pf.Close(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	pf, err = OpenPageFile(path, PageSize4K, 8)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer pf.Close()
+
+	if pf.PageSize() != MinPageSize {
+		t.Errorf("expected the page size of the file, got %d", pf.PageSize())
+	}
+
+	loaded := checkStored(t, pf, rt)
+	loaded.Delete(rect(Point{0, 0}, Point{1, 3}))
+	rt.Delete(rect(Point{0, 0}, Point{1, 3}))
+	checkStored(t, pf, rt)
+
+	// the file stays readable as a paged file.
+	mt, err := OpenMapped(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	mt.Close()
+}
+
+func TestMappedFile(t *testing.T) {
+	dir := t.TempDir()
+	path, log := filepath.Join(dir, "tree.pages"), filepath.Join(dir, "tree.wal")
+	mf, err := OpenMappedFile(path, MinPageSize)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// the mapping grows from a few pages as the tree does.
+	rt, _ := NewTree(2, 5, 12)
+	stored, _ := NewTree(2, 5, 12, WithNodeStore(mf))
+	for i := 0; i < 2000; i++ {
+		obj := rect(Point{uint64(3 * (i % 1000)), uint64(i / 2)}, Point{uint64(3*(i%1000) + 1), uint64(i/2 + 3)})
+		rt.Insert(obj)
+		stored.Insert(obj)
+	}
+	checkStored(t, mf, rt)
+
+	if err := stored.Sync(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if err := mf.Close(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if fi, _ := os.Stat(path); fi.Size() != int64(mf.Pages())*MinPageSize {
+		t.Errorf("expected the file to be trimmed to %d pages, got %d bytes", mf.Pages(), fi.Size())
+	}
+
+	// a crash before Close leaves the pages the mapping grew by.
+	pages := mf.Pages()
+	os.Truncate(path, int64(pages+8)*MinPageSize)
+
+	// the file is modified in place through a log, then read back.
+	if mf, err = OpenMappedFile(path, PageSize4K); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if mf.Pages() != pages {
+		t.Errorf("expected the %d pages written, got %d", pages, mf.Pages())
+	}
+
+	w, err := OpenWAL(mf, log)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	loaded, _, err := w.Recover()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	for i := 0; i < 500; i++ {
+		obj := rect(Point{uint64(3 * i), uint64(i / 2)}, Point{uint64(3*i + 1), uint64(i/2 + 3)})
+		loaded.Delete(obj)
+	}
+	loaded.Sync()
+	w.Close()
+	mf.Close()
+
+	if mf, err = OpenMappedFile(path, PageSize4K); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer mf.Close()
+
+	if mf.PageSize() != MinPageSize {
+		t.Errorf("expected the page size of the file, got %d", mf.PageSize())
+	}
+	checkStored(t, mf, loaded)
+
+	mt, err := OpenMapped(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer mt.Close()
+
+	if mt.Size() != loaded.Size() {
+		t.Errorf("expected %d objects, got %d", loaded.Size(), mt.Size())
+	}
+}
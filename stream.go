@@ -0,0 +1,204 @@
+package hrtree
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"hash"
+	"hash/crc32"
+	"io"
+)
+
+// TreeDecoder reads the objects of a tree file written by WriteTo one by one, in hilbert
+// order, so that files larger than memory can be scanned or filtered without loading
+// them. ReadTreeFrom builds trees from it leaf by leaf.
+type TreeDecoder struct {
+	br             *bufio.Reader
+	tr             io.Reader // br, through the checksum
+	hash           hash.Hash32
+	min, max, bits int
+	count, read    uint64
+	last           hkey // hilbert value of the last record
+	err            error
+}
+
+// NewTreeDecoder reads the header of the tree file of r.
+func NewTreeDecoder(r io.Reader) (*TreeDecoder, error) {
+	d := &TreeDecoder{br: bufio.NewReader(r), hash: crc32.New(castagnoli)}
+	d.tr = io.TeeReader(d.br, d.hash)
+
+	var header [treeFileHeaderSize]byte
+	if _, err := io.ReadFull(d.tr, header[:]); err != nil {
+		return nil, fmt.Errorf("header: %w", unexpected(err))
+	}
+
+	if string(header[:4]) != treeFileMagic {
+		return nil, ErrInvalidTreeFile
+	}
+
+	if dims := binary.LittleEndian.Uint32(header[8:]); dims != Dim {
+		return nil, fmt.Errorf("%d dimensions, %d built in: %w", dims, Dim, ErrDimensionMismatch)
+	}
+
+	if v := binary.LittleEndian.Uint32(header[4:]); v != treeFileVersion {
+		return nil, fmt.Errorf("version %d: %w", v, ErrUnknownVersion)
+	}
+
+	d.min = int(binary.LittleEndian.Uint32(header[12:]))
+	d.max = int(binary.LittleEndian.Uint32(header[16:]))
+	d.bits = int(binary.LittleEndian.Uint32(header[20:]))
+	d.count = binary.LittleEndian.Uint64(header[24:])
+
+	return d, nil
+}
+
+// Len returns the number of objects of the file.
+func (d *TreeDecoder) Len() uint64 {
+	return d.count
+}
+
+// Next returns the next object of the file, as a plain rectangle carrying the stored
+// bounds, layer and attributes. It returns io.EOF after the last one, once the checksum
+// of the file is verified.
+func (d *TreeDecoder) Next() (Rectangle, error) {
+	e, err := d.next()
+	if err != nil {
+		return nil, err
+	}
+
+	return e.obj, nil
+}
+
+// next reads the entry of the next record.
+func (d *TreeDecoder) next() (entry, error) {
+	if d.err != nil {
+		return entry{}, d.err
+	}
+
+	if d.read == d.count {
+		d.err = d.checksum()
+		if d.err == nil {
+			d.err = io.EOF
+		}
+		return entry{}, d.err
+	}
+
+	var rec [treeRecordSize]byte
+	if _, err := io.ReadFull(d.tr, rec[:]); err != nil {
+		d.err = fmt.Errorf("record %d: %w", d.read, unexpected(err))
+		return entry{}, d.err
+	}
+
+	var bb rectangle
+	for j := 0; j < Dim; j++ {
+		bb.lowerLeft[j] = binary.LittleEndian.Uint64(rec[8*j:])
+		bb.upperRight[j] = binary.LittleEndian.Uint64(rec[8*(Dim+j):])
+	}
+
+	obj := bb
+	e := entry{
+		bb:    &bb,
+		obj:   &obj,
+		h:     keyFromBytes(rec[treeRecordKeyOffset:treeRecordLayer]),
+		leaf:  true,
+		layer: rec[treeRecordLayer],
+		attrs: binary.LittleEndian.Uint64(rec[treeRecordAttrOffset:]),
+	}
+
+	if d.read > 0 && d.last.cmp(e.h) > 0 {
+		d.err = fmt.Errorf("record %d: %w", d.read, ErrNotSorted)
+		return entry{}, d.err
+	}
+	d.last = e.h
+	d.read++
+
+	return e, nil
+}
+
+func (d *TreeDecoder) checksum() error {
+	var sum [4]byte
+	want := d.hash.Sum32()
+	if _, err := io.ReadFull(d.br, sum[:]); err != nil {
+		return fmt.Errorf("checksum: %w", unexpected(err))
+	}
+
+	if binary.LittleEndian.Uint32(sum[:]) != want {
+		return fmt.Errorf("checksum: %w", ErrInvalidTreeFile)
+	}
+
+	return nil
+}
+
+// streamPacker builds the nodes of pack from sorted entries given one by one, so that they
+// don't have to be held all at once: each node is built as soon as its last entry comes,
+// then given to the level above.
+type streamPacker struct {
+	tree   *HRtree
+	levels []streamLevel // from the leaves up
+	root   *node
+	p      *reporter
+}
+
+// streamLevel is a level of nodes being built by a streamPacker.
+type streamLevel struct {
+	total, nodes int // entries of the level, and nodes holding them as split by packLevel
+	added        int
+	node         int // index of the node being filled
+	entries      []entry
+}
+
+func newStreamPacker(tree *HRtree, count int) *streamPacker {
+	sp := &streamPacker{tree: tree}
+
+	nodes := 0
+	for total := count; total > 0; {
+		n := (total + tree.max - 1) / tree.max
+		sp.levels = append(sp.levels, streamLevel{total: total, nodes: n})
+		nodes += n
+		if n == 1 {
+			break
+		}
+		total = n
+	}
+	sp.p = tree.report(PhasePack, nodes)
+
+	return sp
+}
+
+// add adds e to the level, building a node if it completes one.
+func (sp *streamPacker) add(level int, e entry) {
+	l := &sp.levels[level]
+	end := (l.node + 1) * l.total / l.nodes
+	if l.entries == nil {
+		l.entries = make([]entry, 0, end-l.node*l.total/l.nodes)
+	}
+
+	l.entries = append(l.entries, e)
+	l.added++
+	if l.added < end {
+		return
+	}
+
+	n := sp.tree.packNode(l.entries, level == 0)
+	l.entries = nil
+	l.node++
+	sp.p.step()
+
+	if level+1 < len(sp.levels) {
+		sp.add(level+1, entry{node: n})
+	} else {
+		sp.root = n
+	}
+}
+
+// finish installs the nodes built as the tree.
+func (sp *streamPacker) finish(count int) {
+	tree := sp.tree
+	tree.size, tree.tombstones, tree.deferred = count, 0, 0
+	tree.root = sp.root
+	if tree.root == nil {
+		tree.root = tree.allocNode()
+		tree.root.leaf = true
+	}
+	sp.p.finish()
+}
@@ -0,0 +1,111 @@
+package hrtree
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"testing"
+)
+
+func TestTreeDecoder(t *testing.T) {
+	rt, _ := NewTree(2, 4, 12)
+	for i := 0; i < 100; i++ {
+		rt.Insert(rect(Point{uint64(3 * i), uint64(i % 9)}, Point{uint64(3*i + 1), uint64(i%9 + 1)}))
+	}
+
+	var buf bytes.Buffer
+	rt.WriteTo(&buf)
+
+	d, err := NewTreeDecoder(bytes.NewReader(buf.Bytes()))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if d.Len() != 100 {
+		t.Errorf("expected 100 objects, got %d", d.Len())
+	}
+
+	var objs []Rectangle
+	for {
+		obj, err := d.Next()
+		if err == io.EOF {
+			break
+		}
+
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		objs = append(objs, obj)
+	}
+
+	// objects come in the order of the leaves.
+	want := rt.SearchIntersect(rect(Point{0, 0}, Point{4095, 4095}))
+	if len(objs) != len(want) {
+		t.Fatalf("expected %d objects, got %d", len(want), len(objs))
+	}
+
+	for i := range want {
+		if objs[i].LowerLeft() != want[i].LowerLeft() || objs[i].UpperRight() != want[i].UpperRight() {
+			t.Errorf("expected %v at %d, got %v", want[i], i, objs[i])
+		}
+	}
+
+	// the checksum is only known at the end.
+	data := bytes.Clone(buf.Bytes())
+	data[len(data)-1] ^= 0xff
+	d, _ = NewTreeDecoder(bytes.NewReader(data))
+	for err = nil; err == nil; _, err = d.Next() {
+	}
+
+	if !errors.Is(err, ErrInvalidTreeFile) {
+		t.Errorf("expected %v, got %v", ErrInvalidTreeFile, err)
+	}
+}
+
+func TestReadTreeFromStreamed(t *testing.T) {
+	for _, n := range []int{0, 1, 4, 5, 17, 64, 65, 1000} {
+		rt, _ := NewTree(2, 4, 12)
+		for i := 0; i < n; i++ {
+			rt.Insert(rect(Point{uint64(3 * i), 0}, Point{uint64(3*i + 1), 1}))
+		}
+
+		var buf bytes.Buffer
+		rt.WriteTo(&buf)
+		loaded, err := ReadTreeFrom(&buf)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		// nodes are split as by a bulk load of the same objects.
+		rt.Compact()
+		if a, b := loaded.Stats(), rt.Stats(); a != b {
+			t.Errorf("%d objects: expected %+v, got %+v", n, b, a)
+		}
+		checkLinks(t, loaded.root)
+	}
+}
+
+// checkLinks checks the parent and sibling links of every node under n, siblings being
+// linked within their parent.
+func checkLinks(t *testing.T, n *node) {
+	t.Helper()
+	if n.leaf {
+		return
+	}
+
+	entries := n.getEntries()
+	for i, e := range entries {
+		var left, right *node
+		if i > 0 {
+			left = entries[i-1].node
+		}
+		if i+1 < len(entries) {
+			right = entries[i+1].node
+		}
+
+		if c := e.node; c.parent != n || c.left != left || c.right != right {
+			t.Fatalf("unexpected parent or siblings of child %d of %v", i, n)
+		}
+		checkLinks(t, e.node)
+	}
+}
@@ -0,0 +1,63 @@
+package hrtree
+
+import (
+	"encoding/json"
+)
+
+// jsonStructure is the document produced by MarshalStructureJSON.
+type jsonStructure struct {
+	Size   int      `json:"size"`
+	Height int      `json:"height"`
+	Root   jsonNode `json:"root"`
+}
+
+type jsonNode struct {
+	Level    int        `json:"level"` // 0 for leaves
+	MBR      *jsonRect  `json:"mbr"`   // null for an empty tree
+	LHV      string     `json:"lhv"`   // decimal, as it may not fit in a JSON number
+	Entries  int        `json:"entries"`
+	Objects  int        `json:"objects"`
+	Children []jsonNode `json:"children,omitempty"`
+}
+
+type jsonRect struct {
+	LowerLeft  Point `json:"lowerLeft"`
+	UpperRight Point `json:"upperRight"`
+}
+
+// MarshalStructureJSON returns the structure of the tree as nested JSON nodes, with their
+// level, bounding-box, LHV and number of entries and objects, leaving the objects out.
+// It is meant for debugging and visualization frontends.
+func (tree *HRtree) MarshalStructureJSON() ([]byte, error) {
+	if tree.rlockTree() {
+		defer tree.runlock()
+	}
+
+	root := structureOf(tree.Root())
+	return json.Marshal(jsonStructure{tree.size, root.Level + 1, root})
+}
+
+func structureOf(v NodeView) jsonNode {
+	jn := jsonNode{LHV: v.LHV().String(), Entries: v.Len()}
+	if bb := v.MBR(); bb != nil {
+		jn.MBR = &jsonRect{bb.LowerLeft(), bb.UpperRight()}
+	}
+
+	if v.IsLeaf() {
+		for _, e := range v.Entries() {
+			if !e.Removed {
+				jn.Objects++
+			}
+		}
+		return jn
+	}
+
+	for _, c := range v.Children() {
+		jc := structureOf(c)
+		jn.Level = jc.Level + 1
+		jn.Objects += jc.Objects
+		jn.Children = append(jn.Children, jc)
+	}
+
+	return jn
+}
@@ -0,0 +1,65 @@
+package hrtree
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestMarshalStructureJSON(t *testing.T) {
+	rt, _ := NewTree(2, 4, 12)
+	for i := 0; i < 50; i++ {
+		rt.Insert(rect(Point{uint64(i), uint64(i)}, Point{uint64(i + 1), uint64(i + 1)}))
+	}
+
+	data, err := rt.MarshalStructureJSON()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	var doc jsonStructure
+	if err := json.Unmarshal(data, &doc); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if doc.Size != 50 || doc.Root.Objects != 50 {
+		t.Errorf("expected 50 objects, got %d in a tree of size %d", doc.Root.Objects, doc.Size)
+	}
+
+	if doc.Root.MBR.LowerLeft != (Point{0, 0}) || doc.Root.MBR.UpperRight != (Point{50, 50}) {
+		t.Errorf("unexpected root bounding-box %v", doc.Root.MBR)
+	}
+
+	var check func(n jsonNode, level int)
+	check = func(n jsonNode, level int) {
+		if n.Level != level {
+			t.Errorf("expected level %d, got %d", level, n.Level)
+		}
+
+		if level > 0 && len(n.Children) != n.Entries {
+			t.Errorf("expected %d children, got %d", n.Entries, len(n.Children))
+		}
+
+		for _, c := range n.Children {
+			check(c, level-1)
+		}
+	}
+	check(doc.Root, doc.Height-1)
+
+	if doc.Height < 2 {
+		t.Errorf("expected several levels, got %d", doc.Height)
+	}
+}
+
+func TestMarshalStructureJSONEmpty(t *testing.T) {
+	rt, _ := NewTree(2, 4, 12)
+
+	data, err := rt.MarshalStructureJSON()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	expected := `{"size":0,"height":1,"root":{"level":0,"mbr":null,"lhv":"0","entries":0,"objects":0}}`
+	if string(data) != expected {
+		t.Errorf("expected %s, got %s", expected, data)
+	}
+}
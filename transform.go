@@ -0,0 +1,91 @@
+package hrtree
+
+import (
+	"errors"
+	"math"
+)
+
+var ErrTransform = errors.New("Transform scales should be positive and finite, and bounds should have upper corners greater than lower ones.")
+
+// transform is an affine map of coordinates applied before encoding, see WithTransform.
+type transform struct {
+	offset Point
+	scale  PointF
+	max    *Point // upper corner of WithBounds, the scale being derived from it
+}
+
+// WithTransform maps the center of objects onto the grid of the curve before computing
+// their hilbert value, by subtracting offset and multiplying by scale on each dimension,
+// so that data in arbitrary ranges, such as UTM coordinates in the millions of meters,
+// spreads over the curve. Coordinates below offset are mapped to 0, and mapped ones are
+// capped by the resolution of the curve. Weights, if any, are applied afterwards.
+//
+// Searches are not affected. Trees read with ReadPages keep the stored hilbert values and
+// should be given the same transform.
+func WithTransform(offset Point, scale PointF) Option {
+	return func(tree *HRtree) {
+		tree.transform = &transform{offset: offset, scale: scale}
+	}
+}
+
+// WithBounds is like WithTransform, with the transform mapping the box from min to max
+// onto the whole grid of the resolution of the tree.
+func WithBounds(min, max Point) Option {
+	return func(tree *HRtree) {
+		tree.transform = &transform{offset: min, max: &max}
+	}
+}
+
+// checkTransform validates the transform of the tree, deriving its scale from its bounds
+// if set with WithBounds.
+func (tree *HRtree) checkTransform() error {
+	t := tree.transform
+	if t == nil {
+		return nil
+	}
+
+	if t.max != nil {
+		scaled := *t
+		for i := range scaled.scale {
+			if t.max[i] <= t.offset[i] {
+				return ErrTransform
+			}
+			scaled.scale[i] = float64(tree.limit()) / float64(t.max[i]-t.offset[i])
+		}
+		tree.transform = &scaled
+		t = &scaled
+	}
+
+	for _, s := range t.scale {
+		if !(s > 0) || math.IsInf(s, 1) {
+			return ErrTransform
+		}
+	}
+
+	return nil
+}
+
+// limit returns the largest coordinate of the resolution of the tree.
+func (tree *HRtree) limit() uint64 {
+	if tree.bits < 64 {
+		return 1<<uint(tree.bits) - 1
+	}
+
+	return math.MaxUint64
+}
+
+// apply maps the point p in place by t, capping coordinates at limit.
+func (t *transform) apply(p []uint64, limit uint64) {
+	for i := range p {
+		if p[i] <= t.offset[i] {
+			p[i] = 0
+			continue
+		}
+
+		if c := float64(p[i]-t.offset[i]) * t.scale[i]; c >= float64(limit) {
+			p[i] = limit
+		} else {
+			p[i] = uint64(c)
+		}
+	}
+}
@@ -0,0 +1,68 @@
+package hrtree
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestTransform(t *testing.T) {
+	invalid := []Option{
+		WithTransform(Point{}, PointF{1, 0}),
+		WithTransform(Point{}, PointF{-1, 1}),
+		WithBounds(Point{10, 10}, Point{20, 10}),
+	}
+
+	for i, opt := range invalid {
+		if _, err := NewTree(2, 4, 12, opt); !errors.Is(err, ErrTransform) {
+			t.Errorf("%d: expected ErrTransform, got %v", i, err)
+		}
+	}
+
+	// dimensions past the second are given a scale of 1 and bounds 1 apart.
+	scale, max := PointF{1, 0.5}, Point{600000, 5100000}
+	for i := 2; i < Dim; i++ {
+		scale[i], max[i] = 1, 1
+	}
+
+	rt, err := NewTree(2, 4, 12, WithTransform(Point{1000000, 5000000}, scale), WithWeights(weights(2, 1)...))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	obj := rect(Point{1000010, 5000100}, Point{1000012, 5000104})
+	if h := rt.key(obj); h.cmp(keyOf(rt.hf.Encode(coords(2*11, 51)...))) != 0 {
+		t.Errorf("expected the hilbert value of the mapped center, got %v", h)
+	}
+
+	// coordinates below the offset map to 0, those beyond the grid are capped.
+	obj = rect(Point{5, 9000000}, Point{5, 9000000})
+	if h := rt.key(obj); h.cmp(keyOf(rt.hf.Encode(coords(0, 1<<12-1)...))) != 0 {
+		t.Errorf("expected the hilbert value of the clamped center, got %v", h)
+	}
+
+	rt, err = NewTree(2, 4, 12, WithBounds(Point{500000, 5000000}, max))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	obj = rect(Point{550000, 5100000}, Point{550000, 5100000})
+	if h := rt.key(obj); h.cmp(keyOf(rt.hf.Encode(coords(1<<11-1, 1<<12-1)...))) != 0 {
+		t.Errorf("expected the bounds to span the grid, got %v", h)
+	}
+
+	for i := uint64(0); i < 300; i++ {
+		rt.Insert(rect(Point{500000 + i*331, 5000000 + i*97}, Point{500100 + i*331, 5000100 + i*97}))
+	}
+
+	if err := rt.CheckInvariants(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if q := rt.SearchIntersect(rect(Point{500000, 5000000}, Point{600000, 5100000})); len(q) != 300 {
+		t.Errorf("expected 300 results, got %d", len(q))
+	}
+
+	if snap := rt.Snapshot(); snap.transform != rt.transform {
+		t.Errorf("expected snapshots to keep the transform")
+	}
+}
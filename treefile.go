@@ -0,0 +1,160 @@
+package hrtree
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"math"
+)
+
+// Layout of the tree file format, see WriteTo. Integers are little-endian, except hilbert
+// values which are big-endian as in pages.
+const (
+	treeFileMagic      = "HRTF"
+	treeFileVersion    = 1
+	treeFileHeaderSize = 32 // magic, version, dimensions, min, max, resolution, count
+
+	treeRecordKeyOffset  = 2 * Dim * 8
+	treeRecordLayer      = treeRecordKeyOffset + pageKeySize
+	treeRecordAttrOffset = treeRecordLayer + 1
+	treeRecordSize       = treeRecordAttrOffset + 8
+)
+
+var ErrInvalidTreeFile = errors.New("Invalid tree file.")
+
+// WriteTo writes the tree to w in a compact binary format read back by ReadTreeFrom: a
+// header with the format version, the number of entries per node and the resolution,
+// then the bounds, hilbert value, layer and attributes of every object, in hilbert order,
+// and a checksum of the whole. Only the leaves are written, objects removed by lazy
+// deletions being left out, and internal nodes are rebuilt on load.
+func (tree *HRtree) WriteTo(w io.Writer) (int64, error) {
+	if tree.rlockTree() {
+		defer tree.runlock()
+	}
+
+	cw := &countingWriter{w: w}
+	hash := crc32.New(castagnoli)
+	bw := bufio.NewWriter(io.MultiWriter(cw, hash))
+
+	var header [treeFileHeaderSize]byte
+	copy(header[:], treeFileMagic)
+	binary.LittleEndian.PutUint32(header[4:], treeFileVersion)
+	binary.LittleEndian.PutUint32(header[8:], Dim)
+	binary.LittleEndian.PutUint32(header[12:], uint32(tree.min))
+	binary.LittleEndian.PutUint32(header[16:], uint32(tree.max))
+	binary.LittleEndian.PutUint32(header[20:], uint32(tree.bits))
+	binary.LittleEndian.PutUint64(header[24:], uint64(tree.size))
+	if _, err := bw.Write(header[:]); err != nil {
+		return cw.n, fmt.Errorf("WriteTo: %w", err)
+	}
+
+	var rec [treeRecordSize]byte
+	var err error
+	tree.root.eachLeaf(func(leaf *node) bool {
+		for _, e := range leaf.getEntries() {
+			if e.dead {
+				continue
+			}
+
+			for i := 0; i < Dim; i++ {
+				binary.LittleEndian.PutUint64(rec[8*i:], e.bb.lowerLeft[i])
+				binary.LittleEndian.PutUint64(rec[8*(Dim+i):], e.bb.upperRight[i])
+			}
+
+			key := rec[treeRecordKeyOffset:treeRecordLayer]
+			clear(key)
+			if err = putKey(key, e.h); err != nil {
+				return false
+			}
+			rec[treeRecordLayer] = e.layer
+			binary.LittleEndian.PutUint64(rec[treeRecordAttrOffset:], e.attrs)
+
+			if _, err = bw.Write(rec[:]); err != nil {
+				return false
+			}
+		}
+		return true
+	})
+
+	if err != nil {
+		return cw.n, fmt.Errorf("WriteTo: %w", err)
+	}
+
+	if err := bw.Flush(); err != nil {
+		return cw.n, fmt.Errorf("WriteTo: %w", err)
+	}
+
+	var sum [4]byte
+	binary.LittleEndian.PutUint32(sum[:], hash.Sum32())
+	if _, err := cw.Write(sum[:]); err != nil {
+		return cw.n, fmt.Errorf("WriteTo: %w", err)
+	}
+
+	return cw.n, nil
+}
+
+// ReadTreeFrom reads a tree written by WriteTo. Objects are restored as plain rectangles
+// carrying the stored bounds, layer and attributes, and packed in a single pass as their
+// order is kept: nodes are built as soon as their objects are read, so that only the tree
+// is held in memory. opts enable optional behaviour, the number of entries per node and
+// the resolution being those of the file.
+func ReadTreeFrom(r io.Reader, opts ...Option) (*HRtree, error) {
+	d, err := NewTreeDecoder(r)
+	if err != nil {
+		return nil, fmt.Errorf("ReadTreeFrom: %w", err)
+	}
+
+	// a corrupt count must not overflow the sizes of the levels.
+	if d.count > math.MaxInt32*uint64(max(d.max, 1)) {
+		return nil, fmt.Errorf("ReadTreeFrom: %d records: %w", d.count, ErrInvalidTreeFile)
+	}
+
+	opts = append(opts[:len(opts):len(opts)], WithNodeEntries(d.min, d.max), WithResolution(d.bits))
+	tree, err := NewTree(d.min, d.max, d.bits, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("ReadTreeFrom: %w", err)
+	}
+
+	sp := newStreamPacker(tree, int(d.count))
+	for {
+		e, err := d.next()
+		if err == io.EOF {
+			break
+		}
+
+		if err != nil {
+			return nil, fmt.Errorf("ReadTreeFrom: %w", err)
+		}
+		sp.add(0, e)
+	}
+
+	sp.finish(int(d.count))
+	tree.changed(int(d.count))
+	tree.publish()
+
+	return tree, nil
+}
+
+// unexpected turns io.EOF into io.ErrUnexpectedEOF, for input ending early.
+func unexpected(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+
+	return err
+}
+
+// countingWriter counts the bytes written to w.
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (cw *countingWriter) Write(p []byte) (int, error) {
+	n, err := cw.w.Write(p)
+	cw.n += int64(n)
+	return n, err
+}
@@ -0,0 +1,84 @@
+package hrtree
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"testing"
+)
+
+func TestWriteToReadTreeFrom(t *testing.T) {
+	rt, _ := NewTree(3, 8, 14, WithLazyDelete())
+	for i := 0; i < 500; i++ {
+		rt.Insert(attributed(Point{uint64(i), uint64(i % 13)}, Point{uint64(i + 2), uint64(i%13 + 1)}, uint64(i%3)))
+	}
+	rt.Delete(rect(Point{7, 7}, Point{9, 8}))
+
+	var buf bytes.Buffer
+	n, err := rt.WriteTo(&buf)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if n != int64(buf.Len()) || n != treeFileHeaderSize+499*treeRecordSize+4 {
+		t.Errorf("expected %d bytes, got %d", buf.Len(), n)
+	}
+
+	loaded, err := ReadTreeFrom(bytes.NewReader(buf.Bytes()))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if loaded.min != 3 || loaded.max != 8 || loaded.bits != 14 || loaded.Size() != 499 {
+		t.Errorf("expected 499 objects with 3-8 entries and 14 bits, got %d with %d-%d and %d bits", loaded.Size(), loaded.min, loaded.max, loaded.bits)
+	}
+
+	bb := rect(Point{0, 0}, Point{1000, 1000})
+	want, got := rt.SearchIntersect(bb, WithMask(2)), loaded.SearchIntersect(bb, WithMask(2))
+	if len(got) != len(want) {
+		t.Fatalf("expected %d results, got %d", len(want), len(got))
+	}
+
+	for i := range want {
+		if !equal(got[i], want[i]) {
+			t.Errorf("expected %v, got %v", want[i], got[i])
+		}
+	}
+}
+
+func TestReadTreeFromInvalid(t *testing.T) {
+	rt, _ := NewTree(2, 4, 12)
+	for i := 0; i < 50; i++ {
+		rt.Insert(rect(Point{uint64(i), 0}, Point{uint64(i + 1), 1}))
+	}
+
+	var buf bytes.Buffer
+	rt.WriteTo(&buf)
+	data := buf.Bytes()
+
+	if _, err := ReadTreeFrom(bytes.NewReader(data[:len(data)-10])); !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Errorf("expected %v, got %v", io.ErrUnexpectedEOF, err)
+	}
+
+	dims := append([]byte(nil), data...)
+	dims[8] = Dim + 1
+	if _, err := ReadTreeFrom(bytes.NewReader(dims)); !errors.Is(err, ErrDimensionMismatch) {
+		t.Errorf("expected %v, got %v", ErrDimensionMismatch, err)
+	}
+
+	corrupt := append([]byte(nil), data...)
+	corrupt[treeFileHeaderSize+3]++
+	if _, err := ReadTreeFrom(bytes.NewReader(corrupt)); !errors.Is(err, ErrInvalidTreeFile) {
+		t.Errorf("expected %v, got %v", ErrInvalidTreeFile, err)
+	}
+
+	corrupt = append([]byte(nil), data...)
+	corrupt[4] = 9
+	if _, err := ReadTreeFrom(bytes.NewReader(corrupt)); !errors.Is(err, ErrUnknownVersion) {
+		t.Errorf("expected %v, got %v", ErrUnknownVersion, err)
+	}
+
+	if _, err := ReadTreeFrom(bytes.NewReader([]byte("HRTP"))); !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Errorf("expected %v, got %v", io.ErrUnexpectedEOF, err)
+	}
+}
@@ -0,0 +1,110 @@
+package hrtree
+
+import (
+	"iter"
+)
+
+// Tree holds objects of type T, sparing callers the type assertions of the results of
+// HRtree. It wraps an HRtree, available through Untyped for the operations it doesn't
+// cover. Objects of other types, inserted through the HRtree, are left out of results.
+type Tree[T Rectangle] struct {
+	tree *HRtree
+}
+
+// NewTreeOf creates a tree of objects of type T, see NewTree.
+func NewTreeOf[T Rectangle](min, max, bits int, opts ...Option) (*Tree[T], error) {
+	tree, err := NewTree(min, max, bits, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Tree[T]{tree}, nil
+}
+
+// Untyped returns the HRtree holding the objects of t.
+func (t *Tree[T]) Untyped() *HRtree {
+	return t.tree
+}
+
+// Size returns the number of objects currently stored in t.
+func (t *Tree[T]) Size() int {
+	return t.tree.Size()
+}
+
+// Insert inserts obj, see HRtree.Insert.
+func (t *Tree[T]) Insert(obj T) error {
+	return t.tree.Insert(obj)
+}
+
+// Delete removes obj, see HRtree.Delete.
+func (t *Tree[T]) Delete(obj T) (bool, error) {
+	return t.tree.Delete(obj)
+}
+
+// SearchIntersect returns the objects intersecting bb, see HRtree.SearchIntersect.
+func (t *Tree[T]) SearchIntersect(bb Rectangle, opts ...QueryOption) []T {
+	return typed[T](t.tree.SearchIntersect(bb, t.opts(opts)...))
+}
+
+// SearchContained returns the objects lying within bb, see HRtree.SearchContained.
+func (t *Tree[T]) SearchContained(bb Rectangle, opts ...QueryOption) []T {
+	return typed[T](t.tree.SearchContained(bb, t.opts(opts)...))
+}
+
+// SearchCovering returns the objects containing bb, see HRtree.SearchCovering.
+func (t *Tree[T]) SearchCovering(bb Rectangle, opts ...QueryOption) []T {
+	return typed[T](t.tree.SearchCovering(bb, t.opts(opts)...))
+}
+
+// SearchPoint returns the objects containing p, see HRtree.SearchPoint.
+func (t *Tree[T]) SearchPoint(p Point, opts ...QueryOption) []T {
+	return typed[T](t.tree.SearchPoint(p, t.opts(opts)...))
+}
+
+// Intersecting returns the objects intersecting bb as a sequence, see HRtree.Intersecting.
+func (t *Tree[T]) Intersecting(bb Rectangle, opts ...QueryOption) iter.Seq[T] {
+	return func(yield func(T) bool) {
+		for obj := range t.tree.Intersecting(bb, t.opts(opts)...) {
+			if !yield(obj.(T)) {
+				return
+			}
+		}
+	}
+}
+
+// NearestNeighbor returns the object nearest to p, see HRtree.NearestNeighbor. It reports
+// false if there is none.
+func (t *Tree[T]) NearestNeighbor(p Point, opts ...QueryOption) (T, bool) {
+	v, ok := t.tree.NearestNeighbor(p, t.opts(opts)...).(T)
+	return v, ok
+}
+
+// NearestWithin returns the objects within maxDist of p, nearest first, see
+// HRtree.NearestWithin.
+func (t *Tree[T]) NearestWithin(p Point, maxDist float64, opts ...QueryOption) iter.Seq2[T, float64] {
+	return func(yield func(T, float64) bool) {
+		for obj, d := range t.tree.NearestWithin(p, maxDist, t.opts(opts)...) {
+			if !yield(obj.(T), d) {
+				return
+			}
+		}
+	}
+}
+
+// opts adds to opts the restriction of a search to objects of type T, see WithFilter.
+func (t *Tree[T]) opts(opts []QueryOption) []QueryOption {
+	return append(opts[:len(opts):len(opts)], WithFilter(func(obj Rectangle) bool {
+		_, ok := obj.(T)
+		return ok
+	}))
+}
+
+// typed converts objs, all of type T.
+func typed[T Rectangle](objs []Rectangle) []T {
+	results := make([]T, len(objs))
+	for i, obj := range objs {
+		results[i] = obj.(T)
+	}
+
+	return results
+}
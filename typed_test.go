@@ -0,0 +1,93 @@
+package hrtree
+
+import (
+	"testing"
+)
+
+// site is a named point.
+type site struct {
+	name string
+	p    Point
+}
+
+func (s *site) LowerLeft() Point {
+	return s.p
+}
+
+func (s *site) UpperRight() Point {
+	return s.p
+}
+
+func TestTypedTree(t *testing.T) {
+	if _, err := NewTreeOf[*site](5, 4, 12); err == nil {
+		t.Errorf("expected an error")
+	}
+
+	tree, err := NewTreeOf[*site](2, 4, 12)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var sites []*site
+	for i := uint64(0); i < 50; i++ {
+		s := &site{string(rune('a' + i%26)), Point{10 * i, 10 * i}}
+		tree.Insert(s)
+		sites = append(sites, s)
+	}
+
+	// objects of other types are left out.
+	tree.Untyped().Insert(rect(Point{0, 0}, Point{100, 100}))
+
+	found := tree.SearchIntersect(rect(Point{0, 0}, Point{35, 35}))
+	if len(found) != 4 || found[0].name != "a" || found[3].name != "d" {
+		t.Errorf("expected sites a to d, got %v", found)
+	}
+
+	if page := tree.SearchIntersect(rect(Point{0, 0}, Point{100, 100}), WithLimit(3)); len(page) != 3 {
+		t.Errorf("expected 3 sites, got %d", len(page))
+	}
+
+	if found := tree.SearchPoint(Point{20, 20}); len(found) != 1 || found[0] != sites[2] {
+		t.Errorf("expected site c, got %v", found)
+	}
+
+	if found := tree.SearchContained(rect(Point{5, 5}, Point{25, 25})); len(found) != 2 {
+		t.Errorf("expected 2 sites, got %v", found)
+	}
+
+	if found := tree.SearchCovering(rect(Point{30, 30}, Point{30, 30})); len(found) != 1 {
+		t.Errorf("expected a site, got %v", found)
+	}
+
+	n := 0
+	for s := range tree.Intersecting(rect(Point{0, 0}, Point{100, 100})) {
+		if s.name == "" {
+			t.Errorf("expected a named site")
+		}
+		n++
+	}
+
+	if n != 11 {
+		t.Errorf("expected 11 sites, got %d", n)
+	}
+
+	if s, ok := tree.NearestNeighbor(Point{52, 52}); !ok || s != sites[5] {
+		t.Errorf("expected site f, got %v", s)
+	}
+
+	for s, d := range tree.NearestWithin(Point{52, 52}, 100) {
+		if s != sites[5] || d > 3 {
+			t.Errorf("expected site f first, got %v at %v", s, d)
+		}
+		break
+	}
+
+	if ok, _ := tree.Delete(sites[0]); !ok || tree.Size() != 50 {
+		t.Errorf("expected site a to be deleted, leaving 50 objects, got %d", tree.Size())
+	}
+
+	empty, _ := NewTreeOf[*site](2, 4, 12)
+	if _, ok := empty.NearestNeighbor(Point{0, 0}); ok {
+		t.Errorf("expected no site")
+	}
+}
@@ -0,0 +1,92 @@
+package hrtree
+
+// UnderflowPolicy selects how nodes left with too few entries by deletions are handled.
+type UnderflowPolicy int
+
+const (
+	// UnderflowRedistribute spreads the entries of the node and of its cooperating
+	// siblings on the right over all of them, dropping the node when they can't all be
+	// filled to the minimum. It is the default.
+	UnderflowRedistribute UnderflowPolicy = iota
+
+	// UnderflowBorrow first moves entries from an adjacent sibling that can spare them,
+	// the right one before the left one, which leaves the structure of the tree
+	// unchanged. Nodes are redistributed as with UnderflowRedistribute otherwise.
+	UnderflowBorrow
+
+	// UnderflowMergeLeft merges the node into its left sibling when their entries fit in
+	// a single node, and spreads them over both otherwise. The rightmost node merges
+	// with its right sibling instead.
+	UnderflowMergeLeft
+
+	// UnderflowMergeRight is like UnderflowMergeLeft, merging with the right sibling.
+	UnderflowMergeRight
+
+	// UnderflowDefer leaves underflowing leaves in place, only dropping empty ones, until
+	// the next Vacuum. Deletions are cheaper, at the cost of sparser nodes meanwhile.
+	UnderflowDefer
+)
+
+// WithUnderflowPolicy sets how underflowing nodes are handled. Append-mostly workloads
+// may prefer merging nodes, churn-heavy ones borrowing entries or deferring the work.
+func WithUnderflowPolicy(p UnderflowPolicy) Option {
+	return func(tree *HRtree) {
+		tree.policy = p
+	}
+}
+
+// lender returns target and an adjacent sibling, in order, that can lend entries to
+// target while neither of them underflows, or nil if there is none.
+func (tree *HRtree) lender(target *node) []*node {
+	for _, nodes := range [][]*node{{target, target.right}, {target.left, target}} {
+		if nodes[0] == nil || nodes[1] == nil {
+			continue
+		}
+
+		if (nodes[0].entries.len()+nodes[1].entries.len())/2 >= tree.underflowAt() {
+			return nodes
+		}
+	}
+
+	return nil
+}
+
+// mergeSiblings returns target and the sibling it merges with, in order, or nil if it
+// has none.
+func (tree *HRtree) mergeSiblings(target *node) []*node {
+	left, right := []*node{target.left, target}, []*node{target, target.right}
+	if tree.policy == UnderflowMergeRight {
+		left, right = right, left
+	}
+
+	for _, nodes := range [][]*node{left, right} {
+		if nodes[0] != nil && nodes[1] != nil {
+			return nodes
+		}
+	}
+
+	return nil
+}
+
+// cooperate spreads the entries of nodes, which include target, over them, or over the
+// others when target is dropped.
+func (tree *HRtree) cooperate(target *node, nodes []*node, drop bool) (*node, []*node) {
+	entries := newListUncapped()
+	for _, node := range nodes {
+		for _, e := range node.getEntries() {
+			entries.insert(e)
+		}
+
+		node.reset()
+	}
+
+	var nn *node
+	if drop && target.parent != nil {
+		nn = target
+		nodes = nn.unlink(nodes)
+	}
+
+	redistributeEntries(entries, nodes)
+
+	return nn, nodes
+}
@@ -0,0 +1,157 @@
+package hrtree
+
+import (
+	"math/rand"
+	"testing"
+)
+
+func TestUnderflowPolicies(t *testing.T) {
+	policies := []UnderflowPolicy{UnderflowRedistribute, UnderflowBorrow, UnderflowMergeLeft, UnderflowMergeRight, UnderflowDefer}
+
+	for _, policy := range policies {
+		r := rand.New(rand.NewSource(1))
+		rt, _ := NewTree(3, 8, 12, WithUnderflowPolicy(policy))
+		objs := make(map[Rectangle]bool)
+		var list []Rectangle
+
+		for i := 0; i < 3000; i++ {
+			// deletions take over halfway through, to shrink the tree back.
+			if len(list) == 0 || r.Intn(5) < 3 && i < 1500 {
+				x, y := uint64(3*i), uint64(r.Intn(1000))
+				obj := rect(Point{x, y}, Point{x + 1 + uint64(r.Intn(2)), y + uint64(r.Intn(10))})
+				rt.Insert(obj)
+				objs[obj] = true
+				list = append(list, obj)
+			} else {
+				j := r.Intn(len(list))
+				if ok, _ := rt.Delete(list[j]); !ok {
+					t.Fatalf("policy %d: failed to delete %v", policy, list[j])
+				}
+				delete(objs, list[j])
+				list[j] = list[len(list)-1]
+				list = list[:len(list)-1]
+			}
+
+			if i%100 == 0 {
+				checkTree(t, rt, objs)
+			}
+		}
+		checkTree(t, rt, objs)
+	}
+}
+
+func TestUnderflowDefer(t *testing.T) {
+	rt, _ := NewTree(3, 8, 12, WithUnderflowPolicy(UnderflowDefer))
+	objs := make(map[Rectangle]bool)
+	var list []Rectangle
+	for i := uint64(0); i < 500; i++ {
+		obj := rect(Point{3 * i, i % 50}, Point{3*i + 1, i%50 + 1})
+		rt.Insert(obj)
+		objs[obj] = true
+		list = append(list, obj)
+	}
+
+	for i, obj := range list {
+		if i%3 != 0 {
+			rt.Delete(obj)
+			delete(objs, obj)
+		}
+	}
+	checkTree(t, rt, objs)
+
+	underflowing := func() (n int) {
+		for _, leaf := range rt.root.leaves(nil) {
+			if rt.isUnderflowing(leaf) {
+				n++
+			}
+		}
+		return
+	}
+
+	before := underflowing()
+	if before == 0 {
+		t.Errorf("expected underflowing leaves before Vacuum")
+	}
+
+	if purged, _ := rt.Vacuum(); purged != 0 {
+		t.Errorf("expected nothing to purge, got %d", purged)
+	}
+	checkTree(t, rt, objs)
+
+	// several adjacent underflowing leaves may not fill each other up.
+	if after := underflowing(); after >= before {
+		t.Errorf("expected fewer underflowing leaves after Vacuum, got %d from %d", after, before)
+	}
+}
+
+func TestRedistributeEntriesEvenly(t *testing.T) {
+	entries := newList(10)
+	for i := uint64(0); i < 10; i++ {
+		entries.insert(entry{bb: rect(Point{i, i}, Point{i, i}), h: hkey{lo: i}, leaf: true})
+	}
+
+	var siblings []*node
+	for i := 0; i < 4; i++ {
+		n := newNode(2, 4)
+		n.leaf = true
+		siblings = append(siblings, n)
+	}
+	redistributeEntries(entries, siblings)
+
+	// the last sibling isn't left with the remainder.
+	for i, n := range siblings {
+		if got := n.entries.len(); got < 2 || got > 3 {
+			t.Errorf("sibling %d: expected 2 or 3 entries, got %d", i, got)
+		}
+	}
+
+	if siblings[0].entries.get(0).h.lo != 0 || siblings[3].lhv.lo != 9 {
+		t.Errorf("expected entries to keep their order across siblings")
+	}
+}
+
+// checkAdjusted checks that the LHVs and bounding-boxes of the nodes under n cover their
+// entries.
+func checkAdjusted(t *testing.T, n *node) {
+	t.Helper()
+
+	entries := n.getEntries()
+	if len(entries) == 0 {
+		return
+	}
+
+	if n.lhv.cmp(entries[len(entries)-1].getLHV()) != 0 {
+		t.Fatalf("LHV %v, largest of the entries %v", n.lhv, entries[len(entries)-1].getLHV())
+	}
+
+	for _, e := range entries {
+		if !n.bb.contains(e.getMBR()) {
+			t.Fatalf("bounding-box %v outside of %v", e.getMBR(), n.bb)
+		}
+
+		if !n.leaf {
+			checkAdjusted(t, e.node)
+		}
+	}
+}
+
+func TestDeleteAdjustsParents(t *testing.T) {
+	for _, policy := range []UnderflowPolicy{UnderflowRedistribute, UnderflowBorrow, UnderflowMergeLeft, UnderflowMergeRight} {
+		r := rand.New(rand.NewSource(1))
+		rt, _ := NewTree(2, 4, 12, WithUnderflowPolicy(policy))
+		var objs []Rectangle
+		for i := 0; i < 2000; i++ {
+			x, y := uint64(r.Intn(1000)), uint64(r.Intn(1000))
+			objs = append(objs, rect(Point{x, y}, Point{x + 1, y + 1}))
+		}
+
+		// bulk loaded levels are linked across parents, so that the siblings taking the
+		// entries of an underflowing node may have other parents and grandparents.
+		rt.ReplaceAll(objs)
+		r.Shuffle(len(objs), func(i, j int) { objs[i], objs[j] = objs[j], objs[i] })
+		for _, obj := range objs[:1500] {
+			rt.Delete(obj)
+			checkAdjusted(t, rt.root)
+		}
+	}
+}
@@ -0,0 +1,128 @@
+package hrtree
+
+import (
+	"errors"
+	"fmt"
+)
+
+var ErrStaleVersion = errors.New("Version should be newer than the current version of its ID.")
+
+// Update moves obj to newBounds, which replaces it in the tree, and reports whether obj
+// was found. When newBounds still fit the leaf of obj without breaking the hilbert order
+// of its entries, the entry is replaced in place; otherwise obj is deleted and newBounds
+// inserted. Versioned objects always take the latter path, and a Versioned newBounds
+// must be newer than the version its ID has once obj is deleted, or Update fails with
+// ErrStaleVersion. Other errors are returned as with Insert.
+func (tree *HRtree) Update(obj, newBounds Rectangle) (ok bool, err error) {
+	if err := tree.lock(); err != nil {
+		return false, fmt.Errorf("Update: %w", err)
+	}
+	defer tree.unlock()
+	defer recoverCorrupted("Update", &err)
+
+	_, v1 := obj.(Versioned)
+	nv, v2 := newBounds.(Versioned)
+	if v2 && tree.stale(obj, nv) {
+		return false, fmt.Errorf("Update: version %d of %q: %w", nv.Version(), nv.ID(), ErrStaleVersion)
+	}
+
+	if v1 || v2 {
+		if !tree.deleteObject(obj) {
+			return false, nil
+		}
+		tree.insertObject(newBounds)
+
+		return true, nil
+	}
+
+	leaf, i := tree.lookup(obj)
+	if leaf == nil {
+		return false, nil
+	}
+	tree.replace(leaf, i, newBounds)
+
+	return true, nil
+}
+
+// stale reports whether nv isn't newer than the version of its ID once obj is deleted,
+// which its insertion would leave in place.
+func (tree *HRtree) stale(obj Rectangle, nv Versioned) bool {
+	cur, known := tree.versions[nv.ID()]
+	latest := cur.version
+	if v, ok := obj.(Versioned); ok && v.ID() == nv.ID() && (!known || v.Version() > latest) {
+		latest, known = v.Version(), true
+	}
+
+	return known && nv.Version() <= latest
+}
+
+// Upsert replaces the object of the tree equal to obj with it, or inserts obj if there is
+// none. The entry is replaced in place unless a WithKeyFunc key moves it out of order.
+// Versioned objects are inserted as with Insert, which supersedes the current version.
+// Errors are returned as with Insert.
+func (tree *HRtree) Upsert(obj Rectangle) (err error) {
+	if err := tree.lock(); err != nil {
+		return fmt.Errorf("Upsert: %w", err)
+	}
+	defer tree.unlock()
+	defer recoverCorrupted("Upsert", &err)
+
+	tree.upsert(obj)
+	return nil
+}
+
+// upsert replaces the object equal to obj with it, or inserts obj, the tree being locked
+// by the caller.
+func (tree *HRtree) upsert(obj Rectangle) {
+	if _, ok := obj.(Versioned); !ok {
+		if leaf, i := tree.lookup(obj); leaf != nil {
+			tree.replace(leaf, i, obj)
+			return
+		}
+	}
+
+	tree.insertObject(obj)
+}
+
+// replace replaces the i-th entry of leaf with obj, in place if it can be moved there, or
+// else by removing the entry from leaf and inserting obj, so that the entry is only looked
+// up once.
+func (tree *HRtree) replace(leaf *node, i int, obj Rectangle) {
+	if leaf.move(i, tree.newEntry(obj)) {
+		tree.changed(1)
+		return
+	}
+
+	tree.removeEntry(leaf, i)
+	tree.insertObject(obj)
+}
+
+// move replaces the i-th entry of leaf n with e, provided e lies within the bounding-box
+// of n and its hilbert value between the ones of its neighbours, so that neither the
+// bounding-boxes nor the order of the tree are broken. Ancestors are then tightened.
+func (n *node) move(i int, e entry) bool {
+	if n.bb == nil || !n.bb.contains(e.obj) {
+		return false
+	}
+
+	// the first entry may only move right and the last one left, as neighbouring leaves
+	// are ordered around them.
+	entries := n.entries.entries
+	lo, hi := entries[i].h, entries[i].h
+	if i > 0 {
+		lo = entries[i-1].h
+	}
+
+	if i < len(entries)-1 {
+		hi = entries[i+1].h
+	}
+
+	if e.h.cmp(lo) < 0 || e.h.cmp(hi) > 0 {
+		return false
+	}
+
+	entries[i] = e
+	n.refresh()
+
+	return true
+}
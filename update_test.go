@@ -0,0 +1,160 @@
+package hrtree
+
+import (
+	"errors"
+	"math/rand"
+	"testing"
+)
+
+func TestUpdate(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	rt, _ := NewTree(2, 6, 12)
+	objs := make(map[Rectangle]bool)
+	var list []Rectangle
+
+	for i := 0; i < 1000; i++ {
+		// lower and upper x coordinates are unique to each object.
+		x, y := uint64(3*i), uint64(r.Intn(1000))
+		obj := rect(Point{x, y}, Point{x + 1, y + 1 + uint64(r.Intn(20))})
+		rt.Insert(obj)
+		objs[obj] = true
+		list = append(list, obj)
+	}
+
+	for i := 0; i < 2000; i++ {
+		j := r.Intn(len(list))
+		obj := list[j].(*rectangle)
+
+		// half of the moves stay close, the others jump anywhere.
+		y := obj.lowerLeft[1] + 1
+		if i%2 == 0 {
+			y = uint64(r.Intn(1000))
+		}
+		moved := rect(Point{obj.lowerLeft[0], y}, Point{obj.upperRight[0], y + 1})
+
+		if ok, _ := rt.Update(obj, moved); !ok {
+			t.Fatalf("expected %v to be found", obj)
+		}
+		delete(objs, obj)
+		objs[moved] = true
+		list[j] = moved
+	}
+
+	checkTree(t, rt, objs)
+
+	if ok, _ := rt.Update(rect(Point{1, 1}, Point{2, 2}), rect(Point{1, 1}, Point{2, 2})); ok {
+		t.Errorf("expected a missing object not to be found")
+	}
+}
+
+func TestUpdateInPlace(t *testing.T) {
+	rt, _ := NewTree(2, 6, 12)
+	var objs []Rectangle
+	for i := 0; i < 100; i++ {
+		x, y := uint64(i%10), uint64(i/10)
+		obj := rect(Point{x, y}, Point{x + 1, y + 1})
+		rt.Insert(obj)
+		objs = append(objs, obj)
+	}
+
+	obj := objs[55]
+	leaf := rt.findLeaf(rt.root, obj)
+	moved := rect(obj.LowerLeft(), obj.UpperRight())
+
+	if ok, _ := rt.Update(obj, moved); !ok {
+		t.Fatalf("expected %v to be found", obj)
+	}
+
+	if rt.findLeaf(rt.root, moved) != leaf {
+		t.Errorf("expected the object to stay in its leaf")
+	}
+
+	for _, e := range leaf.getEntries() {
+		if e.obj == obj {
+			t.Errorf("expected the entry to be replaced")
+		}
+	}
+}
+
+func TestUpsert(t *testing.T) {
+	rt, _ := NewTree(2, 4, 12)
+	for i := 0; i < 64; i++ {
+		rt.Insert(attributed(Point{uint64(i), 0}, Point{uint64(i + 1), 1}, 1))
+	}
+
+	replacement := attributed(Point{10, 0}, Point{11, 1}, 2)
+	rt.Upsert(replacement)
+
+	if rt.Size() != 64 {
+		t.Errorf("expected 64 objects, got %d", rt.Size())
+	}
+
+	bb := rect(Point{0, 0}, Point{100, 100})
+	if q := rt.SearchIntersect(bb, WithMask(2)); len(q) != 1 || q[0] != replacement {
+		t.Errorf("expected the replacement, got %v", q)
+	}
+
+	if n := len(rt.SearchIntersect(bb, WithMask(1))); n != 63 {
+		t.Errorf("expected 63 results, got %d", n)
+	}
+
+	rt.Upsert(rect(Point{100, 0}, Point{101, 1}))
+	if rt.Size() != 65 {
+		t.Errorf("expected 65 objects, got %d", rt.Size())
+	}
+}
+
+func TestUpsertMoved(t *testing.T) {
+	for _, lazy := range []bool{false, true} {
+		opts := []Option{WithKeyFunc(func(r Rectangle) uint64 { return r.(*attrRect).attrs })}
+		if lazy {
+			opts = append(opts, WithLazyDelete())
+		}
+
+		rt, _ := NewTree(2, 4, 12, opts...)
+		for i := 0; i < 64; i++ {
+			rt.Insert(attributed(Point{uint64(i), 0}, Point{uint64(i + 1), 1}, uint64(i)))
+		}
+
+		// the new key orders the object last, out of its leaf.
+		replacement := attributed(Point{10, 0}, Point{11, 1}, 1000)
+		rt.Upsert(replacement)
+
+		if rt.Size() != 64 {
+			t.Errorf("expected 64 objects, got %d", rt.Size())
+		}
+
+		if err := rt.CheckInvariants(); err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+
+		bb := rect(Point{10, 0}, Point{11, 1})
+		if q := rt.SearchContained(bb); len(q) != 1 || q[0] != replacement {
+			t.Errorf("expected the replacement, got %v", q)
+		}
+	}
+}
+
+func TestUpdateVersioned(t *testing.T) {
+	rt, _ := NewTree(2, 4, 12)
+	for i := uint64(0); i < 20; i++ {
+		rt.Insert(position(string(rune('a'+i)), 1, i, i))
+	}
+
+	// the same version would be ignored once the object is deleted at it.
+	if ok, err := rt.Update(position("c", 1, 2, 2), position("c", 1, 30, 30)); ok || !errors.Is(err, ErrStaleVersion) {
+		t.Errorf("expected %v, got %v, %v", ErrStaleVersion, ok, err)
+	}
+
+	if obj, ok := rt.Lookup("c"); !ok || obj.LowerLeft() != (Point{2, 2}) {
+		t.Errorf("expected the object to be left in place, got %v", obj)
+	}
+
+	if ok, err := rt.Update(position("c", 1, 2, 2), position("c", 2, 30, 30)); !ok || err != nil {
+		t.Errorf("unexpected result: %v, %v", ok, err)
+	}
+
+	if obj, ok := rt.Lookup("c"); !ok || obj.LowerLeft() != (Point{30, 30}) || rt.Size() != 20 {
+		t.Errorf("expected the object to be moved, got %v", obj)
+	}
+}
@@ -5,11 +5,11 @@ import (
 )
 
 func assert(ok bool) {
-	assert2(ok, "assertion failed!")
+	assert2(ok, "assertion failed")
 }
 
 func assert2(ok bool, msg string, args ...interface{}) {
 	if !ok {
-		panic(fmt.Sprintf(msg, args...))
+		panic(corrupted(fmt.Sprintf(msg, args...)))
 	}
 }
@@ -0,0 +1,157 @@
+package hrtree
+
+import (
+	"fmt"
+)
+
+// WithLazyDelete makes Delete only mark objects as removed, without any
+// restructuring of the tree. Searches skip removed objects, and the structural
+// cleanup is deferred to Vacuum, where it is done in bulk. Stats reports how many
+// removed objects are waiting for it.
+func WithLazyDelete() Option {
+	return func(tree *HRtree) {
+		tree.lazy = true
+	}
+}
+
+// removeDead drops the entries marked as removed and returns how many there were.
+func (n *node) removeDead() int {
+	live := n.entries.entries[:0]
+	for _, en := range n.getEntries() {
+		if !en.dead {
+			live = append(live, en)
+		}
+	}
+
+	removed := n.entries.len() - len(live)
+	n.entries.entries = live
+	return removed
+}
+
+// leaves returns the leaf nodes under n, from left to right.
+func (n *node) leaves(leaves []*node) []*node {
+	if n.leaf {
+		return append(leaves, n)
+	}
+
+	for _, e := range n.getEntries() {
+		leaves = e.node.leaves(leaves)
+	}
+
+	return leaves
+}
+
+// eachLeaf calls fn on the leaves under n, left to right, until it returns false.
+func (n *node) eachLeaf(fn func(*node) bool) bool {
+	if n.leaf {
+		return fn(n)
+	}
+
+	for _, e := range n.getEntries() {
+		if !e.node.eachLeaf(fn) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// adjustSubtree recomputes the LHV and bounding-box of every node under n, children first.
+func (n *node) adjustSubtree() {
+	if !n.leaf {
+		for _, e := range n.getEntries() {
+			e.node.adjustSubtree()
+		}
+	}
+
+	n.adjustLHV()
+	n.adjustMBR()
+}
+
+// adjustAncestors recomputes the LHV and bounding-box of nodes, all on the same level, and
+// then of their ancestors, each node once.
+func adjustAncestors(nodes []*node) {
+	for len(nodes) > 0 {
+		var parents []*node
+		seen := make(map[*node]bool)
+		for _, n := range nodes {
+			n.adjustLHV()
+			n.adjustMBR()
+			if n.parent != nil && !seen[n.parent] {
+				seen[n.parent] = true
+				parents = append(parents, n.parent)
+			}
+		}
+		nodes = parents
+	}
+}
+
+// rebalance handles the underflows of the given leaves, ordered left to right.
+func (tree *HRtree) rebalance(leaves []*node) {
+	// leaves are visited right to left, as underflow handling may drop the
+	// visited leaf but leaves the ones on its left in place.
+	p := tree.report(PhaseRebalance, len(leaves))
+	for i := len(leaves) - 1; i >= 0 && !tree.root.leaf; i-- {
+		leaf := leaves[i]
+		p.step()
+		if !tree.isUnderflowing(leaf) {
+			continue
+		}
+
+		dl, siblings := tree.handleUnderflow(leaf, nil)
+		tree.adjustTreeForRemove(leaf, dl, siblings)
+	}
+
+	// a merge may leave the siblings taking the entries of a dropped leaf underflowing in
+	// turn, when they were few: they are merged again until no leaf underflows or none can
+	// be dropped any more. Leaves deferred by UnderflowDefer are left to Vacuum.
+	for count := -1; !tree.root.leaf && (tree.policy != UnderflowDefer || tree.deferred == 0); {
+		leaves := tree.root.leaves(nil)
+		if len(leaves) == count {
+			break
+		}
+		count = len(leaves)
+
+		for i := len(leaves) - 1; i >= 0 && !tree.root.leaf; i-- {
+			if leaf := leaves[i]; tree.isUnderflowing(leaf) {
+				dl, siblings := tree.handleUnderflow(leaf, nil)
+				tree.adjustTreeForRemove(leaf, dl, siblings)
+			}
+		}
+	}
+	p.finish()
+}
+
+// Vacuum purges the objects removed by lazy deletions (see WithLazyDelete) and
+// handles the resulting node underflows, as well as those deferred by UnderflowDefer.
+// It returns the number of purged entries. Errors are returned as with Insert.
+func (tree *HRtree) Vacuum() (purged int, err error) {
+	if err := tree.lock(); err != nil {
+		return 0, fmt.Errorf("Vacuum: %w", err)
+	}
+	defer tree.unlock()
+	defer recoverCorrupted("Vacuum", &err)
+
+	if tree.tombstones == 0 && tree.deferred == 0 {
+		return 0, nil
+	}
+
+	// drop the removed entries first, so that underflow handling only ever
+	// moves live entries around.
+	leaves := tree.root.leaves(nil)
+	p := tree.report(PhasePurge, len(leaves))
+	for _, leaf := range leaves {
+		purged += leaf.removeDead()
+		p.step()
+	}
+	tree.root.adjustSubtree()
+	p.finish()
+
+	tree.deferred = 0
+	tree.rebalance(leaves)
+
+	tree.tombstones -= purged
+	tree.changed(0)
+
+	return purged, nil
+}
@@ -0,0 +1,194 @@
+package hrtree
+
+import (
+	"bytes"
+	"math/rand"
+	"testing"
+)
+
+func countEntries(n *node) (live, dead int) {
+	if n.leaf {
+		for _, e := range n.getEntries() {
+			if e.dead {
+				dead++
+			} else {
+				live++
+			}
+		}
+
+		return
+	}
+
+	for _, e := range n.getEntries() {
+		l, d := countEntries(e.node)
+		live += l
+		dead += d
+	}
+
+	return
+}
+
+func TestLazyDelete(t *testing.T) {
+	rt, _ := NewTree(2, 8, 12, WithLazyDelete())
+
+	var things []Rectangle
+	for i := 0; i < 50; i++ {
+		r := rect(Point{uint64(i), uint64(i)}, Point{uint64(i + 1), uint64(i + 1)})
+		things = append(things, r)
+		rt.Insert(r)
+	}
+
+	rootEntries := rt.root.entries.len()
+	for _, r := range things[10:30] {
+		if ok, _ := rt.Delete(r); !ok {
+			t.Errorf("expected %v to be deleted", r)
+		}
+	}
+
+	if ok, _ := rt.Delete(things[10]); ok {
+		t.Errorf("expected a removed object not to be deleted twice")
+	}
+
+	if rt.Size() != 30 {
+		t.Errorf("expected size 30, got %d", rt.Size())
+	}
+
+	if rt.root.entries.len() != rootEntries {
+		t.Errorf("lazy deletion should not restructure the tree")
+	}
+
+	bb := rect(Point{0, 0}, Point{100, 100})
+	q := rt.SearchIntersect(bb)
+	if len(q) != 30 {
+		t.Errorf("expected 30 results, got %d", len(q))
+	}
+
+	for _, r := range things[10:30] {
+		if index(q, r) >= 0 {
+			t.Errorf("removed object %v returned by search", r)
+		}
+	}
+
+	if _, dead := countEntries(rt.root); dead != 20 {
+		t.Errorf("expected 20 removed entries, got %d", dead)
+	}
+}
+
+func TestVacuum(t *testing.T) {
+	rt, _ := NewTree(2, 6, 12, WithLazyDelete())
+
+	var things []Rectangle
+	for i := 0; i < 200; i++ {
+		r := rect(Point{uint64(i), uint64(i)}, Point{uint64(i + 1), uint64(i + 1)})
+		things = append(things, r)
+		rt.Insert(r)
+	}
+
+	for i, r := range things {
+		if i%3 != 0 || i > 150 {
+			rt.Delete(r)
+		}
+	}
+
+	if n, _ := rt.Vacuum(); n != 149 {
+		t.Errorf("expected 149 purged entries, got %d", n)
+	}
+
+	if n, _ := rt.Vacuum(); n != 0 {
+		t.Errorf("expected nothing left to purge, got %d", n)
+	}
+
+	live, dead := countEntries(rt.root)
+	if live != 51 || dead != 0 {
+		t.Errorf("expected 51 live and no removed entries, got %d and %d", live, dead)
+	}
+
+	objs := make(map[Rectangle]bool)
+	for i, r := range things {
+		if i%3 == 0 && i <= 150 {
+			objs[r] = true
+		}
+	}
+	checkTree(t, rt, objs)
+}
+
+func TestVacuumEmpty(t *testing.T) {
+	rt, _ := NewTree(2, 4, 12, WithLazyDelete())
+
+	var things []Rectangle
+	for i := 0; i < 40; i++ {
+		r := rect(Point{uint64(i), 0}, Point{uint64(i + 1), 1})
+		things = append(things, r)
+		rt.Insert(r)
+	}
+
+	for _, r := range things {
+		rt.Delete(r)
+	}
+
+	rt.Vacuum()
+
+	if live, dead := countEntries(rt.root); live != 0 || dead != 0 {
+		t.Errorf("expected an empty tree, got %d live and %d removed entries", live, dead)
+	}
+}
+
+func TestLazyDeleteWritePages(t *testing.T) {
+	rt, _ := NewTree(2, 4, 12, WithLazyDelete())
+
+	var things []Rectangle
+	for i := 0; i < 20; i++ {
+		r := rect(Point{uint64(i), 0}, Point{uint64(i + 1), 1})
+		things = append(things, r)
+		rt.Insert(r)
+	}
+
+	for _, r := range things[:5] {
+		rt.Delete(r)
+	}
+
+	var buf bytes.Buffer
+	rt.WritePages(&buf, MinPageSize)
+
+	loaded, err := ReadPages(bytes.NewReader(buf.Bytes()))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if n := len(loaded.SearchIntersect(rect(Point{0, 0}, Point{100, 100}))); n != 15 {
+		t.Errorf("expected 15 results, got %d", n)
+	}
+
+	if n, _ := loaded.Vacuum(); n != 5 {
+		t.Errorf("expected 5 purged entries, got %d", n)
+	}
+}
+
+func TestVacuumFill(t *testing.T) {
+	for _, policy := range []UnderflowPolicy{UnderflowRedistribute, UnderflowMergeLeft, UnderflowDefer} {
+		r := rand.New(rand.NewSource(1))
+		rt, _ := NewTree(2, 4, 12, WithLazyDelete(), WithUnderflowPolicy(policy))
+
+		var things []Rectangle
+		for i := 0; i < 2000; i++ {
+			x, y := uint64(r.Intn(1000)), uint64(r.Intn(1000))
+			obj := rect(Point{x, y}, Point{x + 1, y + 1})
+			things = append(things, obj)
+			rt.Insert(obj)
+		}
+
+		// one object in five is kept, so that few leaves keep enough entries on their own.
+		for _, obj := range things {
+			if r.Intn(5) > 0 {
+				rt.Delete(obj)
+			}
+		}
+		rt.Vacuum()
+
+		for i, leaf := range rt.root.leaves(nil) {
+			if leaf != rt.root && rt.isUnderflowing(leaf) {
+				t.Errorf("policy %v: leaf %d left underflowing with %d entries", policy, i, leaf.entries.len())
+			}
+		}
+	}
+}
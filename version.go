@@ -0,0 +1,104 @@
+package hrtree
+
+// Versioned is implemented by objects tracked under an application ID, such as the
+// successive positions of a vehicle. Inserting a newer version of an object replaces
+// the current one.
+type Versioned interface {
+	ID() string
+	Version() uint64
+}
+
+// version records the current version of an ID. Deleted IDs are remembered with a
+// nil obj, so that merging replicas doesn't bring them back.
+type version struct {
+	obj     Rectangle
+	version uint64
+}
+
+// supersede records v as the current version of its ID, marking the previous one as
+// removed. It reports false if v is not newer than the current version.
+func (tree *HRtree) supersede(v Versioned) bool {
+	cur, ok := tree.versions[v.ID()]
+	if ok && cur.version >= v.Version() {
+		return false
+	}
+
+	if ok && cur.obj != nil {
+		if leaf, i := tree.findEntry(tree.root, cur.obj, sameID(v.ID())); leaf != nil {
+			leaf.entries.entries[i].dead = true
+			leaf.touch()
+			tree.size--
+			tree.tombstones++
+		}
+	}
+
+	if tree.versions == nil {
+		tree.versions = make(map[string]version)
+	}
+	tree.versions[v.ID()] = version{v.(Rectangle), v.Version()}
+
+	return true
+}
+
+// deleteVersion removes the current version of v's ID from the tree. The deletion is
+// recorded at the newest of both versions.
+func (tree *HRtree) deleteVersion(v Versioned) bool {
+	cur, ok := tree.versions[v.ID()]
+	if !ok || cur.obj == nil {
+		return false
+	}
+
+	deleted := version{version: cur.version}
+	if v.Version() > deleted.version {
+		deleted.version = v.Version()
+	}
+	tree.versions[v.ID()] = deleted
+
+	leaf, i := tree.findEntry(tree.root, cur.obj, sameID(v.ID()))
+	if leaf == nil {
+		return false
+	}
+
+	leaf.entries.entries[i].dead = true
+	leaf.touch()
+	tree.size--
+	tree.tombstones++
+	return true
+}
+
+func sameID(id string) func(Rectangle) bool {
+	return func(obj Rectangle) bool {
+		v, ok := obj.(Versioned)
+		return ok && v.ID() == id
+	}
+}
+
+// Lookup returns the current version of the object with the given ID.
+func (tree *HRtree) Lookup(id string) (Rectangle, bool) {
+	if tree.rlockTree() {
+		defer tree.runlock()
+	}
+
+	cur := tree.versions[id]
+	return cur.obj, cur.obj != nil
+}
+
+// findEntry finds the live leaf entry lying within obj's bounds whose object satisfies
+// match. It returns the leaf and the entry's position in it, or a nil leaf.
+func (tree *HRtree) findEntry(n *node, obj Rectangle, match func(Rectangle) bool) (*node, int) {
+	for i, e := range n.getEntries() {
+		if !e.getMBR().contains(obj) {
+			continue
+		}
+
+		if !n.leaf {
+			if leaf, j := tree.findEntry(e.node, obj, match); leaf != nil {
+				return leaf, j
+			}
+		} else if !e.dead && match(e.obj) {
+			return n, i
+		}
+	}
+
+	return nil, -1
+}
@@ -0,0 +1,95 @@
+package hrtree
+
+import (
+	"testing"
+)
+
+type vehicle struct {
+	rectangle
+	id      string
+	version uint64
+}
+
+func (v *vehicle) ID() string {
+	return v.id
+}
+
+func (v *vehicle) Version() uint64 {
+	return v.version
+}
+
+func position(id string, version uint64, x, y uint64) *vehicle {
+	return &vehicle{*rect(Point{x, y}, Point{x, y}), id, version}
+}
+
+func TestVersionedInsert(t *testing.T) {
+	rt, _ := NewTree(2, 4, 12)
+	bb := rect(Point{0, 0}, Point{100, 100})
+
+	for i := uint64(1); i <= 20; i++ {
+		rt.Insert(position("a", i, i, i))
+		rt.Insert(position("b", i, 50-i, i))
+	}
+
+	if rt.Size() != 2 {
+		t.Errorf("expected 2 objects, got %d", rt.Size())
+	}
+
+	q := rt.SearchIntersect(bb)
+	if len(q) != 2 {
+		t.Fatalf("expected 2 results, got %d", len(q))
+	}
+
+	for _, r := range q {
+		if r.(*vehicle).version != 20 {
+			t.Errorf("expected the latest version, got %d", r.(*vehicle).version)
+		}
+	}
+
+	// older versions are ignored
+	rt.Insert(position("a", 3, 3, 3))
+	if cur, _ := rt.Lookup("a"); cur.(*vehicle).version != 20 {
+		t.Errorf("stale version replaced the current one")
+	}
+
+	if n, _ := rt.Vacuum(); n != 38 {
+		t.Errorf("expected 38 superseded versions to be purged, got %d", n)
+	}
+
+	if n := len(rt.SearchIntersect(bb)); n != 2 {
+		t.Errorf("expected 2 results after vacuum, got %d", n)
+	}
+}
+
+func TestVersionedDelete(t *testing.T) {
+	rt, _ := NewTree(2, 4, 12)
+
+	rt.Insert(position("a", 1, 1, 1))
+	rt.Insert(position("a", 2, 5, 5))
+
+	if ok, _ := rt.Delete(position("a", 0, 0, 0)); !ok {
+		t.Errorf("expected the object to be deleted by ID")
+	}
+
+	if _, ok := rt.Lookup("a"); ok {
+		t.Errorf("expected no current version after deletion")
+	}
+
+	if ok, _ := rt.Delete(position("a", 2, 5, 5)); ok {
+		t.Errorf("expected the object to be deleted only once")
+	}
+
+	if rt.Size() != 0 {
+		t.Errorf("expected an empty tree, got size %d", rt.Size())
+	}
+
+	rt.Insert(position("a", 2, 1, 1))
+	if _, ok := rt.Lookup("a"); ok {
+		t.Errorf("expected a deleted version not to be inserted again")
+	}
+
+	rt.Insert(position("a", 3, 1, 1))
+	if cur, ok := rt.Lookup("a"); !ok || cur.(*vehicle).version != 3 {
+		t.Errorf("expected a newer version of a deleted ID to be inserted")
+	}
+}
@@ -0,0 +1,78 @@
+package hrtree
+
+import (
+	"math/big"
+)
+
+// NodeView is a read-only view of a node, for exporters, visualizers and custom
+// traversals. Views reflect the tree as it is, they should not be used while the tree
+// is being modified.
+type NodeView struct {
+	n *node
+}
+
+// EntryView describes an object stored in a leaf.
+type EntryView struct {
+	Object  Rectangle
+	Hilbert *big.Int // hilbert value of the center of the object
+	Removed bool     // removed by a lazy deletion, waiting for Vacuum
+}
+
+// Root returns a view of the root node of the tree.
+func (tree *HRtree) Root() NodeView {
+	return NodeView{tree.root}
+}
+
+// IsLeaf reports whether the node stores objects rather than child nodes.
+func (v NodeView) IsLeaf() bool {
+	return v.n.leaf
+}
+
+// Len returns the number of entries of the node.
+func (v NodeView) Len() int {
+	return v.n.entries.len()
+}
+
+// Children returns views of the child nodes, ordered by LHV. It returns nil for leaves.
+func (v NodeView) Children() []NodeView {
+	if v.n.leaf {
+		return nil
+	}
+
+	children := make([]NodeView, 0, v.n.entries.len())
+	for _, e := range v.n.getEntries() {
+		children = append(children, NodeView{e.node})
+	}
+
+	return children
+}
+
+// Entries returns the objects stored in a leaf, ordered by hilbert value. It returns nil
+// for non-leaf nodes.
+func (v NodeView) Entries() []EntryView {
+	if !v.n.leaf {
+		return nil
+	}
+
+	entries := make([]EntryView, 0, v.n.entries.len())
+	for _, e := range v.n.getEntries() {
+		entries = append(entries, EntryView{e.obj, e.h.Int(), e.dead})
+	}
+
+	return entries
+}
+
+// MBR returns the bounding-box of all entries of the node, or nil for an empty node.
+func (v NodeView) MBR() Rectangle {
+	if v.n.bb == nil || v.n.entries.len() == 0 {
+		return nil
+	}
+
+	bb := *v.n.bb
+	return &bb
+}
+
+// LHV returns the largest hilbert value of the objects under the node.
+func (v NodeView) LHV() *big.Int {
+	return v.n.lhv.Int()
+}
@@ -0,0 +1,112 @@
+package hrtree
+
+import (
+	"testing"
+)
+
+func TestNodeView(t *testing.T) {
+	rt, _ := NewTree(2, 4, 12)
+	for i := 0; i < 200; i++ {
+		rt.Insert(rect(Point{uint64(i % 20), uint64(i / 20)}, Point{uint64(i%20 + 1), uint64(i/20 + 1)}))
+	}
+
+	objects := 0
+	var walk func(v NodeView)
+	walk = func(v NodeView) {
+		if !v.IsLeaf() {
+			if v.Entries() != nil {
+				t.Errorf("expected no entries for a non-leaf node")
+			}
+
+			children := v.Children()
+			if len(children) != v.Len() {
+				t.Errorf("expected %d children, got %d", v.Len(), len(children))
+			}
+
+			bb := v.MBR().(*rectangle)
+			for _, c := range children {
+				if !bb.contains(c.MBR()) {
+					t.Errorf("child %v not contained by %v", c.MBR(), bb)
+				}
+				walk(c)
+			}
+			return
+		}
+
+		if v.Children() != nil {
+			t.Errorf("expected no children for a leaf")
+		}
+
+		entries := v.Entries()
+		for i, e := range entries {
+			if i > 0 && entries[i-1].Hilbert.Cmp(e.Hilbert) > 0 {
+				t.Errorf("leaf entries not ordered by hilbert value")
+			}
+
+			if !v.MBR().(*rectangle).contains(e.Object) {
+				t.Errorf("object %v not contained by %v", e.Object, v.MBR())
+			}
+		}
+
+		if last := entries[len(entries)-1].Hilbert; v.LHV().Cmp(last) != 0 {
+			t.Errorf("expected LHV %v, got %v", last, v.LHV())
+		}
+		objects += len(entries)
+	}
+	walk(rt.Root())
+
+	if objects != rt.Size() {
+		t.Errorf("expected %d objects, got %d", rt.Size(), objects)
+	}
+
+	// views hand out copies.
+	rt.Root().MBR().(*rectangle).lowerLeft[0] = 100
+	rt.Root().LHV().SetInt64(-1)
+	if rt.root.bb.lowerLeft[0] == 100 || rt.root.lhv.Int().Sign() < 0 {
+		t.Errorf("expected the tree to be left untouched")
+	}
+}
+
+func TestNodeViewEmpty(t *testing.T) {
+	rt, _ := NewTree(2, 4, 12)
+
+	v := rt.Root()
+	if !v.IsLeaf() || v.Len() != 0 || v.MBR() != nil || len(v.Entries()) != 0 {
+		t.Errorf("expected an empty leaf")
+	}
+}
+
+func TestNodeViewLHV(t *testing.T) {
+	rt, _ := NewTree(2, 4, 12)
+	for i := 0; i < 300; i++ {
+		x, y := uint64(i*7%64), uint64(i*13%64)
+		rt.Insert(rect(Point{x, y}, Point{x + 1, y + 1}))
+	}
+
+	for i, obj := range rt.SearchIntersect(rect(Point{0, 0}, Point{64, 64})) {
+		if i%3 == 0 {
+			rt.Delete(obj)
+		}
+	}
+
+	// the LHV of a node is the largest LHV of its children, which are ordered by it.
+	var check func(v NodeView)
+	check = func(v NodeView) {
+		children := v.Children()
+		for i, c := range children {
+			check(c)
+			if i > 0 && children[i-1].LHV().Cmp(c.LHV()) > 0 {
+				t.Errorf("children not ordered by LHV")
+			}
+		}
+
+		if len(children) > 0 && v.LHV().Cmp(children[len(children)-1].LHV()) != 0 {
+			t.Errorf("expected LHV %v, got %v", children[len(children)-1].LHV(), v.LHV())
+		}
+	}
+	check(rt.Root())
+
+	if rt.Root().IsLeaf() {
+		t.Errorf("expected several levels")
+	}
+}
@@ -0,0 +1,255 @@
+package hrtree
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"os"
+)
+
+var ErrWALPageSize = errors.New("Write-ahead log and store have different page sizes.")
+
+const (
+	walMagic      = "HRTW"
+	walHeaderSize = 12 // magic, page count and page size of a batch
+)
+
+// WAL is a NodeStore logging the pages written to another store before passing them on,
+// so that a tree writing to it through WithNodeStore can be recovered after a crash. Each
+// mutation writes its pages, ending with the metadata page, as one batch which is synced
+// to the log before the store sees any of it: whatever the store held at the crash, the
+// batches replayed by OpenWAL bring it to the state of the last complete mutation.
+// Sync syncs the store, then empties the log.
+type WAL struct {
+	NodeStore
+	f      *os.File
+	size   int64    // bytes of the log
+	batch  []byte   // batch being written, after its header
+	ids    []uint64 // pages of the batch
+	report RecoveryReport
+}
+
+// RecoveryReport tells how OpenWAL replayed a log. Batches are written whole, each synced
+// before the next is started, so only the last one can be cut short by a crash; any batch
+// failing its checksum ends the replay all the same, as the ones after it would apply to
+// pages it never wrote.
+type RecoveryReport struct {
+	Applied     int   // batches written to the store
+	Skipped     int   // batches dropped from the end of the log, torn or corrupted
+	TruncatedAt int64 // offset of the first dropped batch, -1 if the whole log was replayed
+}
+
+// OpenWAL opens or creates the log at path for store s, replaying its complete batches.
+// Use Recover to load the tree of s, or create a new one with NewTree and
+// WithNodeStore(w).
+func OpenWAL(s NodeStore, path string) (*WAL, error) {
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("OpenWAL: %w", err)
+	}
+
+	w := &WAL{NodeStore: s, f: f}
+	if err := w.replay(); err != nil {
+		f.Close()
+		return nil, fmt.Errorf("OpenWAL: %w", err)
+	}
+
+	return w, nil
+}
+
+// replay writes the complete batches of the log to the store, then empties the log. A
+// batch cut short by a crash, or failing its checksum, ends the replay: it and whatever
+// follows are counted as skipped, since their mutations never fully reached the store.
+func (w *WAL) replay() error {
+	fi, err := w.f.Stat()
+	if err != nil {
+		return err
+	}
+
+	pageSize := w.PageSize()
+	header := make([]byte, walHeaderSize)
+	w.report = RecoveryReport{TruncatedAt: -1}
+
+	off := int64(0)
+	for ; off < fi.Size(); off += walHeaderSize + batchSize(header, pageSize) {
+		if _, err := w.f.ReadAt(header, off); err != nil || string(header[:4]) != walMagic {
+			break
+		}
+
+		// a header torn by a crash may hold any page size: only a batch checking out with
+		// its own size tells that the log was written for another store.
+		if size := int(binary.LittleEndian.Uint32(header[8:])); size != pageSize {
+			if w.readBatch(header, off, size, fi.Size()) != nil {
+				return fmt.Errorf("%d-byte pages in the log, %d in the store: %w", size, pageSize, ErrWALPageSize)
+			}
+			break
+		}
+
+		batch := w.readBatch(header, off, pageSize, fi.Size())
+		if batch == nil {
+			break
+		}
+
+		for p := batch; len(p) > 0; p = p[8+pageSize:] {
+			id := binary.LittleEndian.Uint64(p)
+			if err := w.NodeStore.WritePage(id, p[8:8+pageSize]); err != nil {
+				return &PageError{id, err}
+			}
+		}
+		w.report.Applied++
+	}
+
+	if off < fi.Size() {
+		w.report.TruncatedAt = off
+		w.report.Skipped = w.skipped(off, fi.Size())
+	}
+
+	if w.report.Applied > 0 {
+		if err := w.NodeStore.Sync(); err != nil {
+			return err
+		}
+	}
+
+	return w.truncate()
+}
+
+// batchSize returns the bytes following the given batch header, for pages of pageSize
+// bytes: the pages with their ids, and the checksum.
+func batchSize(header []byte, pageSize int) int64 {
+	return int64(binary.LittleEndian.Uint32(header[4:]))*int64(8+pageSize) + 4
+}
+
+// readBatch returns the pages with their ids of the batch of header at off, for pages of
+// pageSize bytes, or nil if the batch is cut short before end or fails its checksum.
+func (w *WAL) readBatch(header []byte, off int64, pageSize int, end int64) []byte {
+	// the pages of a torn header may not even fit in an int64.
+	if int64(binary.LittleEndian.Uint32(header[4:])) > end/int64(8+pageSize) {
+		return nil
+	}
+
+	size := batchSize(header, pageSize)
+	if off+walHeaderSize+size > end {
+		return nil
+	}
+
+	batch := make([]byte, size)
+	if _, err := w.f.ReadAt(batch, off+walHeaderSize); err != nil {
+		return nil
+	}
+
+	crc := crc32.Update(crc32.Checksum(header, castagnoli), castagnoli, batch[:size-4])
+	if binary.LittleEndian.Uint32(batch[size-4:]) != crc {
+		return nil
+	}
+
+	return batch[:size-4]
+}
+
+// skipped counts the batches from off to the end of the log, going by their headers as
+// long as they can be read. Bytes that don't start with one count as a single batch.
+func (w *WAL) skipped(off, size int64) int {
+	header := make([]byte, walHeaderSize)
+	n := 0
+	for ; off < size; off += walHeaderSize + batchSize(header, w.PageSize()) {
+		n++
+		if _, err := w.f.ReadAt(header, off); err != nil || string(header[:4]) != walMagic {
+			break
+		}
+	}
+
+	return n
+}
+
+// Recover loads the tree of the store, brought up to date by OpenWAL, which keeps
+// writing its changes through w. opts are those of OpenTree. The report tells which
+// batches of the log were replayed and which were dropped.
+func (w *WAL) Recover(opts ...Option) (*HRtree, RecoveryReport, error) {
+	tree, err := OpenTree(w, opts...)
+	if err != nil {
+		return nil, w.report, fmt.Errorf("Recover: %w", err)
+	}
+
+	return tree, w.report, nil
+}
+
+// ReadPage reads page id, from the batch being written if it is part of it.
+func (w *WAL) ReadPage(id uint64, page []byte) error {
+	pageSize := w.PageSize()
+	for i := len(w.ids) - 1; i >= 0; i-- {
+		if w.ids[i] == id {
+			copy(page, w.batch[i*(8+pageSize)+8:])
+			return nil
+		}
+	}
+
+	return w.NodeStore.ReadPage(id, page)
+}
+
+// WritePage adds page id to the batch, which the metadata page completes: the batch is
+// then synced to the log, and written to the store.
+func (w *WAL) WritePage(id uint64, page []byte) error {
+	var rec [8]byte
+	binary.LittleEndian.PutUint64(rec[:], id)
+	w.batch = append(append(w.batch, rec[:]...), page...)
+	w.ids = append(w.ids, id)
+
+	if id != 0 {
+		return nil
+	}
+
+	batch, ids := w.batch, w.ids
+	w.batch, w.ids = w.batch[:0], w.ids[:0]
+
+	header := make([]byte, walHeaderSize)
+	copy(header, walMagic)
+	binary.LittleEndian.PutUint32(header[4:], uint32(len(ids)))
+	binary.LittleEndian.PutUint32(header[8:], uint32(w.PageSize()))
+
+	var sum [4]byte
+	crc := crc32.Update(crc32.Checksum(header, castagnoli), castagnoli, batch)
+	binary.LittleEndian.PutUint32(sum[:], crc)
+
+	record := append(append(header, batch...), sum[:]...)
+	if _, err := w.f.WriteAt(record, w.size); err != nil {
+		return err
+	}
+
+	if err := w.f.Sync(); err != nil {
+		return err
+	}
+	w.size += int64(len(record))
+
+	pageSize := w.PageSize()
+	for i, id := range ids {
+		if err := w.NodeStore.WritePage(id, batch[i*(8+pageSize)+8:(i+1)*(8+pageSize)]); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Sync syncs the store, whose pages then no longer need the log, and empties it.
+func (w *WAL) Sync() error {
+	if err := w.NodeStore.Sync(); err != nil {
+		return err
+	}
+
+	return w.truncate()
+}
+
+// Close closes the log, leaving the store open. Changes not synced are replayed by the
+// next OpenWAL.
+func (w *WAL) Close() error {
+	return w.f.Close()
+}
+
+func (w *WAL) truncate() error {
+	if err := w.f.Truncate(0); err != nil {
+		return err
+	}
+	w.size = 0
+
+	return w.f.Sync()
+}
@@ -0,0 +1,169 @@
+package hrtree
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestWAL(t *testing.T) {
+	dir := t.TempDir()
+	pages, log := filepath.Join(dir, "tree.pages"), filepath.Join(dir, "tree.wal")
+
+	pf, _ := OpenPageFile(pages, MinPageSize, 4)
+	w, err := OpenWAL(pf, log)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	rt, _ := NewTree(2, 5, 12, WithNodeStore(w))
+	for i := 0; i < 500; i++ {
+		rt.Insert(rect(Point{uint64(3 * i), uint64(i % 50)}, Point{uint64(3*i + 1), uint64(i%50 + 2)}))
+	}
+
+	if err := rt.Sync(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if fi, _ := os.Stat(log); fi.Size() != 0 {
+		t.Errorf("expected Sync to empty the log, got %d bytes", fi.Size())
+	}
+
+	for i := 0; i < 300; i++ {
+		rt.Insert(rect(Point{uint64(3*i + 1500), 7}, Point{uint64(3*i + 1501), 9}))
+		if i%3 == 0 {
+			rt.Delete(rect(Point{uint64(3 * i), uint64(i % 50)}, Point{uint64(3*i + 1), uint64(i%50 + 2)}))
+		}
+	}
+
+	// crash: the file holds the pages evicted from the pool since the last Sync, along with
+	// a batch cut short.
+	pf.f.Close()
+	w.Close()
+	fi, _ := os.Stat(log)
+	f, _ := os.OpenFile(log, os.O_WRONLY|os.O_APPEND, 0)
+	f.Write([]byte(walMagic + "\x02\x00\x00\x00\x00\x02\x00\x00partial"))
+	f.Close()
+
+	pf, _ = OpenPageFile(pages, MinPageSize, 4)
+	defer pf.Close()
+	if w, err = OpenWAL(pf, log); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer w.Close()
+
+	recovered, report, err := w.Recover()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	checkStored(t, pf, rt)
+
+	if report.Applied < 300 || report.Skipped != 1 || report.TruncatedAt != fi.Size() {
+		t.Errorf("expected the batch cut short to be skipped at %d, got %+v", fi.Size(), report)
+	}
+
+	// the recovered tree keeps logging its changes.
+	recovered.Insert(rect(Point{4000, 4000}, Point{4001, 4001}))
+	rt.Insert(rect(Point{4000, 4000}, Point{4001, 4001}))
+	if fi, _ := os.Stat(log); fi.Size() == 0 {
+		t.Errorf("expected the insertion to be logged")
+	}
+	checkStored(t, w, rt)
+
+	other, _ := OpenPageFile(filepath.Join(dir, "other.pages"), PageSize4K, 4)
+	defer other.Close()
+	if _, err := OpenWAL(other, log); !errors.Is(err, ErrWALPageSize) {
+		t.Errorf("expected %v, got %v", ErrWALPageSize, err)
+	}
+}
+
+func TestWALCorrupted(t *testing.T) {
+	dir := t.TempDir()
+	pages, log := filepath.Join(dir, "tree.pages"), filepath.Join(dir, "tree.wal")
+
+	pf, _ := OpenPageFile(pages, MinPageSize, 4)
+	defer pf.Close()
+	w, _ := OpenWAL(pf, log)
+	rt, _ := NewTree(2, 5, 12, WithNodeStore(w))
+	rt.Insert(rect(Point{0, 0}, Point{1, 1}))
+	rt.Sync()
+
+	var sizes []int64
+	for i := 1; i <= 5; i++ {
+		rt.Insert(rect(Point{uint64(i), 0}, Point{uint64(i + 1), 1}))
+		fi, _ := os.Stat(log)
+		sizes = append(sizes, fi.Size())
+	}
+	w.Close()
+
+	// flip a byte of the third batch: it and the two after it are dropped.
+	f, _ := os.OpenFile(log, os.O_RDWR, 0)
+	b := make([]byte, 1)
+	f.ReadAt(b, sizes[1]+walHeaderSize+8)
+	f.WriteAt([]byte{b[0] ^ 0xff}, sizes[1]+walHeaderSize+8)
+	f.Close()
+
+	w, err := OpenWAL(pf, log)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer w.Close()
+
+	recovered, report, err := w.Recover()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if want := (RecoveryReport{Applied: 2, Skipped: 3, TruncatedAt: sizes[1]}); report != want {
+		t.Errorf("expected %+v, got %+v", want, report)
+	}
+
+	if recovered.Size() != 3 {
+		t.Errorf("expected the tree of the second batch, got %d objects", recovered.Size())
+	}
+
+	if fi, _ := os.Stat(log); fi.Size() != 0 {
+		t.Errorf("expected the dropped batches to be truncated, got %d bytes", fi.Size())
+	}
+}
+
+func TestWALTornPageSize(t *testing.T) {
+	dir := t.TempDir()
+	pages, log := filepath.Join(dir, "tree.pages"), filepath.Join(dir, "tree.wal")
+
+	pf, _ := OpenPageFile(pages, MinPageSize, 4)
+	defer pf.Close()
+	w, _ := OpenWAL(pf, log)
+	rt, _ := NewTree(2, 5, 12, WithNodeStore(w))
+	for i := 0; i < 3; i++ {
+		rt.Insert(rect(Point{uint64(i), 0}, Point{uint64(i + 1), 1}))
+	}
+	w.Close()
+
+	// a header torn after its magic, with garbage for the page count and size.
+	fi, _ := os.Stat(log)
+	f, _ := os.OpenFile(log, os.O_WRONLY|os.O_APPEND, 0)
+	f.Write([]byte(walMagic + "\xff\xff\xff\xff\xff\xff\xff\xff"))
+	f.Close()
+
+	w, err := OpenWAL(pf, log)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer w.Close()
+
+	recovered, report, err := w.Recover()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// the batches of the empty tree and of the three insertions are replayed.
+	if want := (RecoveryReport{Applied: 4, Skipped: 1, TruncatedAt: fi.Size()}); report != want {
+		t.Errorf("expected %+v, got %+v", want, report)
+	}
+
+	if recovered.Size() != 3 {
+		t.Errorf("expected 3 objects, got %d", recovered.Size())
+	}
+}
@@ -0,0 +1,68 @@
+package hrtree
+
+import (
+	"errors"
+	"math"
+)
+
+var ErrWeights = errors.New("Weights should be positive and given for each dimension.")
+
+// WithWeights scales the center of objects by a weight per dimension before computing
+// their hilbert value, so that coordinate spaces much longer along some dimensions, such
+// as time by space, don't degenerate into an ordering by a single dimension. A dimension
+// spanning 1000 times as many values as another one would be given weights 1 and 1000.
+// Scaled coordinates are capped by the resolution of the curve.
+//
+// Searches are not affected. Trees read with ReadPages keep the stored hilbert values and
+// should be given the same weights.
+func WithWeights(weights ...float64) Option {
+	return func(tree *HRtree) {
+		tree.weights = weights
+	}
+}
+
+// checkWeights validates the weights of the tree.
+func (tree *HRtree) checkWeights() error {
+	if tree.weights == nil {
+		return nil
+	}
+
+	if len(tree.weights) != Dim {
+		return ErrWeights
+	}
+
+	for _, w := range tree.weights {
+		if !(w > 0) || math.IsInf(w, 1) {
+			return ErrWeights
+		}
+	}
+
+	return nil
+}
+
+// weigh maps the point p in place by the transform and scales it by the weights of the
+// tree, and returns it.
+func (tree *HRtree) weigh(p []uint64) []uint64 {
+	if tree.transform == nil && tree.weights == nil {
+		return p
+	}
+
+	limit := tree.limit()
+	if tree.transform != nil {
+		tree.transform.apply(p, limit)
+	}
+
+	for i, w := range tree.weights {
+		if w == 1 {
+			continue
+		}
+
+		if c := float64(p[i]) * w; c >= float64(limit) {
+			p[i] = limit
+		} else {
+			p[i] = uint64(c)
+		}
+	}
+
+	return p
+}
@@ -0,0 +1,68 @@
+package hrtree
+
+import (
+	"errors"
+	"testing"
+)
+
+// weights returns w followed by weights of 1 for the dimensions left out.
+func weights(w ...float64) []float64 {
+	for len(w) < Dim {
+		w = append(w, 1)
+	}
+	return w
+}
+
+// coords returns c followed by coordinates of 0 for the dimensions left out.
+func coords(c ...uint64) []uint64 {
+	return append(c, make([]uint64, Dim-len(c))...)
+}
+
+func TestWeights(t *testing.T) {
+	for _, w := range [][]float64{{1}, weights(1, 0), weights(-1, 2), []float64{1, 2, 3, 4}[:Dim+1]} {
+		if _, err := NewTree(2, 4, 12, WithWeights(w...)); !errors.Is(err, ErrWeights) {
+			t.Errorf("expected ErrWeights for %v, got %v", w, err)
+		}
+	}
+
+	rt, err := NewTree(2, 4, 12, WithWeights(weights(1, 256)...))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	obj := rect(Point{10, 3}, Point{12, 5})
+	if h := rt.key(obj); h.cmp(keyOf(rt.hf.Encode(coords(11, 4*256)...))) != 0 {
+		t.Errorf("expected the hilbert value of the scaled center, got %v", h)
+	}
+
+	// scaled coordinates are capped by the resolution.
+	obj = rect(Point{10, 100}, Point{12, 100})
+	if h := rt.key(obj); h.cmp(keyOf(rt.hf.Encode(coords(11, 1<<12-1)...))) != 0 {
+		t.Errorf("expected the hilbert value of the capped center, got %v", h)
+	}
+}
+
+func TestWeightsLocality(t *testing.T) {
+	// a space 4096 wide and 16 high, where leaves of an unweighted curve span its height.
+	spread := func(opts ...Option) (height uint64) {
+		rt, _ := NewTree(8, 16, 12, opts...)
+		objs := make(map[Rectangle]bool)
+		for x := uint64(0); x < 4096; x += 16 {
+			for y := uint64(0); y < 16; y += 2 {
+				obj := rect(Point{x, y}, Point{x + 1, y + 1})
+				rt.Insert(obj)
+				objs[obj] = true
+			}
+		}
+		checkTree(t, rt, objs)
+
+		for _, leaf := range rt.root.leaves(nil) {
+			height += leaf.bb.upperRight[1] - leaf.bb.lowerLeft[1]
+		}
+		return
+	}
+
+	if plain, weighted := spread(), spread(WithWeights(weights(1, 256)...)); weighted >= plain {
+		t.Errorf("expected weights to shrink the height of leaves, got %d from %d", weighted, plain)
+	}
+}
@@ -0,0 +1,81 @@
+// Package wellknown stores geometries given as well-known text (WKT) or binary (WKB), such
+// as database exports, in trees. Geometries are indexed through the bounding-box of their
+// positions, mapped to the cells of a grid, and keep their raw encoding as payload:
+//
+//	grid, _ := hrtree.NewGrid(hrtree.PointF{-180, -90}, hrtree.PointF{180, 90}, 16)
+//	g, err := wellknown.FromWKT(grid, "LINESTRING (30 10, 10 30, 40 40)")
+//	...
+//	tree.Insert(g)
+//
+// Extended variants carrying an SRID, as written by PostGIS, are accepted and the SRID is
+// ignored, as are altitudes and measures. In 3-dimensional builds, geometries lie at 0 in
+// the third dimension, which the grid should span, as geojson.World does.
+package wellknown
+
+import (
+	"errors"
+	"math"
+
+	"github.com/jtejido/hrtree"
+)
+
+var (
+	ErrWKT   = errors.New("Invalid well-known text geometry.")
+	ErrWKB   = errors.New("Invalid well-known binary geometry.")
+	ErrEmpty = errors.New("Geometry should have at least one position.")
+)
+
+// Geometry is a WKT or WKB geometry, stored in trees through its bounding-box.
+type Geometry struct {
+	*hrtree.RectF
+	Raw []byte // the text or binary it was read from
+}
+
+// FromWKT returns the geometry of text on grid g.
+func FromWKT(g *hrtree.Grid, text string) (*Geometry, error) {
+	min, max, err := BoundsWKT(text)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Geometry{g.Rect(min, max), []byte(text)}, nil
+}
+
+// FromWKB returns the geometry of data on grid g. data is kept, and should not be modified
+// afterwards.
+func FromWKB(g *hrtree.Grid, data []byte) (*Geometry, error) {
+	min, max, err := BoundsWKB(data)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Geometry{g.Rect(min, max), data}, nil
+}
+
+// bounds is the bounding-box of the positions of a geometry.
+type bounds struct {
+	min, max hrtree.PointF
+	empty    bool
+}
+
+func newBounds() *bounds {
+	return &bounds{
+		min:   hrtree.PointF{math.Inf(1), math.Inf(1)},
+		max:   hrtree.PointF{math.Inf(-1), math.Inf(-1)},
+		empty: true,
+	}
+}
+
+func (b *bounds) add(x, y float64) {
+	b.min[0], b.max[0] = math.Min(b.min[0], x), math.Max(b.max[0], x)
+	b.min[1], b.max[1] = math.Min(b.min[1], y), math.Max(b.max[1], y)
+	b.empty = false
+}
+
+func (b *bounds) result() (hrtree.PointF, hrtree.PointF, error) {
+	if b.empty {
+		return hrtree.PointF{}, hrtree.PointF{}, ErrEmpty
+	}
+
+	return b.min, b.max, nil
+}
@@ -0,0 +1,159 @@
+package wellknown
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"math"
+	"testing"
+
+	"github.com/jtejido/hrtree"
+)
+
+func TestBoundsWKT(t *testing.T) {
+	for _, c := range []struct {
+		text     string
+		min, max hrtree.PointF
+	}{
+		{"POINT (30 10)", hrtree.PointF{30, 10}, hrtree.PointF{30, 10}},
+		{"LINESTRING (30 10, 10 30, 40 40)", hrtree.PointF{10, 10}, hrtree.PointF{40, 40}},
+		{"polygon((35 10,45 45,15 40,10 20,35 10),(20 30,35 35,30 20,20 30))", hrtree.PointF{10, 10}, hrtree.PointF{45, 45}},
+		{"MULTIPOINT ((10 40), (40 30), (20 20), (30 10))", hrtree.PointF{10, 10}, hrtree.PointF{40, 40}},
+		{"MULTIPOINT (10 40, 40 30)", hrtree.PointF{10, 30}, hrtree.PointF{40, 40}},
+		{"POINT Z (1.5 -2e1 300)", hrtree.PointF{1.5, -20}, hrtree.PointF{1.5, -20}},
+		{"SRID=4326;MULTIPOLYGON (((-1 -1, 1 -1, 1 1, -1 -1)), EMPTY)", hrtree.PointF{-1, -1}, hrtree.PointF{1, 1}},
+		{"GEOMETRYCOLLECTION (POINT EMPTY, POINT (4 6), LINESTRING ZM (4 6 1 2, 7 10 3 4))", hrtree.PointF{4, 6}, hrtree.PointF{7, 10}},
+	} {
+		min, max, err := BoundsWKT(c.text)
+		if err != nil || min != c.min || max != c.max {
+			t.Errorf("expected %s to span %v to %v, got %v to %v and %v", c.text, c.min, c.max, min, max, err)
+		}
+	}
+
+	for _, text := range []string{
+		"",
+		"(1 2)",
+		"CIRCLE (1 2)",
+		"POINT (1)",
+		"POINT (1 2 3 4 5)",
+		"POINT (1 2",
+		"POINT (1 2))",
+		"POINT (1 x)",
+		"POINT 1 2",
+		"POINT (1 2) POINT (3 4)",
+		"POINT (1 2) (3 4)",
+		"POINT",
+		"SRID=4326 POINT (1 2)",
+	} {
+		if _, _, err := BoundsWKT(text); !errors.Is(err, ErrWKT) {
+			t.Errorf("expected %q to be rejected, got %v", text, err)
+		}
+	}
+
+	if _, _, err := BoundsWKT("LINESTRING EMPTY"); !errors.Is(err, ErrEmpty) {
+		t.Errorf("expected %v, got %v", ErrEmpty, err)
+	}
+}
+
+// wkb encodes a geometry of the given type with its parts in byte order o.
+func wkb(o binary.ByteOrder, typ uint32, parts ...any) []byte {
+	var buf bytes.Buffer
+	if o == binary.LittleEndian {
+		buf.WriteByte(1)
+	} else {
+		buf.WriteByte(0)
+	}
+
+	binary.Write(&buf, o, typ)
+	for _, p := range parts {
+		if b, ok := p.([]byte); ok {
+			buf.Write(b)
+		} else {
+			binary.Write(&buf, o, p)
+		}
+	}
+
+	return buf.Bytes()
+}
+
+func TestBoundsWKB(t *testing.T) {
+	le, be := binary.LittleEndian, binary.BigEndian
+	for _, c := range []struct {
+		name     string
+		data     []byte
+		min, max hrtree.PointF
+	}{
+		{"point", wkb(le, 1, 30.0, 10.0), hrtree.PointF{30, 10}, hrtree.PointF{30, 10}},
+		{"linestring", wkb(be, 2, uint32(3), []float64{30, 10, 10, 30, 40, 40}), hrtree.PointF{10, 10}, hrtree.PointF{40, 40}},
+		{"polygon z", wkb(le, 1003, uint32(1), uint32(4), []float64{0, 0, 9, 5, 0, 9, 5, 5, 9, 0, 0, 9}), hrtree.PointF{0, 0}, hrtree.PointF{5, 5}},
+		{"ewkb", wkb(le, 1|ewkbSRID|ewkbZ, uint32(4326), []float64{-3, 4, 5}), hrtree.PointF{-3, 4}, hrtree.PointF{-3, 4}},
+		{"collection", wkb(be, 7, uint32(3),
+			wkb(le, 1, math.NaN(), math.NaN()),
+			wkb(be, 4, uint32(2), wkb(le, 1, 1.0, 2.0), wkb(be, 1, 7.0, -1.0)),
+			wkb(le, 3002, uint32(1), []float64{2, 8, 0, 0}),
+		), hrtree.PointF{1, -1}, hrtree.PointF{7, 8}},
+	} {
+		min, max, err := BoundsWKB(c.data)
+		if err != nil || min != c.min || max != c.max {
+			t.Errorf("expected the %s to span %v to %v, got %v to %v and %v", c.name, c.min, c.max, min, max, err)
+		}
+	}
+
+	point := wkb(le, 1, 30.0, 10.0)
+	for _, data := range [][]byte{
+		nil,
+		{2, 1, 0, 0, 0},
+		point[:len(point)-1],
+		append(point, 0),
+		wkb(le, 99, 1.0, 2.0),
+		wkb(le, 4001, 1.0, 2.0),
+		wkb(le, 2, uint32(1<<30)),
+	} {
+		if _, _, err := BoundsWKB(data); !errors.Is(err, ErrWKB) {
+			t.Errorf("expected %v to be rejected, got %v", data, err)
+		}
+	}
+
+	if _, _, err := BoundsWKB(wkb(le, 6, uint32(0))); !errors.Is(err, ErrEmpty) {
+		t.Errorf("expected %v, got %v", ErrEmpty, err)
+	}
+}
+
+func TestGeometry(t *testing.T) {
+	max := hrtree.PointF{180, 90}
+	for i := 2; i < hrtree.Dim; i++ {
+		max[i] = 1
+	}
+
+	grid, _ := hrtree.NewGrid(hrtree.PointF{-180, -90}, max, 16)
+	tree, _ := hrtree.NewTree(2, 4, 16, hrtree.WithRefine(hrtree.RefineF))
+
+	text := "LINESTRING (2 48, 3 49)"
+	a, err := FromWKT(grid, text)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	data := wkb(binary.LittleEndian, 1, -74.0, 40.7)
+	b, err := FromWKB(grid, data)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	tree.Insert(a)
+	tree.Insert(b)
+
+	found := tree.SearchIntersect(grid.Rect(hrtree.PointF{0, 45}, hrtree.PointF{5, 50}))
+	if len(found) != 1 || string(found[0].(*Geometry).Raw) != text {
+		t.Errorf("expected %s, got %v", text, found)
+	}
+
+	found = tree.SearchIntersect(grid.Rect(hrtree.PointF{-75, 40}, hrtree.PointF{-74, 41}))
+	if len(found) != 1 || !bytes.Equal(found[0].(*Geometry).Raw, data) {
+		t.Errorf("expected the WKB point, got %v", found)
+	}
+
+	if _, err := FromWKT(grid, "POINT"); !errors.Is(err, ErrWKT) {
+		t.Errorf("expected %v, got %v", ErrWKT, err)
+	}
+}
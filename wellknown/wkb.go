@@ -0,0 +1,193 @@
+package wellknown
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+
+	"github.com/jtejido/hrtree"
+)
+
+// flags of the geometry types of extended WKB.
+const (
+	ewkbZ    = 0x80000000
+	ewkbM    = 0x40000000
+	ewkbSRID = 0x20000000
+)
+
+// BoundsWKB returns the corners of the bounding-box of the positions of data.
+func BoundsWKB(data []byte) (min, max hrtree.PointF, err error) {
+	r := wkbReader{data: data, b: newBounds()}
+	if err := r.geometry(0); err != nil {
+		return min, max, fmt.Errorf("BoundsWKB: offset %d: %w", r.off, err)
+	}
+
+	if r.off != len(data) {
+		return min, max, fmt.Errorf("BoundsWKB: %d trailing bytes: %w", len(data)-r.off, ErrWKB)
+	}
+
+	if min, max, err = r.b.result(); err != nil {
+		return min, max, fmt.Errorf("BoundsWKB: %w", err)
+	}
+
+	return min, max, nil
+}
+
+// maxNesting bounds the depth of collections, so that malicious inputs can't exhaust the
+// stack.
+const maxNesting = 64
+
+type wkbReader struct {
+	data  []byte
+	off   int
+	order binary.ByteOrder
+	b     *bounds
+}
+
+func (r *wkbReader) next(n int) ([]byte, error) {
+	if n > len(r.data)-r.off {
+		return nil, ErrWKB
+	}
+
+	p := r.data[r.off : r.off+n]
+	r.off += n
+	return p, nil
+}
+
+func (r *wkbReader) uint32() (uint32, error) {
+	p, err := r.next(4)
+	if err != nil {
+		return 0, err
+	}
+
+	return r.order.Uint32(p), nil
+}
+
+// count reads a number of items of at least size bytes each, checking that they fit in
+// the rest of the data.
+func (r *wkbReader) count(size int) (int, error) {
+	n, err := r.uint32()
+	if err != nil {
+		return 0, err
+	}
+
+	if uint64(n)*uint64(size) > uint64(len(r.data)-r.off) {
+		return 0, ErrWKB
+	}
+
+	return int(n), nil
+}
+
+// points reads n points of dims coordinates each.
+func (r *wkbReader) points(n, dims int) error {
+	for i := 0; i < n; i++ {
+		p, err := r.next(8 * dims)
+		if err != nil {
+			return err
+		}
+
+		x := math.Float64frombits(r.order.Uint64(p))
+		y := math.Float64frombits(r.order.Uint64(p[8:]))
+		// empty points are encoded with NaN coordinates.
+		if !math.IsNaN(x) && !math.IsNaN(y) {
+			r.b.add(x, y)
+		}
+	}
+
+	return nil
+}
+
+func (r *wkbReader) geometry(depth int) error {
+	if depth > maxNesting {
+		return ErrWKB
+	}
+
+	p, err := r.next(1)
+	if err != nil {
+		return err
+	}
+
+	switch p[0] {
+	case 0:
+		r.order = binary.BigEndian
+	case 1:
+		r.order = binary.LittleEndian
+	default:
+		return ErrWKB
+	}
+
+	typ, err := r.uint32()
+	if err != nil {
+		return err
+	}
+
+	dims := 2
+	if typ&ewkbZ != 0 {
+		dims++
+	}
+	if typ&ewkbM != 0 {
+		dims++
+	}
+	if typ&ewkbSRID != 0 {
+		if _, err := r.next(4); err != nil {
+			return err
+		}
+	}
+
+	// ISO types of 3 and 4 dimensions are offset by 1000, 2000 and 3000.
+	typ &^= ewkbZ | ewkbM | ewkbSRID
+	switch typ / 1000 {
+	case 1, 2:
+		dims++
+	case 3:
+		dims += 2
+	case 0:
+	default:
+		return ErrWKB
+	}
+
+	switch typ % 1000 {
+	case 1: // point
+		return r.points(1, dims)
+
+	case 2: // linestring
+		n, err := r.count(8 * dims)
+		if err != nil {
+			return err
+		}
+		return r.points(n, dims)
+
+	case 3, 17: // polygon, triangle
+		rings, err := r.count(4)
+		if err != nil {
+			return err
+		}
+
+		for i := 0; i < rings; i++ {
+			n, err := r.count(8 * dims)
+			if err != nil {
+				return err
+			}
+
+			if err := r.points(n, dims); err != nil {
+				return err
+			}
+		}
+		return nil
+
+	case 4, 5, 6, 7, 15, 16: // multi geometries, collection, polyhedral surface, tin
+		n, err := r.count(5)
+		if err != nil {
+			return err
+		}
+
+		for i := 0; i < n; i++ {
+			if err := r.geometry(depth + 1); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	return ErrWKB
+}
@@ -0,0 +1,114 @@
+package wellknown
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/jtejido/hrtree"
+)
+
+// keywords of WKT, geometry types followed by their modifiers.
+var keywords = map[string]bool{
+	"POINT": true, "LINESTRING": true, "POLYGON": true, "MULTIPOINT": true,
+	"MULTILINESTRING": true, "MULTIPOLYGON": true, "GEOMETRYCOLLECTION": true,
+	"TRIANGLE": true, "TIN": true, "POLYHEDRALSURFACE": true,
+	"Z": false, "M": false, "ZM": false, "EMPTY": false,
+}
+
+// BoundsWKT returns the corners of the bounding-box of the positions of text.
+func BoundsWKT(text string) (min, max hrtree.PointF, err error) {
+	if s := strings.TrimSpace(text); len(s) > 5 && strings.EqualFold(s[:5], "SRID=") {
+		i := strings.IndexByte(s, ';')
+		if i < 0 {
+			return min, max, fmt.Errorf("BoundsWKT: %w", ErrWKT)
+		}
+		text = s[i+1:]
+	}
+
+	b := newBounds()
+	var pos []float64
+	depth, typed, body := 0, false, false
+
+	// flush adds the position read since the last separator.
+	flush := func() error {
+		if len(pos) == 0 {
+			return nil
+		}
+
+		if len(pos) < 2 || len(pos) > 4 {
+			return ErrWKT
+		}
+
+		b.add(pos[0], pos[1])
+		pos = pos[:0]
+		return nil
+	}
+
+	for i := 0; i < len(text); {
+		c := text[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+			continue
+
+		case c == '(' || c == ')' || c == ',':
+			if err := flush(); err != nil {
+				return min, max, fmt.Errorf("BoundsWKT: offset %d: %w", i, err)
+			}
+
+			switch c {
+			case '(':
+				if depth == 0 {
+					if body || !typed {
+						return min, max, fmt.Errorf("BoundsWKT: offset %d: %w", i, ErrWKT)
+					}
+					body = true
+				}
+				depth++
+			case ')':
+				depth--
+			}
+
+			if depth < 0 || (c == ',' && depth == 0) {
+				return min, max, fmt.Errorf("BoundsWKT: offset %d: %w", i, ErrWKT)
+			}
+			i++
+			continue
+		}
+
+		j := i
+		for j < len(text) && !strings.ContainsRune(" \t\n\r(),", rune(text[j])) {
+			j++
+		}
+		word := text[i:j]
+
+		if c >= 'A' && c <= 'Z' || c >= 'a' && c <= 'z' {
+			geometry, ok := keywords[strings.ToUpper(word)]
+			if !ok || len(pos) > 0 || (geometry && typed && depth == 0) || (!geometry && !typed) {
+				return min, max, fmt.Errorf("BoundsWKT: %q: %w", word, ErrWKT)
+			}
+			typed = true
+			if strings.EqualFold(word, "EMPTY") && depth == 0 {
+				body = true
+			}
+		} else {
+			v, err := strconv.ParseFloat(word, 64)
+			if err != nil || depth == 0 {
+				return min, max, fmt.Errorf("BoundsWKT: %q: %w", word, ErrWKT)
+			}
+			pos = append(pos, v)
+		}
+		i = j
+	}
+
+	if depth != 0 || !body {
+		return min, max, fmt.Errorf("BoundsWKT: %w", ErrWKT)
+	}
+
+	if min, max, err = b.result(); err != nil {
+		return min, max, fmt.Errorf("BoundsWKT: %w", err)
+	}
+
+	return min, max, nil
+}
@@ -0,0 +1,106 @@
+package hrtree
+
+import (
+	"errors"
+	"math"
+)
+
+var ErrWrapDimension = errors.New("Wrapped dimensions should be distinct and between 0 and Dim-1.")
+
+// WithWrap makes the given dimensions periodic, such as longitude, wrapping around the
+// end of the coordinate space of the curve, 2^bits-1, back to 0. A search window whose
+// lower bound is greater than its upper bound along such a dimension goes from its lower
+// bound to the end of the space, then from 0 to its upper bound: it is split into the
+// wrapped windows, and objects found in several of them are only returned once.
+//
+// Objects themselves don't wrap, those crossing the end of the space should be stored
+// as separate parts.
+func WithWrap(dims ...int) Option {
+	return func(tree *HRtree) {
+		tree.wrap = dims
+	}
+}
+
+// NewWrapWindow returns a search window with the given corners, which unlike NewRect
+// may have lowerLeft above upperRight along the dimensions of WithWrap.
+func NewWrapWindow(lowerLeft, upperRight Point) Rectangle {
+	return &rectangle{lowerLeft, upperRight}
+}
+
+// checkWrap validates the wrapped dimensions of the tree.
+func (tree *HRtree) checkWrap() error {
+	for i, d := range tree.wrap {
+		if d < 0 || d >= Dim {
+			return ErrWrapDimension
+		}
+
+		for _, prev := range tree.wrap[:i] {
+			if d == prev {
+				return ErrWrapDimension
+			}
+		}
+	}
+
+	return nil
+}
+
+// windows splits bb into the windows it covers once wrapped, or returns nil if it
+// doesn't wrap.
+func (tree *HRtree) windows(bb Rectangle) []*rectangle {
+	if tree.wrap == nil {
+		return nil
+	}
+
+	ll, ur := bb.LowerLeft(), bb.UpperRight()
+	wraps := false
+	for _, d := range tree.wrap {
+		wraps = wraps || ll[d] > ur[d]
+	}
+
+	if !wraps {
+		return nil
+	}
+
+	limit := uint64(math.MaxUint64)
+	if tree.bits < 64 {
+		limit = 1<<uint(tree.bits) - 1
+	}
+
+	windows := []*rectangle{{ll, ur}}
+	for _, d := range tree.wrap {
+		if ll[d] <= ur[d] {
+			continue
+		}
+
+		for _, w := range windows {
+			upper := &rectangle{w.lowerLeft, w.upperRight}
+			upper.upperRight[d] = limit
+			w.lowerLeft[d] = 0
+			windows = append(windows, upper)
+		}
+	}
+
+	return windows
+}
+
+// intersectAny reports whether r intersects any of windows.
+func intersectAny(r *rectangle, windows []*rectangle) bool {
+	for _, w := range windows {
+		if intersect(r, w) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// foundIn reports whether a search of any of windows finds obj.
+func (tree *HRtree) foundIn(windows []*rectangle, obj Rectangle) bool {
+	for _, w := range windows {
+		if intersect(w, obj) && (tree.refine == nil || tree.refine(obj, w)) {
+			return true
+		}
+	}
+
+	return false
+}
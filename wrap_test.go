@@ -0,0 +1,59 @@
+package hrtree
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestWrap(t *testing.T) {
+	for _, dims := range [][]int{{-1}, {Dim}, {0, 0}} {
+		if _, err := NewTree(2, 4, 8, WithWrap(dims...)); !errors.Is(err, ErrWrapDimension) {
+			t.Errorf("expected ErrWrapDimension for %v, got %v", dims, err)
+		}
+	}
+
+	rt, _ := NewTree(2, 4, 8, WithWrap(0, 1))
+	west := rect(Point{2, 100}, Point{4, 102})
+	east := rect(Point{250, 100}, Point{253, 102})
+	middle := rect(Point{120, 100}, Point{130, 102})
+	corner := rect(Point{254, 254}, Point{255, 255})
+	wide := rect(Point{0, 100}, Point{255, 101}) // found by both windows along x
+	for _, obj := range []Rectangle{west, east, middle, corner, wide} {
+		rt.Insert(obj)
+	}
+
+	tests := []struct {
+		bb       Rectangle
+		expected []Rectangle
+	}{
+		{NewWrapWindow(Point{240, 90}, Point{10, 110}), []Rectangle{west, east, wide}},
+		{rect(Point{100, 90}, Point{140, 110}), []Rectangle{middle, wide}},
+		{rect(Point{240, 250}, Point{10, 5}), []Rectangle{corner}},
+		{rect(Point{200, 200}, Point{100, 100}), []Rectangle{west, east, corner, wide}},
+	}
+
+	for _, test := range tests {
+		for name, found := range map[string][]Rectangle{
+			"SearchIntersect":       rt.SearchIntersect(test.bb),
+			"SearchIntersectPooled": rt.SearchIntersectPooled(test.bb).Objects,
+		} {
+			if len(found) != len(test.expected) {
+				t.Errorf("%s %v: expected %v, got %v", name, test.bb, test.expected, found)
+				continue
+			}
+
+			for _, obj := range test.expected {
+				n := 0
+				for _, f := range found {
+					if f == obj {
+						n++
+					}
+				}
+
+				if n != 1 {
+					t.Errorf("%s %v: expected %v once, got it %d times", name, test.bb, obj, n)
+				}
+			}
+		}
+	}
+}